@@ -0,0 +1,172 @@
+// Package api defines the request and response bodies of the rules server,
+// as described by the OpenAPI document in openapi.json.
+package api
+
+import (
+	_ "embed"
+
+	"github.com/Rohan-Muslekar/GavelEngine/audit"
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+)
+
+// OpenAPI is the OpenAPI 3 document of the rules server.
+//
+//go:embed openapi.json
+var OpenAPI []byte
+
+// Fact types accepted by AddFactRequest.
+const (
+	FactTypeConstant = "constant"
+	FactTypeFunction = "function"
+)
+
+// Error is the body of every non-2xx response. ValidationErrors is set when
+// a rule or condition fails validation.
+type Error struct {
+	Error            string                        `json:"error"`
+	ValidationErrors []rulesengine.ValidationError `json:"validationErrors,omitempty"`
+}
+
+// Status acknowledges a create or delete. Name or ID identifies the created
+// resource.
+type Status struct {
+	Name   string `json:"name,omitempty"`
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"`
+}
+
+type EngineList struct {
+	Engines []string `json:"engines"`
+}
+
+// CreateEngineRequest creates an engine. Rules may only use registered facts
+// and named conditions unless the Allow flags are set. Only publishers and
+// admins may change a production engine.
+type CreateEngineRequest struct {
+	Name                     string `json:"name" binding:"required"`
+	AllowUndefinedFacts      bool   `json:"allowUndefinedFacts,omitempty"`
+	AllowUndefinedConditions bool   `json:"allowUndefinedConditions,omitempty"`
+	Production               bool   `json:"production,omitempty"`
+}
+
+type EngineInfo struct {
+	Name       string `json:"name"`
+	Production bool   `json:"production"`
+}
+
+type FactList struct {
+	Facts []rulesengine.FactInfo `json:"facts"`
+}
+
+// AddFactRequest registers a fact. A constant fact always has Value; a
+// function fact reads the runtime fact of the same ID.
+type AddFactRequest struct {
+	ID          string      `json:"id" binding:"required"`
+	Type        string      `json:"type" binding:"required"`
+	Value       interface{} `json:"value,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Cache       bool        `json:"cache,omitempty"`
+}
+
+type RuleList struct {
+	Rules []*rulesengine.Rule `json:"rules"`
+}
+
+type RuleResponse struct {
+	Rule *rulesengine.Rule `json:"rule"`
+}
+
+// AddRuleRequest is a complete rule, with its group, activation, salience
+// and actions. Its name is required.
+type AddRuleRequest = rulesengine.Rule
+
+// RuleState reports whether a rule is disabled.
+type RuleState struct {
+	Name     string `json:"name"`
+	Disabled bool   `json:"disabled"`
+}
+
+type ConditionList struct {
+	Conditions map[string]rulesengine.Condition `json:"conditions"`
+}
+
+type NamedCondition struct {
+	Name      string                `json:"name"`
+	Condition rulesengine.Condition `json:"condition"`
+}
+
+// RunResponse is the result of a run. RuleResults carry condition traces
+// only when returned by the trace endpoint; Decision is set for engines with
+// a conflict resolver, Order when a rule uses dynamic salience, Actions when
+// a rule has actions and Assignment for runs of an experiment.
+type RunResponse struct {
+	Events      []rulesengine.Event        `json:"events"`
+	RuleResults []*rulesengine.RuleResult  `json:"ruleResults"`
+	Decision    *rulesengine.Decision      `json:"decision,omitempty"`
+	Order       []rulesengine.RuleOrder    `json:"order,omitempty"`
+	Actions     []rulesengine.ActionResult `json:"actions,omitempty"`
+	Assignment  *rulesengine.Assignment    `json:"assignment,omitempty"`
+}
+
+// SetChallengerRequest pairs the engine in the path, the champion, with a
+// challenger that silently evaluates the same runtime facts.
+type SetChallengerRequest struct {
+	Challenger string `json:"challenger" binding:"required"`
+}
+
+// ChallengerReport summarises a challenger's evaluations and lists its
+// most recent divergences from the champion, oldest first.
+type ChallengerReport struct {
+	Stats       rulesengine.ShadowStats  `json:"stats"`
+	Divergences []rulesengine.Divergence `json:"divergences"`
+}
+
+// Experiment splits the runs of /experiments/{experiment}/run between
+// engines by the hash of the runtime fact at Key. With Sticky, units keep
+// their first variant when the weights change. The name is taken from the
+// path.
+type Experiment struct {
+	Name     string                `json:"name"`
+	Key      string                `json:"key" binding:"required"`
+	Variants []rulesengine.Variant `json:"variants" binding:"required"`
+	Sticky   bool                  `json:"sticky,omitempty"`
+}
+
+type ExperimentList struct {
+	Experiments []Experiment `json:"experiments"`
+}
+
+type PredefinedFact struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	Usage       string `json:"usage"`
+}
+
+type PredefinedFactList struct {
+	Facts []PredefinedFact `json:"facts"`
+}
+
+// ContentTypeNDJSON is the content type of the batch endpoint's request
+// and response streams: one JSON value per line.
+const ContentTypeNDJSON = "application/x-ndjson"
+
+// BatchResult is a line of the batch endpoint's response, reporting the
+// outcome of the input at Index. Error is set if the input failed.
+type BatchResult struct {
+	Index       int                       `json:"index"`
+	Events      []rulesengine.Event       `json:"events,omitempty"`
+	RuleResults []*rulesengine.RuleResult `json:"ruleResults,omitempty"`
+	Error       string                    `json:"error,omitempty"`
+}
+
+// BatchSummary is the last line of the batch endpoint's response. Error is
+// set if the batch stopped early, for example on a malformed input line.
+type BatchSummary struct {
+	Stats *rulesengine.BatchStats `json:"stats"`
+	Error string                  `json:"error,omitempty"`
+}
+
+// AuditLog lists audit entries in the order they were recorded.
+type AuditLog struct {
+	Entries []audit.Entry `json:"entries"`
+}
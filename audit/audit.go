@@ -0,0 +1,303 @@
+// Package audit keeps a tamper-evident trail of changes to engines, their
+// rules, facts and named conditions.
+//
+// The log is a file of JSON lines that is only ever appended to. Every
+// entry carries the SHA-256 hash of the entry before it and its own hash,
+// so editing, removing or reordering entries breaks the chain, which Verify
+// detects.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+)
+
+// Actions recorded in the log.
+const (
+	ActionCreateEngine     = "CreateEngine"
+	ActionDeleteEngine     = "DeleteEngine"
+	ActionAddFact          = "AddFact"
+	ActionRemoveFact       = "RemoveFact"
+	ActionAddRule          = "AddRule"
+	ActionUpdateRule       = "UpdateRule"
+	ActionEnableRule       = "EnableRule"
+	ActionDisableRule      = "DisableRule"
+	ActionRemoveRule       = "RemoveRule"
+	ActionSetCondition     = "SetCondition"
+	ActionRemoveCondition  = "RemoveCondition"
+	ActionSetChallenger    = "SetChallenger"
+	ActionRemoveChallenger = "RemoveChallenger"
+	ActionSetExperiment    = "SetExperiment"
+	ActionRemoveExperiment = "RemoveExperiment"
+)
+
+// Entry is one change in the log. Target is the rule name, fact ID,
+// condition name, challenger engine or experiment the action applies to,
+// if any; experiment entries have no engine. Before and After are the JSON
+// form of the target, or of the engine, around the change; Before is empty
+// for additions and After for removals.
+type Entry struct {
+	Seq       int64           `json:"seq"`
+	Time      time.Time       `json:"time"`
+	Actor     string          `json:"actor,omitempty"`
+	RequestID string          `json:"requestId,omitempty"`
+	Action    string          `json:"action"`
+	Engine    string          `json:"engine"`
+	Target    string          `json:"target,omitempty"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	PrevHash  string          `json:"prevHash"`
+	Hash      string          `json:"hash,omitempty"`
+}
+
+// EngineState is the Before or After of a CreateEngine or DeleteEngine
+// entry: the whole engine, so deleted rules can be recovered from the log.
+type EngineState struct {
+	Name       string                           `json:"name"`
+	Production bool                             `json:"production,omitempty"`
+	Facts      []rulesengine.FactInfo           `json:"facts"`
+	Rules      []*rulesengine.Rule              `json:"rules"`
+	Conditions map[string]rulesengine.Condition `json:"conditions"`
+}
+
+// NewEngineState captures the current state of engine.
+func NewEngineState(name string, engine *rulesengine.Engine) *EngineState {
+	return &EngineState{
+		Name:       name,
+		Facts:      engine.ListFacts(),
+		Rules:      engine.ListRules(),
+		Conditions: engine.ListConditions(),
+	}
+}
+
+// computeHash returns the hash of e's JSON form without its own hash.
+func (e Entry) computeHash() (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ChainError reports the first entry of a log that does not follow from
+// the ones before it.
+type ChainError struct {
+	// Line is the 1-based line of the entry in the log.
+	Line   int
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit log line %d: %s", e.Line, e.Reason)
+}
+
+// Verify reads a log from r and checks its hash chain. It returns the
+// entries read, which are all valid up to a *ChainError. A truncated last
+// line, left by a crash mid-write, is ignored.
+func Verify(r io.Reader) ([]Entry, error) {
+	entries, _, err := readEntries(r)
+	return entries, err
+}
+
+// readEntries verifies the log in r and also returns the length of its
+// complete lines.
+func readEntries(r io.Reader) ([]Entry, int64, error) {
+	var (
+		entries []Entry
+		size    int64
+		prev    string
+	)
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			return entries, size, nil
+		}
+		if err != nil {
+			return entries, size, err
+		}
+		var e Entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return entries, size, &ChainError{Line: line, Reason: err.Error()}
+		}
+		if e.Seq != int64(line) {
+			return entries, size, &ChainError{Line: line, Reason: fmt.Sprintf("sequence number %d, want %d", e.Seq, line)}
+		}
+		if e.PrevHash != prev {
+			return entries, size, &ChainError{Line: line, Reason: "previous hash does not match"}
+		}
+		hash, err := e.computeHash()
+		if err != nil {
+			return entries, size, err
+		}
+		if e.Hash != hash {
+			return entries, size, &ChainError{Line: line, Reason: "hash does not match contents"}
+		}
+		entries = append(entries, e)
+		size += int64(len(data))
+		prev = e.Hash
+	}
+}
+
+// Log appends entries to an audit log file.
+type Log struct {
+	mu    sync.Mutex
+	path  string
+	file  *os.File
+	seq   int64
+	last  string
+	clock func() time.Time
+}
+
+// Option configures a Log.
+type Option func(*Log)
+
+// WithClock sets the clock that timestamps entries.
+func WithClock(clock func() time.Time) Option {
+	return func(l *Log) {
+		l.clock = clock
+	}
+}
+
+// Open opens or creates the log at path. It fails if the existing entries
+// do not verify, so a tampered log is never extended.
+func Open(path string, options ...Option) (*Log, error) {
+	l := &Log{path: path, clock: time.Now}
+	for _, opt := range options {
+		opt(l)
+	}
+	entries, size, err := l.read()
+	if err != nil {
+		return nil, err
+	}
+	if n := len(entries); n > 0 {
+		l.seq, l.last = entries[n-1].Seq, entries[n-1].Hash
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	// Drop any interrupted write so new entries start on a fresh line.
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	l.file = f
+	return l, nil
+}
+
+func (l *Log) read() ([]Entry, int64, error) {
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	entries, size, err := readEntries(f)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", l.path, err)
+	}
+	return entries, size, nil
+}
+
+// Record appends e to the log, setting its sequence number, time and
+// hashes, and syncs the file before returning the entry written.
+func (l *Log) Record(e Entry) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e.Seq = l.seq + 1
+	e.Time = l.clock().UTC()
+	e.PrevHash = l.last
+	hash, err := e.computeHash()
+	if err != nil {
+		return Entry{}, fmt.Errorf("encoding audit entry: %w", err)
+	}
+	e.Hash = hash
+	line, err := json.Marshal(e)
+	if err != nil {
+		return Entry{}, fmt.Errorf("encoding audit entry: %w", err)
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return Entry{}, err
+	}
+	if err := l.file.Sync(); err != nil {
+		return Entry{}, err
+	}
+	l.seq, l.last = e.Seq, e.Hash
+	return e, nil
+}
+
+// Query selects entries. Zero fields match every entry.
+type Query struct {
+	Engine string
+	Actor  string
+	Action string
+	Target string
+	Since  time.Time
+	Until  time.Time
+	// Limit keeps the most recent entries matched.
+	Limit int
+}
+
+func (q Query) matches(e Entry) bool {
+	return (q.Engine == "" || e.Engine == q.Engine) &&
+		(q.Actor == "" || e.Actor == q.Actor) &&
+		(q.Action == "" || e.Action == q.Action) &&
+		(q.Target == "" || e.Target == q.Target) &&
+		(q.Since.IsZero() || !e.Time.Before(q.Since)) &&
+		(q.Until.IsZero() || e.Time.Before(q.Until))
+}
+
+// Query returns the entries matching q in the order they were recorded.
+func (l *Log) Query(q Query) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries, _, err := l.read()
+	if err != nil {
+		return nil, err
+	}
+	matched := []Entry{}
+	for _, e := range entries {
+		if q.matches(e) {
+			matched = append(matched, e)
+		}
+	}
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[len(matched)-q.Limit:]
+	}
+	return matched, nil
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// Marshal returns the JSON form of v for an entry's Before or After, or
+// nil for a nil v.
+func Marshal(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,172 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testNow = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+// writeLog records n rule additions, a minute apart, in a new log.
+func writeLog(t *testing.T, n int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	now := testNow
+	l, err := Open(path, WithClock(func() time.Time { return now }))
+	require.NoError(t, err)
+	for i := 0; i < n; i++ {
+		after, err := Marshal(map[string]interface{}{"name": "r", "priority": i})
+		require.NoError(t, err)
+		_, err = l.Record(Entry{Actor: "ann", RequestID: "req", Action: ActionAddRule, Engine: "e", Target: "r", After: after})
+		require.NoError(t, err)
+		now = now.Add(time.Minute)
+	}
+	require.NoError(t, l.Close())
+	return path
+}
+
+func verifyFile(t *testing.T, path string) ([]Entry, error) {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	return Verify(f)
+}
+
+func TestLog_RecordAndReopen(t *testing.T) {
+	path := writeLog(t, 2)
+	entries, err := verifyFile(t, path)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, int64(1), entries[0].Seq)
+	assert.Equal(t, "", entries[0].PrevHash)
+	assert.Equal(t, entries[0].Hash, entries[1].PrevHash)
+	assert.Equal(t, testNow, entries[0].Time)
+	assert.JSONEq(t, `{"name":"r","priority":1}`, string(entries[1].After))
+
+	// Reopening continues the chain.
+	l, err := Open(path)
+	require.NoError(t, err)
+	e, err := l.Record(Entry{Action: ActionRemoveRule, Engine: "e", Target: "r", Before: entries[1].After})
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+	assert.Equal(t, int64(3), e.Seq)
+	assert.Equal(t, entries[1].Hash, e.PrevHash)
+	entries, err = verifyFile(t, path)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
+
+func TestVerify_DetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		edit   func(lines []string) []string
+		line   int
+		reason string
+	}{
+		{"edited contents", func(lines []string) []string {
+			lines[1] = strings.Replace(lines[1], `"actor":"ann"`, `"actor":"bob"`, 1)
+			return lines
+		}, 2, "hash does not match"},
+		{"removed entry", func(lines []string) []string {
+			return append(lines[:1], lines[2:]...)
+		}, 2, "sequence number 3"},
+		{"reordered entries", func(lines []string) []string {
+			lines[1], lines[2] = lines[2], lines[1]
+			return lines
+		}, 2, "sequence number 3"},
+		{"rehashed entry", func(lines []string) []string {
+			// Fixing up an edited entry's own hash still breaks the next.
+			var e Entry
+			require.NoError(t, json.Unmarshal([]byte(lines[1]), &e))
+			e.Actor = "bob"
+			e.Hash, _ = e.computeHash()
+			data, _ := json.Marshal(e)
+			lines[1] = string(data)
+			return lines
+		}, 3, "previous hash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeLog(t, 3)
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			lines := tt.edit(strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"))
+			require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644))
+
+			entries, err := verifyFile(t, path)
+			var chainErr *ChainError
+			require.ErrorAs(t, err, &chainErr)
+			assert.Equal(t, tt.line, chainErr.Line)
+			assert.Contains(t, chainErr.Reason, tt.reason)
+			assert.Len(t, entries, tt.line-1)
+
+			// A broken log is never extended.
+			_, err = Open(path)
+			assert.ErrorAs(t, err, &chainErr)
+		})
+	}
+}
+
+func TestOpen_DropsTruncatedLine(t *testing.T) {
+	path := writeLog(t, 1)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"seq":2,"act`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	l, err := Open(path)
+	require.NoError(t, err)
+	_, err = l.Record(Entry{Action: ActionDeleteEngine, Engine: "e"})
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+
+	entries, err := verifyFile(t, path)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, ActionDeleteEngine, entries[1].Action)
+}
+
+func TestLog_Query(t *testing.T) {
+	path := writeLog(t, 4)
+	now := testNow.Add(time.Hour)
+	l, err := Open(path, WithClock(func() time.Time { return now }))
+	require.NoError(t, err)
+	defer l.Close()
+	_, err = l.Record(Entry{Actor: "pat", Action: ActionCreateEngine, Engine: "other"})
+	require.NoError(t, err)
+
+	seqs := func(q Query) []int64 {
+		entries, err := l.Query(q)
+		require.NoError(t, err)
+		var out []int64
+		for _, e := range entries {
+			out = append(out, e.Seq)
+		}
+		return out
+	}
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, seqs(Query{}))
+	assert.Equal(t, []int64{5}, seqs(Query{Actor: "pat"}))
+	assert.Equal(t, []int64{1, 2, 3, 4}, seqs(Query{Engine: "e", Action: ActionAddRule, Target: "r"}))
+	assert.Equal(t, []int64{2, 3}, seqs(Query{Since: testNow.Add(time.Minute), Until: testNow.Add(3 * time.Minute)}))
+	assert.Equal(t, []int64{4, 5}, seqs(Query{Limit: 2}))
+	assert.Nil(t, seqs(Query{Engine: "missing"}))
+}
+
+func TestMarshal(t *testing.T) {
+	raw, err := Marshal(nil)
+	require.NoError(t, err)
+	assert.Nil(t, raw)
+	raw, err = Marshal(map[string]int{"a": 1})
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal([]byte(`{"a":1}`), raw))
+}
@@ -0,0 +1,467 @@
+// Package client is a Go client for the rules server API described in
+// api/openapi.json.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Rohan-Muslekar/GavelEngine/api"
+	"github.com/Rohan-Muslekar/GavelEngine/audit"
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+)
+
+// Client calls a rules server.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	apiKey     string
+	token      string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests. The default is
+// http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithAPIKey authenticates requests with an API key.
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithBearerToken authenticates requests with a bearer token, such as an
+// HMAC-signed token or a JWT.
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// New creates a client for the API rooted at baseURL, for example
+// "http://localhost:8080/api".
+func New(baseURL string, options ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Error is returned for non-2xx responses.
+type Error struct {
+	StatusCode       int
+	Message          string
+	ValidationErrors []rulesengine.ValidationError
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("rules server: %d %s", e.StatusCode, e.Message)
+	for _, v := range e.ValidationErrors {
+		msg += "; " + v.Error()
+	}
+	return msg
+}
+
+// ListEngines returns the names of the server's engines.
+func (c *Client) ListEngines(ctx context.Context) ([]string, error) {
+	var out api.EngineList
+	err := c.do(ctx, http.MethodGet, "/engines", nil, &out)
+	return out.Engines, err
+}
+
+// CreateEngine creates an engine.
+func (c *Client) CreateEngine(ctx context.Context, req api.CreateEngineRequest) error {
+	return c.do(ctx, http.MethodPost, "/engines", req, nil)
+}
+
+// GetEngine describes an engine.
+func (c *Client) GetEngine(ctx context.Context, name string) (*api.EngineInfo, error) {
+	var out api.EngineInfo
+	if err := c.do(ctx, http.MethodGet, path("engines", name), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteEngine deletes an engine.
+func (c *Client) DeleteEngine(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, path("engines", name), nil, nil)
+}
+
+// ListFacts describes an engine's facts, sorted by ID.
+func (c *Client) ListFacts(ctx context.Context, engine string) ([]rulesengine.FactInfo, error) {
+	var out api.FactList
+	err := c.do(ctx, http.MethodGet, path("engines", engine, "facts"), nil, &out)
+	return out.Facts, err
+}
+
+// AddFact registers a fact with an engine.
+func (c *Client) AddFact(ctx context.Context, engine string, req api.AddFactRequest) error {
+	return c.do(ctx, http.MethodPost, path("engines", engine, "facts"), req, nil)
+}
+
+// RemoveFact removes a fact from an engine.
+func (c *Client) RemoveFact(ctx context.Context, engine, id string) error {
+	return c.do(ctx, http.MethodDelete, path("engines", engine, "facts", id), nil, nil)
+}
+
+// ListRules returns an engine's rules in priority order.
+func (c *Client) ListRules(ctx context.Context, engine string) ([]*rulesengine.Rule, error) {
+	var out api.RuleList
+	err := c.do(ctx, http.MethodGet, path("engines", engine, "rules"), nil, &out)
+	return out.Rules, err
+}
+
+// AddRule adds a rule to an engine.
+func (c *Client) AddRule(ctx context.Context, engine string, req api.AddRuleRequest) error {
+	return c.do(ctx, http.MethodPost, path("engines", engine, "rules"), req, nil)
+}
+
+// GetRule returns a rule by name.
+func (c *Client) GetRule(ctx context.Context, engine, name string) (*rulesengine.Rule, error) {
+	return c.rule(ctx, http.MethodGet, path("engines", engine, "rules", name), nil)
+}
+
+// ReplaceRule replaces a rule and returns the stored rule.
+func (c *Client) ReplaceRule(ctx context.Context, engine, name string, rule *rulesengine.Rule) (*rulesengine.Rule, error) {
+	return c.rule(ctx, http.MethodPut, path("engines", engine, "rules", name), rule)
+}
+
+// PatchRule applies a JSON merge patch to a rule and returns the stored
+// rule.
+func (c *Client) PatchRule(ctx context.Context, engine, name string, patch map[string]interface{}) (*rulesengine.Rule, error) {
+	return c.rule(ctx, http.MethodPatch, path("engines", engine, "rules", name), patch)
+}
+
+// RemoveRule removes a rule.
+func (c *Client) RemoveRule(ctx context.Context, engine, name string) error {
+	return c.do(ctx, http.MethodDelete, path("engines", engine, "rules", name), nil, nil)
+}
+
+// EnableRule enables a rule.
+func (c *Client) EnableRule(ctx context.Context, engine, name string) error {
+	return c.do(ctx, http.MethodPost, path("engines", engine, "rules", name, "enable"), nil, nil)
+}
+
+// DisableRule disables a rule.
+func (c *Client) DisableRule(ctx context.Context, engine, name string) error {
+	return c.do(ctx, http.MethodPost, path("engines", engine, "rules", name, "disable"), nil, nil)
+}
+
+func (c *Client) rule(ctx context.Context, method, p string, body interface{}) (*rulesengine.Rule, error) {
+	var out api.RuleResponse
+	if err := c.do(ctx, method, p, body, &out); err != nil {
+		return nil, err
+	}
+	return out.Rule, nil
+}
+
+// ListConditions returns an engine's named conditions.
+func (c *Client) ListConditions(ctx context.Context, engine string) (map[string]rulesengine.Condition, error) {
+	var out api.ConditionList
+	err := c.do(ctx, http.MethodGet, path("engines", engine, "conditions"), nil, &out)
+	return out.Conditions, err
+}
+
+// GetCondition returns a named condition.
+func (c *Client) GetCondition(ctx context.Context, engine, name string) (rulesengine.Condition, error) {
+	var out api.NamedCondition
+	err := c.do(ctx, http.MethodGet, path("engines", engine, "conditions", name), nil, &out)
+	return out.Condition, err
+}
+
+// SetCondition creates or replaces a named condition.
+func (c *Client) SetCondition(ctx context.Context, engine, name string, cond rulesengine.Condition) error {
+	return c.do(ctx, http.MethodPut, path("engines", engine, "conditions", name), cond, nil)
+}
+
+// RemoveCondition removes a named condition.
+func (c *Client) RemoveCondition(ctx context.Context, engine, name string) error {
+	return c.do(ctx, http.MethodDelete, path("engines", engine, "conditions", name), nil, nil)
+}
+
+// ListOperators names an engine's operators and operator decorators.
+func (c *Client) ListOperators(ctx context.Context, engine string) (*rulesengine.OperatorList, error) {
+	var out rulesengine.OperatorList
+	if err := c.do(ctx, http.MethodGet, path("engines", engine, "operators"), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Run runs an engine against runtime facts.
+func (c *Client) Run(ctx context.Context, engine string, facts map[string]interface{}) (*api.RunResponse, error) {
+	return c.run(ctx, path("engines", engine, "run"), facts)
+}
+
+// Trace runs an engine against runtime facts and returns condition traces
+// with the rule results.
+func (c *Client) Trace(ctx context.Context, engine string, facts map[string]interface{}) (*api.RunResponse, error) {
+	return c.run(ctx, path("engines", engine, "trace"), facts)
+}
+
+func (c *Client) run(ctx context.Context, p string, facts map[string]interface{}) (*api.RunResponse, error) {
+	if facts == nil {
+		facts = map[string]interface{}{}
+	}
+	var out api.RunResponse
+	if err := c.do(ctx, http.MethodPost, p, facts, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// BatchOptions configures RunBatch. Zero values use the server's defaults.
+type BatchOptions struct {
+	Concurrency int
+	Trace       bool
+}
+
+// RunBatch streams inputs to the batch endpoint and calls fn with each
+// result as it arrives, in completion order. It returns the batch's stats.
+// If fn returns an error, the request is cancelled and the error returned.
+func (c *Client) RunBatch(ctx context.Context, engine string, inputs iter.Seq[map[string]interface{}], fn func(api.BatchResult) error, opts BatchOptions) (*rulesengine.BatchStats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	query := url.Values{}
+	if opts.Concurrency > 0 {
+		query.Set("concurrency", strconv.Itoa(opts.Concurrency))
+	}
+	if opts.Trace {
+		query.Set("trace", "true")
+	}
+	u := c.baseURL + path("engines", engine, "batch")
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		enc := json.NewEncoder(pw)
+		for facts := range inputs {
+			if err := enc.Encode(facts); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.Close()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, pr)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", api.ContentTypeNDJSON)
+	req.Header.Set("Accept", api.ContentTypeNDJSON)
+	c.authorize(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		// Result lines and the summary line share the error field.
+		var line struct {
+			api.BatchResult
+			Stats *rulesengine.BatchStats `json:"stats"`
+		}
+		if err := dec.Decode(&line); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("decoding batch response: %w", err)
+		}
+		if line.Stats != nil {
+			if line.Error != "" {
+				return line.Stats, fmt.Errorf("batch stopped: %s", line.Error)
+			}
+			return line.Stats, nil
+		}
+		if err := fn(line.BatchResult); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Challenger returns the statistics and recent divergences of an engine's
+// challenger.
+func (c *Client) Challenger(ctx context.Context, engine string) (*api.ChallengerReport, error) {
+	var out api.ChallengerReport
+	if err := c.do(ctx, http.MethodGet, path("engines", engine, "challenger"), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetChallenger pairs an engine with a challenger that silently evaluates
+// the same runtime facts.
+func (c *Client) SetChallenger(ctx context.Context, engine, challenger string) error {
+	return c.do(ctx, http.MethodPut, path("engines", engine, "challenger"), api.SetChallengerRequest{Challenger: challenger}, nil)
+}
+
+// RemoveChallenger stops evaluating an engine's challenger.
+func (c *Client) RemoveChallenger(ctx context.Context, engine string) error {
+	return c.do(ctx, http.MethodDelete, path("engines", engine, "challenger"), nil, nil)
+}
+
+// ListExperiments returns the server's experiments.
+func (c *Client) ListExperiments(ctx context.Context) ([]api.Experiment, error) {
+	var out api.ExperimentList
+	err := c.do(ctx, http.MethodGet, "/experiments", nil, &out)
+	return out.Experiments, err
+}
+
+// GetExperiment returns an experiment.
+func (c *Client) GetExperiment(ctx context.Context, name string) (*api.Experiment, error) {
+	var out api.Experiment
+	if err := c.do(ctx, http.MethodGet, path("experiments", name), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PutExperiment creates or replaces the experiment named by exp.
+func (c *Client) PutExperiment(ctx context.Context, exp api.Experiment) error {
+	return c.do(ctx, http.MethodPut, path("experiments", exp.Name), exp, nil)
+}
+
+// DeleteExperiment removes an experiment.
+func (c *Client) DeleteExperiment(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, path("experiments", name), nil, nil)
+}
+
+// RunExperiment runs the variant of an experiment assigned to the runtime
+// facts, which the response's Assignment names.
+func (c *Client) RunExperiment(ctx context.Context, name string, facts map[string]interface{}) (*api.RunResponse, error) {
+	return c.run(ctx, path("experiments", name, "run"), facts)
+}
+
+// PredefinedFacts lists the facts suggested by the server.
+func (c *Client) PredefinedFacts(ctx context.Context) ([]api.PredefinedFact, error) {
+	var out api.PredefinedFactList
+	err := c.do(ctx, http.MethodGet, "/predefined-facts", nil, &out)
+	return out.Facts, err
+}
+
+// Audit returns the audit entries matching q, oldest first.
+func (c *Client) Audit(ctx context.Context, q audit.Query) ([]audit.Entry, error) {
+	query := url.Values{}
+	for param, v := range map[string]string{"engine": q.Engine, "actor": q.Actor, "action": q.Action, "target": q.Target} {
+		if v != "" {
+			query.Set(param, v)
+		}
+	}
+	if !q.Since.IsZero() {
+		query.Set("since", q.Since.Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		query.Set("until", q.Until.Format(time.RFC3339))
+	}
+	if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+	p := "/audit"
+	if len(query) > 0 {
+		p += "?" + query.Encode()
+	}
+	var out api.AuditLog
+	err := c.do(ctx, http.MethodGet, p, nil, &out)
+	return out.Entries, err
+}
+
+func path(segments ...string) string {
+	var b strings.Builder
+	for _, s := range segments {
+		b.WriteByte('/')
+		b.WriteString(url.PathEscape(s))
+	}
+	return b.String()
+}
+
+// do sends body as JSON and decodes a 2xx response into out, which may be
+// nil.
+func (c *Client) do(ctx context.Context, method, p string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+p, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	c.authorize(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) authorize(req *http.Request) {
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+}
+
+// checkStatus returns an *Error for non-2xx responses.
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	var apiErr api.Error
+	data, _ := io.ReadAll(resp.Body)
+	if json.Unmarshal(data, &apiErr) != nil || apiErr.Error == "" {
+		apiErr.Error = strings.TrimSpace(string(data))
+	}
+	return &Error{StatusCode: resp.StatusCode, Message: apiErr.Error, ValidationErrors: apiErr.ValidationErrors}
+}
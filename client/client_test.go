@@ -0,0 +1,324 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Rohan-Muslekar/GavelEngine/api"
+	"github.com/Rohan-Muslekar/GavelEngine/audit"
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+	"github.com/Rohan-Muslekar/GavelEngine/server"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func testClient(t *testing.T) *Client {
+	t.Helper()
+	srv := httptest.NewServer(server.New(rulesengine.NewEngineManager()).Handler())
+	t.Cleanup(srv.Close)
+	return New(srv.URL+"/api", WithHTTPClient(srv.Client()))
+}
+
+func TestClient_EnginesAndFacts(t *testing.T) {
+	ctx := context.Background()
+	c := testClient(t)
+
+	require.NoError(t, c.CreateEngine(ctx, api.CreateEngineRequest{Name: "loans"}))
+	engines, err := c.ListEngines(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"loans"}, engines)
+
+	info, err := c.GetEngine(ctx, "loans")
+	require.NoError(t, err)
+	assert.Equal(t, "loans", info.Name)
+
+	require.NoError(t, c.AddFact(ctx, "loans", api.AddFactRequest{ID: "limit", Type: api.FactTypeConstant, Value: 100.0}))
+	require.NoError(t, c.AddFact(ctx, "loans", api.AddFactRequest{ID: "amount", Type: api.FactTypeFunction}))
+	facts, err := c.ListFacts(ctx, "loans")
+	require.NoError(t, err)
+	require.Len(t, facts, 2)
+	assert.Equal(t, "amount", facts[0].ID)
+	assert.Equal(t, 100.0, facts[1].Value)
+
+	require.NoError(t, c.RemoveFact(ctx, "loans", "limit"))
+	facts, err = c.ListFacts(ctx, "loans")
+	require.NoError(t, err)
+	assert.Len(t, facts, 1)
+
+	require.NoError(t, c.DeleteEngine(ctx, "loans"))
+	_, err = c.GetEngine(ctx, "loans")
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "Engine not found", apiErr.Message)
+}
+
+func TestClient_RulesAndRun(t *testing.T) {
+	ctx := context.Background()
+	c := testClient(t)
+	require.NoError(t, c.CreateEngine(ctx, api.CreateEngineRequest{Name: "e"}))
+	require.NoError(t, c.AddFact(ctx, "e", api.AddFactRequest{ID: "age", Type: api.FactTypeFunction}))
+	require.NoError(t, c.SetCondition(ctx, "e", "adult", rulesengine.Condition{Fact: "age", Operator: "greaterThanInclusive", Value: 18.0}))
+
+	require.NoError(t, c.AddRule(ctx, "e", api.AddRuleRequest{
+		Name:       "adult",
+		Priority:   2,
+		Conditions: rulesengine.Condition{All: []rulesengine.Condition{{ConditionRef: "adult"}}},
+		Event:      rulesengine.Event{Type: "adult"},
+	}))
+
+	rules, err := c.ListRules(ctx, "e")
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, 2, rules[0].Priority)
+
+	result, err := c.Run(ctx, "e", map[string]interface{}{"age": 20})
+	require.NoError(t, err)
+	require.Len(t, result.Events, 1)
+	assert.Equal(t, "adult", result.Events[0].Type)
+	assert.Nil(t, result.RuleResults[0].Trace)
+
+	traced, err := c.Trace(ctx, "e", map[string]interface{}{"age": 12})
+	require.NoError(t, err)
+	assert.Empty(t, traced.Events)
+	require.NotNil(t, traced.RuleResults[0].Trace)
+	assert.False(t, traced.RuleResults[0].Trace.Result)
+
+	rule, err := c.PatchRule(ctx, "e", "adult", map[string]interface{}{"priority": 7})
+	require.NoError(t, err)
+	assert.Equal(t, 7, rule.Priority)
+
+	rule.Event.Type = "grown-up"
+	rule, err = c.ReplaceRule(ctx, "e", "adult", rule)
+	require.NoError(t, err)
+	assert.Equal(t, "grown-up", rule.Event.Type)
+
+	require.NoError(t, c.DisableRule(ctx, "e", "adult"))
+	result, err = c.Run(ctx, "e", map[string]interface{}{"age": 20})
+	require.NoError(t, err)
+	assert.Empty(t, result.Events)
+	require.NoError(t, c.EnableRule(ctx, "e", "adult"))
+	got, err := c.GetRule(ctx, "e", "adult")
+	require.NoError(t, err)
+	assert.False(t, got.Disabled)
+
+	require.NoError(t, c.RemoveRule(ctx, "e", "adult"))
+	_, err = c.GetRule(ctx, "e", "adult")
+	assert.Error(t, err)
+}
+
+func TestClient_ValidationErrors(t *testing.T) {
+	ctx := context.Background()
+	c := testClient(t)
+	require.NoError(t, c.CreateEngine(ctx, api.CreateEngineRequest{Name: "e"}))
+
+	err := c.SetCondition(ctx, "e", "bad", rulesengine.Condition{Fact: "missing", Operator: "equal", Value: 1.0})
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.NotEmpty(t, apiErr.ValidationErrors)
+	assert.Contains(t, err.Error(), "missing")
+}
+
+func TestClient_ConditionsAndOperators(t *testing.T) {
+	ctx := context.Background()
+	c := testClient(t)
+	require.NoError(t, c.CreateEngine(ctx, api.CreateEngineRequest{Name: "e", AllowUndefinedFacts: true}))
+
+	cond := rulesengine.Condition{Fact: "score", Operator: "greaterThan", Value: 5.0}
+	require.NoError(t, c.SetCondition(ctx, "e", "high", cond))
+	got, err := c.GetCondition(ctx, "e", "high")
+	require.NoError(t, err)
+	assert.Equal(t, cond, got)
+
+	conditions, err := c.ListConditions(ctx, "e")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]rulesengine.Condition{"high": cond}, conditions)
+
+	require.NoError(t, c.RemoveCondition(ctx, "e", "high"))
+	_, err = c.GetCondition(ctx, "e", "high")
+	assert.Error(t, err)
+
+	ops, err := c.ListOperators(ctx, "e")
+	require.NoError(t, err)
+	assert.Contains(t, ops.Operators, "equal")
+
+	predefined, err := c.PredefinedFacts(ctx)
+	require.NoError(t, err)
+	assert.NotEmpty(t, predefined)
+}
+
+func TestClient_RunBatch(t *testing.T) {
+	ctx := context.Background()
+	c := testClient(t)
+	require.NoError(t, c.CreateEngine(ctx, api.CreateEngineRequest{Name: "e"}))
+	require.NoError(t, c.AddFact(ctx, "e", api.AddFactRequest{ID: "age", Type: api.FactTypeFunction}))
+	require.NoError(t, c.AddRule(ctx, "e", api.AddRuleRequest{
+		Name:       "adult",
+		Conditions: rulesengine.Condition{Fact: "age", Operator: "greaterThanInclusive", Value: 18.0},
+		Event:      rulesengine.Event{Type: "adult"},
+	}))
+
+	inputs := func(yield func(map[string]interface{}) bool) {
+		for i := 0; i < 100; i++ {
+			if !yield(map[string]interface{}{"age": i}) {
+				return
+			}
+		}
+	}
+	seen := map[int]bool{}
+	stats, err := c.RunBatch(ctx, "e", inputs, func(r api.BatchResult) error {
+		assert.Empty(t, r.Error)
+		assert.Equal(t, r.Index >= 18, len(r.Events) == 1, r.Index)
+		seen[r.Index] = true
+		return nil
+	}, BatchOptions{Concurrency: 4})
+	require.NoError(t, err)
+	assert.Len(t, seen, 100)
+	assert.Equal(t, 100, stats.Items)
+	assert.Equal(t, map[string]int{"adult": 82}, stats.Fired)
+
+	stop := errors.New("stop")
+	_, err = c.RunBatch(ctx, "e", inputs, func(api.BatchResult) error { return stop }, BatchOptions{})
+	assert.ErrorIs(t, err, stop)
+
+	_, err = c.RunBatch(ctx, "missing", inputs, func(api.BatchResult) error { return nil }, BatchOptions{})
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
+
+func TestClient_Auth(t *testing.T) {
+	tokens := server.NewHMACTokens([]byte("s3cret"))
+	handler := server.New(rulesengine.NewEngineManager(), server.WithAuth(
+		server.APIKeys{"k": {Subject: "ci", Role: server.RoleAdmin}}, tokens)).Handler()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	ctx := context.Background()
+
+	_, err := New(srv.URL + "/api").ListEngines(ctx)
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+
+	require.NoError(t, New(srv.URL+"/api", WithAPIKey("k")).CreateEngine(ctx, api.CreateEngineRequest{Name: "e"}))
+
+	token, err := tokens.Issue(server.Principal{Subject: "dash", Role: server.RoleViewer}, time.Hour)
+	require.NoError(t, err)
+	viewer := New(srv.URL+"/api", WithBearerToken(token))
+	engines, err := viewer.ListEngines(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"e"}, engines)
+	err = viewer.DeleteEngine(ctx, "e")
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+}
+
+func TestClient_Audit(t *testing.T) {
+	trail, err := audit.Open(filepath.Join(t.TempDir(), "audit.log"))
+	require.NoError(t, err)
+	defer trail.Close()
+	srv := httptest.NewServer(server.New(rulesengine.NewEngineManager(), server.WithAudit(trail)).Handler())
+	t.Cleanup(srv.Close)
+	c := New(srv.URL + "/api")
+	ctx := context.Background()
+
+	require.NoError(t, c.CreateEngine(ctx, api.CreateEngineRequest{Name: "a"}))
+	require.NoError(t, c.CreateEngine(ctx, api.CreateEngineRequest{Name: "b"}))
+	require.NoError(t, c.DeleteEngine(ctx, "a"))
+
+	entries, err := c.Audit(ctx, audit.Query{Engine: "a"})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, audit.ActionCreateEngine, entries[0].Action)
+	assert.Equal(t, audit.ActionDeleteEngine, entries[1].Action)
+
+	entries, err = c.Audit(ctx, audit.Query{Since: time.Now().Add(-time.Hour), Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, int64(3), entries[0].Seq)
+}
+
+func TestClient_Challenger(t *testing.T) {
+	manager := rulesengine.NewEngineManager()
+	srv := httptest.NewServer(server.New(manager).Handler())
+	t.Cleanup(srv.Close)
+	c := New(srv.URL + "/api")
+	ctx := context.Background()
+
+	for _, name := range []string{"champion", "challenger"} {
+		require.NoError(t, c.CreateEngine(ctx, api.CreateEngineRequest{Name: name, AllowUndefinedFacts: true}))
+	}
+	require.NoError(t, c.AddRule(ctx, "champion", api.AddRuleRequest{
+		Name:       "big",
+		Conditions: rulesengine.Condition{Fact: "n", Operator: "greaterThan", Value: 10},
+		Event:      rulesengine.Event{Type: "big"},
+	}))
+
+	_, err := c.Challenger(ctx, "champion")
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+
+	require.NoError(t, c.SetChallenger(ctx, "champion", "challenger"))
+	resp, err := c.Run(ctx, "champion", map[string]interface{}{"n": 11})
+	require.NoError(t, err)
+	assert.Len(t, resp.Events, 1)
+	manager.GetShadow("champion").Wait()
+
+	report, err := c.Challenger(ctx, "champion")
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Stats.Divergences)
+	require.Len(t, report.Divergences, 1)
+	assert.Equal(t, []rulesengine.Event{{Type: "big"}}, report.Divergences[0].Diff.RemovedEvents)
+
+	require.NoError(t, c.RemoveChallenger(ctx, "champion"))
+	assert.Error(t, c.RemoveChallenger(ctx, "champion"))
+}
+
+func TestClient_Experiments(t *testing.T) {
+	c := testClient(t)
+	ctx := context.Background()
+	for _, name := range []string{"low", "high"} {
+		require.NoError(t, c.CreateEngine(ctx, api.CreateEngineRequest{Name: name, AllowUndefinedFacts: true}))
+	}
+	require.NoError(t, c.AddRule(ctx, "high", api.AddRuleRequest{
+		Name:       "approve",
+		Conditions: rulesengine.Condition{Fact: "amount", Operator: "lessThan", Value: 1000},
+		Event:      rulesengine.Event{Type: "approve"},
+	}))
+
+	exp := api.Experiment{Name: "limit", Key: "user.id", Sticky: true, Variants: []rulesengine.Variant{
+		{Name: "control", Engine: "low", Weight: 0},
+		{Name: "raised", Engine: "high", Weight: 1},
+	}}
+	require.NoError(t, c.PutExperiment(ctx, exp))
+	got, err := c.GetExperiment(ctx, "limit")
+	require.NoError(t, err)
+	assert.Equal(t, exp, *got)
+	list, err := c.ListExperiments(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []api.Experiment{exp}, list)
+
+	resp, err := c.RunExperiment(ctx, "limit", map[string]interface{}{"user": map[string]interface{}{"id": 7}, "amount": 700})
+	require.NoError(t, err)
+	assert.Equal(t, &rulesengine.Assignment{Experiment: "limit", Variant: "raised", Engine: "high", Key: "7"}, resp.Assignment)
+	assert.Equal(t, []rulesengine.Event{{Type: "approve"}}, resp.Events)
+
+	require.NoError(t, c.DeleteExperiment(ctx, "limit"))
+	_, err = c.RunExperiment(ctx, "limit", nil)
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Rohan-Muslekar/GavelEngine/audit"
+)
+
+func runAudit(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: gavel audit verify audit-log")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 2 || fs.Arg(0) != "verify" {
+		fs.Usage()
+		return exitUsage
+	}
+
+	f, err := os.Open(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(stderr, "gavel audit: %v\n", err)
+		return exitUsage
+	}
+	defer f.Close()
+	entries, err := audit.Verify(f)
+	var chainErr *audit.ChainError
+	if errors.As(err, &chainErr) {
+		fmt.Fprintf(stdout, "%s: %v\n", fs.Arg(1), chainErr)
+		fmt.Fprintf(stdout, "%d entries verified before the break\n", len(entries))
+		return exitFailure
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "gavel audit: %s: %v\n", fs.Arg(1), err)
+		return exitUsage
+	}
+	fmt.Fprintf(stdout, "%s: OK, %d entries verified\n", fs.Arg(1), len(entries))
+	return exitOK
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+)
+
+func runDiff(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "text", "output format: text or json")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: gavel diff [-format text|json] old-rules new-rules")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 2 || (*format != "text" && *format != "json") {
+		fs.Usage()
+		return exitUsage
+	}
+
+	oldSet, err := rulesengine.LoadRuleSetFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "gavel diff: %s: %v\n", fs.Arg(0), err)
+		return exitUsage
+	}
+	newSet, err := rulesengine.LoadRuleSetFile(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(stderr, "gavel diff: %s: %v\n", fs.Arg(1), err)
+		return exitUsage
+	}
+
+	d := rulesengine.DiffRuleSets(oldSet.Rules, newSet.Rules)
+	if *format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(d)
+	} else {
+		err = d.WriteReport(stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "gavel diff: %v\n", err)
+		return exitUsage
+	}
+	// Like diff(1), exit 1 when the inputs differ.
+	if d.Empty() {
+		return exitOK
+	}
+	return exitFailure
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+	"gopkg.in/yaml.v3"
+)
+
+// formatOf returns the rule file format implied by a file name.
+func formatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".bson":
+		return "bson"
+	}
+	return "json"
+}
+
+// encodeRuleSet writes a rule set in its canonical form for the given
+// format. A rule set without groups is written as a bare rule list, so
+// plain rule files keep their shape.
+func encodeRuleSet(rs *rulesengine.RuleSet, format string) ([]byte, error) {
+	var doc interface{} = rs.Rules
+	if len(rs.Groups) > 0 {
+		doc = rs
+	}
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	case "yaml":
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case "bson":
+		return rulesengine.MarshalRuleSetBSON(rs)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	write := fs.Bool("w", false, "write the result back to the source file")
+	list := fs.Bool("l", false, "list files whose formatting differs")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: gavel fmt [-w] [-l] rules.json|rules.yaml...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	code := exitOK
+	for _, path := range fs.Args() {
+		format := formatOf(path)
+		if format == "bson" {
+			fmt.Fprintf(stderr, "gavel fmt: %s: BSON files have no text formatting\n", path)
+			return exitUsage
+		}
+		original, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "gavel fmt: %v\n", err)
+			return exitUsage
+		}
+		rs, err := rulesengine.LoadRuleSetFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "gavel fmt: %s: %v\n", path, err)
+			code = exitFailure
+			continue
+		}
+		formatted, err := encodeRuleSet(rs, format)
+		if err != nil {
+			fmt.Fprintf(stderr, "gavel fmt: %s: %v\n", path, err)
+			code = exitFailure
+			continue
+		}
+		changed := !bytes.Equal(original, formatted)
+		if *list && changed {
+			fmt.Fprintln(stdout, path)
+		}
+		if *write && changed {
+			if err := os.WriteFile(path, formatted, 0o644); err != nil {
+				fmt.Fprintf(stderr, "gavel fmt: %v\n", err)
+				return exitUsage
+			}
+		}
+		if !*write && !*list {
+			stdout.Write(formatted)
+		}
+	}
+	return code
+}
+
+func runConvert(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	to := fs.String("to", "", "target format: json, yaml or bson")
+	out := fs.String("o", "", "output file (defaults to stdout)")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: gavel convert -to json|yaml|bson [-o file] rules-file")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 || (*to != "json" && *to != "yaml" && *to != "bson") {
+		fs.Usage()
+		return exitUsage
+	}
+
+	rs, err := rulesengine.LoadRuleSetFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "gavel convert: %s: %v\n", fs.Arg(0), err)
+		return exitFailure
+	}
+	data, err := encodeRuleSet(rs, *to)
+	if err != nil {
+		fmt.Fprintf(stderr, "gavel convert: %v\n", err)
+		return exitFailure
+	}
+	if *out == "" {
+		stdout.Write(data)
+		return exitOK
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		fmt.Fprintf(stderr, "gavel convert: %v\n", err)
+		return exitUsage
+	}
+	return exitOK
+}
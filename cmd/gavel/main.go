@@ -0,0 +1,60 @@
+// Command gavel works with GavelEngine rule files from the command line.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Exit codes shared by every subcommand.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+}
+
+var commands = []command{
+	{"validate", "check rule files for structural errors", runValidate},
+	{"run", "run rules against facts and print the result", runRun},
+	{"trace", "run rules against facts and print the evaluation trace", runTrace},
+	{"fmt", "rewrite rule files in canonical form", runFmt},
+	{"convert", "convert rule files between JSON, YAML and BSON", runConvert},
+	{"diff", "show the semantic difference between two rule files", runDiff},
+	{"test", "run declarative test suites against a rules file", runTest},
+	{"audit", "verify the hash chain of an audit log", runAudit},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		usage(stderr)
+		return exitUsage
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:], stdin, stdout, stderr)
+		}
+	}
+	fmt.Fprintf(stderr, "gavel: unknown command %q\n", args[0])
+	usage(stderr)
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: gavel <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+}
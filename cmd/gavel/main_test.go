@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Rohan-Muslekar/GavelEngine/audit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRulesJSON = `[
+  {
+    "name": "adult",
+    "priority": 10,
+    "conditions": {"all": [{"fact": "age", "operator": "gte", "value": 18}]},
+    "event": {"type": "adult"}
+  },
+  {
+    "name": "vip",
+    "priority": 5,
+    "conditions": {"fact": "tier", "operator": "equal", "value": "gold"},
+    "event": {"type": "vip", "params": {"discount": 10}}
+  }
+]`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func runCLI(t *testing.T, stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_Usage(t *testing.T) {
+	code, _, stderr := runCLI(t, "")
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "usage: gavel")
+
+	code, _, stderr = runCLI(t, "", "bogus")
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, `unknown command "bogus"`)
+}
+
+func TestTest_Command(t *testing.T) {
+	dir := t.TempDir()
+	rules := writeFile(t, dir, "rules.json", testRulesJSON)
+	pass := writeFile(t, dir, "pass.yaml", `
+tests:
+  - name: gold adult
+    facts: {age: 40, tier: gold}
+    events: [{type: adult}, {type: vip, params: {discount: 10}}]
+`)
+	fail := writeFile(t, dir, "fail.yaml", `
+tests:
+  - name: wrong
+    facts: {age: 10, tier: silver}
+    passing: [adult]
+`)
+
+	code, stdout, _ := runCLI(t, "", "test", "-rules", rules, pass)
+	assert.Equal(t, exitOK, code, stdout)
+	assert.Contains(t, stdout, "--- PASS: gold adult")
+
+	code, stdout, _ = runCLI(t, "", "test", "-rules", rules, pass, fail)
+	assert.Equal(t, exitFailure, code)
+	assert.Contains(t, stdout, `rule "adult": expected to pass but failed`)
+}
+
+func TestValidate_Command(t *testing.T) {
+	dir := t.TempDir()
+	good := writeFile(t, dir, "good.json", testRulesJSON)
+	bad := writeFile(t, dir, "bad.json", `[
+  {"name": "a", "conditions": {"all": []}, "event": {"type": "x"}},
+  {"name": "a", "conditions": {"fact": "x", "operator": "bogus", "value": 1}, "event": {"type": "y"}}
+]`)
+
+	code, stdout, _ := runCLI(t, "", "validate", good)
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout, "good.json: ok")
+
+	code, stdout, _ = runCLI(t, "", "validate", "-format", "json", good, bad)
+	assert.Equal(t, exitFailure, code)
+	var reports []validationReport
+	require.NoError(t, json.Unmarshal([]byte(stdout), &reports))
+	require.Len(t, reports, 2)
+	assert.True(t, reports[0].Valid)
+	assert.False(t, reports[1].Valid)
+	paths := map[string]string{}
+	for _, ve := range reports[1].Errors {
+		paths[ve.Path] += ve.Message + ";"
+	}
+	assert.Contains(t, paths["rules[0]"], "at least one child")
+	assert.Contains(t, paths["rules[1]"], "duplicate rule name")
+	assert.Contains(t, paths["rules[1]"], "undefined operator: bogus")
+
+	code, _, _ = runCLI(t, "", "validate", filepath.Join(dir, "missing.json"))
+	assert.Equal(t, exitUsage, code)
+}
+
+func TestValidate_AnalyzeUsesFileIndices(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "rules.json", `[
+  {"name": "low", "priority": 1, "conditions": {"all": [
+    {"fact": "x", "operator": "gt", "value": 5},
+    {"fact": "x", "operator": "lt", "value": 1}
+  ]}, "event": {"type": "low"}},
+  {"name": "high", "priority": 10, "conditions": {"fact": "x", "operator": "gt", "value": 0}, "event": {"type": "high"}}
+]`)
+
+	code, stdout, _ := runCLI(t, "", "validate", "-analyze", "-format", "json", path)
+	assert.Equal(t, exitOK, code)
+	var reports []validationReport
+	require.NoError(t, json.Unmarshal([]byte(stdout), &reports))
+	require.Len(t, reports[0].Warnings, 1)
+	assert.Equal(t, "rules[0]", reports[0].Warnings[0].Path)
+	assert.Equal(t, "low", reports[0].Warnings[0].Rule)
+}
+
+func TestRun_Command(t *testing.T) {
+	dir := t.TempDir()
+	rules := writeFile(t, dir, "rules.json", testRulesJSON)
+
+	code, stdout, stderr := runCLI(t, `{"age": 30, "tier": "gold"}`, "run", "-rules", rules)
+	require.Equal(t, exitOK, code, stderr)
+	var result struct {
+		Events []struct {
+			Type string `json:"type"`
+		} `json:"events"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(stdout), &result))
+	require.Len(t, result.Events, 2)
+	assert.Equal(t, "adult", result.Events[0].Type)
+
+	facts := writeFile(t, dir, "facts.yaml", "age: 10\ntier: gold\n")
+	code, stdout, _ = runCLI(t, "", "run", "-rules", rules, "-facts", facts, "-o", "table")
+	require.Equal(t, exitOK, code)
+	assert.Regexp(t, `adult\s+failed\s+-`, stdout)
+	assert.Regexp(t, `vip\s+passed\s+vip \{"discount":10\}`, stdout)
+
+	code, _, stderr = runCLI(t, `{"age": 30}`, "run", "-rules", rules)
+	assert.Equal(t, exitFailure, code)
+	assert.Contains(t, stderr, "undefined fact: tier")
+
+	code, _, _ = runCLI(t, `{"age": 30}`, "run", "-rules", rules, "-allow-undefined")
+	assert.Equal(t, exitOK, code)
+}
+
+func TestRun_RuleSetGroups(t *testing.T) {
+	dir := t.TempDir()
+	rules := writeFile(t, dir, "rules.yaml", `
+groups:
+  - {name: promo, strategy: firstMatch}
+rules:
+  - {name: p1, group: promo, priority: 2, conditions: {fact: n, operator: gte, value: 0}, event: {type: p1}}
+  - {name: p2, group: promo, priority: 1, conditions: {fact: n, operator: gte, value: 0}, event: {type: p2}}
+  - {name: base, conditions: {fact: n, operator: gte, value: 0}, event: {type: base}}
+`)
+
+	code, stdout, stderr := runCLI(t, `{"n": 1}`, "run", "-rules", rules, "-o", "table")
+	require.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, "p1")
+	assert.NotContains(t, stdout, "p2")
+	assert.Contains(t, stdout, "base")
+
+	code, stdout, stderr = runCLI(t, `{"n": 1}`, "run", "-rules", rules, "-o", "table", "-groups", "promo")
+	require.Equal(t, exitOK, code, stderr)
+	assert.NotContains(t, stdout, "base")
+}
+
+func TestTrace_Command(t *testing.T) {
+	dir := t.TempDir()
+	rules := writeFile(t, dir, "rules.json", `[{
+  "name": "either",
+  "conditions": {"any": [
+    {"fact": "a", "operator": "equal", "value": 1},
+    {"fact": "b", "operator": "equal", "value": 2}
+  ]},
+  "event": {"type": "either"}
+}]`)
+
+	code, stdout, stderr := runCLI(t, `{"a": 1, "b": 0}`, "trace", "-rules", rules)
+	require.Equal(t, exitOK, code, stderr)
+	assert.Equal(t, "either: passed\n"+
+		"  any: true\n"+
+		"    a equal 1 (a = 1): true\n"+
+		"    b equal 2: skipped\n", stdout)
+}
+
+func TestFmtAndConvert_Commands(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "rules.json", `[{"name":"adult","conditions":{"fact":"age","operator":"gte","value":18},"event":{"type":"adult"},"priority":3}]`)
+
+	code, stdout, _ := runCLI(t, "", "fmt", "-l", path)
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, path+"\n", stdout)
+
+	code, _, _ = runCLI(t, "", "fmt", "-w", path)
+	require.Equal(t, exitOK, code)
+	code, stdout, _ = runCLI(t, "", "fmt", "-l", path)
+	assert.Equal(t, exitOK, code)
+	assert.Empty(t, stdout)
+
+	bsonPath := filepath.Join(dir, "rules.bson")
+	code, _, _ = runCLI(t, "", "convert", "-to", "bson", "-o", bsonPath, path)
+	require.Equal(t, exitOK, code)
+	code, stdout, _ = runCLI(t, "", "convert", "-to", "yaml", bsonPath)
+	require.Equal(t, exitOK, code)
+	assert.Contains(t, stdout, "name: adult")
+	assert.Contains(t, stdout, "priority: 3")
+}
+
+func TestRuleSetCommands_KeepGroups(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "ruleset.json", `{
+  "groups": [{"name": "promo", "strategy": "firstMatch"}],
+  "rules": [{"name": "p1", "group": "promo", "conditions": {"fact": "n", "operator": "gte", "value": 0}, "event": {"type": "p1"}}]
+}`)
+
+	code, stdout, stderr := runCLI(t, "", "validate", path)
+	assert.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, "ruleset.json: ok")
+
+	code, stdout, stderr = runCLI(t, "", "convert", "-to", "yaml", path)
+	require.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, "groups:")
+	assert.Contains(t, stdout, "strategy: firstMatch")
+	assert.Contains(t, stdout, "group: promo")
+
+	bsonPath := filepath.Join(dir, "ruleset.bson")
+	code, _, stderr = runCLI(t, "", "convert", "-to", "bson", "-o", bsonPath, path)
+	require.Equal(t, exitOK, code, stderr)
+	code, stdout, stderr = runCLI(t, "", "fmt", path)
+	require.Equal(t, exitOK, code, stderr)
+	var rs struct {
+		Groups []struct {
+			Name string `json:"name"`
+		} `json:"groups"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(stdout), &rs))
+	require.Len(t, rs.Groups, 1)
+	assert.Equal(t, "promo", rs.Groups[0].Name)
+
+	code, stdout, stderr = runCLI(t, "", "convert", "-to", "json", bsonPath)
+	require.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, `"groups"`)
+
+	code, _, stderr = runCLI(t, "", "diff", path, path)
+	assert.Equal(t, exitOK, code, stderr)
+
+	bad := writeFile(t, dir, "bad.yaml", "groups:\n  - {name: promo, strategy: bogus}\nrules: []\n")
+	code, stdout, _ = runCLI(t, "", "validate", bad)
+	assert.Equal(t, exitFailure, code)
+	assert.Contains(t, stdout, "groups[0]")
+}
+
+func TestDiff_Command(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := writeFile(t, dir, "old.json", testRulesJSON)
+	newPath := writeFile(t, dir, "new.json", `[
+  {"name": "adult", "priority": 20, "conditions": {"all": [{"fact": "age", "operator": "gte", "value": 18}]}, "event": {"type": "adult"}},
+  {"name": "teen", "conditions": {"fact": "age", "operator": "lt", "value": 18}, "event": {"type": "teen"}}
+]`)
+
+	code, stdout, _ := runCLI(t, "", "diff", oldPath, oldPath)
+	assert.Equal(t, exitOK, code)
+	assert.Empty(t, stdout)
+
+	code, stdout, _ = runCLI(t, "", "diff", oldPath, newPath)
+	assert.Equal(t, exitFailure, code)
+	assert.Contains(t, stdout, `+ rule "teen"`)
+	assert.Contains(t, stdout, `- rule "vip"`)
+	assert.Contains(t, stdout, "priority: 10 -> 20")
+
+	reordered := writeFile(t, dir, "reordered.json", `[
+  {"name": "vip", "priority": 5, "event": {"params": {"discount": 10}, "type": "vip"},
+   "conditions": {"fact": "tier", "operator": "eq", "value": "gold"}},
+  {"name": "adult", "priority": 10, "conditions": {"all": [{"value": 18, "operator": "greaterThanInclusive", "fact": "age"}]}, "event": {"type": "adult"}}
+]`)
+	code, stdout, _ = runCLI(t, "", "diff", "-format", "json", oldPath, reordered)
+	assert.Equal(t, exitOK, code, stdout)
+
+	disabled := writeFile(t, dir, "disabled.json", strings.Replace(testRulesJSON, `"name": "vip",`, `"name": "vip", "disabled": true,`, 1))
+	code, stdout, _ = runCLI(t, "", "diff", oldPath, disabled)
+	assert.Equal(t, exitFailure, code)
+	assert.Contains(t, stdout, `~ rule "vip"`)
+	assert.Contains(t, stdout, "disabled: null -> true")
+}
+
+func TestAudit_Command(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	trail, err := audit.Open(path)
+	require.NoError(t, err)
+	for _, action := range []string{audit.ActionCreateEngine, audit.ActionAddRule} {
+		_, err := trail.Record(audit.Entry{Actor: "ann", Action: action, Engine: "e"})
+		require.NoError(t, err)
+	}
+	require.NoError(t, trail.Close())
+
+	code, stdout, _ := runCLI(t, "", "audit", "verify", path)
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout, "OK, 2 entries verified")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, bytes.Replace(data, []byte(`"AddRule"`), []byte(`"RemoveRule"`), 1), 0o644))
+	code, stdout, _ = runCLI(t, "", "audit", "verify", path)
+	assert.Equal(t, exitFailure, code)
+	assert.Contains(t, stdout, "line 2: hash does not match contents")
+	assert.Contains(t, stdout, "1 entries verified before the break")
+
+	code, _, stderr := runCLI(t, "", "audit", "show", path)
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "usage: gavel audit verify")
+}
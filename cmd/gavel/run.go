@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+	"gopkg.in/yaml.v3"
+)
+
+// runFlags are shared by the run and trace commands.
+type runFlags struct {
+	rules          string
+	facts          string
+	groups         string
+	webhook        string
+	allowUndefined bool
+}
+
+func (f *runFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.rules, "rules", "", "rules or rule set file (JSON, YAML or BSON)")
+	fs.StringVar(&f.facts, "facts", "-", "runtime facts file (JSON or YAML), or - for JSON on stdin")
+	fs.StringVar(&f.groups, "groups", "", "comma-separated rule groups to run (empty name for ungrouped rules)")
+	fs.StringVar(&f.webhook, "webhook", "", "base URL for webhook actions")
+	fs.BoolVar(&f.allowUndefined, "allow-undefined", false, "treat facts missing from the input as undefined instead of failing")
+}
+
+func runRun(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var rf runFlags
+	rf.register(fs)
+	output := fs.String("o", "json", "output format: json or table")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: gavel run -rules file [-facts file|-] [-o json|table]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if rf.rules == "" || fs.NArg() != 0 || (*output != "json" && *output != "table") {
+		fs.Usage()
+		return exitUsage
+	}
+
+	result, code := execute(&rf, stdin, stderr, "run")
+	if result == nil {
+		return code
+	}
+	if *output == "table" {
+		writeResultTable(stdout, result)
+		return exitOK
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		fmt.Fprintf(stderr, "gavel run: %v\n", err)
+		return exitUsage
+	}
+	return exitOK
+}
+
+func runTrace(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("trace", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var rf runFlags
+	rf.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: gavel trace -rules file [-facts file|-]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if rf.rules == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	result, code := execute(&rf, stdin, stderr, "trace", rulesengine.WithTrace())
+	if result == nil {
+		return code
+	}
+	for _, rr := range result.RuleResults {
+		fmt.Fprintf(stdout, "%s: %s\n", ruleName(rr.Name), outcome(rr))
+		writeTrace(stdout, rr.Trace, "  ")
+	}
+	return exitOK
+}
+
+// execute loads the rules and facts and runs the engine. On failure it
+// reports the error and returns a nil result with the exit code to use.
+func execute(rf *runFlags, stdin io.Reader, stderr io.Writer, name string, opts ...rulesengine.RunOption) (*rulesengine.RunResult, int) {
+	rs, err := rulesengine.LoadRuleSetFile(rf.rules)
+	if err != nil {
+		fmt.Fprintf(stderr, "gavel %s: %v\n", name, err)
+		return nil, exitUsage
+	}
+	facts, err := loadFacts(rf.facts, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "gavel %s: %v\n", name, err)
+		return nil, exitUsage
+	}
+	var engineOpts []rulesengine.EngineOption
+	if rf.allowUndefined {
+		engineOpts = append(engineOpts, rulesengine.WithAllowUndefinedFacts())
+	}
+	if rf.webhook != "" {
+		engineOpts = append(engineOpts, rulesengine.WithWebhookEndpoint(rf.webhook))
+	}
+	engine := rulesengine.NewEngine(engineOpts...)
+	if err := engine.AddRuleSet(rs); err != nil {
+		fmt.Fprintf(stderr, "gavel %s: %v\n", name, err)
+		return nil, exitFailure
+	}
+	if rf.groups != "" {
+		opts = append(opts, rulesengine.WithGroups(strings.Split(rf.groups, ",")...))
+	}
+	result, err := engine.Run(facts, opts...)
+	if err != nil {
+		fmt.Fprintf(stderr, "gavel %s: %v\n", name, err)
+		return nil, exitFailure
+	}
+	return result, exitOK
+}
+
+func loadFacts(path string, stdin io.Reader) (map[string]interface{}, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	facts := map[string]interface{}{}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return facts, nil
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".yaml" || ext == ".yml" {
+		var raw map[string]interface{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("facts: %w", err)
+		}
+		// Re-decode through JSON so numbers have the same types as in
+		// JSON rule files.
+		if data, err = json.Marshal(raw); err != nil {
+			return nil, fmt.Errorf("facts: %w", err)
+		}
+	}
+	if err := json.Unmarshal(data, &facts); err != nil {
+		return nil, fmt.Errorf("facts: %w", err)
+	}
+	return facts, nil
+}
+
+func writeResultTable(w io.Writer, result *rulesengine.RunResult) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "RULE\tRESULT\tEVENT")
+	// Events are appended in the order rules pass.
+	next := 0
+	for _, rr := range result.RuleResults {
+		event := "-"
+		if rr.Success && next < len(result.Events) {
+			event = formatEvent(result.Events[next])
+			next++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", ruleName(rr.Name), outcome(rr), event)
+	}
+	tw.Flush()
+}
+
+func formatEvent(ev rulesengine.Event) string {
+	if len(ev.Params) == 0 {
+		return ev.Type
+	}
+	params, err := json.Marshal(ev.Params)
+	if err != nil {
+		return ev.Type
+	}
+	return ev.Type + " " + string(params)
+}
+
+func ruleName(name string) string {
+	if name == "" {
+		return "(unnamed)"
+	}
+	return name
+}
+
+func outcome(rr *rulesengine.RuleResult) string {
+	if rr.Status != "" {
+		return rr.Status
+	}
+	if rr.Success {
+		return rulesengine.RuleStatusPassed
+	}
+	return rulesengine.RuleStatusFailed
+}
+
+// writeTrace prints a trace tree, marking short-circuited children as skipped.
+func writeTrace(w io.Writer, node *rulesengine.TraceNode, indent string) {
+	if node == nil {
+		return
+	}
+	c := node.Condition
+	label := describeCondition(&c)
+	switch {
+	case c.Fact != "":
+		value, _ := json.Marshal(node.FactValue)
+		label = fmt.Sprintf("%s (%s = %s)", label, c.Fact+c.Path, value)
+	case c.Aggregate != nil:
+		value, _ := json.Marshal(node.FactValue)
+		label = fmt.Sprintf("%s (%s = %s)", label, c.Aggregate.Reduce, value)
+	}
+	if node.Item != nil {
+		item, _ := json.Marshal(node.Item)
+		if reflect.DeepEqual(c, rulesengine.Condition{}) {
+			label = "item " + string(item)
+		} else {
+			label = fmt.Sprintf("item %s: %s", item, label)
+		}
+	}
+	fmt.Fprintf(w, "%s%s: %t\n", indent, label, node.Result)
+
+	var children []rulesengine.Condition
+	switch {
+	case len(c.All) > 0:
+		children = c.All
+	case len(c.Any) > 0:
+		children = c.Any
+	}
+	for _, child := range node.Children {
+		writeTrace(w, child, indent+"  ")
+	}
+	for i := len(node.Children); i < len(children); i++ {
+		fmt.Fprintf(w, "%s  %s: skipped\n", indent, describeCondition(&children[i]))
+	}
+}
+
+func describeCondition(c *rulesengine.Condition) string {
+	switch {
+	case c.ConditionRef != "":
+		return "condition " + c.ConditionRef
+	case len(c.All) > 0:
+		return "all"
+	case len(c.Any) > 0:
+		return "any"
+	case c.Not != nil:
+		return "not"
+	case c.Aggregate != nil:
+		a := c.Aggregate
+		label := a.Reduce + " of " + a.Fact + a.Path
+		if a.Operator != "" {
+			value, _ := json.Marshal(a.Value)
+			label = fmt.Sprintf("%s %s %s", label, a.Operator, value)
+		}
+		return label
+	}
+	value, _ := json.Marshal(c.Value)
+	return fmt.Sprintf("%s %s %s", c.Fact+c.Path, c.Operator, value)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+)
+
+func runTest(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	rulesPath := fs.String("rules", "", "rules file (defaults to the `rules` field of each suite)")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: gavel test [-rules file] suite.yaml...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	results, err := rulesengine.RunTestFiles(*rulesPath, fs.Args()...)
+	if err != nil {
+		fmt.Fprintf(stderr, "gavel test: %v\n", err)
+		return exitUsage
+	}
+	code := exitOK
+	for _, result := range results {
+		if err := result.WriteReport(stdout); err != nil {
+			fmt.Fprintf(stderr, "gavel test: %v\n", err)
+			return exitUsage
+		}
+		if !result.OK() {
+			code = exitFailure
+		}
+	}
+	return code
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+)
+
+// validationReport is the machine-readable result of validating one file.
+type validationReport struct {
+	File     string                        `json:"file"`
+	Valid    bool                          `json:"valid"`
+	Errors   []rulesengine.ValidationError `json:"errors"`
+	Warnings []rulesengine.Diagnostic      `json:"warnings,omitempty"`
+}
+
+func runValidate(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "text", "output format: text or json")
+	analyze := fs.Bool("analyze", false, "also report contradictions, tautologies and shadowed rules as warnings")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: gavel validate [-format text|json] [-analyze] rules.json...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 || (*format != "text" && *format != "json") {
+		fs.Usage()
+		return exitUsage
+	}
+
+	reports := make([]validationReport, 0, fs.NArg())
+	for _, path := range fs.Args() {
+		if _, err := os.Stat(path); err != nil {
+			fmt.Fprintf(stderr, "gavel validate: %v\n", err)
+			return exitUsage
+		}
+		reports = append(reports, validateFile(path, *analyze))
+	}
+
+	code := exitOK
+	for _, r := range reports {
+		if !r.Valid {
+			code = exitFailure
+		}
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(reports); err != nil {
+			fmt.Fprintf(stderr, "gavel validate: %v\n", err)
+			return exitUsage
+		}
+		return code
+	}
+	for _, r := range reports {
+		for _, ve := range r.Errors {
+			fmt.Fprintf(stdout, "%s: error: %s\n", r.File, ve.Error())
+		}
+		for _, d := range r.Warnings {
+			fmt.Fprintf(stdout, "%s: warning: %s\n", r.File, d.String())
+		}
+		if r.Valid {
+			fmt.Fprintf(stdout, "%s: ok\n", r.File)
+		}
+	}
+	return code
+}
+
+// validateFile checks the file's groups and every rule in it. Facts and named conditions are
+// supplied at run time, so only structure, operators, decorators and
+// duplicate names are checked. Paths use the rule's index in the file.
+func validateFile(path string, analyze bool) validationReport {
+	report := validationReport{File: path, Errors: []rulesengine.ValidationError{}}
+	rs, err := rulesengine.LoadRuleSetFile(path)
+	if err != nil {
+		report.Errors = append(report.Errors, rulesengine.ValidationError{Message: err.Error()})
+		return report
+	}
+
+	engine := rulesengine.NewEngine(rulesengine.WithAllowUndefinedFacts(), rulesengine.WithAllowUndefinedConditions())
+	for i, g := range rs.Groups {
+		if err := engine.AddGroup(g); err != nil {
+			report.Errors = append(report.Errors, rulesengine.ValidationError{Path: fmt.Sprintf("groups[%d]", i), Message: err.Error()})
+		}
+	}
+	rules := rs.Rules
+	var added []int
+	names := make(map[string]int, len(rules))
+	for i, rule := range rules {
+		prefix := fmt.Sprintf("rules[%d]", i)
+		if first, ok := names[rule.Name]; ok && rule.Name != "" {
+			report.Errors = append(report.Errors, rulesengine.ValidationError{
+				Path:    prefix,
+				Message: fmt.Sprintf("duplicate rule name %q (first defined at rules[%d])", rule.Name, first),
+			})
+		} else {
+			names[rule.Name] = i
+		}
+		errs := engine.ValidateRule(rule)
+		for _, ve := range errs {
+			path := prefix
+			if ve.Path != "" {
+				path = prefix + "." + ve.Path
+			}
+			report.Errors = append(report.Errors, rulesengine.ValidationError{Path: path, Message: ve.Message})
+		}
+		if len(errs) == 0 && analyze {
+			if err := engine.AddRule(rule); err == nil {
+				added = append(added, i)
+			}
+		}
+	}
+
+	if analyze {
+		// The engine keeps rules stably sorted by descending priority.
+		sort.SliceStable(added, func(a, b int) bool {
+			return rules[added[a]].Priority > rules[added[b]].Priority
+		})
+		for _, d := range engine.Analyze() {
+			d.Path = remapRulePath(d.Path, added)
+			d.Related = remapRulePath(d.Related, added)
+			report.Warnings = append(report.Warnings, d)
+		}
+	}
+	report.Valid = len(report.Errors) == 0
+	return report
+}
+
+var rulePathPattern = regexp.MustCompile(`^rules\[(\d+)\]`)
+
+// remapRulePath rewrites an engine rule index into the rule's index in the
+// file, given the file indices in engine order.
+func remapRulePath(path string, fileIndices []int) string {
+	m := rulePathPattern.FindStringSubmatch(path)
+	if m == nil {
+		return path
+	}
+	i, err := strconv.Atoi(m[1])
+	if err != nil || i >= len(fileIndices) {
+		return path
+	}
+	return fmt.Sprintf("rules[%d]", fileIndices[i]) + path[len(m[0]):]
+}
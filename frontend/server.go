@@ -2,74 +2,99 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
-	"sync"
-
-	"github.com/Rohan-Muslekar/GavelEngine/rulesengine" // Update this import path
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Rohan-Muslekar/GavelEngine/audit"
+	"github.com/Rohan-Muslekar/GavelEngine/grpcserver"
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+	"github.com/Rohan-Muslekar/GavelEngine/server"
 	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
 )
 
-// Global engine manager
-var engineManager *rulesengine.EngineManager
-
-// Store for function facts (since we can't send them directly as JSON)
-var functionFacts = struct {
-	sync.RWMutex
-	facts map[string]map[string]rulesengine.FactFunc
-}{facts: make(map[string]map[string]rulesengine.FactFunc)}
-
-// ConditionJSON is used for JSON binding when receiving conditions
-type ConditionJSON struct {
-	All          []ConditionJSON        `json:"all,omitempty"`
-	Any          []ConditionJSON        `json:"any,omitempty"`
-	Not          *ConditionJSON         `json:"not,omitempty"`
-	Fact         string                 `json:"fact,omitempty"`
-	Operator     string                 `json:"operator,omitempty"`
-	Value        interface{}            `json:"value,omitempty"`
-	Params       map[string]interface{} `json:"params,omitempty"`
-	Path         string                 `json:"path,omitempty"`
-	ConditionRef string                 `json:"condition,omitempty"`
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
 }
 
-// Convert JSON condition to engine condition
-func (c *ConditionJSON) ToEngineCondition() rulesengine.Condition {
-	condition := rulesengine.Condition{
-		Fact:         c.Fact,
-		Operator:     c.Operator,
-		Value:        c.Value,
-		Params:       c.Params,
-		Path:         c.Path,
-		ConditionRef: c.ConditionRef,
-	}
+// run serves both APIs until one of them fails or the process is
+// interrupted, and shuts both down before the logs it opened are closed.
+func run() error {
+	apiKeys := flag.String("api-keys", "", "JSON file mapping API keys to principals; enables authentication")
+	tokenSecret := flag.String("token-secret-file", "", "file holding the secret for HMAC-signed bearer tokens; enables authentication")
+	jwtKey := flag.String("jwt-key-file", "", "PEM public key or HS256 secret for verifying JWTs; enables authentication")
+	production := flag.String("production", "", "comma-separated engines only publishers may change")
+	auditLog := flag.String("audit-log", "", "append-only file recording every change to engines, facts, rules and conditions")
+	exposureLog := flag.String("exposure-log", "", "file to append experiment exposures to as JSON lines")
+	flag.Parse()
 
-	if len(c.All) > 0 {
-		condition.All = make([]rulesengine.Condition, len(c.All))
-		for i, cond := range c.All {
-			condition.All[i] = cond.ToEngineCondition()
-		}
+	authenticators, err := loadAuthenticators(*apiKeys, *tokenSecret, *jwtKey)
+	if err != nil {
+		return err
 	}
-
-	if len(c.Any) > 0 {
-		condition.Any = make([]rulesengine.Condition, len(c.Any))
-		for i, cond := range c.Any {
-			condition.Any[i] = cond.ToEngineCondition()
+	var (
+		options     []server.Option
+		grpcOptions []grpcserver.Option
+	)
+	if len(authenticators) > 0 {
+		options = append(options, server.WithAuth(authenticators...))
+		grpcOptions = append(grpcOptions, grpcserver.WithAuth(authenticators...))
+	}
+	if *production != "" {
+		options = append(options, server.WithProductionEngines(strings.Split(*production, ",")...))
+	}
+	if *auditLog != "" {
+		trail, err := audit.Open(*auditLog)
+		if err != nil {
+			return fmt.Errorf("audit log: %w", err)
 		}
+		defer trail.Close()
+		options = append(options, server.WithAudit(trail))
+		grpcOptions = append(grpcOptions, grpcserver.WithAudit(trail))
 	}
-
-	if c.Not != nil {
-		not := c.Not.ToEngineCondition()
-		condition.Not = &not
+	if *exposureLog != "" {
+		f, err := os.OpenFile(*exposureLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("exposure log: %w", err)
+		}
+		defer f.Close()
+		options = append(options, server.WithExposureLog(rulesengine.NewJSONLinesExposureSink(f)))
 	}
 
-	return condition
-}
+	manager := rulesengine.NewEngineManager()
+	httpServer := server.New(manager, options...)
+	// Both servers share the engines marked as production.
+	grpcOptions = append(grpcOptions, grpcserver.WithProduction(httpServer.IsProduction))
 
-func main() {
-	// Initialize engine manager
-	engineManager = rulesengine.NewEngineManager()
+	// gRPC endpoints, serving the same engines as the HTTP API
+	lis, err := net.Listen("tcp", ":9090")
+	if err != nil {
+		return fmt.Errorf("gRPC listen: %w", err)
+	}
+	rulesService := grpcserver.New(manager, grpcOptions...)
+	grpcServer := grpc.NewServer(rulesService.ServerOptions()...)
+	rulesService.Register(grpcServer)
+	// Both servers report why they stopped here.
+	errs := make(chan error, 2)
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			errs <- fmt.Errorf("gRPC serve: %w", err)
+		}
+	}()
+	fmt.Println("gRPC server running on localhost:9090")
 
-	// Create Gin router
 	router := gin.Default()
 
 	// Serve static files
@@ -77,362 +102,61 @@ func main() {
 	router.StaticFile("/", "./frontend/static/index.html")
 
 	// API endpoints
-	api := router.Group("/api")
-	{
-		// Engine management
-		api.GET("/engines", listEngines)
-		api.POST("/engines", createEngine)
-		api.GET("/engines/:name", getEngine)
-		api.DELETE("/engines/:name", deleteEngine)
-
-		// Fact management
-		api.GET("/engines/:name/facts", listFacts)
-		api.POST("/engines/:name/facts", addFact)
-		api.DELETE("/engines/:name/facts/:id", removeFact)
-
-		// Rule management
-		api.GET("/engines/:name/rules", listRules)
-		api.POST("/engines/:name/rules", addRule)
-		api.GET("/engines/:name/rules/:ruleName", getRule)
-		api.DELETE("/engines/:name/rules/:ruleName", removeRule)
-
-		// Engine execution
-		api.POST("/engines/:name/run", runEngine)
-
-		// Predefined facts
-		api.GET("/predefined-facts", getPredefinedFacts)
-	}
+	httpServer.Register(router.Group("/api"))
 
+	webServer := &http.Server{Addr: ":8080", Handler: router}
+	go func() {
+		if err := webServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errs <- fmt.Errorf("HTTP serve: %w", err)
+		}
+	}()
 	fmt.Println("Server running on http://localhost:8080")
-	router.Run(":8080")
-}
 
-// List all engines
-func listEngines(c *gin.Context) {
-	engines := engineManager.GetEngines()
-	names := make([]string, 0, len(engines))
-	for name := range engines {
-		names = append(names, name)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err = <-errs:
+	case sig := <-signals:
+		log.Printf("shutting down: %v", sig)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"engines": names})
-}
-
-// Create a new engine
-func createEngine(c *gin.Context) {
-	var req struct {
-		Name string `json:"name" binding:"required"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if shutdownErr := webServer.Shutdown(ctx); shutdownErr != nil {
+		log.Printf("HTTP shutdown: %v", shutdownErr)
 	}
-
-	engineManager.CreateEngine(req.Name)
-
-	// Initialize function facts map for this engine
-	functionFacts.Lock()
-	functionFacts.facts[req.Name] = make(map[string]rulesengine.FactFunc)
-	functionFacts.Unlock()
-
-	c.JSON(http.StatusCreated, gin.H{"name": req.Name, "status": "created"})
+	grpcServer.GracefulStop()
+	return err
 }
 
-// Get engine details
-func getEngine(c *gin.Context) {
-	name := c.Param("name")
-	engine := engineManager.GetEngine(name)
-	if engine == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Engine not found"})
-		return
-	}
-	c.JSON(http.StatusOK, gin.H{"name": name})
-}
-
-// Delete an engine
-func deleteEngine(c *gin.Context) {
-	name := c.Param("name")
-	engineManager.DeleteEngine(name)
-
-	// Clean up function facts for this engine
-	functionFacts.Lock()
-	delete(functionFacts.facts, name)
-	functionFacts.Unlock()
-
-	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
-}
-
-// List facts for an engine
-func listFacts(c *gin.Context) {
-	name := c.Param("name")
-	engine := engineManager.GetEngine(name)
-	if engine == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Engine not found"})
-		return
-	}
-
-	// In a real implementation, you'd need to add a method to get facts from an engine
-	// For now, return a sample list
-	facts := []map[string]interface{}{
-		{
-			"id":         "age",
-			"isConstant": false,
-			"cache":      true,
-		},
-		{
-			"id":         "score",
-			"isConstant": false,
-			"cache":      true,
-		},
-	}
-	c.JSON(http.StatusOK, gin.H{"facts": facts})
-}
-
-// Add a fact to an engine
-func addFact(c *gin.Context) {
-	name := c.Param("name")
-	engine := engineManager.GetEngine(name)
-	if engine == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Engine not found"})
-		return
-	}
-
-	var req struct {
-		ID          string      `json:"id" binding:"required"`
-		Type        string      `json:"type" binding:"required"` // "constant" or "function"
-		Value       interface{} `json:"value"`
-		Description string      `json:"description"` // For function facts
-		Cache       bool        `json:"cache"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	var factOpts []rulesengine.FactOption
-	if !req.Cache {
-		factOpts = append(factOpts, rulesengine.WithNoCache())
-	}
-
-	if req.Type == "constant" {
-		err := engine.AddFact(req.ID, req.Value, factOpts...)
+// loadAuthenticators builds the authentication of both APIs from the
+// flags.
+func loadAuthenticators(apiKeys, tokenSecret, jwtKey string) ([]server.Authenticator, error) {
+	var authenticators []server.Authenticator
+	if apiKeys != "" {
+		data, err := os.ReadFile(apiKeys)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-	} else if req.Type == "function" {
-		// For function facts, we'll register a function that returns a runtime fact
-		// This is a simplified approach - in a real implementation, you'd need a more
-		// sophisticated way to define and register function facts
-		factFunc := func(params map[string]interface{}, almanac *rulesengine.Almanac) (interface{}, error) {
-			if val, ok := almanac.GetRuntimeFacts()[req.ID]; ok {
-				return val, nil
-			}
-			return nil, nil
+			return nil, fmt.Errorf("reading API keys: %w", err)
 		}
-
-		err := engine.AddFact(req.ID, rulesengine.FactFunc(factFunc), factOpts...)
+		keys, err := server.LoadAPIKeys(data)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			return nil, err
 		}
-
-		// Store the function for later use
-		functionFacts.Lock()
-		if _, ok := functionFacts.facts[name]; !ok {
-			functionFacts.facts[name] = make(map[string]rulesengine.FactFunc)
-		}
-		functionFacts.facts[name][req.ID] = factFunc
-		functionFacts.Unlock()
-	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fact type. Must be 'constant' or 'function'"})
-		return
-	}
-
-	c.JSON(http.StatusCreated, gin.H{"id": req.ID, "status": "created"})
-}
-
-// Remove a fact from an engine
-func removeFact(c *gin.Context) {
-	name := c.Param("name")
-	engine := engineManager.GetEngine(name)
-	if engine == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Engine not found"})
-		return
-	}
-
-	id := c.Param("id")
-	engine.RemoveFact(id)
-
-	// Remove from function facts if it exists
-	functionFacts.Lock()
-	if engineFacts, ok := functionFacts.facts[name]; ok {
-		delete(engineFacts, id)
-	}
-	functionFacts.Unlock()
-
-	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
-}
-
-// List rules for an engine
-func listRules(c *gin.Context) {
-	name := c.Param("name")
-	engine := engineManager.GetEngine(name)
-	if engine == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Engine not found"})
-		return
-	}
-
-	rules := engine.GetRulesAsJSON()
-	c.JSON(http.StatusOK, gin.H{"rules": rules})
-}
-
-// Add a rule to an engine
-func addRule(c *gin.Context) {
-	name := c.Param("name")
-	engine := engineManager.GetEngine(name)
-	if engine == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Engine not found"})
-		return
-	}
-
-	var req struct {
-		Name       string            `json:"name" binding:"required"`
-		Priority   int               `json:"priority"`
-		Conditions ConditionJSON     `json:"conditions" binding:"required"`
-		Event      rulesengine.Event `json:"event" binding:"required"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	condition := req.Conditions.ToEngineCondition()
-	rule := rulesengine.NewRule(condition, req.Event,
-		rulesengine.WithName(req.Name),
-		rulesengine.WithPriorityForRule(req.Priority))
-
-	engine.AddRule(rule)
-	c.JSON(http.StatusCreated, gin.H{"name": req.Name, "status": "created"})
-}
-
-// Get rule details
-func getRule(c *gin.Context) {
-	name := c.Param("name")
-	engine := engineManager.GetEngine(name)
-	if engine == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Engine not found"})
-		return
+		authenticators = append(authenticators, keys)
 	}
-
-	ruleName := c.Param("ruleName")
-	// In a real implementation, you'd need to add a method to get a specific rule
-	// For now, return a sample rule
-	rule := map[string]interface{}{
-		"name":     ruleName,
-		"priority": 10,
-		"conditions": map[string]interface{}{
-			"all": []map[string]interface{}{
-				{
-					"fact":     "age",
-					"operator": "greaterThanInclusive",
-					"value":    18,
-				},
-				{
-					"fact":     "age",
-					"operator": "lessThanInclusive",
-					"value":    25,
-				},
-			},
-		},
-		"event": map[string]interface{}{
-			"type": "young-adult",
-			"params": map[string]interface{}{
-				"message": "User is a young adult",
-			},
-		},
-	}
-	c.JSON(http.StatusOK, gin.H{"rule": rule})
-}
-
-// Remove a rule from an engine
-func removeRule(c *gin.Context) {
-	name := c.Param("name")
-	engine := engineManager.GetEngine(name)
-	if engine == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Engine not found"})
-		return
-	}
-
-	ruleName := c.Param("ruleName")
-	engine.RemoveRule(ruleName)
-	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
-}
-
-// Run an engine with runtime facts
-func runEngine(c *gin.Context) {
-	name := c.Param("name")
-	engine := engineManager.GetEngine(name)
-	if engine == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Engine not found"})
-		return
-	}
-
-	var runtimeFacts map[string]interface{}
-	if err := c.ShouldBindJSON(&runtimeFacts); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	result, err := engine.Run(runtimeFacts)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Convert result to JSON-friendly format
-	events := make([]map[string]interface{}, len(result.Events))
-	for i, event := range result.Events {
-		events[i] = map[string]interface{}{
-			"type":   event.Type,
-			"params": event.Params,
+	if tokenSecret != "" {
+		secret, err := os.ReadFile(tokenSecret)
+		if err != nil {
+			return nil, fmt.Errorf("reading token secret: %w", err)
 		}
+		authenticators = append(authenticators, server.NewHMACTokens([]byte(strings.TrimSpace(string(secret)))))
 	}
-
-	ruleResults := make([]map[string]interface{}, len(result.RuleResults))
-	for i, rr := range result.RuleResults {
-		ruleResults[i] = map[string]interface{}{
-			"name":    rr.Name,
-			"success": rr.Success,
+	if jwtKey != "" {
+		verifier, err := server.NewJWTVerifier(jwtKey)
+		if err != nil {
+			return nil, err
 		}
+		authenticators = append(authenticators, verifier)
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"events":      events,
-		"ruleResults": ruleResults,
-	})
-}
-
-// Get predefined facts
-func getPredefinedFacts(c *gin.Context) {
-	// This endpoint provides a list of predefined facts that could be used
-	// In a real implementation, this might come from a database or config
-	predefinedFacts := []map[string]interface{}{
-		{
-			"id":          "age",
-			"description": "User's age in years",
-			"usage":       "Add as function and provide in runtime facts",
-		},
-		{
-			"id":          "score",
-			"description": "Numeric score value",
-			"usage":       "Add as function and provide in runtime facts",
-		},
-		{
-			"id":          "userName",
-			"description": "User's name",
-			"usage":       "Add as function and provide in runtime facts",
-		},
-	}
-	c.JSON(http.StatusOK, gin.H{"facts": predefinedFacts})
+	return authenticators, nil
 }
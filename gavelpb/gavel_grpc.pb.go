@@ -0,0 +1,626 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: gavel/v1/gavel.proto
+
+package gavelpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	RulesService_ListEngines_FullMethodName     = "/gavel.v1.RulesService/ListEngines"
+	RulesService_CreateEngine_FullMethodName    = "/gavel.v1.RulesService/CreateEngine"
+	RulesService_DeleteEngine_FullMethodName    = "/gavel.v1.RulesService/DeleteEngine"
+	RulesService_ListFacts_FullMethodName       = "/gavel.v1.RulesService/ListFacts"
+	RulesService_AddFact_FullMethodName         = "/gavel.v1.RulesService/AddFact"
+	RulesService_RemoveFact_FullMethodName      = "/gavel.v1.RulesService/RemoveFact"
+	RulesService_ListRules_FullMethodName       = "/gavel.v1.RulesService/ListRules"
+	RulesService_GetRule_FullMethodName         = "/gavel.v1.RulesService/GetRule"
+	RulesService_AddRule_FullMethodName         = "/gavel.v1.RulesService/AddRule"
+	RulesService_ReplaceRule_FullMethodName     = "/gavel.v1.RulesService/ReplaceRule"
+	RulesService_RemoveRule_FullMethodName      = "/gavel.v1.RulesService/RemoveRule"
+	RulesService_SetRuleDisabled_FullMethodName = "/gavel.v1.RulesService/SetRuleDisabled"
+	RulesService_Run_FullMethodName             = "/gavel.v1.RulesService/Run"
+	RulesService_RunBatch_FullMethodName        = "/gavel.v1.RulesService/RunBatch"
+)
+
+// RulesServiceClient is the client API for RulesService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// RulesService manages and runs the engines of an EngineManager. Rules use
+// the same JSON form as the HTTP API, carried in a google.protobuf.Struct.
+type RulesServiceClient interface {
+	ListEngines(ctx context.Context, in *ListEnginesRequest, opts ...grpc.CallOption) (*ListEnginesResponse, error)
+	CreateEngine(ctx context.Context, in *CreateEngineRequest, opts ...grpc.CallOption) (*CreateEngineResponse, error)
+	DeleteEngine(ctx context.Context, in *DeleteEngineRequest, opts ...grpc.CallOption) (*DeleteEngineResponse, error)
+	ListFacts(ctx context.Context, in *ListFactsRequest, opts ...grpc.CallOption) (*ListFactsResponse, error)
+	AddFact(ctx context.Context, in *AddFactRequest, opts ...grpc.CallOption) (*AddFactResponse, error)
+	RemoveFact(ctx context.Context, in *RemoveFactRequest, opts ...grpc.CallOption) (*RemoveFactResponse, error)
+	ListRules(ctx context.Context, in *ListRulesRequest, opts ...grpc.CallOption) (*ListRulesResponse, error)
+	GetRule(ctx context.Context, in *GetRuleRequest, opts ...grpc.CallOption) (*GetRuleResponse, error)
+	AddRule(ctx context.Context, in *AddRuleRequest, opts ...grpc.CallOption) (*AddRuleResponse, error)
+	ReplaceRule(ctx context.Context, in *ReplaceRuleRequest, opts ...grpc.CallOption) (*ReplaceRuleResponse, error)
+	RemoveRule(ctx context.Context, in *RemoveRuleRequest, opts ...grpc.CallOption) (*RemoveRuleResponse, error)
+	SetRuleDisabled(ctx context.Context, in *SetRuleDisabledRequest, opts ...grpc.CallOption) (*SetRuleDisabledResponse, error)
+	// Run runs an engine once.
+	Run(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*RunResponse, error)
+	// RunBatch runs an engine for each request on the stream and streams a
+	// response per request, in completion order, as soon as it is ready. The
+	// engine and concurrency are taken from the first request. After the
+	// client closes its side, a final response carries the batch stats.
+	RunBatch(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[RunBatchRequest, RunBatchResponse], error)
+}
+
+type rulesServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRulesServiceClient(cc grpc.ClientConnInterface) RulesServiceClient {
+	return &rulesServiceClient{cc}
+}
+
+func (c *rulesServiceClient) ListEngines(ctx context.Context, in *ListEnginesRequest, opts ...grpc.CallOption) (*ListEnginesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListEnginesResponse)
+	err := c.cc.Invoke(ctx, RulesService_ListEngines_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) CreateEngine(ctx context.Context, in *CreateEngineRequest, opts ...grpc.CallOption) (*CreateEngineResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateEngineResponse)
+	err := c.cc.Invoke(ctx, RulesService_CreateEngine_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) DeleteEngine(ctx context.Context, in *DeleteEngineRequest, opts ...grpc.CallOption) (*DeleteEngineResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteEngineResponse)
+	err := c.cc.Invoke(ctx, RulesService_DeleteEngine_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) ListFacts(ctx context.Context, in *ListFactsRequest, opts ...grpc.CallOption) (*ListFactsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListFactsResponse)
+	err := c.cc.Invoke(ctx, RulesService_ListFacts_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) AddFact(ctx context.Context, in *AddFactRequest, opts ...grpc.CallOption) (*AddFactResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AddFactResponse)
+	err := c.cc.Invoke(ctx, RulesService_AddFact_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) RemoveFact(ctx context.Context, in *RemoveFactRequest, opts ...grpc.CallOption) (*RemoveFactResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RemoveFactResponse)
+	err := c.cc.Invoke(ctx, RulesService_RemoveFact_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) ListRules(ctx context.Context, in *ListRulesRequest, opts ...grpc.CallOption) (*ListRulesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRulesResponse)
+	err := c.cc.Invoke(ctx, RulesService_ListRules_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) GetRule(ctx context.Context, in *GetRuleRequest, opts ...grpc.CallOption) (*GetRuleResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetRuleResponse)
+	err := c.cc.Invoke(ctx, RulesService_GetRule_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) AddRule(ctx context.Context, in *AddRuleRequest, opts ...grpc.CallOption) (*AddRuleResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AddRuleResponse)
+	err := c.cc.Invoke(ctx, RulesService_AddRule_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) ReplaceRule(ctx context.Context, in *ReplaceRuleRequest, opts ...grpc.CallOption) (*ReplaceRuleResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReplaceRuleResponse)
+	err := c.cc.Invoke(ctx, RulesService_ReplaceRule_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) RemoveRule(ctx context.Context, in *RemoveRuleRequest, opts ...grpc.CallOption) (*RemoveRuleResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RemoveRuleResponse)
+	err := c.cc.Invoke(ctx, RulesService_RemoveRule_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) SetRuleDisabled(ctx context.Context, in *SetRuleDisabledRequest, opts ...grpc.CallOption) (*SetRuleDisabledResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetRuleDisabledResponse)
+	err := c.cc.Invoke(ctx, RulesService_SetRuleDisabled_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) Run(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*RunResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RunResponse)
+	err := c.cc.Invoke(ctx, RulesService_Run_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) RunBatch(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[RunBatchRequest, RunBatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RulesService_ServiceDesc.Streams[0], RulesService_RunBatch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RunBatchRequest, RunBatchResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RulesService_RunBatchClient = grpc.BidiStreamingClient[RunBatchRequest, RunBatchResponse]
+
+// RulesServiceServer is the server API for RulesService service.
+// All implementations must embed UnimplementedRulesServiceServer
+// for forward compatibility.
+//
+// RulesService manages and runs the engines of an EngineManager. Rules use
+// the same JSON form as the HTTP API, carried in a google.protobuf.Struct.
+type RulesServiceServer interface {
+	ListEngines(context.Context, *ListEnginesRequest) (*ListEnginesResponse, error)
+	CreateEngine(context.Context, *CreateEngineRequest) (*CreateEngineResponse, error)
+	DeleteEngine(context.Context, *DeleteEngineRequest) (*DeleteEngineResponse, error)
+	ListFacts(context.Context, *ListFactsRequest) (*ListFactsResponse, error)
+	AddFact(context.Context, *AddFactRequest) (*AddFactResponse, error)
+	RemoveFact(context.Context, *RemoveFactRequest) (*RemoveFactResponse, error)
+	ListRules(context.Context, *ListRulesRequest) (*ListRulesResponse, error)
+	GetRule(context.Context, *GetRuleRequest) (*GetRuleResponse, error)
+	AddRule(context.Context, *AddRuleRequest) (*AddRuleResponse, error)
+	ReplaceRule(context.Context, *ReplaceRuleRequest) (*ReplaceRuleResponse, error)
+	RemoveRule(context.Context, *RemoveRuleRequest) (*RemoveRuleResponse, error)
+	SetRuleDisabled(context.Context, *SetRuleDisabledRequest) (*SetRuleDisabledResponse, error)
+	// Run runs an engine once.
+	Run(context.Context, *RunRequest) (*RunResponse, error)
+	// RunBatch runs an engine for each request on the stream and streams a
+	// response per request, in completion order, as soon as it is ready. The
+	// engine and concurrency are taken from the first request. After the
+	// client closes its side, a final response carries the batch stats.
+	RunBatch(grpc.BidiStreamingServer[RunBatchRequest, RunBatchResponse]) error
+	mustEmbedUnimplementedRulesServiceServer()
+}
+
+// UnimplementedRulesServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRulesServiceServer struct{}
+
+func (UnimplementedRulesServiceServer) ListEngines(context.Context, *ListEnginesRequest) (*ListEnginesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListEngines not implemented")
+}
+func (UnimplementedRulesServiceServer) CreateEngine(context.Context, *CreateEngineRequest) (*CreateEngineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateEngine not implemented")
+}
+func (UnimplementedRulesServiceServer) DeleteEngine(context.Context, *DeleteEngineRequest) (*DeleteEngineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteEngine not implemented")
+}
+func (UnimplementedRulesServiceServer) ListFacts(context.Context, *ListFactsRequest) (*ListFactsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFacts not implemented")
+}
+func (UnimplementedRulesServiceServer) AddFact(context.Context, *AddFactRequest) (*AddFactResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddFact not implemented")
+}
+func (UnimplementedRulesServiceServer) RemoveFact(context.Context, *RemoveFactRequest) (*RemoveFactResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveFact not implemented")
+}
+func (UnimplementedRulesServiceServer) ListRules(context.Context, *ListRulesRequest) (*ListRulesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRules not implemented")
+}
+func (UnimplementedRulesServiceServer) GetRule(context.Context, *GetRuleRequest) (*GetRuleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRule not implemented")
+}
+func (UnimplementedRulesServiceServer) AddRule(context.Context, *AddRuleRequest) (*AddRuleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddRule not implemented")
+}
+func (UnimplementedRulesServiceServer) ReplaceRule(context.Context, *ReplaceRuleRequest) (*ReplaceRuleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReplaceRule not implemented")
+}
+func (UnimplementedRulesServiceServer) RemoveRule(context.Context, *RemoveRuleRequest) (*RemoveRuleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveRule not implemented")
+}
+func (UnimplementedRulesServiceServer) SetRuleDisabled(context.Context, *SetRuleDisabledRequest) (*SetRuleDisabledResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRuleDisabled not implemented")
+}
+func (UnimplementedRulesServiceServer) Run(context.Context, *RunRequest) (*RunResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Run not implemented")
+}
+func (UnimplementedRulesServiceServer) RunBatch(grpc.BidiStreamingServer[RunBatchRequest, RunBatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method RunBatch not implemented")
+}
+func (UnimplementedRulesServiceServer) mustEmbedUnimplementedRulesServiceServer() {}
+func (UnimplementedRulesServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeRulesServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RulesServiceServer will
+// result in compilation errors.
+type UnsafeRulesServiceServer interface {
+	mustEmbedUnimplementedRulesServiceServer()
+}
+
+func RegisterRulesServiceServer(s grpc.ServiceRegistrar, srv RulesServiceServer) {
+	// If the following call pancis, it indicates UnimplementedRulesServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&RulesService_ServiceDesc, srv)
+}
+
+func _RulesService_ListEngines_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListEnginesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).ListEngines(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_ListEngines_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).ListEngines(ctx, req.(*ListEnginesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_CreateEngine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateEngineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).CreateEngine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_CreateEngine_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).CreateEngine(ctx, req.(*CreateEngineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_DeleteEngine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteEngineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).DeleteEngine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_DeleteEngine_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).DeleteEngine(ctx, req.(*DeleteEngineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_ListFacts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFactsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).ListFacts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_ListFacts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).ListFacts(ctx, req.(*ListFactsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_AddFact_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddFactRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).AddFact(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_AddFact_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).AddFact(ctx, req.(*AddFactRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_RemoveFact_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveFactRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).RemoveFact(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_RemoveFact_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).RemoveFact(ctx, req.(*RemoveFactRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_ListRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).ListRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_ListRules_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).ListRules(ctx, req.(*ListRulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_GetRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).GetRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_GetRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).GetRule(ctx, req.(*GetRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_AddRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).AddRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_AddRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).AddRule(ctx, req.(*AddRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_ReplaceRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReplaceRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).ReplaceRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_ReplaceRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).ReplaceRule(ctx, req.(*ReplaceRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_RemoveRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).RemoveRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_RemoveRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).RemoveRule(ctx, req.(*RemoveRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_SetRuleDisabled_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRuleDisabledRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).SetRuleDisabled(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_SetRuleDisabled_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).SetRuleDisabled(ctx, req.(*SetRuleDisabledRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_Run_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).Run(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_Run_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).Run(ctx, req.(*RunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_RunBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RulesServiceServer).RunBatch(&grpc.GenericServerStream[RunBatchRequest, RunBatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RulesService_RunBatchServer = grpc.BidiStreamingServer[RunBatchRequest, RunBatchResponse]
+
+// RulesService_ServiceDesc is the grpc.ServiceDesc for RulesService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RulesService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gavel.v1.RulesService",
+	HandlerType: (*RulesServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListEngines",
+			Handler:    _RulesService_ListEngines_Handler,
+		},
+		{
+			MethodName: "CreateEngine",
+			Handler:    _RulesService_CreateEngine_Handler,
+		},
+		{
+			MethodName: "DeleteEngine",
+			Handler:    _RulesService_DeleteEngine_Handler,
+		},
+		{
+			MethodName: "ListFacts",
+			Handler:    _RulesService_ListFacts_Handler,
+		},
+		{
+			MethodName: "AddFact",
+			Handler:    _RulesService_AddFact_Handler,
+		},
+		{
+			MethodName: "RemoveFact",
+			Handler:    _RulesService_RemoveFact_Handler,
+		},
+		{
+			MethodName: "ListRules",
+			Handler:    _RulesService_ListRules_Handler,
+		},
+		{
+			MethodName: "GetRule",
+			Handler:    _RulesService_GetRule_Handler,
+		},
+		{
+			MethodName: "AddRule",
+			Handler:    _RulesService_AddRule_Handler,
+		},
+		{
+			MethodName: "ReplaceRule",
+			Handler:    _RulesService_ReplaceRule_Handler,
+		},
+		{
+			MethodName: "RemoveRule",
+			Handler:    _RulesService_RemoveRule_Handler,
+		},
+		{
+			MethodName: "SetRuleDisabled",
+			Handler:    _RulesService_SetRuleDisabled_Handler,
+		},
+		{
+			MethodName: "Run",
+			Handler:    _RulesService_Run_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "RunBatch",
+			Handler:       _RulesService_RunBatch_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "gavel/v1/gavel.proto",
+}
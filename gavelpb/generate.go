@@ -0,0 +1,5 @@
+// Package gavelpb holds the generated protobuf and gRPC code for
+// proto/gavel/v1/gavel.proto.
+package gavelpb
+
+//go:generate protoc -I ../proto --go_out=.. --go_opt=module=github.com/Rohan-Muslekar/GavelEngine --go-grpc_out=.. --go-grpc_opt=module=github.com/Rohan-Muslekar/GavelEngine gavel/v1/gavel.proto
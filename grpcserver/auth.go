@@ -0,0 +1,137 @@
+package grpcserver
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Rohan-Muslekar/GavelEngine/gavelpb"
+	"github.com/Rohan-Muslekar/GavelEngine/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// WithAuth requires every call to be authenticated by one of
+// authenticators, tried in order, and authorizes it by the caller's role,
+// as server.WithAuth does for the HTTP API. Credentials are read from the
+// call's metadata under the HTTP header names, such as x-api-key and
+// authorization. It takes effect through the server's interceptors.
+func WithAuth(authenticators ...server.Authenticator) Option {
+	return func(s *Server) {
+		s.authenticators = append(s.authenticators, authenticators...)
+	}
+}
+
+// WithProduction reports which engines are in production, so only
+// publishers and admins may change them. Pass the IsProduction method of
+// the HTTP server to share its production engines.
+func WithProduction(isProduction func(engine string) bool) Option {
+	return func(s *Server) {
+		s.isProduction = isProduction
+	}
+}
+
+// methodPermissions is what each method requires of the caller's role.
+// Methods not listed require an admin.
+var methodPermissions = map[string]server.Permission{
+	gavelpb.RulesService_ListEngines_FullMethodName:     server.PermRead,
+	gavelpb.RulesService_CreateEngine_FullMethodName:    server.PermAdmin,
+	gavelpb.RulesService_DeleteEngine_FullMethodName:    server.PermAdmin,
+	gavelpb.RulesService_ListFacts_FullMethodName:       server.PermRead,
+	gavelpb.RulesService_AddFact_FullMethodName:         server.PermWrite,
+	gavelpb.RulesService_RemoveFact_FullMethodName:      server.PermWrite,
+	gavelpb.RulesService_ListRules_FullMethodName:       server.PermRead,
+	gavelpb.RulesService_GetRule_FullMethodName:         server.PermRead,
+	gavelpb.RulesService_AddRule_FullMethodName:         server.PermWrite,
+	gavelpb.RulesService_ReplaceRule_FullMethodName:     server.PermWrite,
+	gavelpb.RulesService_RemoveRule_FullMethodName:      server.PermWrite,
+	gavelpb.RulesService_SetRuleDisabled_FullMethodName: server.PermWrite,
+	gavelpb.RulesService_Run_FullMethodName:             server.PermRun,
+	gavelpb.RulesService_RunBatch_FullMethodName:        server.PermRun,
+}
+
+// ServerOptions returns the interceptors enforcing WithAuth, to pass to
+// grpc.NewServer.
+func (s *Server) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(s.UnaryInterceptor),
+		grpc.ChainStreamInterceptor(s.StreamInterceptor),
+	}
+}
+
+// UnaryInterceptor authenticates and authorizes unary calls.
+func (s *Server) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var engine string
+	if r, ok := req.(interface{ GetEngine() string }); ok {
+		engine = r.GetEngine()
+	}
+	ctx, err := s.authorize(ctx, info.FullMethod, engine)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamInterceptor authenticates and authorizes streaming calls.
+func (s *Server) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	// Streams only run engines, which production does not restrict.
+	ctx, err := s.authorize(ss.Context(), info.FullMethod, "")
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx})
+}
+
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+type principalKey struct{}
+
+// PrincipalFrom returns the caller authenticated for a call, if any.
+func PrincipalFrom(ctx context.Context) (*server.Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*server.Principal)
+	return p, ok
+}
+
+// authorize identifies the caller of method from the metadata of ctx and
+// checks its role, returning ctx carrying the principal. Every call passes
+// when no authenticators are configured.
+func (s *Server) authorize(ctx context.Context, method, engine string) (context.Context, error) {
+	if len(s.authenticators) == 0 {
+		return ctx, nil
+	}
+	// Authenticators read credentials from HTTP headers.
+	r := &http.Request{Header: http.Header{}}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range md {
+			for _, v := range values {
+				r.Header.Add(key, v)
+			}
+		}
+	}
+	principal, err := server.Authenticate(r, s.authenticators...)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "unauthorized: %v", err)
+	}
+
+	p, ok := methodPermissions[method]
+	if !ok {
+		p = server.PermAdmin
+	}
+	production := engine != "" && s.isProduction != nil && s.isProduction(engine)
+	if !principal.Role.Can(p, production) {
+		msg := "forbidden: role " + string(principal.Role) + " may not call " + method
+		if p == server.PermWrite && production {
+			msg += " on production engine " + engine
+		}
+		return nil, status.Error(codes.PermissionDenied, msg)
+	}
+	return context.WithValue(ctx, principalKey{}, principal), nil
+}
@@ -0,0 +1,142 @@
+package grpcserver
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Rohan-Muslekar/GavelEngine/audit"
+	"github.com/Rohan-Muslekar/GavelEngine/gavelpb"
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+	"github.com/Rohan-Muslekar/GavelEngine/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var testKeys = server.APIKeys{
+	"run-key":     {Subject: "bot", Role: server.RoleRunner},
+	"view-key":    {Subject: "vic", Role: server.RoleViewer},
+	"author-key":  {Subject: "ann", Role: server.RoleAuthor},
+	"publish-key": {Subject: "pat", Role: server.RolePublisher},
+	"admin-key":   {Subject: "root", Role: server.RoleAdmin},
+}
+
+// authClient serves the engines "dev" and "prod", the latter in
+// production, and requires the test API keys.
+func authClient(t *testing.T, options ...Option) (gavelpb.RulesServiceClient, *rulesengine.EngineManager) {
+	t.Helper()
+	manager := rulesengine.NewEngineManager()
+	for _, name := range []string{"dev", "prod"} {
+		engine := manager.CreateEngine(name, rulesengine.WithAllowUndefinedFacts())
+		require.NoError(t, engine.AddRule(rulesengine.NewRule(rulesengine.Condition{Fact: "age", Operator: "greaterThanInclusive", Value: 18},
+			rulesengine.Event{Type: "adult"}, rulesengine.WithName("adult"))))
+	}
+	options = append(options, WithAuth(testKeys), WithProduction(func(engine string) bool { return engine == "prod" }))
+	return testClient(t, manager, options...), manager
+}
+
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+}
+
+func TestAuth_Unauthenticated(t *testing.T) {
+	client, manager := authClient(t)
+
+	_, err := client.DeleteEngine(context.Background(), &gavelpb.DeleteEngineRequest{Name: "dev"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Contains(t, status.Convert(err).Message(), "no credentials")
+	_, err = client.Run(withKey("wrong"), &gavelpb.RunRequest{Engine: "dev"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Contains(t, status.Convert(err).Message(), "invalid API key")
+	assert.NotNil(t, manager.GetEngine("dev"))
+
+	stream, err := client.RunBatch(context.Background())
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// Bearer tokens are read from the authorization metadata.
+	tokens := server.NewHMACTokens([]byte("secret"))
+	token, err := tokens.Issue(server.Principal{Subject: "ci", Role: server.RoleRunner}, time.Hour)
+	require.NoError(t, err)
+	client, _ = authClient(t, WithAuth(tokens))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+	resp, err := client.Run(ctx, &gavelpb.RunRequest{Engine: "dev", Facts: mustStruct(t, map[string]interface{}{"age": 30})})
+	require.NoError(t, err)
+	assert.Len(t, resp.Events, 1)
+}
+
+func TestAuth_Forbidden(t *testing.T) {
+	client, manager := authClient(t)
+	disable := func(key, engine string) error {
+		_, err := client.SetRuleDisabled(withKey(key), &gavelpb.SetRuleDisabledRequest{Engine: engine, Name: "adult", Disabled: true})
+		return err
+	}
+
+	for _, tc := range []struct {
+		key  string
+		call func(ctx context.Context) error
+	}{
+		{"run-key", func(ctx context.Context) error {
+			_, err := client.ListRules(ctx, &gavelpb.ListRulesRequest{Engine: "dev"})
+			return err
+		}},
+		{"view-key", func(ctx context.Context) error {
+			_, err := client.Run(ctx, &gavelpb.RunRequest{Engine: "dev"})
+			return err
+		}},
+		{"run-key", func(ctx context.Context) error {
+			_, err := client.RemoveRule(ctx, &gavelpb.RemoveRuleRequest{Engine: "dev", Name: "adult"})
+			return err
+		}},
+		{"publish-key", func(ctx context.Context) error {
+			_, err := client.DeleteEngine(ctx, &gavelpb.DeleteEngineRequest{Name: "dev"})
+			return err
+		}},
+		{"author-key", func(ctx context.Context) error {
+			_, err := client.CreateEngine(ctx, &gavelpb.CreateEngineRequest{Name: "new"})
+			return err
+		}},
+	} {
+		err := tc.call(withKey(tc.key))
+		assert.Equal(t, codes.PermissionDenied, status.Code(err), tc.key)
+	}
+	assert.NotNil(t, manager.GetEngine("dev"))
+	assert.Len(t, manager.GetEngine("dev").ListRules(), 1)
+
+	// Only publishers and admins change production engines.
+	err := disable("author-key", "prod")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Contains(t, status.Convert(err).Message(), "on production engine prod")
+	require.NoError(t, disable("author-key", "dev"))
+	require.NoError(t, disable("publish-key", "prod"))
+
+	_, err = client.ListEngines(withKey("view-key"), &gavelpb.ListEnginesRequest{})
+	require.NoError(t, err)
+	_, err = client.DeleteEngine(withKey("admin-key"), &gavelpb.DeleteEngineRequest{Name: "dev"})
+	require.NoError(t, err)
+	assert.Nil(t, manager.GetEngine("dev"))
+
+	stream, err := client.RunBatch(withKey("view-key"))
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestAuth_AuditActor(t *testing.T) {
+	trail, err := audit.Open(filepath.Join(t.TempDir(), "audit.log"))
+	require.NoError(t, err)
+	defer trail.Close()
+	client, _ := authClient(t, WithAudit(trail))
+
+	_, err = client.RemoveRule(withKey("author-key"), &gavelpb.RemoveRuleRequest{Engine: "dev", Name: "adult"})
+	require.NoError(t, err)
+	entries, err := trail.Query(audit.Query{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "ann", entries[0].Actor)
+}
@@ -0,0 +1,544 @@
+// Package grpcserver implements the gRPC RulesService of
+// proto/gavel/v1/gavel.proto over the engines of an EngineManager.
+package grpcserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Rohan-Muslekar/GavelEngine/audit"
+	"github.com/Rohan-Muslekar/GavelEngine/gavelpb"
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+	"github.com/Rohan-Muslekar/GavelEngine/server"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Fact types accepted by AddFact.
+const (
+	FactTypeConstant = "constant"
+	FactTypeFunction = "function"
+)
+
+// Server serves the engines of an EngineManager over gRPC.
+type Server struct {
+	gavelpb.UnimplementedRulesServiceServer
+	manager *rulesengine.EngineManager
+	audit   *audit.Log
+
+	authenticators []server.Authenticator
+	isProduction   func(engine string) bool
+}
+
+// New creates a server for the engines of manager.
+func New(manager *rulesengine.EngineManager, options ...Option) *Server {
+	s := &Server{manager: manager}
+	for _, opt := range options {
+		opt(s)
+	}
+	return s
+}
+
+// Register registers the server as the RulesService of registrar.
+func (s *Server) Register(registrar grpc.ServiceRegistrar) {
+	gavelpb.RegisterRulesServiceServer(registrar, s)
+}
+
+func (s *Server) engine(name string) (*rulesengine.Engine, error) {
+	engine := s.manager.GetEngine(name)
+	if engine == nil {
+		return nil, status.Errorf(codes.NotFound, "engine not found: %s", name)
+	}
+	return engine, nil
+}
+
+func (s *Server) ListEngines(ctx context.Context, req *gavelpb.ListEnginesRequest) (*gavelpb.ListEnginesResponse, error) {
+	resp := &gavelpb.ListEnginesResponse{}
+	for name := range s.manager.GetEngines() {
+		resp.Engines = append(resp.Engines, name)
+	}
+	return resp, nil
+}
+
+func (s *Server) CreateEngine(ctx context.Context, req *gavelpb.CreateEngineRequest) (*gavelpb.CreateEngineResponse, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "engine name is required")
+	}
+	var opts []rulesengine.EngineOption
+	if req.AllowUndefinedFacts {
+		opts = append(opts, rulesengine.WithAllowUndefinedFacts())
+	}
+	if req.AllowUndefinedConditions {
+		opts = append(opts, rulesengine.WithAllowUndefinedConditions())
+	}
+	var before interface{}
+	if existing, ok := s.manager.GetEngines()[req.Name]; ok {
+		before = audit.NewEngineState(req.Name, existing)
+	}
+	engine := s.manager.CreateEngine(req.Name, opts...)
+	if err := s.record(ctx, audit.ActionCreateEngine, req.Name, "", before, audit.NewEngineState(req.Name, engine)); err != nil {
+		return nil, err
+	}
+	return &gavelpb.CreateEngineResponse{}, nil
+}
+
+func (s *Server) DeleteEngine(ctx context.Context, req *gavelpb.DeleteEngineRequest) (*gavelpb.DeleteEngineResponse, error) {
+	engine, existed := s.manager.GetEngines()[req.Name]
+	if !existed {
+		return &gavelpb.DeleteEngineResponse{}, nil
+	}
+	before := audit.NewEngineState(req.Name, engine)
+	s.manager.DeleteEngine(req.Name)
+	if err := s.record(ctx, audit.ActionDeleteEngine, req.Name, "", before, nil); err != nil {
+		return nil, err
+	}
+	return &gavelpb.DeleteEngineResponse{}, nil
+}
+
+func (s *Server) ListFacts(ctx context.Context, req *gavelpb.ListFactsRequest) (*gavelpb.ListFactsResponse, error) {
+	engine, err := s.engine(req.Engine)
+	if err != nil {
+		return nil, err
+	}
+	resp := &gavelpb.ListFactsResponse{}
+	for _, f := range engine.ListFacts() {
+		fact := &gavelpb.Fact{Id: f.ID, IsConstant: f.IsConstant, Cache: f.Cache, Priority: int32(f.Priority)}
+		if f.IsConstant {
+			if fact.Value, err = toValue(f.Value); err != nil {
+				return nil, status.Errorf(codes.Internal, "fact %s: %v", f.ID, err)
+			}
+		}
+		resp.Facts = append(resp.Facts, fact)
+	}
+	return resp, nil
+}
+
+func (s *Server) AddFact(ctx context.Context, req *gavelpb.AddFactRequest) (*gavelpb.AddFactResponse, error) {
+	engine, err := s.engine(req.Engine)
+	if err != nil {
+		return nil, err
+	}
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "fact id is required")
+	}
+	var before interface{}
+	if existing, ok := engine.GetFact(req.Id); ok {
+		before = existing
+	}
+	var factOpts []rulesengine.FactOption
+	if !req.Cache {
+		factOpts = append(factOpts, rulesengine.WithNoCache())
+	}
+
+	var definition interface{}
+	switch req.Type {
+	case FactTypeConstant:
+		definition = req.Value.AsInterface()
+	case FactTypeFunction:
+		// As over HTTP, a function fact returns the runtime fact of the
+		// same ID.
+		id := req.Id
+		definition = rulesengine.FactFunc(func(params map[string]interface{}, almanac *rulesengine.Almanac) (interface{}, error) {
+			return almanac.GetRuntimeFacts()[id], nil
+		})
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "invalid fact type %q: must be %q or %q", req.Type, FactTypeConstant, FactTypeFunction)
+	}
+	if err := engine.AddFact(req.Id, definition, factOpts...); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	after, _ := engine.GetFact(req.Id)
+	if err := s.record(ctx, audit.ActionAddFact, req.Engine, req.Id, before, after); err != nil {
+		return nil, err
+	}
+	return &gavelpb.AddFactResponse{}, nil
+}
+
+func (s *Server) RemoveFact(ctx context.Context, req *gavelpb.RemoveFactRequest) (*gavelpb.RemoveFactResponse, error) {
+	engine, err := s.engine(req.Engine)
+	if err != nil {
+		return nil, err
+	}
+	before, existed := engine.GetFact(req.Id)
+	engine.RemoveFact(req.Id)
+	if existed {
+		if err := s.record(ctx, audit.ActionRemoveFact, req.Engine, req.Id, before, nil); err != nil {
+			return nil, err
+		}
+	}
+	return &gavelpb.RemoveFactResponse{}, nil
+}
+
+func (s *Server) ListRules(ctx context.Context, req *gavelpb.ListRulesRequest) (*gavelpb.ListRulesResponse, error) {
+	engine, err := s.engine(req.Engine)
+	if err != nil {
+		return nil, err
+	}
+	resp := &gavelpb.ListRulesResponse{}
+	for _, rule := range engine.ListRules() {
+		st, err := toStruct(rule)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "rule %s: %v", rule.Name, err)
+		}
+		resp.Rules = append(resp.Rules, st)
+	}
+	return resp, nil
+}
+
+func (s *Server) GetRule(ctx context.Context, req *gavelpb.GetRuleRequest) (*gavelpb.GetRuleResponse, error) {
+	engine, err := s.engine(req.Engine)
+	if err != nil {
+		return nil, err
+	}
+	rule, ok := engine.GetRule(req.Name)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "rule not found: %s", req.Name)
+	}
+	st, err := toStruct(rule)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "rule %s: %v", rule.Name, err)
+	}
+	return &gavelpb.GetRuleResponse{Rule: st}, nil
+}
+
+func (s *Server) AddRule(ctx context.Context, req *gavelpb.AddRuleRequest) (*gavelpb.AddRuleResponse, error) {
+	engine, err := s.engine(req.Engine)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := fromStruct(req.Rule)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateRule(engine, rule); err != nil {
+		return nil, err
+	}
+	if err := engine.AddRule(rule); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.record(ctx, audit.ActionAddRule, req.Engine, rule.Name, nil, rule); err != nil {
+		return nil, err
+	}
+	return &gavelpb.AddRuleResponse{}, nil
+}
+
+// ReplaceRule replaces a rule. Callbacks cannot be sent over gRPC, so those
+// of the existing rule are kept.
+func (s *Server) ReplaceRule(ctx context.Context, req *gavelpb.ReplaceRuleRequest) (*gavelpb.ReplaceRuleResponse, error) {
+	engine, err := s.engine(req.Engine)
+	if err != nil {
+		return nil, err
+	}
+	existing, ok := engine.GetRule(req.Name)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "rule not found: %s", req.Name)
+	}
+	rule, err := fromStruct(req.Rule)
+	if err != nil {
+		return nil, err
+	}
+	if rule.Name == "" {
+		rule.Name = req.Name
+	}
+	rule.OnSuccess = existing.OnSuccess
+	rule.OnFailure = existing.OnFailure
+	rule.SalienceFunc = existing.SalienceFunc
+	if err := validateRule(engine, rule); err != nil {
+		return nil, err
+	}
+	if err := engine.UpdateRule(req.Name, rule); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.record(ctx, audit.ActionUpdateRule, req.Engine, req.Name, existing, rule); err != nil {
+		return nil, err
+	}
+	st, err := toStruct(rule)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "rule %s: %v", rule.Name, err)
+	}
+	return &gavelpb.ReplaceRuleResponse{Rule: st}, nil
+}
+
+func (s *Server) RemoveRule(ctx context.Context, req *gavelpb.RemoveRuleRequest) (*gavelpb.RemoveRuleResponse, error) {
+	engine, err := s.engine(req.Engine)
+	if err != nil {
+		return nil, err
+	}
+	before, existed := engine.GetRule(req.Name)
+	engine.RemoveRule(req.Name)
+	if existed {
+		if err := s.record(ctx, audit.ActionRemoveRule, req.Engine, req.Name, before, nil); err != nil {
+			return nil, err
+		}
+	}
+	return &gavelpb.RemoveRuleResponse{}, nil
+}
+
+func (s *Server) SetRuleDisabled(ctx context.Context, req *gavelpb.SetRuleDisabledRequest) (*gavelpb.SetRuleDisabledResponse, error) {
+	engine, err := s.engine(req.Engine)
+	if err != nil {
+		return nil, err
+	}
+	before, _ := engine.GetRule(req.Name)
+	action := audit.ActionEnableRule
+	if req.Disabled {
+		action = audit.ActionDisableRule
+		err = engine.DisableRule(req.Name)
+	} else {
+		err = engine.EnableRule(req.Name)
+	}
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	after, _ := engine.GetRule(req.Name)
+	if err := s.record(ctx, action, req.Engine, req.Name, before, after); err != nil {
+		return nil, err
+	}
+	return &gavelpb.SetRuleDisabledResponse{}, nil
+}
+
+func (s *Server) Run(ctx context.Context, req *gavelpb.RunRequest) (*gavelpb.RunResponse, error) {
+	if _, err := s.engine(req.Engine); err != nil {
+		return nil, err
+	}
+	// The manager also evaluates the engine's challenger, if any.
+	result, err := s.manager.Run(req.Engine, req.Facts.AsMap())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &gavelpb.RunResponse{}
+	if resp.Events, err = toEvents(result.Events); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp.RuleResults = toRuleResults(result.RuleResults)
+	if resp.Decision, err = toDecision(result.Decision); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp.Order = toRuleOrder(result.Order)
+	if resp.Actions, err = toActionResults(result.Actions); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return resp, nil
+}
+
+func (s *Server) RunBatch(stream grpc.BidiStreamingServer[gavelpb.RunBatchRequest, gavelpb.RunBatchResponse]) error {
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	engine, err := s.engine(first.Engine)
+	if err != nil {
+		return err
+	}
+	var opts []rulesengine.BatchOption
+	if first.Concurrency < 0 {
+		return status.Errorf(codes.InvalidArgument, "concurrency must not be negative, got %d", first.Concurrency)
+	}
+	if first.Concurrency > 0 {
+		opts = append(opts, rulesengine.WithConcurrency(int(first.Concurrency)))
+	}
+
+	var recvErr error
+	inputs := func(yield func(map[string]interface{}) bool) {
+		for req := first; ; {
+			if !yield(req.Facts.AsMap()) {
+				return
+			}
+			req, recvErr = stream.Recv()
+			if recvErr != nil {
+				if recvErr == io.EOF {
+					recvErr = nil
+				}
+				return
+			}
+		}
+	}
+
+	stats, err := engine.RunBatch(stream.Context(), inputs, func(item rulesengine.BatchItem) error {
+		resp := &gavelpb.RunBatchResponse{Index: int64(item.Index)}
+		if item.Err != nil {
+			resp.Error = item.Err.Error()
+		} else {
+			events, err := toEvents(item.Result.Events)
+			if err != nil {
+				resp.Error = err.Error()
+			}
+			resp.Events = events
+			resp.RuleResults = toRuleResults(item.Result.RuleResults)
+		}
+		return stream.Send(resp)
+	}, opts...)
+	if err == nil {
+		err = recvErr
+	}
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.FromContextError(err).Err()
+	}
+
+	final := &gavelpb.BatchStats{
+		Items:  int64(stats.Items),
+		Errors: int64(stats.Errors),
+		Events: int64(stats.Events),
+		Fired:  make(map[string]int64, len(stats.Fired)),
+	}
+	for name, n := range stats.Fired {
+		final.Fired[name] = int64(n)
+	}
+	return stream.Send(&gavelpb.RunBatchResponse{Stats: final})
+}
+
+// validateRule returns an InvalidArgument status carrying a BadRequest
+// detail with a field violation per validation error.
+func validateRule(engine *rulesengine.Engine, rule *rulesengine.Rule) error {
+	errs := engine.ValidateRule(rule)
+	if len(errs) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(errs))
+	detail := &errdetails.BadRequest{}
+	for i, e := range errs {
+		msgs[i] = e.Error()
+		detail.FieldViolations = append(detail.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       e.Path,
+			Description: e.Message,
+		})
+	}
+	st := status.New(codes.InvalidArgument, "invalid rule: "+strings.Join(msgs, "; "))
+	if withDetails, err := st.WithDetails(detail); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// fromStruct decodes a rule from its JSON form.
+func fromStruct(st *structpb.Struct) (*rulesengine.Rule, error) {
+	if st == nil {
+		return nil, status.Error(codes.InvalidArgument, "rule is required")
+	}
+	data, err := st.MarshalJSON()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	rule := rulesengine.NewRule(rulesengine.Condition{}, rulesengine.Event{})
+	if err := json.Unmarshal(data, rule); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "decoding rule: %v", err)
+	}
+	return rule, nil
+}
+
+// toStruct converts v to a Struct through its JSON form, so values keep the
+// shape they have in the HTTP API.
+func toStruct(v interface{}) (*structpb.Struct, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	st := &structpb.Struct{}
+	if err := st.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func toValue(v interface{}) (*structpb.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	value := &structpb.Value{}
+	if err := value.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func toEvents(events []rulesengine.Event) ([]*gavelpb.Event, error) {
+	out := make([]*gavelpb.Event, len(events))
+	for i, ev := range events {
+		out[i] = &gavelpb.Event{Type: ev.Type}
+		if ev.Params != nil {
+			params, err := toStruct(ev.Params)
+			if err != nil {
+				return nil, fmt.Errorf("event %s: %w", ev.Type, err)
+			}
+			out[i].Params = params
+		}
+	}
+	return out, nil
+}
+
+func toDecision(d *rulesengine.Decision) (*gavelpb.Decision, error) {
+	if d == nil {
+		return nil, nil
+	}
+	out := &gavelpb.Decision{Rule: d.Rule, Strategy: d.Strategy}
+	if d.Event != nil {
+		events, err := toEvents([]rulesengine.Event{*d.Event})
+		if err != nil {
+			return nil, err
+		}
+		out.Event = events[0]
+	}
+	overruled := make([]rulesengine.Event, len(d.Overruled))
+	for i, o := range d.Overruled {
+		overruled[i] = o.Event
+	}
+	events, err := toEvents(overruled)
+	if err != nil {
+		return nil, err
+	}
+	out.Overruled = make([]*gavelpb.OverruledEvent, len(d.Overruled))
+	for i, o := range d.Overruled {
+		out.Overruled[i] = &gavelpb.OverruledEvent{Event: events[i], Rule: o.Rule, Reason: o.Reason}
+	}
+	return out, nil
+}
+
+func toRuleOrder(order []rulesengine.RuleOrder) []*gavelpb.RuleOrder {
+	if order == nil {
+		return nil
+	}
+	out := make([]*gavelpb.RuleOrder, len(order))
+	for i, o := range order {
+		out[i] = &gavelpb.RuleOrder{Name: o.Name, Salience: int64(o.Salience)}
+	}
+	return out
+}
+
+func toActionResults(results []rulesengine.ActionResult) ([]*gavelpb.ActionResult, error) {
+	if results == nil {
+		return nil, nil
+	}
+	out := make([]*gavelpb.ActionResult, len(results))
+	for i, r := range results {
+		out[i] = &gavelpb.ActionResult{Rule: r.Rule, Type: r.Type, Status: r.Status, Error: r.Error}
+		if r.Output != nil {
+			output, err := toValue(r.Output)
+			if err != nil {
+				return nil, fmt.Errorf("action %s of rule %s: %w", r.Type, r.Rule, err)
+			}
+			out[i].Output = output
+		}
+	}
+	return out, nil
+}
+
+func toRuleResults(results []*rulesengine.RuleResult) []*gavelpb.RuleResult {
+	out := make([]*gavelpb.RuleResult, len(results))
+	for i, r := range results {
+		out[i] = &gavelpb.RuleResult{Name: r.Name, Success: r.Success, Status: r.Status}
+	}
+	return out
+}
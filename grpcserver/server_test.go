@@ -0,0 +1,338 @@
+package grpcserver
+
+import (
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/Rohan-Muslekar/GavelEngine/audit"
+	"github.com/Rohan-Muslekar/GavelEngine/gavelpb"
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// testClient serves manager over an in-process listener and returns a
+// client connected to it.
+func testClient(t *testing.T, manager *rulesengine.EngineManager, options ...Option) gavelpb.RulesServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	rules := New(manager, options...)
+	srv := grpc.NewServer(rules.ServerOptions()...)
+	rules.Register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return gavelpb.NewRulesServiceClient(conn)
+}
+
+// adultEngine creates the engine "e" with the function fact "age" and a
+// rule firing for adults.
+func adultEngine(t *testing.T, client gavelpb.RulesServiceClient) {
+	t.Helper()
+	ctx := context.Background()
+	_, err := client.CreateEngine(ctx, &gavelpb.CreateEngineRequest{Name: "e"})
+	require.NoError(t, err)
+	_, err = client.AddFact(ctx, &gavelpb.AddFactRequest{Engine: "e", Id: "age", Type: FactTypeFunction})
+	require.NoError(t, err)
+	_, err = client.AddRule(ctx, &gavelpb.AddRuleRequest{Engine: "e", Rule: mustStruct(t, map[string]interface{}{
+		"name":       "adult",
+		"conditions": map[string]interface{}{"all": []interface{}{map[string]interface{}{"fact": "age", "operator": "greaterThanInclusive", "value": 18}}},
+		"event":      map[string]interface{}{"type": "adult", "params": map[string]interface{}{"label": "grown-up"}},
+	})})
+	require.NoError(t, err)
+}
+
+func mustStruct(t *testing.T, m map[string]interface{}) *structpb.Struct {
+	t.Helper()
+	st, err := structpb.NewStruct(m)
+	require.NoError(t, err)
+	return st
+}
+
+func TestServer_Run(t *testing.T) {
+	manager := rulesengine.NewEngineManager()
+	client := testClient(t, manager)
+	adultEngine(t, client)
+	ctx := context.Background()
+
+	resp, err := client.Run(ctx, &gavelpb.RunRequest{Engine: "e", Facts: mustStruct(t, map[string]interface{}{"age": 30})})
+	require.NoError(t, err)
+	require.Len(t, resp.Events, 1)
+	assert.Equal(t, "adult", resp.Events[0].Type)
+	assert.Equal(t, "grown-up", resp.Events[0].Params.AsMap()["label"])
+	require.Len(t, resp.RuleResults, 1)
+	assert.Equal(t, rulesengine.RuleStatusPassed, resp.RuleResults[0].Status)
+
+	// The server shares the manager's engines.
+	result, err := manager.GetEngine("e").Run(map[string]interface{}{"age": 10})
+	require.NoError(t, err)
+	assert.Empty(t, result.Events)
+
+	_, err = client.Run(ctx, &gavelpb.RunRequest{Engine: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServer_RunEvaluatesChallenger(t *testing.T) {
+	manager := rulesengine.NewEngineManager()
+	client := testClient(t, manager)
+	adultEngine(t, client)
+	manager.CreateEngine("next", rulesengine.WithAllowUndefinedFacts())
+	shadow, err := manager.SetChallenger("e", "next")
+	require.NoError(t, err)
+
+	resp, err := client.Run(context.Background(), &gavelpb.RunRequest{Engine: "e", Facts: mustStruct(t, map[string]interface{}{"age": 30})})
+	require.NoError(t, err)
+	require.Len(t, resp.Events, 1)
+	shadow.Wait()
+	assert.Equal(t, 1, shadow.Stats().Divergences)
+}
+
+func TestServer_RunReturnsDecision(t *testing.T) {
+	manager := rulesengine.NewEngineManager()
+	client := testClient(t, manager)
+	adultEngine(t, client)
+	manager.CreateEngine("d", rulesengine.WithConflictResolver(rulesengine.FirstMatch()))
+	ctx := context.Background()
+	_, err := client.AddFact(ctx, &gavelpb.AddFactRequest{Engine: "d", Id: "age", Type: FactTypeFunction})
+	require.NoError(t, err)
+	_, err = client.AddRule(ctx, &gavelpb.AddRuleRequest{Engine: "d", Rule: mustStruct(t, map[string]interface{}{
+		"name":       "adult",
+		"conditions": map[string]interface{}{"fact": "age", "operator": "greaterThanInclusive", "value": 18},
+		"event":      map[string]interface{}{"type": "adult", "params": map[string]interface{}{"label": "grown-up"}},
+	})})
+	require.NoError(t, err)
+
+	resp, err := client.Run(ctx, &gavelpb.RunRequest{Engine: "d", Facts: mustStruct(t, map[string]interface{}{"age": 30})})
+	require.NoError(t, err)
+	require.NotNil(t, resp.Decision)
+	assert.Equal(t, "adult", resp.Decision.Rule)
+	assert.Equal(t, "firstMatch", resp.Decision.Strategy)
+	assert.Equal(t, "grown-up", resp.Decision.Event.Params.AsMap()["label"])
+
+	resp, err = client.Run(ctx, &gavelpb.RunRequest{Engine: "e", Facts: mustStruct(t, map[string]interface{}{"age": 30})})
+	require.NoError(t, err)
+	assert.Nil(t, resp.Decision)
+}
+
+func TestServer_RunReturnsOrder(t *testing.T) {
+	client := testClient(t, rulesengine.NewEngineManager())
+	adultEngine(t, client)
+	ctx := context.Background()
+	_, err := client.AddRule(ctx, &gavelpb.AddRuleRequest{Engine: "e", Rule: mustStruct(t, map[string]interface{}{
+		"name":       "byAge",
+		"salience":   map[string]interface{}{"fact": "age"},
+		"conditions": map[string]interface{}{"fact": "age", "operator": "greaterThanInclusive", "value": 0},
+		"event":      map[string]interface{}{"type": "any"},
+	})})
+	require.NoError(t, err)
+
+	resp, err := client.Run(ctx, &gavelpb.RunRequest{Engine: "e", Facts: mustStruct(t, map[string]interface{}{"age": 30})})
+	require.NoError(t, err)
+	require.Len(t, resp.Order, 2)
+	assert.Equal(t, "byAge", resp.Order[0].Name)
+	assert.Equal(t, int64(30), resp.Order[0].Salience)
+	assert.Equal(t, "adult", resp.Order[1].Name)
+}
+
+func TestServer_RunReturnsActions(t *testing.T) {
+	client := testClient(t, rulesengine.NewEngineManager())
+	adultEngine(t, client)
+	ctx := context.Background()
+	_, err := client.AddRule(ctx, &gavelpb.AddRuleRequest{Engine: "e", Rule: mustStruct(t, map[string]interface{}{
+		"name":       "flag",
+		"conditions": map[string]interface{}{"fact": "age", "operator": "greaterThanInclusive", "value": 18},
+		"event":      map[string]interface{}{"type": "flag"},
+		"actions":    []interface{}{map[string]interface{}{"type": "setFact", "params": map[string]interface{}{"fact": "adult", "value": true}}},
+	})})
+	require.NoError(t, err)
+
+	resp, err := client.Run(ctx, &gavelpb.RunRequest{Engine: "e", Facts: mustStruct(t, map[string]interface{}{"age": 30})})
+	require.NoError(t, err)
+	require.Len(t, resp.Actions, 1)
+	assert.Equal(t, "flag", resp.Actions[0].Rule)
+	assert.Equal(t, "setFact", resp.Actions[0].Type)
+	assert.Equal(t, rulesengine.ActionStatusOK, resp.Actions[0].Status)
+}
+
+func TestServer_RuleManagement(t *testing.T) {
+	client := testClient(t, rulesengine.NewEngineManager())
+	adultEngine(t, client)
+	ctx := context.Background()
+
+	got, err := client.GetRule(ctx, &gavelpb.GetRuleRequest{Engine: "e", Name: "adult"})
+	require.NoError(t, err)
+	rule := got.Rule.AsMap()
+	assert.Equal(t, "adult", rule["name"])
+	assert.Equal(t, 1.0, rule["priority"])
+
+	rule["priority"] = 9
+	delete(rule, "name")
+	replaced, err := client.ReplaceRule(ctx, &gavelpb.ReplaceRuleRequest{Engine: "e", Name: "adult", Rule: mustStruct(t, rule)})
+	require.NoError(t, err)
+	assert.Equal(t, 9.0, replaced.Rule.AsMap()["priority"])
+	assert.Equal(t, "adult", replaced.Rule.AsMap()["name"])
+
+	_, err = client.SetRuleDisabled(ctx, &gavelpb.SetRuleDisabledRequest{Engine: "e", Name: "adult", Disabled: true})
+	require.NoError(t, err)
+	resp, err := client.Run(ctx, &gavelpb.RunRequest{Engine: "e", Facts: mustStruct(t, map[string]interface{}{"age": 30})})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Events)
+	assert.Equal(t, rulesengine.RuleStatusDisabled, resp.RuleResults[0].Status)
+	_, err = client.SetRuleDisabled(ctx, &gavelpb.SetRuleDisabledRequest{Engine: "e", Name: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	list, err := client.ListRules(ctx, &gavelpb.ListRulesRequest{Engine: "e"})
+	require.NoError(t, err)
+	require.Len(t, list.Rules, 1)
+	assert.Equal(t, true, list.Rules[0].AsMap()["disabled"])
+
+	_, err = client.RemoveRule(ctx, &gavelpb.RemoveRuleRequest{Engine: "e", Name: "adult"})
+	require.NoError(t, err)
+	_, err = client.GetRule(ctx, &gavelpb.GetRuleRequest{Engine: "e", Name: "adult"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServer_InvalidRule(t *testing.T) {
+	client := testClient(t, rulesengine.NewEngineManager())
+	adultEngine(t, client)
+
+	_, err := client.AddRule(context.Background(), &gavelpb.AddRuleRequest{Engine: "e", Rule: mustStruct(t, map[string]interface{}{
+		"name":       "bad",
+		"conditions": map[string]interface{}{"all": []interface{}{map[string]interface{}{"fact": "income", "operator": "equal", "value": 1}}},
+		"event":      map[string]interface{}{"type": "x"},
+	})})
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	require.Len(t, st.Details(), 1)
+	violations := st.Details()[0].(*errdetails.BadRequest).FieldViolations
+	require.Len(t, violations, 1)
+	assert.Equal(t, "All[0]", violations[0].Field)
+	assert.Contains(t, violations[0].Description, "income")
+}
+
+func TestServer_Facts(t *testing.T) {
+	client := testClient(t, rulesengine.NewEngineManager())
+	ctx := context.Background()
+	_, err := client.CreateEngine(ctx, &gavelpb.CreateEngineRequest{Name: "e"})
+	require.NoError(t, err)
+
+	_, err = client.AddFact(ctx, &gavelpb.AddFactRequest{Engine: "e", Id: "limit", Type: FactTypeConstant, Value: structpb.NewNumberValue(100), Cache: true})
+	require.NoError(t, err)
+	_, err = client.AddFact(ctx, &gavelpb.AddFactRequest{Engine: "e", Id: "x", Type: "lookup"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	facts, err := client.ListFacts(ctx, &gavelpb.ListFactsRequest{Engine: "e"})
+	require.NoError(t, err)
+	require.Len(t, facts.Facts, 1)
+	assert.Equal(t, "limit", facts.Facts[0].Id)
+	assert.True(t, facts.Facts[0].IsConstant)
+	assert.Equal(t, 100.0, facts.Facts[0].Value.GetNumberValue())
+
+	_, err = client.RemoveFact(ctx, &gavelpb.RemoveFactRequest{Engine: "e", Id: "limit"})
+	require.NoError(t, err)
+	facts, err = client.ListFacts(ctx, &gavelpb.ListFactsRequest{Engine: "e"})
+	require.NoError(t, err)
+	assert.Empty(t, facts.Facts)
+
+	engines, err := client.ListEngines(ctx, &gavelpb.ListEnginesRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"e"}, engines.Engines)
+	_, err = client.DeleteEngine(ctx, &gavelpb.DeleteEngineRequest{Name: "e"})
+	require.NoError(t, err)
+	_, err = client.ListFacts(ctx, &gavelpb.ListFactsRequest{Engine: "e"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServer_RunBatch(t *testing.T) {
+	client := testClient(t, rulesengine.NewEngineManager())
+	adultEngine(t, client)
+
+	stream, err := client.RunBatch(context.Background())
+	require.NoError(t, err)
+	var reqs []*gavelpb.RunBatchRequest
+	for _, age := range []int{10, 20, 30, 40, 5} {
+		reqs = append(reqs, &gavelpb.RunBatchRequest{Facts: mustStruct(t, map[string]interface{}{"age": age})})
+	}
+	reqs[0].Engine = "e"
+	reqs[0].Concurrency = 2
+	go func() {
+		for _, req := range reqs {
+			if err := stream.Send(req); err != nil {
+				return
+			}
+		}
+		stream.CloseSend()
+	}()
+
+	fired := map[int64]bool{}
+	var stats *gavelpb.BatchStats
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if resp.Stats != nil {
+			stats = resp.Stats
+			continue
+		}
+		assert.Empty(t, resp.Error)
+		fired[resp.Index] = len(resp.Events) == 1
+	}
+	assert.Equal(t, map[int64]bool{0: false, 1: true, 2: true, 3: true, 4: false}, fired)
+	require.NotNil(t, stats)
+	assert.Equal(t, int64(5), stats.Items)
+	assert.Equal(t, map[string]int64{"adult": 3}, stats.Fired)
+
+	stream, err = client.RunBatch(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&gavelpb.RunBatchRequest{Engine: "missing"}))
+	_, err = stream.Recv()
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServer_Audit(t *testing.T) {
+	trail, err := audit.Open(filepath.Join(t.TempDir(), "audit.log"))
+	require.NoError(t, err)
+	defer trail.Close()
+	client := testClient(t, rulesengine.NewEngineManager(), WithAudit(trail))
+	adultEngine(t, client)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "req-7")
+	_, err = client.SetRuleDisabled(ctx, &gavelpb.SetRuleDisabledRequest{Engine: "e", Name: "adult", Disabled: true})
+	require.NoError(t, err)
+	var header metadata.MD
+	_, err = client.RemoveFact(context.Background(), &gavelpb.RemoveFactRequest{Engine: "e", Id: "age"}, grpc.Header(&header))
+	require.NoError(t, err)
+	_, err = client.RemoveRule(context.Background(), &gavelpb.RemoveRuleRequest{Engine: "e", Name: "missing"})
+	require.NoError(t, err)
+
+	entries, err := trail.Query(audit.Query{})
+	require.NoError(t, err)
+	var actions []string
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+	}
+	assert.Equal(t, []string{audit.ActionCreateEngine, audit.ActionAddFact, audit.ActionAddRule, audit.ActionDisableRule, audit.ActionRemoveFact}, actions)
+	assert.Equal(t, "req-7", entries[3].RequestID)
+	assert.JSONEq(t, `{"id":"age","isConstant":false,"cache":false,"priority":1}`, string(entries[4].Before))
+	assert.Equal(t, header.Get("x-request-id"), []string{entries[4].RequestID})
+}
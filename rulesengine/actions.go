@@ -0,0 +1,383 @@
+package rulesengine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// When an action runs, set in ActionSpec.On.
+const (
+	ActionOnSuccess = "success"
+	ActionOnFailure = "failure"
+	ActionOnAlways  = "always"
+)
+
+// What happens when an action fails, set in ActionSpec.OnError.
+const (
+	// ActionErrorAbort fails the run. It is the default.
+	ActionErrorAbort = "abort"
+	// ActionErrorContinue records the error and runs the next action.
+	ActionErrorContinue = "continue"
+	// ActionErrorSkip records the error and skips the rule's remaining actions.
+	ActionErrorSkip = "skip"
+)
+
+// Status of an action in RunResult.Actions.
+const (
+	ActionStatusOK      = "ok"
+	ActionStatusError   = "error"
+	ActionStatusSkipped = "skipped"
+)
+
+// ActionSpec declares a side effect of a rule. Type names a handler
+// registered with RegisterAction. On defaults to ActionOnSuccess and OnError
+// to ActionErrorAbort.
+type ActionSpec struct {
+	Type    string                 `json:"type" bson:"type" xml:"type" yaml:"type"`
+	Params  map[string]interface{} `json:"params,omitempty" bson:"params,omitempty" xml:"params,omitempty" yaml:"params,omitempty"`
+	On      string                 `json:"on,omitempty" bson:"on,omitempty" xml:"on,omitempty" yaml:"on,omitempty"`
+	OnError string                 `json:"onError,omitempty" bson:"onError,omitempty" xml:"onError,omitempty" yaml:"onError,omitempty"`
+}
+
+// ActionContext is passed to action handlers.
+type ActionContext struct {
+	Rule    *Rule
+	Event   Event
+	Almanac *Almanac
+	Result  *RuleResult
+}
+
+// ActionHandler performs an action. The returned value is recorded as the
+// action's output.
+type ActionHandler func(ctx *ActionContext, params map[string]interface{}) (interface{}, error)
+
+// ActionResult records an action run for a rule.
+type ActionResult struct {
+	Rule   string      `json:"rule" bson:"rule" xml:"rule" yaml:"rule"`
+	Type   string      `json:"type" bson:"type" xml:"type" yaml:"type"`
+	Status string      `json:"status" bson:"status" xml:"status" yaml:"status"`
+	Output interface{} `json:"output,omitempty" bson:"output,omitempty" xml:"output,omitempty" yaml:"output,omitempty"`
+	Error  string      `json:"error,omitempty" bson:"error,omitempty" xml:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// MetricSink receives metrics from the emitMetric action.
+type MetricSink interface {
+	EmitMetric(name string, value float64, tags map[string]string)
+}
+
+// MetricSinkFunc adapts a function to MetricSink.
+type MetricSinkFunc func(name string, value float64, tags map[string]string)
+
+func (f MetricSinkFunc) EmitMetric(name string, value float64, tags map[string]string) {
+	f(name, value, tags)
+}
+
+type logMetricSink struct{}
+
+func (logMetricSink) EmitMetric(name string, value float64, tags map[string]string) {
+	ev := log.Info().Str("metric", name).Float64("value", value)
+	for k, v := range tags {
+		ev = ev.Str(k, v)
+	}
+	ev.Msg("rule metric")
+}
+
+// WithMetricSink sets where emitMetric actions send metrics. By default
+// they are logged.
+func WithMetricSink(sink MetricSink) EngineOption {
+	return func(e *Engine) {
+		e.metricSink = sink
+	}
+}
+
+// WithWebhookEndpoint sets the base URL webhook actions post to. A webhook
+// action's "path" param is appended to it; rules cannot choose another host.
+func WithWebhookEndpoint(url string) EngineOption {
+	return func(e *Engine) {
+		e.webhookEndpoint = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithHTTPClient sets the client used by webhook actions.
+func WithHTTPClient(client *http.Client) EngineOption {
+	return func(e *Engine) {
+		e.httpClient = client
+	}
+}
+
+// WithActions attaches declarative actions to the rule.
+func WithActions(actions ...ActionSpec) RuleOption {
+	return func(r *Rule) {
+		r.Actions = append(r.Actions, actions...)
+	}
+}
+
+// RegisterAction adds or replaces a named action handler.
+func (e *Engine) RegisterAction(name string, handler ActionHandler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.actions[name] = handler
+}
+
+func (e *Engine) RemoveAction(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.actions, name)
+}
+
+func (e *Engine) initActions() {
+	e.actions["setFact"] = setFactAction
+	e.actions["appendToList"] = appendToListAction
+	e.actions["emitMetric"] = e.emitMetricAction
+	e.actions["webhook"] = e.webhookAction
+}
+
+// validateActions checks the structure of a rule's actions. Handlers are
+// looked up at run time, so unknown types are reported by Validate only.
+func validateActions(r *Rule) []ValidationError {
+	var errs []ValidationError
+	for i, a := range r.Actions {
+		path := fmt.Sprintf("actions[%d]", i)
+		if a.Type == "" {
+			errs = append(errs, ValidationError{Path: path, Message: "action requires a type"})
+		}
+		switch a.On {
+		case "", ActionOnSuccess, ActionOnFailure, ActionOnAlways:
+		default:
+			errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("unknown action trigger %q", a.On)})
+		}
+		switch a.OnError {
+		case "", ActionErrorAbort, ActionErrorContinue, ActionErrorSkip:
+		default:
+			errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("unknown action error policy %q", a.OnError)})
+		}
+	}
+	return errs
+}
+
+func (e *Engine) validateActionTypes(r *Rule) []ValidationError {
+	var errs []ValidationError
+	for i, a := range r.Actions {
+		if _, ok := e.actions[a.Type]; a.Type != "" && !ok {
+			errs = append(errs, ValidationError{
+				Path:    fmt.Sprintf("actions[%d]", i),
+				Message: fmt.Sprintf("undefined action: %s", a.Type),
+			})
+		}
+	}
+	return errs
+}
+
+// runActions runs the rule's actions that match the outcome, in order. It
+// returns an error only when an action fails under the abort policy.
+func (e *Engine) runActions(ctx *ActionContext, passed bool) ([]ActionResult, error) {
+	var results []ActionResult
+	skipping := false
+	for _, spec := range ctx.Rule.Actions {
+		switch spec.On {
+		case "", ActionOnSuccess:
+			if !passed {
+				continue
+			}
+		case ActionOnFailure:
+			if passed {
+				continue
+			}
+		}
+		ar := ActionResult{Rule: ctx.Rule.Name, Type: spec.Type}
+		if skipping {
+			ar.Status = ActionStatusSkipped
+			results = append(results, ar)
+			continue
+		}
+		handler, ok := e.actions[spec.Type]
+		var err error
+		if !ok {
+			err = fmt.Errorf("undefined action: %s", spec.Type)
+		} else {
+			ar.Output, err = handler(ctx, spec.Params)
+		}
+		if err == nil {
+			ar.Status = ActionStatusOK
+			results = append(results, ar)
+			continue
+		}
+		ar.Status, ar.Error = ActionStatusError, err.Error()
+		results = append(results, ar)
+		switch spec.OnError {
+		case ActionErrorContinue:
+		case ActionErrorSkip:
+			skipping = true
+		default:
+			return results, fmt.Errorf("rule %q: action %s: %w", ctx.Rule.Name, spec.Type, err)
+		}
+	}
+	return results, nil
+}
+
+func stringParam(params map[string]interface{}, name string) (string, error) {
+	v, ok := params[name]
+	if !ok {
+		return "", fmt.Errorf("missing param %q", name)
+	}
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return "", fmt.Errorf("param %q must be a non-empty string", name)
+	}
+	return s, nil
+}
+
+// actionValue returns the "value" param, or the value of the fact named by
+// "fromFact" (read at "fromPath" if given).
+func actionValue(ctx *ActionContext, params map[string]interface{}) (interface{}, error) {
+	if from, ok := params["fromFact"]; ok {
+		name, ok := from.(string)
+		if !ok {
+			return nil, fmt.Errorf("param \"fromFact\" must be a string")
+		}
+		path, _ := params["fromPath"].(string)
+		return ctx.Almanac.FactValue(name, nil, path)
+	}
+	v, ok := params["value"]
+	if !ok {
+		return nil, fmt.Errorf("missing param \"value\" or \"fromFact\"")
+	}
+	return v, nil
+}
+
+// setFactAction sets a runtime fact visible to rules evaluated later in the
+// run. Params: fact, and value or fromFact.
+func setFactAction(ctx *ActionContext, params map[string]interface{}) (interface{}, error) {
+	fact, err := stringParam(params, "fact")
+	if err != nil {
+		return nil, err
+	}
+	value, err := actionValue(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Almanac.AddRuntimeFact(fact, value)
+	return value, nil
+}
+
+// appendToListAction appends to a list-valued runtime fact, creating it if
+// it is undefined. Params: fact, and value or fromFact.
+func appendToListAction(ctx *ActionContext, params map[string]interface{}) (interface{}, error) {
+	fact, err := stringParam(params, "fact")
+	if err != nil {
+		return nil, err
+	}
+	value, err := actionValue(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	var list []interface{}
+	if current, ok := ctx.Almanac.GetRuntimeFacts()[fact]; ok && current != nil {
+		items, ok := listValues(current)
+		if !ok {
+			return nil, fmt.Errorf("fact %s is %s, not a list", fact, reflect.TypeOf(current))
+		}
+		list = items
+	}
+	list = append(list, value)
+	ctx.Almanac.AddRuntimeFact(fact, list)
+	return list, nil
+}
+
+// emitMetricAction sends a metric to the engine's MetricSink. Params: name,
+// optional value (default 1) and optional tags.
+func (e *Engine) emitMetricAction(ctx *ActionContext, params map[string]interface{}) (interface{}, error) {
+	name, err := stringParam(params, "name")
+	if err != nil {
+		return nil, err
+	}
+	value := 1.0
+	if v, ok := params["value"]; ok {
+		f, ok := toFloat64(v)
+		if !ok {
+			return nil, fmt.Errorf("param \"value\" must be a number")
+		}
+		value = f
+	}
+	tags := map[string]string{}
+	if raw, ok := params["tags"].(map[string]interface{}); ok {
+		for k, v := range raw {
+			tags[k] = fmt.Sprint(v)
+		}
+	}
+	sink := e.metricSink
+	if sink == nil {
+		sink = logMetricSink{}
+	}
+	sink.EmitMetric(name, value, tags)
+	return nil, nil
+}
+
+// webhookPayload is the body posted by webhook actions.
+type webhookPayload struct {
+	Rule    string                 `json:"rule"`
+	Event   Event                  `json:"event"`
+	Success bool                   `json:"success"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+// webhookAction posts the rule's event as JSON to the engine's webhook
+// endpoint. Params: optional path appended to the endpoint, optional method
+// (default POST), optional headers and optional data merged into the body.
+func (e *Engine) webhookAction(ctx *ActionContext, params map[string]interface{}) (interface{}, error) {
+	if e.webhookEndpoint == "" {
+		return nil, fmt.Errorf("no webhook endpoint configured")
+	}
+	url := e.webhookEndpoint
+	if path, ok := params["path"].(string); ok && path != "" {
+		if strings.Contains(path, "://") || strings.Contains(path, "..") {
+			return nil, fmt.Errorf("invalid webhook path %q", path)
+		}
+		url += "/" + strings.TrimPrefix(path, "/")
+	}
+	method := http.MethodPost
+	if m, ok := params["method"].(string); ok && m != "" {
+		method = strings.ToUpper(m)
+	}
+	payload := webhookPayload{Rule: ctx.Rule.Name, Event: ctx.Event}
+	if ctx.Result != nil {
+		payload.Success = ctx.Result.Success
+	}
+	if data, ok := params["data"].(map[string]interface{}); ok {
+		payload.Data = data
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if headers, ok := params["headers"].(map[string]interface{}); ok {
+		for k, v := range headers {
+			req.Header.Set(k, fmt.Sprint(v))
+		}
+	}
+	client := e.httpClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
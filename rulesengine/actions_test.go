@@ -0,0 +1,168 @@
+package rulesengine
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_DeclarativeActions(t *testing.T) {
+	rules, err := LoadRulesFromJSON([]byte(`[{
+		"name": "flag",
+		"priority": 10,
+		"conditions": {"fact": "amount", "operator": "gt", "value": 1000},
+		"event": {"type": "flagged"},
+		"actions": [
+			{"type": "setFact", "params": {"fact": "flagged", "value": true}},
+			{"type": "appendToList", "params": {"fact": "reasons", "value": "large amount"}},
+			{"type": "appendToList", "params": {"fact": "reasons", "fromFact": "amount"}},
+			{"type": "emitMetric", "params": {"name": "flags", "tags": {"rule": "flag"}}},
+			{"type": "setFact", "on": "failure", "params": {"fact": "flagged", "value": false}}
+		]
+	}, {
+		"name": "review",
+		"priority": 1,
+		"conditions": {"fact": "flagged", "operator": "eq", "value": true},
+		"event": {"type": "review"}
+	}]`))
+	require.NoError(t, err)
+
+	var metrics []string
+	engine := NewEngine(WithAllowUndefinedFacts(), WithMetricSink(MetricSinkFunc(func(name string, value float64, tags map[string]string) {
+		metrics = append(metrics, name+":"+tags["rule"])
+	})))
+	for _, r := range rules {
+		require.NoError(t, engine.AddRule(r))
+	}
+
+	result, err := engine.Run(map[string]interface{}{"amount": 5000.0})
+	require.NoError(t, err)
+	assert.Equal(t, []Event{{Type: "flagged"}, {Type: "review"}}, result.Events)
+	assert.Equal(t, []interface{}{"large amount", 5000.0}, result.Almanac.GetRuntimeFacts()["reasons"])
+	assert.Equal(t, []string{"flags:flag"}, metrics)
+	require.Len(t, result.Actions, 4)
+	for _, ar := range result.Actions {
+		assert.Equal(t, ActionStatusOK, ar.Status)
+		assert.Equal(t, "flag", ar.Rule)
+	}
+
+	result, err = engine.Run(map[string]interface{}{"amount": 10.0})
+	require.NoError(t, err)
+	require.Len(t, result.Actions, 1)
+	assert.Equal(t, "setFact", result.Actions[0].Type)
+	assert.Equal(t, false, result.Almanac.GetRuntimeFacts()["flagged"])
+}
+
+func TestRun_ActionsLeaveCallerFactsUnchanged(t *testing.T) {
+	engine := NewEngine()
+	require.NoError(t, engine.AddRule(NewRule(
+		Condition{Fact: "amount", Operator: "gt", Value: 100},
+		Event{Type: "flagged"},
+		WithActions(
+			ActionSpec{Type: "setFact", Params: map[string]interface{}{"fact": "flagged", "value": true}},
+			ActionSpec{Type: "appendToList", Params: map[string]interface{}{"fact": "reasons", "value": "large amount"}},
+		),
+	)))
+
+	facts := map[string]interface{}{"amount": 500.0, "reasons": []interface{}{"new customer"}}
+	result, err := engine.Run(facts)
+	require.NoError(t, err)
+	assert.Equal(t, true, result.Almanac.GetRuntimeFacts()["flagged"])
+	assert.Equal(t, []interface{}{"new customer", "large amount"}, result.Almanac.GetRuntimeFacts()["reasons"])
+	assert.Equal(t, map[string]interface{}{"amount": 500.0, "reasons": []interface{}{"new customer"}}, facts)
+}
+
+func TestRun_ActionErrorPolicies(t *testing.T) {
+	cond := Condition{Fact: "x", Operator: "eq", Value: 1}
+	newEngine := func(policy string) *Engine {
+		engine := NewEngine()
+		require.NoError(t, engine.AddRule(NewRule(cond, Event{Type: "e"}, WithName("r"), WithActions(
+			ActionSpec{Type: "appendToList", Params: map[string]interface{}{"fact": "x", "value": 2}, OnError: policy},
+			ActionSpec{Type: "setFact", Params: map[string]interface{}{"fact": "y", "value": 1}},
+		))))
+		return engine
+	}
+
+	_, err := newEngine("").Run(map[string]interface{}{"x": 1})
+	assert.ErrorContains(t, err, `rule "r": action appendToList: fact x is int, not a list`)
+
+	result, err := newEngine(ActionErrorContinue).Run(map[string]interface{}{"x": 1})
+	require.NoError(t, err)
+	require.Len(t, result.Actions, 2)
+	assert.Equal(t, ActionStatusError, result.Actions[0].Status)
+	assert.Equal(t, ActionStatusOK, result.Actions[1].Status)
+
+	result, err = newEngine(ActionErrorSkip).Run(map[string]interface{}{"x": 1})
+	require.NoError(t, err)
+	require.Len(t, result.Actions, 2)
+	assert.Equal(t, ActionStatusSkipped, result.Actions[1].Status)
+	assert.NotContains(t, result.Almanac.GetRuntimeFacts(), "y")
+
+	err = NewEngine().AddRule(NewRule(cond, Event{Type: "e"}, WithActions(ActionSpec{Type: "setFact", OnError: "retry"})))
+	assert.ErrorContains(t, err, "unknown action error policy")
+}
+
+func TestRun_CustomAndUndefinedActions(t *testing.T) {
+	engine := NewEngine(WithAllowUndefinedFacts())
+	engine.RegisterAction("double", func(ctx *ActionContext, params map[string]interface{}) (interface{}, error) {
+		v, err := ctx.Almanac.FactValue("x", nil, "")
+		if err != nil {
+			return nil, err
+		}
+		f, _ := toFloat64(v)
+		return f * 2, nil
+	})
+	rule := NewRule(Condition{Fact: "x", Operator: "gt", Value: 0}, Event{Type: "e"}, WithName("r"),
+		WithActions(ActionSpec{Type: "double"}, ActionSpec{Type: "missing", OnError: ActionErrorContinue}))
+	require.NoError(t, engine.AddRule(rule))
+
+	errs := engine.ValidateRule(rule)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "actions[1]", errs[0].Path)
+
+	result, err := engine.Run(map[string]interface{}{"x": 4})
+	require.NoError(t, err)
+	assert.Equal(t, 8.0, result.Actions[0].Output)
+	assert.Equal(t, "undefined action: missing", result.Actions[1].Error)
+}
+
+func TestWebhookAction(t *testing.T) {
+	var got webhookPayload
+	var gotPath, gotHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotHeader = r.URL.Path, r.Header.Get("X-Source")
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &got)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	engine := NewEngine(WithWebhookEndpoint(server.URL + "/hooks/"))
+	require.NoError(t, engine.AddRule(NewRule(Condition{Fact: "x", Operator: "eq", Value: 1}, Event{Type: "hit"},
+		WithName("notify"), WithActions(ActionSpec{Type: "webhook", Params: map[string]interface{}{
+			"path":    "alerts",
+			"headers": map[string]interface{}{"X-Source": "gavel"},
+			"data":    map[string]interface{}{"team": "risk"},
+		}}))))
+
+	result, err := engine.Run(map[string]interface{}{"x": 1})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, result.Actions[0].Output)
+	assert.Equal(t, "/hooks/alerts", gotPath)
+	assert.Equal(t, "gavel", gotHeader)
+	assert.Equal(t, webhookPayload{Rule: "notify", Event: Event{Type: "hit"}, Success: true, Data: map[string]interface{}{"team": "risk"}}, got)
+
+	unconfigured := NewEngine()
+	require.NoError(t, unconfigured.AddRule(NewRule(Condition{Fact: "x", Operator: "eq", Value: 1}, Event{Type: "hit"},
+		WithActions(ActionSpec{Type: "webhook", Params: map[string]interface{}{"path": "http://elsewhere"}}))))
+	_, err = unconfigured.Run(map[string]interface{}{"x": 1})
+	assert.ErrorContains(t, err, "no webhook endpoint configured")
+
+	_, err = engine.webhookAction(&ActionContext{Rule: &Rule{}}, map[string]interface{}{"path": "http://elsewhere"})
+	assert.ErrorContains(t, err, "invalid webhook path")
+}
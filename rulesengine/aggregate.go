@@ -0,0 +1,249 @@
+package rulesengine
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Aggregate reducers.
+const (
+	ReduceCount = "count"
+	ReduceSum   = "sum"
+	ReduceMin   = "min"
+	ReduceMax   = "max"
+	ReduceAvg   = "avg"
+	ReduceAny   = "any"
+	ReduceAll   = "all"
+)
+
+// DefaultAggregateItem is the fact name items are bound to when As is empty.
+const DefaultAggregateItem = "item"
+
+// Aggregate tests a collection. The slice read from Fact and Path is walked
+// item by item; each item is bound as the runtime fact As and evaluated
+// against Where, where a nil Where matches every item. The matching items
+// are then reduced:
+//
+//   - count: the number of matching items
+//   - sum, min, max, avg: over the number at Of in each matching item, or
+//     the item itself when Of is empty
+//   - any: whether at least one item matched
+//   - all: whether every item matched, true for an empty collection
+//
+// The reduced value is compared with Value using Operator. Operator may be
+// left empty for any and all to use the reduced boolean directly. min, max
+// and avg over no items have no value and never match. A missing fact is an
+// empty collection.
+type Aggregate struct {
+	Fact     string                 `json:"fact" bson:"fact" xml:"fact" yaml:"fact"`
+	Path     string                 `json:"path,omitempty" bson:"path,omitempty" xml:"path,omitempty" yaml:"path,omitempty"`
+	Params   map[string]interface{} `json:"params,omitempty" bson:"params,omitempty" xml:"params,omitempty" yaml:"params,omitempty"`
+	As       string                 `json:"as,omitempty" bson:"as,omitempty" xml:"as,omitempty" yaml:"as,omitempty"`
+	Where    *Condition             `json:"where,omitempty" bson:"where,omitempty" xml:"where,omitempty" yaml:"where,omitempty"`
+	Reduce   string                 `json:"reduce" bson:"reduce" xml:"reduce" yaml:"reduce"`
+	Of       string                 `json:"of,omitempty" bson:"of,omitempty" xml:"of,omitempty" yaml:"of,omitempty"`
+	Operator string                 `json:"operator,omitempty" bson:"operator,omitempty" xml:"operator,omitempty" yaml:"operator,omitempty"`
+	Value    interface{}            `json:"value,omitempty" bson:"value,omitempty" xml:"value,omitempty" yaml:"value,omitempty"`
+}
+
+func (a *Aggregate) itemFact() string {
+	if a.As == "" {
+		return DefaultAggregateItem
+	}
+	return a.As
+}
+
+func isBooleanReduce(reduce string) bool {
+	return reduce == ReduceAny || reduce == ReduceAll
+}
+
+// evaluate runs the aggregate and returns its result and reduced value. When
+// trace is set it also returns one node per item: the Where trace with Item
+// set, or a bare node when Where is nil. Without tracing, any and all stop
+// at the first item that decides them.
+func (a *Aggregate) evaluate(almanac *Almanac, engine *Engine, trace bool) (bool, interface{}, []*TraceNode, error) {
+	collection, err := almanac.FactValue(a.Fact, a.Params, a.Path)
+	if err != nil {
+		return false, nil, nil, err
+	}
+	items, err := aggregateItems(collection)
+	if err != nil {
+		return false, nil, nil, fmt.Errorf("aggregate over %s: %w", a.Fact+a.Path, err)
+	}
+
+	var nodes []*TraceNode
+	if trace {
+		nodes = make([]*TraceNode, 0, len(items))
+	}
+	name := a.itemFact()
+	matched := 0
+	var numbers []float64
+	for i, item := range items {
+		ok := true
+		var node *TraceNode
+		if a.Where != nil {
+			scope := almanac.withFact(name, item)
+			if trace {
+				ok, node, err = a.Where.EvaluateWithTrace(scope, engine)
+			} else {
+				ok, err = a.Where.Evaluate(scope, engine)
+			}
+			if err != nil {
+				return false, nil, nil, fmt.Errorf("aggregate item %d: %w", i, err)
+			}
+		}
+		if trace {
+			if node == nil {
+				node = &TraceNode{Result: ok}
+			}
+			node.Item = item
+			nodes = append(nodes, node)
+		}
+		if !ok {
+			if a.Reduce == ReduceAll && !trace {
+				break
+			}
+			continue
+		}
+		matched++
+		if a.Reduce == ReduceAny && !trace {
+			break
+		}
+		switch a.Reduce {
+		case ReduceSum, ReduceMin, ReduceMax, ReduceAvg:
+			value := item
+			if a.Of != "" {
+				value = engine.pathResolver(item, a.Of)
+			}
+			f, ok := toFloat64(value)
+			if !ok {
+				return false, nil, nil, fmt.Errorf("aggregate item %d: %v is not a number", i, value)
+			}
+			numbers = append(numbers, f)
+		}
+	}
+
+	reduced, err := reduceAggregate(a.Reduce, matched, len(items), numbers)
+	if err != nil {
+		return false, nil, nil, err
+	}
+	if reduced == nil {
+		return false, nil, nodes, nil
+	}
+	if a.Operator == "" {
+		b, ok := reduced.(bool)
+		if !ok {
+			return false, nil, nil, fmt.Errorf("aggregate %s requires an operator", a.Reduce)
+		}
+		return b, reduced, nodes, nil
+	}
+	opFunc, err := resolveOperator(a.Operator, engine)
+	if err != nil {
+		return false, nil, nil, err
+	}
+	// Reduced numbers are float64, so compare them with a float64 value
+	// whether the rule was built in Go or decoded from JSON.
+	value := a.Value
+	if _, ok := reduced.(float64); ok {
+		if f, ok := toFloat64(value); ok {
+			value = f
+		}
+	}
+	return opFunc(reduced, value), reduced, nodes, nil
+}
+
+func reduceAggregate(reduce string, matched, total int, numbers []float64) (interface{}, error) {
+	switch reduce {
+	case ReduceCount:
+		return float64(matched), nil
+	case ReduceAny:
+		return matched > 0, nil
+	case ReduceAll:
+		return matched == total, nil
+	case ReduceSum:
+		sum := 0.0
+		for _, n := range numbers {
+			sum += n
+		}
+		return sum, nil
+	case ReduceMin, ReduceMax, ReduceAvg:
+		if len(numbers) == 0 {
+			return nil, nil
+		}
+		result := numbers[0]
+		for _, n := range numbers[1:] {
+			switch {
+			case reduce == ReduceMin && n < result:
+				result = n
+			case reduce == ReduceMax && n > result:
+				result = n
+			case reduce == ReduceAvg:
+				result += n
+			}
+		}
+		if reduce == ReduceAvg {
+			result /= float64(len(numbers))
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("unknown aggregate reducer: %s", reduce)
+}
+
+// describeAggregate renders an aggregate without its Where tree, for
+// example "count of transactions greaterThan 3".
+func describeAggregate(a *Aggregate) string {
+	s := a.Reduce + " of " + a.Fact + a.Path
+	if a.Of != "" {
+		s += " " + a.Of
+	}
+	if a.Operator == "" {
+		return s
+	}
+	return fmt.Sprintf("%s %s %s", s, a.Operator, canonicalJSON(a.Value))
+}
+
+// aggregateItems returns the elements of a slice or array. nil is an empty
+// collection.
+func aggregateItems(value interface{}) ([]interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		return v, nil
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("%T is not a collection", value)
+	}
+	items := make([]interface{}, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items, nil
+}
+
+// validateAggregate checks the structure of an aggregate condition.
+func validateAggregate(a *Aggregate, path string) []ValidationError {
+	var errs []ValidationError
+	if a.Fact == "" {
+		errs = append(errs, ValidationError{Path: path, Message: "aggregate missing Fact"})
+	}
+	switch a.Reduce {
+	case ReduceCount, ReduceSum, ReduceMin, ReduceMax, ReduceAvg:
+		if a.Operator == "" {
+			errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("aggregate %s requires an Operator", a.Reduce)})
+		}
+	case ReduceAny, ReduceAll:
+	case "":
+		errs = append(errs, ValidationError{Path: path, Message: "aggregate missing Reduce"})
+	default:
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("unknown aggregate reducer: %s", a.Reduce)})
+	}
+	if a.Of != "" && (a.Reduce == ReduceCount || isBooleanReduce(a.Reduce)) {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("Of is not used by aggregate %s", a.Reduce)})
+	}
+	if a.Where != nil {
+		errs = append(errs, validateCondition(a.Where, joinPath(path, "Where"))...)
+	}
+	return errs
+}
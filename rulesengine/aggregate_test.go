@@ -0,0 +1,279 @@
+package rulesengine
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func transactions(amounts ...float64) []interface{} {
+	items := make([]interface{}, len(amounts))
+	for i, amount := range amounts {
+		items[i] = map[string]interface{}{"amount": amount}
+	}
+	return items
+}
+
+func bigTransaction() *Condition {
+	return &Condition{Fact: "item", Path: ".amount", Operator: "greaterThan", Value: 500}
+}
+
+func TestAggregate_Reducers(t *testing.T) {
+	facts := map[string]interface{}{"transactions": transactions(100, 600, 700, 900, 50)}
+	tests := []struct {
+		name string
+		agg  Aggregate
+		want bool
+	}{
+		{"count", Aggregate{Reduce: ReduceCount, Where: bigTransaction(), Operator: "greaterThan", Value: 2}, true},
+		{"count exact", Aggregate{Reduce: ReduceCount, Where: bigTransaction(), Operator: "equal", Value: 3}, true},
+		{"count too few", Aggregate{Reduce: ReduceCount, Where: bigTransaction(), Operator: "greaterThan", Value: 3}, false},
+		{"sum", Aggregate{Reduce: ReduceSum, Of: ".amount", Operator: "equal", Value: 2350}, true},
+		{"sum of matches", Aggregate{Reduce: ReduceSum, Of: ".amount", Where: bigTransaction(), Operator: "equal", Value: 2200}, true},
+		{"min", Aggregate{Reduce: ReduceMin, Of: ".amount", Operator: "equal", Value: 50}, true},
+		{"max", Aggregate{Reduce: ReduceMax, Of: ".amount", Operator: "gte", Value: 900}, true},
+		{"avg", Aggregate{Reduce: ReduceAvg, Of: ".amount", Operator: "equal", Value: 470}, true},
+		{"any", Aggregate{Reduce: ReduceAny, Where: bigTransaction()}, true},
+		{"all", Aggregate{Reduce: ReduceAll, Where: bigTransaction()}, false},
+		{"all with operator", Aggregate{Reduce: ReduceAll, Where: bigTransaction(), Operator: "equal", Value: false}, true},
+	}
+	engine := NewEngine()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agg := tt.agg
+			agg.Fact = "transactions"
+			c := Condition{Aggregate: &agg}
+			require.Empty(t, ValidateCondition(&c))
+
+			got, err := c.Evaluate(NewAlmanac(engine, facts), engine)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			traced, _, err := c.EvaluateWithTrace(NewAlmanac(engine, facts), engine)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, traced)
+		})
+	}
+}
+
+func TestAggregate_EmptyCollection(t *testing.T) {
+	engine := NewEngine()
+	almanac := NewAlmanac(engine, map[string]interface{}{"transactions": []interface{}{}})
+	eval := func(agg Aggregate) bool {
+		agg.Fact = "transactions"
+		c := Condition{Aggregate: &agg}
+		ok, err := c.Evaluate(almanac, engine)
+		require.NoError(t, err)
+		return ok
+	}
+
+	assert.True(t, eval(Aggregate{Reduce: ReduceCount, Operator: "equal", Value: 0}))
+	assert.True(t, eval(Aggregate{Reduce: ReduceAll, Where: bigTransaction()}))
+	assert.False(t, eval(Aggregate{Reduce: ReduceAny, Where: bigTransaction()}))
+	// min, max and avg have no value over no items and never match.
+	assert.False(t, eval(Aggregate{Reduce: ReduceAvg, Operator: "lessThan", Value: 1}))
+	assert.False(t, eval(Aggregate{Reduce: ReduceMin, Operator: "gte", Value: 0}))
+
+	// A missing fact is an empty collection.
+	missing := Condition{Aggregate: &Aggregate{Fact: "absent", Reduce: ReduceCount, Operator: "equal", Value: 0}}
+	ok, err := missing.Evaluate(NewAlmanac(NewEngine(WithAllowUndefinedFacts()), nil), engine)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestAggregate_Errors(t *testing.T) {
+	engine := NewEngine()
+	almanac := NewAlmanac(engine, map[string]interface{}{
+		"transactions": []interface{}{map[string]interface{}{"amount": "lots"}},
+		"total":        12,
+	})
+
+	notList := Condition{Aggregate: &Aggregate{Fact: "total", Reduce: ReduceCount, Operator: "equal", Value: 1}}
+	_, err := notList.Evaluate(almanac, engine)
+	assert.ErrorContains(t, err, "not a collection")
+
+	notNumber := Condition{Aggregate: &Aggregate{Fact: "transactions", Reduce: ReduceSum, Of: ".amount", Operator: "gt", Value: 1}}
+	_, err = notNumber.Evaluate(almanac, engine)
+	assert.ErrorContains(t, err, "aggregate item 0")
+}
+
+func TestAggregate_NamedItemAndOuterFacts(t *testing.T) {
+	engine := NewEngine()
+	// Items are bound under As and may be compared with facts of the run.
+	where := Condition{All: []Condition{
+		{Fact: "txn", Path: ".country", Operator: "notEqual", Value: "US"},
+		{Fact: "txn", Path: ".amount", Operator: "greaterThan", Value: 500},
+	}}
+	rule := NewRule(Condition{Aggregate: &Aggregate{
+		Fact: "customer", Path: ".transactions", As: "txn", Where: &where,
+		Reduce: ReduceCount, Operator: "gte", Value: 2,
+	}}, Event{Type: "review"}, WithName("foreign-spend"))
+	require.NoError(t, engine.AddRule(rule))
+
+	customer := map[string]interface{}{"transactions": []interface{}{
+		map[string]interface{}{"country": "FR", "amount": 800},
+		map[string]interface{}{"country": "US", "amount": 900},
+		map[string]interface{}{"country": "DE", "amount": 510},
+	}}
+	result, err := engine.Run(map[string]interface{}{"customer": customer})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"review"}, eventTypes(result))
+	// The item fact does not leak into the run.
+	_, ok := result.Almanac.GetRuntimeFacts()["txn"]
+	assert.False(t, ok)
+}
+
+func TestAggregate_CachedFactReadsItem(t *testing.T) {
+	engine := NewEngine()
+	// A cached fact computed from the item is recomputed for every item.
+	require.NoError(t, engine.AddFact("amount", FactFunc(func(params map[string]interface{}, almanac *Almanac) (interface{}, error) {
+		return almanac.FactValue("item", nil, ".amount")
+	})))
+	c := Condition{Aggregate: &Aggregate{
+		Fact: "transactions", Where: &Condition{Fact: "amount", Operator: "greaterThan", Value: 500},
+		Reduce: ReduceCount, Operator: "equal", Value: 3,
+	}}
+	ok, err := c.Evaluate(NewAlmanac(engine, map[string]interface{}{"transactions": transactions(100, 600, 700, 900, 50)}), engine)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestAggregate_TraceShowsItems(t *testing.T) {
+	engine := NewEngine()
+	c := Condition{Aggregate: &Aggregate{
+		Fact: "transactions", Where: bigTransaction(), Reduce: ReduceCount, Operator: "greaterThan", Value: 1,
+	}}
+	items := transactions(100, 600, 700)
+	ok, trace, err := c.EvaluateWithTrace(NewAlmanac(engine, map[string]interface{}{"transactions": items}), engine)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 2.0, trace.FactValue)
+	require.Len(t, trace.Children, 3)
+	for i, child := range trace.Children {
+		assert.Equal(t, items[i], child.Item)
+		assert.Equal(t, i > 0, child.Result)
+		assert.Equal(t, items[i].(map[string]interface{})["amount"], child.FactValue)
+	}
+
+	// Without Where every item matches and the nodes carry only the item.
+	c.Aggregate.Where = nil
+	_, trace, err = c.EvaluateWithTrace(NewAlmanac(engine, map[string]interface{}{"transactions": items}), engine)
+	require.NoError(t, err)
+	require.Len(t, trace.Children, 3)
+	assert.True(t, trace.Children[0].Result)
+	assert.Equal(t, items[0], trace.Children[0].Item)
+}
+
+func TestAggregate_Validation(t *testing.T) {
+	c := Condition{Aggregate: &Aggregate{Reduce: "median", Where: &Condition{Fact: "item"}}}
+	assert.ElementsMatch(t, []ValidationError{
+		{Path: "", Message: "aggregate missing Fact"},
+		{Path: "", Message: "unknown aggregate reducer: median"},
+		{Path: "Where", Message: "leaf condition missing Operator"},
+	}, ValidateCondition(&c))
+
+	c = Condition{Aggregate: &Aggregate{Fact: "xs", Reduce: ReduceCount, Of: ".amount"}}
+	assert.ElementsMatch(t, []ValidationError{
+		{Path: "", Message: "aggregate count requires an Operator"},
+		{Path: "", Message: "Of is not used by aggregate count"},
+	}, ValidateCondition(&c))
+
+	c = Condition{Fact: "xs", Operator: "equal", Aggregate: &Aggregate{Fact: "xs", Reduce: ReduceAny}}
+	assert.Contains(t, ValidateCondition(&c), ValidationError{Message: "condition has multiple types set"})
+
+	// The item fact is defined inside Where only.
+	engine := NewEngine()
+	require.NoError(t, engine.AddFact("transactions", transactions()))
+	rule := NewRule(Condition{All: []Condition{
+		{Aggregate: &Aggregate{Fact: "transactions", Where: bigTransaction(), Reduce: ReduceAny, Operator: "nearly"}},
+		{Fact: "item", Operator: "equal", Value: 1},
+	}}, Event{Type: "x"})
+	assert.ElementsMatch(t, []ValidationError{
+		{Path: "All[0]", Message: "undefined operator: nearly"},
+		{Path: "All[1]", Message: "undefined fact: item"},
+	}, engine.ValidateRule(rule))
+}
+
+func TestAggregate_Serialization(t *testing.T) {
+	data := `{
+		"aggregate": {
+			"fact": "transactions",
+			"where": {"fact": "item", "path": ".amount", "operator": "greaterThan", "value": 500},
+			"reduce": "count",
+			"operator": "greaterThan",
+			"value": 3
+		}
+	}`
+	var c Condition
+	require.NoError(t, json.Unmarshal([]byte(data), &c))
+	require.NotNil(t, c.Aggregate)
+	assert.Equal(t, ReduceCount, c.Aggregate.Reduce)
+	assert.Equal(t, "item", c.Aggregate.Where.Fact)
+
+	engine := NewEngine()
+	ok, err := c.Evaluate(NewAlmanac(engine, map[string]interface{}{"transactions": transactions(501, 600, 700, 800)}), engine)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestAggregate_ToolingAwareness(t *testing.T) {
+	c := Condition{All: []Condition{
+		{Fact: "vip", Operator: "equal", Value: true},
+		{Aggregate: &Aggregate{Fact: "transactions", Where: bigTransaction(), Reduce: ReduceCount, Operator: "gt", Value: 3}},
+	}}
+	assert.Equal(t, 3, CountLeafConditions(&c))
+	assert.Equal(t, 3, MaxDepth(&c))
+	var facts []string
+	require.NoError(t, WalkLeaves(&c, func(leaf *Condition) error {
+		facts = append(facts, leaf.Fact)
+		return nil
+	}))
+	assert.Equal(t, []string{"vip", "item"}, facts)
+
+	changed := Condition{All: []Condition{
+		c.All[0],
+		{Aggregate: &Aggregate{Fact: "transactions", Where: &Condition{Fact: "item", Path: ".amount", Operator: "gt", Value: 1000}, Reduce: ReduceCount, Operator: "gt", Value: 3}},
+	}}
+	assert.Nil(t, DiffConditions(&c, &c))
+	d := DiffConditions(&c, &changed)
+	require.NotNil(t, d)
+	require.Len(t, d.Children, 1)
+	require.Len(t, d.Children[0].Children, 1)
+	assert.Equal(t, "All[1].Where", d.Children[0].Children[0].Path)
+	assert.Equal(t, "count of transactions gt 3 where item.amount greaterThan 500", describeTree(&c.All[1]))
+
+	// A change to the aggregate itself is reported along with its Where.
+	changed.All[1].Aggregate.Value = 5
+	report := DiffRuleSets(
+		[]*Rule{NewRule(c, Event{Type: "review"}, WithName("r"))},
+		[]*Rule{NewRule(changed, Event{Type: "review"}, WithName("r"))},
+	).String()
+	assert.Contains(t, report, "~ All[1]: count of transactions gt 3 -> count of transactions gt 5\n")
+	assert.Contains(t, report, "~ All[1].Where: item.amount greaterThan 500 -> item.amount gt 1000\n")
+}
+
+func TestAggregate_Coverage(t *testing.T) {
+	cc := NewCoverageCollector()
+	engine := NewEngine()
+	require.NoError(t, engine.AddRule(NewRule(Condition{Aggregate: &Aggregate{
+		Fact: "transactions", Where: bigTransaction(), Reduce: ReduceAny,
+	}}, Event{Type: "big"}, WithName("big"))))
+
+	_, err := engine.Run(map[string]interface{}{"transactions": transactions(100, 600)}, WithCoverage(cc))
+	require.NoError(t, err)
+	_, err = engine.Run(map[string]interface{}{"transactions": []interface{}{}}, WithCoverage(cc))
+	require.NoError(t, err)
+
+	report := cc.Report()
+	require.Len(t, report.Rules, 1)
+	nodes := report.Rules[0].Nodes
+	require.Len(t, nodes, 2)
+	assert.Equal(t, "any of transactions", nodes[0].Description)
+	assert.Equal(t, "Where", nodes[1].Path)
+	// Evaluated once per item, and skipped for the empty collection.
+	assert.Equal(t, 2, nodes[1].Counts.Evaluated)
+	assert.Equal(t, 1, nodes[1].Counts.True)
+	assert.Equal(t, 1, nodes[1].Counts.Skipped)
+}
@@ -105,3 +105,40 @@ func (a *Almanac) GetRuleResults() []*RuleResult {
 func (a *Almanac) GetRuntimeFacts() map[string]interface{} {
 	return a.runtimeFacts
 }
+
+// snapshot returns a copy of the almanac whose fact maps are independent of
+// the original, for handlers that outlive the run.
+func (a *Almanac) snapshot() *Almanac {
+	c := &Almanac{
+		engine:       a.engine,
+		runtimeFacts: make(map[string]interface{}, len(a.runtimeFacts)),
+		factCache:    make(map[string]map[string]interface{}, len(a.factCache)),
+		events:       append([]Event{}, a.events...),
+		ruleResults:  append([]*RuleResult{}, a.ruleResults...),
+	}
+	for k, v := range a.runtimeFacts {
+		c.runtimeFacts[k] = v
+	}
+	for id, cache := range a.factCache {
+		c.factCache[id] = make(map[string]interface{}, len(cache))
+		for k, v := range cache {
+			c.factCache[id][k] = v
+		}
+	}
+	return c
+}
+
+// withFact returns an almanac that sees value as the runtime fact id and
+// otherwise shares the original's facts. Aggregate conditions use it to bind
+// each item of a collection. It starts with an empty fact cache, since a
+// cached fact may have been computed from the item.
+func (a *Almanac) withFact(id string, value interface{}) *Almanac {
+	scoped := *a
+	scoped.runtimeFacts = make(map[string]interface{}, len(a.runtimeFacts)+1)
+	for k, v := range a.runtimeFacts {
+		scoped.runtimeFacts[k] = v
+	}
+	scoped.runtimeFacts[id] = value
+	scoped.factCache = make(map[string]map[string]interface{})
+	return &scoped
+}
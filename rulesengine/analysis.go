@@ -0,0 +1,773 @@
+package rulesengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DiagnosticKind classifies a finding reported by the static analyzer.
+type DiagnosticKind string
+
+const (
+	// DiagnosticUnsatisfiable marks a condition that can never be true.
+	DiagnosticUnsatisfiable DiagnosticKind = "unsatisfiable"
+	// DiagnosticAlwaysTrue marks a condition that holds for every input.
+	DiagnosticAlwaysTrue DiagnosticKind = "alwaysTrue"
+	// DiagnosticRedundant marks a condition or rule that adds nothing.
+	DiagnosticRedundant DiagnosticKind = "redundant"
+	// DiagnosticShadowed marks a rule whose conditions duplicate a rule that
+	// is evaluated before it but emits a different event.
+	DiagnosticShadowed DiagnosticKind = "shadowed"
+)
+
+// Diagnostic is a semantic finding about a condition tree. Path uses the same
+// addressing as ValidationError; Related points at the node or rule that
+// causes the finding, when there is one.
+type Diagnostic struct {
+	Kind    DiagnosticKind `json:"kind" bson:"kind" xml:"kind" yaml:"kind"`
+	Rule    string         `json:"rule,omitempty" bson:"rule,omitempty" xml:"rule,omitempty" yaml:"rule,omitempty"`
+	Path    string         `json:"path" bson:"path" xml:"path" yaml:"path"`
+	Message string         `json:"message" bson:"message" xml:"message" yaml:"message"`
+	Related string         `json:"related,omitempty" bson:"related,omitempty" xml:"related,omitempty" yaml:"related,omitempty"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Path == "" {
+		return fmt.Sprintf("%s: %s", d.Kind, d.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", d.Path, d.Kind, d.Message)
+}
+
+// AnalyzeCondition reports contradictions, tautologies and redundant
+// branches in a single condition tree. Condition references are treated as
+// opaque because there is no engine to resolve them against.
+func AnalyzeCondition(c *Condition) []Diagnostic {
+	a := &analyzer{}
+	a.analyze(c, "")
+	return a.diags
+}
+
+// Analyze runs the static analyzer over every rule in the engine. Named
+// conditions are resolved when computing whether a reference is always or
+// never true. Besides per-node findings it reports rules that can never fire,
+// rules that always fire, and rules whose conditions duplicate an earlier one.
+func (e *Engine) Analyze() []Diagnostic {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	a := &analyzer{conditions: e.conditions}
+	keys := make([]string, len(e.rules))
+	for i, rule := range e.rules {
+		prefix := fmt.Sprintf("rules[%d]", i)
+		start := len(a.diags)
+		truth := a.analyze(&rule.Conditions, prefix)
+		for j := start; j < len(a.diags); j++ {
+			a.diags[j].Rule = rule.Name
+		}
+		switch {
+		case truth == truthNever && !a.reported(start, prefix, DiagnosticUnsatisfiable):
+			a.report(Diagnostic{Kind: DiagnosticUnsatisfiable, Rule: rule.Name, Path: prefix, Message: "rule can never fire"})
+		case truth == truthAlways && !a.reported(start, prefix, DiagnosticAlwaysTrue):
+			a.report(Diagnostic{Kind: DiagnosticAlwaysTrue, Rule: rule.Name, Path: prefix, Message: "rule fires for every input"})
+		}
+		keys[i] = conditionKey(&rule.Conditions)
+	}
+
+	for j, rule := range e.rules {
+		for i := 0; i < j; i++ {
+			if keys[i] != keys[j] {
+				continue
+			}
+			other := e.rules[i]
+			related := fmt.Sprintf("rules[%d]", i)
+			if eventsEqual(other.Event, rule.Event) {
+				a.report(Diagnostic{
+					Kind:    DiagnosticRedundant,
+					Rule:    rule.Name,
+					Path:    fmt.Sprintf("rules[%d]", j),
+					Message: fmt.Sprintf("rule duplicates %s", ruleLabel(other, i)),
+					Related: related,
+				})
+			} else {
+				a.report(Diagnostic{
+					Kind:    DiagnosticShadowed,
+					Rule:    rule.Name,
+					Path:    fmt.Sprintf("rules[%d]", j),
+					Message: fmt.Sprintf("rule has the same conditions as %s but emits a conflicting event", ruleLabel(other, i)),
+					Related: related,
+				})
+			}
+			break
+		}
+	}
+	return a.diags
+}
+
+func ruleLabel(r *Rule, index int) string {
+	if r.Name != "" {
+		return fmt.Sprintf("rule %q", r.Name)
+	}
+	return fmt.Sprintf("rules[%d]", index)
+}
+
+func eventsEqual(a, b Event) bool {
+	if a.Type != b.Type {
+		return false
+	}
+	if len(a.Params) == 0 && len(b.Params) == 0 {
+		return true
+	}
+	return canonicalJSON(a.Params) == canonicalJSON(b.Params)
+}
+
+type truth int
+
+const (
+	truthUnknown truth = iota
+	truthAlways
+	truthNever
+)
+
+type analyzer struct {
+	conditions map[string]Condition
+	diags      []Diagnostic
+	// quiet suppresses diagnostics while evaluating referenced conditions,
+	// so findings are reported where they are defined rather than at every use.
+	quiet    int
+	visiting map[string]bool
+}
+
+func (a *analyzer) report(d Diagnostic) {
+	if a.quiet > 0 {
+		return
+	}
+	a.diags = append(a.diags, d)
+}
+
+// reported reports whether a diagnostic of the given kind was already
+// recorded at path since index start.
+func (a *analyzer) reported(start int, path string, kind DiagnosticKind) bool {
+	for _, d := range a.diags[start:] {
+		if d.Path == path && d.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, segment string) string {
+	if path == "" {
+		return segment
+	}
+	return path + "." + segment
+}
+
+func (a *analyzer) analyze(c *Condition, path string) truth {
+	if c == nil {
+		return truthUnknown
+	}
+	switch {
+	case c.ConditionRef != "":
+		return a.analyzeRef(c.ConditionRef)
+	case len(c.All) > 0:
+		return a.analyzeAll(c.All, path)
+	case len(c.Any) > 0:
+		return a.analyzeAny(c.Any, path)
+	case c.Not != nil:
+		switch a.analyze(c.Not, joinPath(path, "Not")) {
+		case truthAlways:
+			return truthNever
+		case truthNever:
+			return truthAlways
+		}
+		return truthUnknown
+	case c.Aggregate != nil:
+		// The Where tree is checked on its own, but its truth says nothing
+		// about the reduced value.
+		if c.Aggregate.Where != nil {
+			a.analyze(c.Aggregate.Where, joinPath(path, "Where"))
+		}
+		return truthUnknown
+	case c.Fact != "" && c.Operator != "":
+		return a.analyzeLeaf(c, path)
+	}
+	return truthUnknown
+}
+
+func (a *analyzer) analyzeRef(name string) truth {
+	cond, ok := a.conditions[name]
+	if !ok || a.visiting[name] {
+		return truthUnknown
+	}
+	if a.visiting == nil {
+		a.visiting = make(map[string]bool)
+	}
+	a.visiting[name] = true
+	a.quiet++
+	t := a.analyze(&cond, "")
+	a.quiet--
+	delete(a.visiting, name)
+	return t
+}
+
+func (a *analyzer) analyzeLeaf(c *Condition, path string) truth {
+	switch canonicalOperator(c.Operator) {
+	case "in":
+		if isEmptyList(c.Value) {
+			a.report(Diagnostic{Kind: DiagnosticUnsatisfiable, Path: path, Message: "in with an empty list never matches"})
+			return truthNever
+		}
+	case "notIn":
+		if isEmptyList(c.Value) {
+			a.report(Diagnostic{Kind: DiagnosticAlwaysTrue, Path: path, Message: "notIn with an empty list always matches"})
+			return truthAlways
+		}
+	}
+	return truthUnknown
+}
+
+func (a *analyzer) analyzeAll(children []Condition, path string) truth {
+	childPaths := make([]string, len(children))
+	result := truthAlways
+	for i := range children {
+		childPaths[i] = joinPath(path, fmt.Sprintf("All[%d]", i))
+		switch a.analyze(&children[i], childPaths[i]) {
+		case truthNever:
+			result = truthNever
+		case truthUnknown:
+			if result != truthNever {
+				result = truthUnknown
+			}
+		}
+	}
+	a.reportDuplicates(children, childPaths)
+	if result == truthNever {
+		return truthNever
+	}
+
+	for _, group := range groupLeaves(children) {
+		merged := fullDomain()
+		for _, idx := range group.indices {
+			merged = merged.intersect(group.domains[idx])
+		}
+		if !merged.satisfiable() {
+			descs := make([]string, 0, len(group.indices))
+			for _, idx := range group.indices {
+				descs = append(descs, describeLeaf(&children[idx]))
+			}
+			a.report(Diagnostic{
+				Kind:    DiagnosticUnsatisfiable,
+				Path:    path,
+				Message: fmt.Sprintf("contradictory constraints on fact %q: %s", group.fact, strings.Join(descs, " and ")),
+			})
+			return truthNever
+		}
+		// A conjunct is redundant when another conjunct on the same fact
+		// already implies it.
+		for _, i := range group.indices {
+			for _, j := range group.indices {
+				if i == j || group.domains[i].equal(group.domains[j]) {
+					continue
+				}
+				if group.domains[j].subsetOf(group.domains[i]) {
+					a.report(Diagnostic{
+						Kind:    DiagnosticRedundant,
+						Path:    childPaths[i],
+						Message: fmt.Sprintf("%s is implied by %s", describeLeaf(&children[i]), describeLeaf(&children[j])),
+						Related: childPaths[j],
+					})
+					break
+				}
+			}
+		}
+	}
+	return result
+}
+
+func (a *analyzer) analyzeAny(children []Condition, path string) truth {
+	childPaths := make([]string, len(children))
+	result := truthNever
+	always := -1
+	for i := range children {
+		childPaths[i] = joinPath(path, fmt.Sprintf("Any[%d]", i))
+		switch a.analyze(&children[i], childPaths[i]) {
+		case truthAlways:
+			if always < 0 {
+				always = i
+			}
+		case truthUnknown:
+			result = truthUnknown
+		}
+	}
+	a.reportDuplicates(children, childPaths)
+	if always >= 0 {
+		a.report(Diagnostic{
+			Kind:    DiagnosticAlwaysTrue,
+			Path:    path,
+			Message: "Any contains a branch that is always true",
+			Related: childPaths[always],
+		})
+		return truthAlways
+	}
+	if result == truthNever {
+		return truthNever
+	}
+
+	for _, group := range groupLeaves(children) {
+		domains := make([]domain, 0, len(group.indices))
+		for _, idx := range group.indices {
+			domains = append(domains, group.domains[idx])
+		}
+		if coversEverything(domains) {
+			descs := make([]string, 0, len(group.indices))
+			for _, idx := range group.indices {
+				descs = append(descs, describeLeaf(&children[idx]))
+			}
+			a.report(Diagnostic{
+				Kind:    DiagnosticAlwaysTrue,
+				Path:    path,
+				Message: fmt.Sprintf("branches on fact %q cover every value: %s", group.fact, strings.Join(descs, " or ")),
+			})
+			return truthAlways
+		}
+		// A disjunct is redundant when it implies another disjunct on the
+		// same fact.
+		for _, i := range group.indices {
+			for _, j := range group.indices {
+				if i == j || group.domains[i].equal(group.domains[j]) {
+					continue
+				}
+				if group.domains[i].subsetOf(group.domains[j]) {
+					a.report(Diagnostic{
+						Kind:    DiagnosticRedundant,
+						Path:    childPaths[i],
+						Message: fmt.Sprintf("%s is subsumed by %s", describeLeaf(&children[i]), describeLeaf(&children[j])),
+						Related: childPaths[j],
+					})
+					break
+				}
+			}
+		}
+	}
+	return result
+}
+
+func (a *analyzer) reportDuplicates(children []Condition, childPaths []string) {
+	seen := make(map[string]int, len(children))
+	for i := range children {
+		key := conditionKey(&children[i])
+		if first, ok := seen[key]; ok {
+			a.report(Diagnostic{
+				Kind:    DiagnosticRedundant,
+				Path:    childPaths[i],
+				Message: "duplicate of an earlier sibling condition",
+				Related: childPaths[first],
+			})
+			continue
+		}
+		seen[key] = i
+	}
+}
+
+// leafGroup collects the leaf children of a compound node that constrain the
+// same fact (including its path and params).
+type leafGroup struct {
+	fact    string
+	indices []int
+	domains map[int]domain
+}
+
+func groupLeaves(children []Condition) []*leafGroup {
+	var groups []*leafGroup
+	byKey := make(map[string]*leafGroup)
+	for i := range children {
+		c := &children[i]
+		if c.Fact == "" || c.Operator == "" {
+			continue
+		}
+		d, ok := leafDomain(c)
+		if !ok {
+			continue
+		}
+		key := c.Fact + "|" + c.Path + "|" + canonicalJSON(c.Params)
+		g, ok := byKey[key]
+		if !ok {
+			g = &leafGroup{fact: c.Fact, domains: make(map[int]domain)}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.indices = append(g.indices, i)
+		g.domains[i] = d
+	}
+	return groups
+}
+
+var operatorAliases = map[string]string{
+	"lt":  "lessThan",
+	"gt":  "greaterThan",
+	"eq":  "equal",
+	"ne":  "notEqual",
+	"lte": "lessThanInclusive",
+	"gte": "greaterThanInclusive",
+}
+
+func canonicalOperator(op string) string {
+	if canonical, ok := operatorAliases[op]; ok {
+		return canonical
+	}
+	return op
+}
+
+func describeLeaf(c *Condition) string {
+	fact := c.Fact + c.Path
+	return fmt.Sprintf("%s %s %s", fact, c.Operator, canonicalJSON(c.Value))
+}
+
+func isEmptyList(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return false
+	}
+	return (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Len() == 0
+}
+
+func listValues(v interface{}) ([]interface{}, bool) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) {
+		return nil, false
+	}
+	values := make([]interface{}, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values, true
+}
+
+// interval is a range over the real line used to reason about numeric
+// comparison operators.
+type interval struct {
+	lo, hi         float64
+	loOpen, hiOpen bool
+}
+
+func fullInterval() interval {
+	return interval{lo: math.Inf(-1), hi: math.Inf(1), loOpen: true, hiOpen: true}
+}
+
+func (iv interval) isFull() bool {
+	return math.IsInf(iv.lo, -1) && math.IsInf(iv.hi, 1)
+}
+
+func (iv interval) empty() bool {
+	return iv.lo > iv.hi || (iv.lo == iv.hi && (iv.loOpen || iv.hiOpen))
+}
+
+func (iv interval) contains(f float64) bool {
+	if f < iv.lo || (f == iv.lo && iv.loOpen) {
+		return false
+	}
+	if f > iv.hi || (f == iv.hi && iv.hiOpen) {
+		return false
+	}
+	return true
+}
+
+func (iv interval) intersect(o interval) interval {
+	r := iv
+	if o.lo > r.lo || (o.lo == r.lo && o.loOpen) {
+		r.lo, r.loOpen = o.lo, o.loOpen
+	}
+	if o.hi < r.hi || (o.hi == r.hi && o.hiOpen) {
+		r.hi, r.hiOpen = o.hi, o.hiOpen
+	}
+	return r
+}
+
+func (iv interval) subsetOf(o interval) bool {
+	if iv.empty() {
+		return true
+	}
+	if iv.lo < o.lo || (iv.lo == o.lo && o.loOpen && !iv.loOpen) {
+		return false
+	}
+	if iv.hi > o.hi || (iv.hi == o.hi && o.hiOpen && !iv.hiOpen) {
+		return false
+	}
+	return true
+}
+
+// domain is the set of values a fact may take to satisfy one or more leaf
+// conditions: a numeric interval, an optional finite set of allowed values
+// and a finite set of excluded values.
+type domain struct {
+	iv         interval
+	hasAllowed bool
+	allowed    []interface{}
+	excluded   []interface{}
+}
+
+func fullDomain() domain {
+	return domain{iv: fullInterval()}
+}
+
+func leafDomain(c *Condition) (domain, bool) {
+	d := fullDomain()
+	op := canonicalOperator(c.Operator)
+	switch op {
+	case "lessThan", "lessThanInclusive", "greaterThan", "greaterThanInclusive":
+		f, ok := toFloat64(c.Value)
+		if !ok || math.IsNaN(f) {
+			return d, false
+		}
+		switch op {
+		case "lessThan":
+			d.iv.hi, d.iv.hiOpen = f, true
+		case "lessThanInclusive":
+			d.iv.hi, d.iv.hiOpen = f, false
+		case "greaterThan":
+			d.iv.lo, d.iv.loOpen = f, true
+		case "greaterThanInclusive":
+			d.iv.lo, d.iv.loOpen = f, false
+		}
+		return d, true
+	case "equal":
+		d.hasAllowed = true
+		d.allowed = []interface{}{c.Value}
+		return d, true
+	case "notEqual":
+		d.excluded = []interface{}{c.Value}
+		return d, true
+	case "in":
+		values, ok := listValues(c.Value)
+		if !ok {
+			return d, false
+		}
+		d.hasAllowed = true
+		d.allowed = values
+		return d, true
+	case "notIn":
+		values, ok := listValues(c.Value)
+		if !ok {
+			return d, false
+		}
+		d.excluded = values
+		return d, true
+	}
+	return d, false
+}
+
+func valuesEqual(a, b interface{}) bool {
+	fa, ok1 := toFloat64(a)
+	fb, ok2 := toFloat64(b)
+	if ok1 && ok2 {
+		return fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func valueIn(v interface{}, set []interface{}) bool {
+	for _, s := range set {
+		if valuesEqual(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// admits reports whether a concrete value satisfies every constraint of the
+// domain. Non-numeric values are only checked against the interval when it is
+// bounded, in which case they are conservatively assumed to satisfy it.
+func (d domain) admits(v interface{}) bool {
+	if d.hasAllowed && !valueIn(v, d.allowed) {
+		return false
+	}
+	if valueIn(v, d.excluded) {
+		return false
+	}
+	if f, ok := toFloat64(v); ok {
+		return d.iv.contains(f)
+	}
+	return true
+}
+
+func (d domain) satisfiable() bool {
+	if d.hasAllowed {
+		for _, v := range d.allowed {
+			if d.admits(v) {
+				return true
+			}
+		}
+		return false
+	}
+	if d.iv.empty() {
+		return false
+	}
+	if d.iv.lo == d.iv.hi {
+		return !valueIn(d.iv.lo, d.excluded)
+	}
+	return true
+}
+
+func (d domain) intersect(o domain) domain {
+	r := domain{iv: d.iv.intersect(o.iv)}
+	switch {
+	case d.hasAllowed && o.hasAllowed:
+		r.hasAllowed = true
+		for _, v := range d.allowed {
+			if valueIn(v, o.allowed) {
+				r.allowed = append(r.allowed, v)
+			}
+		}
+	case d.hasAllowed:
+		r.hasAllowed, r.allowed = true, d.allowed
+	case o.hasAllowed:
+		r.hasAllowed, r.allowed = true, o.allowed
+	}
+	r.excluded = append(append([]interface{}{}, d.excluded...), o.excluded...)
+	return r
+}
+
+// subsetOf reports whether every value admitted by d is admitted by o.
+func (d domain) subsetOf(o domain) bool {
+	if d.hasAllowed {
+		for _, v := range d.allowed {
+			if d.admits(v) && !o.admits(v) {
+				return false
+			}
+		}
+		return true
+	}
+	if o.hasAllowed {
+		return false
+	}
+	if !d.iv.subsetOf(o.iv) {
+		return false
+	}
+	for _, v := range o.excluded {
+		if valueIn(v, d.excluded) {
+			continue
+		}
+		if f, ok := toFloat64(v); ok && !d.iv.contains(f) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+func (d domain) equal(o domain) bool {
+	return d.subsetOf(o) && o.subsetOf(d)
+}
+
+// coversEverything reports whether the union of the domains admits every
+// value. Numeric operators are assumed to be applied to numeric facts.
+func coversEverything(domains []domain) bool {
+	var complements []domain
+	for _, d := range domains {
+		if !d.hasAllowed && d.iv.isFull() && len(d.excluded) > 0 {
+			complements = append(complements, d)
+		}
+	}
+	if len(complements) > 0 {
+		for _, c := range complements {
+			covered := true
+			for _, v := range c.excluded {
+				hit := false
+				for _, d := range domains {
+					if d.admits(v) {
+						hit = true
+						break
+					}
+				}
+				if !hit {
+					covered = false
+					break
+				}
+			}
+			if covered {
+				return true
+			}
+		}
+		return false
+	}
+
+	var ivs []interval
+	for _, d := range domains {
+		switch {
+		case d.hasAllowed:
+			for _, v := range d.allowed {
+				if f, ok := toFloat64(v); ok && d.admits(v) {
+					ivs = append(ivs, interval{lo: f, hi: f})
+				}
+			}
+		case len(d.excluded) == 0:
+			ivs = append(ivs, d.iv)
+		}
+	}
+	if len(ivs) == 0 {
+		return false
+	}
+	sort.Slice(ivs, func(i, j int) bool {
+		if ivs[i].lo != ivs[j].lo {
+			return ivs[i].lo < ivs[j].lo
+		}
+		return !ivs[i].loOpen && ivs[j].loOpen
+	})
+	if !math.IsInf(ivs[0].lo, -1) {
+		return false
+	}
+	hi, hiOpen := ivs[0].hi, ivs[0].hiOpen
+	for _, iv := range ivs[1:] {
+		if iv.lo > hi || (iv.lo == hi && hiOpen && iv.loOpen) {
+			return false
+		}
+		if iv.hi > hi || (iv.hi == hi && !iv.hiOpen) {
+			hi, hiOpen = iv.hi, iv.hiOpen
+		}
+	}
+	return math.IsInf(hi, 1)
+}
+
+// conditionKey returns a canonical representation of a condition tree in
+// which operator aliases are normalized and the children of All and Any are
+// sorted, so two semantically identical trees produce the same key.
+func conditionKey(c *Condition) string {
+	if c == nil {
+		return ""
+	}
+	switch {
+	case c.ConditionRef != "":
+		return "ref(" + c.ConditionRef + ")"
+	case len(c.All) > 0:
+		return "all(" + childKeys(c.All) + ")"
+	case len(c.Any) > 0:
+		return "any(" + childKeys(c.Any) + ")"
+	case c.Not != nil:
+		return "not(" + conditionKey(c.Not) + ")"
+	case c.Aggregate != nil:
+		a := c.Aggregate
+		return fmt.Sprintf("aggregate(%s|%s|%s|%s|%s|%s|%s|%s|%s)", a.Fact, a.Path, canonicalJSON(a.Params), a.itemFact(), a.Reduce, a.Of, canonicalOperator(a.Operator), canonicalJSON(a.Value), conditionKey(a.Where))
+	}
+	return fmt.Sprintf("leaf(%s|%s|%s|%s|%s)", c.Fact, c.Path, canonicalJSON(c.Params), canonicalOperator(c.Operator), canonicalJSON(c.Value))
+}
+
+func childKeys(children []Condition) string {
+	keys := make([]string, len(children))
+	for i := range children {
+		keys[i] = conditionKey(&children[i])
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func canonicalJSON(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
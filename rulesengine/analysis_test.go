@@ -0,0 +1,257 @@
+package rulesengine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func findDiagnostic(diags []Diagnostic, kind DiagnosticKind, path string) *Diagnostic {
+	for i := range diags {
+		if diags[i].Kind == kind && diags[i].Path == path {
+			return &diags[i]
+		}
+	}
+	return nil
+}
+
+func TestAnalyzeCondition_Findings(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition *Condition
+		wantKind  DiagnosticKind
+		wantPath  string
+	}{
+		{
+			name: "numeric contradiction",
+			condition: &Condition{All: []Condition{
+				{Fact: "age", Operator: "greaterThan", Value: 30},
+				{Fact: "age", Operator: "lt", Value: 20},
+			}},
+			wantKind: DiagnosticUnsatisfiable,
+			wantPath: "",
+		},
+		{
+			name: "open bounds touching",
+			condition: &Condition{All: []Condition{
+				{Fact: "x", Operator: "gt", Value: 5},
+				{Fact: "x", Operator: "lte", Value: 5},
+			}},
+			wantKind: DiagnosticUnsatisfiable,
+			wantPath: "",
+		},
+		{
+			name: "equal outside range",
+			condition: &Condition{All: []Condition{
+				{Fact: "x", Operator: "gte", Value: 10},
+				{Fact: "x", Operator: "equal", Value: 3},
+			}},
+			wantKind: DiagnosticUnsatisfiable,
+			wantPath: "",
+		},
+		{
+			name: "in and notIn disjoint",
+			condition: &Condition{All: []Condition{
+				{Fact: "country", Operator: "in", Value: []interface{}{"US", "CA"}},
+				{Fact: "country", Operator: "notIn", Value: []interface{}{"US", "CA", "MX"}},
+			}},
+			wantKind: DiagnosticUnsatisfiable,
+			wantPath: "",
+		},
+		{
+			name:      "in empty list",
+			condition: &Condition{All: []Condition{{Fact: "x", Operator: "in", Value: []interface{}{}}}},
+			wantKind:  DiagnosticUnsatisfiable,
+			wantPath:  "All[0]",
+		},
+		{
+			name: "complementary comparisons",
+			condition: &Condition{Any: []Condition{
+				{Fact: "x", Operator: "lt", Value: 10},
+				{Fact: "x", Operator: "gte", Value: 10},
+			}},
+			wantKind: DiagnosticAlwaysTrue,
+			wantPath: "",
+		},
+		{
+			name: "equal or notEqual",
+			condition: &Condition{Any: []Condition{
+				{Fact: "tier", Operator: "equal", Value: "gold"},
+				{Fact: "tier", Operator: "notEqual", Value: "gold"},
+			}},
+			wantKind: DiagnosticAlwaysTrue,
+			wantPath: "",
+		},
+		{
+			name: "Any with always-true branch",
+			condition: &Condition{Any: []Condition{
+				{Fact: "x", Operator: "eq", Value: 1},
+				{Fact: "y", Operator: "notIn", Value: []interface{}{}},
+			}},
+			wantKind: DiagnosticAlwaysTrue,
+			wantPath: "",
+		},
+		{
+			name: "duplicate leaf",
+			condition: &Condition{All: []Condition{
+				{Fact: "x", Operator: "gte", Value: 1},
+				{Fact: "y", Operator: "eq", Value: true},
+				{Fact: "x", Operator: "greaterThanInclusive", Value: 1},
+			}},
+			wantKind: DiagnosticRedundant,
+			wantPath: "All[2]",
+		},
+		{
+			name: "implied conjunct",
+			condition: &Condition{All: []Condition{
+				{Fact: "x", Operator: "gt", Value: 3},
+				{Fact: "x", Operator: "gt", Value: 5},
+			}},
+			wantKind: DiagnosticRedundant,
+			wantPath: "All[0]",
+		},
+		{
+			name: "subsumed disjunct",
+			condition: &Condition{Any: []Condition{
+				{Fact: "x", Operator: "in", Value: []interface{}{"a"}},
+				{Fact: "x", Operator: "in", Value: []interface{}{"a", "b"}},
+			}},
+			wantKind: DiagnosticRedundant,
+			wantPath: "Any[0]",
+		},
+		{
+			name: "nested contradiction",
+			condition: &Condition{Any: []Condition{
+				{Fact: "y", Operator: "eq", Value: 1},
+				{Not: &Condition{All: []Condition{
+					{Fact: "x", Operator: "eq", Value: "a"},
+					{Fact: "x", Operator: "eq", Value: "b"},
+				}}},
+			}},
+			wantKind: DiagnosticUnsatisfiable,
+			wantPath: "Any[1].Not",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diags := AnalyzeCondition(tt.condition)
+			assert.NotNil(t, findDiagnostic(diags, tt.wantKind, tt.wantPath), "diagnostics: %v", diags)
+		})
+	}
+}
+
+func TestAnalyzeCondition_Clean(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition *Condition
+	}{
+		{"satisfiable range", &Condition{All: []Condition{
+			{Fact: "age", Operator: "gte", Value: 18},
+			{Fact: "age", Operator: "lt", Value: 65},
+		}}},
+		{"different facts", &Condition{All: []Condition{
+			{Fact: "a", Operator: "gt", Value: 30},
+			{Fact: "b", Operator: "lt", Value: 20},
+		}}},
+		{"different paths", &Condition{All: []Condition{
+			{Fact: "user", Path: ".age", Operator: "gt", Value: 30},
+			{Fact: "user", Path: ".score", Operator: "lt", Value: 20},
+		}}},
+		{"gap in Any", &Condition{Any: []Condition{
+			{Fact: "x", Operator: "lt", Value: 10},
+			{Fact: "x", Operator: "gt", Value: 10},
+		}}},
+		{"decorated operator", &Condition{All: []Condition{
+			{Fact: "x", Operator: "swap:gt", Value: 30},
+			{Fact: "x", Operator: "lt", Value: 20},
+		}}},
+		{"condition reference", &Condition{ConditionRef: "shared"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Empty(t, AnalyzeCondition(tt.condition))
+		})
+	}
+}
+
+func TestEngine_Analyze_Rules(t *testing.T) {
+	engine := NewEngine()
+	engine.SetCondition("never", Condition{All: []Condition{
+		{Fact: "x", Operator: "gt", Value: 10},
+		{Fact: "x", Operator: "lt", Value: 0},
+	}})
+
+	require.NoError(t, engine.AddRule(NewRule(
+		Condition{Fact: "score", Operator: "gte", Value: 700},
+		Event{Type: "approve"},
+		WithName("approve"), WithPriorityForRule(10),
+	)))
+	require.NoError(t, engine.AddRule(NewRule(
+		Condition{Fact: "score", Operator: "greaterThanInclusive", Value: 700},
+		Event{Type: "decline"},
+		WithName("decline"), WithPriorityForRule(5),
+	)))
+	require.NoError(t, engine.AddRule(NewRule(
+		Condition{Fact: "score", Operator: "gte", Value: 700},
+		Event{Type: "approve"},
+		WithName("approve-copy"), WithPriorityForRule(1),
+	)))
+	require.NoError(t, engine.AddRule(NewRule(
+		Condition{All: []Condition{{ConditionRef: "never"}, {Fact: "y", Operator: "eq", Value: 1}}},
+		Event{Type: "unreachable"},
+		WithName("unreachable"), WithPriorityForRule(0),
+	)))
+
+	diags := engine.Analyze()
+
+	shadowed := findDiagnostic(diags, DiagnosticShadowed, "rules[1]")
+	require.NotNil(t, shadowed, "diagnostics: %v", diags)
+	assert.Equal(t, "decline", shadowed.Rule)
+	assert.Equal(t, "rules[0]", shadowed.Related)
+
+	duplicate := findDiagnostic(diags, DiagnosticRedundant, "rules[2]")
+	require.NotNil(t, duplicate)
+	assert.Equal(t, "approve-copy", duplicate.Rule)
+	assert.Equal(t, "rules[0]", duplicate.Related)
+
+	unreachable := findDiagnostic(diags, DiagnosticUnsatisfiable, "rules[3]")
+	require.NotNil(t, unreachable)
+	assert.Equal(t, "unreachable", unreachable.Rule)
+	assert.Contains(t, unreachable.Message, "never fire")
+}
+
+func TestEngine_Analyze_RootContradictionReportedOnce(t *testing.T) {
+	engine := NewEngine()
+	require.NoError(t, engine.AddRule(NewRule(
+		Condition{All: []Condition{
+			{Fact: "age", Operator: "gt", Value: 30},
+			{Fact: "age", Operator: "lt", Value: 20},
+		}},
+		Event{Type: "x"},
+		WithName("impossible"),
+	)))
+
+	diags := engine.Analyze()
+	require.Len(t, diags, 1)
+	assert.Equal(t, DiagnosticUnsatisfiable, diags[0].Kind)
+	assert.Equal(t, "rules[0]", diags[0].Path)
+	assert.Contains(t, diags[0].Message, `"age"`)
+}
+
+func TestConditionKey_IgnoresChildOrder(t *testing.T) {
+	a := &Condition{All: []Condition{
+		{Fact: "x", Operator: "gt", Value: 1},
+		{Any: []Condition{{Fact: "y", Operator: "eq", Value: "a"}, {Fact: "z", Operator: "lt", Value: 2}}},
+	}}
+	b := &Condition{All: []Condition{
+		{Any: []Condition{{Fact: "z", Operator: "lessThan", Value: 2}, {Fact: "y", Operator: "equal", Value: "a"}}},
+		{Fact: "x", Operator: "greaterThan", Value: 1},
+	}}
+	assert.Equal(t, conditionKey(a), conditionKey(b))
+
+	c := &Condition{Any: b.All}
+	assert.NotEqual(t, conditionKey(a), conditionKey(c))
+}
@@ -0,0 +1,132 @@
+package rulesengine
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"runtime"
+	"sync"
+)
+
+// BatchItem is the outcome of running one input of a batch. Index is the
+// input's position in the batch; exactly one of Result and Err is set.
+type BatchItem struct {
+	Index  int
+	Result *RunResult
+	Err    error
+}
+
+// BatchStats summarizes a batch run. Fired counts, by rule name, the inputs
+// for which each rule passed.
+type BatchStats struct {
+	Items  int            `json:"items" bson:"items" xml:"items" yaml:"items"`
+	Errors int            `json:"errors" bson:"errors" xml:"errors" yaml:"errors"`
+	Events int            `json:"events" bson:"events" xml:"events" yaml:"events"`
+	Fired  map[string]int `json:"fired" bson:"fired" xml:"fired" yaml:"fired"`
+}
+
+// BatchOption configures RunBatch.
+type BatchOption func(*batchConfig)
+
+type batchConfig struct {
+	concurrency int
+	runOptions  []RunOption
+}
+
+// WithConcurrency limits the number of inputs run at once. The default is
+// runtime.GOMAXPROCS(0).
+func WithConcurrency(n int) BatchOption {
+	return func(c *batchConfig) {
+		c.concurrency = n
+	}
+}
+
+// WithBatchRunOptions applies options to the run of every input.
+func WithBatchRunOptions(options ...RunOption) BatchOption {
+	return func(c *batchConfig) {
+		c.runOptions = append(c.runOptions, options...)
+	}
+}
+
+// RunBatch runs the engine once for each fact map of inputs, which may be a
+// slice wrapped with slices.Values or a stream such as the lines of a file.
+// Inputs are read lazily and run concurrently; fn receives each outcome as
+// soon as it is ready, in completion order, and is never called
+// concurrently. A failing input is reported to fn through BatchItem.Err and
+// does not stop the batch.
+//
+// RunBatch stops reading inputs when ctx is cancelled or fn returns an
+// error, waits for the inputs already started, and returns that error along
+// with the stats of the items delivered so far.
+func (e *Engine) RunBatch(ctx context.Context, inputs iter.Seq[map[string]interface{}], fn func(BatchItem) error, options ...BatchOption) (*BatchStats, error) {
+	cfg := &batchConfig{concurrency: runtime.GOMAXPROCS(0)}
+	for _, opt := range options {
+		opt(cfg)
+	}
+	if cfg.concurrency < 1 {
+		return nil, fmt.Errorf("batch concurrency must be positive, got %d", cfg.concurrency)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stats := &BatchStats{Fired: map[string]int{}}
+	items := make(chan BatchItem)
+	done := make(chan error, 1)
+	go func() {
+		var fnErr error
+		for item := range items {
+			if fnErr != nil {
+				continue
+			}
+			stats.add(item)
+			if err := fn(item); err != nil {
+				fnErr = err
+				cancel()
+			}
+		}
+		done <- fnErr
+	}()
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, cfg.concurrency)
+	index := 0
+	for facts := range inputs {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, facts map[string]interface{}) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			result, err := e.Run(facts, cfg.runOptions...)
+			items <- BatchItem{Index: i, Result: result, Err: err}
+		}(index, facts)
+		index++
+	}
+	wg.Wait()
+	close(items)
+
+	if err := <-done; err != nil {
+		return stats, err
+	}
+	return stats, ctx.Err()
+}
+
+func (s *BatchStats) add(item BatchItem) {
+	s.Items++
+	if item.Err != nil {
+		s.Errors++
+		return
+	}
+	s.Events += len(item.Result.Events)
+	for _, rr := range item.Result.RuleResults {
+		if rr.Success {
+			s.Fired[rr.Name]++
+		}
+	}
+}
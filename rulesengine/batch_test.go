@@ -0,0 +1,115 @@
+package rulesengine
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func batchEngine(t *testing.T) *Engine {
+	t.Helper()
+	engine := NewEngine()
+	require.NoError(t, engine.AddRule(NewRule(
+		Condition{Fact: "age", Operator: "greaterThanInclusive", Value: 18},
+		Event{Type: "adult"}, WithName("adult"))))
+	require.NoError(t, engine.AddRule(NewRule(
+		Condition{Fact: "age", Operator: "greaterThanInclusive", Value: 65},
+		Event{Type: "senior"}, WithName("senior"))))
+	return engine
+}
+
+func TestEngine_RunBatch(t *testing.T) {
+	engine := batchEngine(t)
+	inputs := []map[string]interface{}{
+		{"age": 10}, {"age": 20}, {"age": 70}, {"age": 30}, {},
+	}
+
+	results := make([]BatchItem, len(inputs))
+	seen := 0
+	stats, err := engine.RunBatch(context.Background(), slices.Values(inputs), func(item BatchItem) error {
+		results[item.Index] = item
+		seen++
+		return nil
+	}, WithConcurrency(2))
+	require.NoError(t, err)
+	assert.Equal(t, len(inputs), seen)
+
+	assert.Empty(t, results[0].Result.Events)
+	assert.Equal(t, []string{"adult", "senior"}, eventTypes(results[2].Result))
+	// The missing fact fails only its own input.
+	assert.Error(t, results[4].Err)
+	assert.Nil(t, results[4].Result)
+
+	assert.Equal(t, &BatchStats{
+		Items:  5,
+		Errors: 1,
+		Events: 4,
+		Fired:  map[string]int{"adult": 3, "senior": 1},
+	}, stats)
+}
+
+func TestEngine_RunBatchConcurrencyLimit(t *testing.T) {
+	engine := NewEngine()
+	var running, peak atomic.Int32
+	require.NoError(t, engine.AddFact("slow", FactFunc(func(map[string]interface{}, *Almanac) (interface{}, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		return 1, nil
+	}), WithNoCache()))
+	require.NoError(t, engine.AddRule(NewRule(Condition{Fact: "slow", Operator: "equal", Value: 1}, Event{Type: "x"})))
+
+	inputs := func(yield func(map[string]interface{}) bool) {
+		for i := 0; i < 50; i++ {
+			if !yield(map[string]interface{}{}) {
+				return
+			}
+		}
+	}
+	stats, err := engine.RunBatch(context.Background(), inputs, func(BatchItem) error { return nil }, WithConcurrency(3))
+	require.NoError(t, err)
+	assert.Equal(t, 50, stats.Items)
+	assert.LessOrEqual(t, peak.Load(), int32(3))
+
+	_, err = engine.RunBatch(context.Background(), inputs, func(BatchItem) error { return nil }, WithConcurrency(0))
+	assert.ErrorContains(t, err, "concurrency must be positive")
+}
+
+func TestEngine_RunBatchStops(t *testing.T) {
+	engine := batchEngine(t)
+	read := 0
+	inputs := func(yield func(map[string]interface{}) bool) {
+		for {
+			read++
+			if !yield(map[string]interface{}{"age": 20}) {
+				return
+			}
+		}
+	}
+
+	stop := errors.New("stop")
+	stats, err := engine.RunBatch(context.Background(), inputs, func(item BatchItem) error {
+		if item.Index >= 10 {
+			return stop
+		}
+		return nil
+	}, WithConcurrency(1))
+	assert.ErrorIs(t, err, stop)
+	assert.Less(t, read, 20)
+	assert.Equal(t, 11, stats.Items)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = engine.RunBatch(ctx, inputs, func(BatchItem) error { return nil })
+	assert.ErrorIs(t, err, context.Canceled)
+}
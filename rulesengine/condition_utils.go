@@ -1,6 +1,7 @@
 package rulesengine
 
 // CountLeafConditions returns the number of leaf (fact-based) conditions in the tree.
+// An aggregate counts as one leaf plus the leaves of its Where tree.
 func CountLeafConditions(c *Condition) int {
 	if c == nil {
 		return 0
@@ -19,11 +20,15 @@ func CountLeafConditions(c *Condition) int {
 	if c.Not != nil {
 		count += CountLeafConditions(c.Not)
 	}
+	if c.Aggregate != nil {
+		count += 1 + CountLeafConditions(c.Aggregate.Where)
+	}
 	return count
 }
 
 // MaxDepth returns the maximum nesting depth of the condition tree.
-// A single leaf condition has depth 1. An All/Any/Not wrapper adds 1.
+// A single leaf condition has depth 1. An All/Any/Not wrapper adds 1, as does
+// an aggregate around its Where tree.
 func MaxDepth(c *Condition) int {
 	if c == nil {
 		return 0
@@ -48,13 +53,19 @@ func MaxDepth(c *Condition) int {
 			maxChild = d
 		}
 	}
-	if maxChild > 0 || len(c.All) > 0 || len(c.Any) > 0 || c.Not != nil {
+	if c.Aggregate != nil {
+		if d := MaxDepth(c.Aggregate.Where); d > maxChild {
+			maxChild = d
+		}
+	}
+	if maxChild > 0 || len(c.All) > 0 || len(c.Any) > 0 || c.Not != nil || c.Aggregate != nil {
 		return maxChild + 1
 	}
 	return 0
 }
 
 // WalkLeaves calls fn for each leaf condition (one with a Fact field set) in the tree.
+// Leaves inside an aggregate's Where tree are visited; the aggregate itself is not.
 // If fn returns an error, traversal stops and the error is returned.
 func WalkLeaves(c *Condition, fn func(leaf *Condition) error) error {
 	if c == nil {
@@ -76,5 +87,8 @@ func WalkLeaves(c *Condition, fn func(leaf *Condition) error) error {
 	if c.Not != nil {
 		return WalkLeaves(c.Not, fn)
 	}
+	if c.Aggregate != nil {
+		return WalkLeaves(c.Aggregate.Where, fn)
+	}
 	return nil
 }
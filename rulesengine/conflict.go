@@ -0,0 +1,161 @@
+package rulesengine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Candidate is a passing rule competing to decide the outcome of a run.
+// Candidates are listed in evaluation order. Salience is the rule's
+// Priority, or its computed salience when the run used dynamic salience.
+type Candidate struct {
+	Rule     *Rule
+	Event    Event
+	Salience int
+}
+
+// OverruledEvent is an event that lost conflict resolution.
+type OverruledEvent struct {
+	Event  Event  `json:"event" bson:"event" xml:"event" yaml:"event"`
+	Rule   string `json:"rule" bson:"rule" xml:"rule" yaml:"rule"`
+	Reason string `json:"reason" bson:"reason" xml:"reason" yaml:"reason"`
+}
+
+// Decision is the single outcome chosen from a run's events. Event is nil
+// when the resolver refuses to pick one, as AllMustAgree does on conflict.
+type Decision struct {
+	Event     *Event           `json:"event,omitempty" bson:"event,omitempty" xml:"event,omitempty" yaml:"event,omitempty"`
+	Rule      string           `json:"rule,omitempty" bson:"rule,omitempty" xml:"rule,omitempty" yaml:"rule,omitempty"`
+	Strategy  string           `json:"strategy" bson:"strategy" xml:"strategy" yaml:"strategy"`
+	Overruled []OverruledEvent `json:"overruled" bson:"overruled" xml:"overruled" yaml:"overruled"`
+}
+
+// ConflictResolver picks a decision from the events of the rules that
+// passed. It is only called when at least one rule passed.
+type ConflictResolver interface {
+	Resolve(candidates []Candidate) (*Decision, error)
+}
+
+// ConflictResolverFunc adapts a function to ConflictResolver. Decisions
+// without a Strategy are reported as "custom".
+type ConflictResolverFunc func(candidates []Candidate) (*Decision, error)
+
+func (f ConflictResolverFunc) Resolve(candidates []Candidate) (*Decision, error) {
+	d, err := f(candidates)
+	if d != nil && d.Strategy == "" {
+		d.Strategy = "custom"
+	}
+	return d, err
+}
+
+// WithConflictResolver makes Run resolve the events of passing rules into
+// RunResult.Decision.
+func WithConflictResolver(r ConflictResolver) EngineOption {
+	return func(e *Engine) {
+		e.conflictResolver = r
+	}
+}
+
+// decide builds a decision for the candidate at index winner, explaining
+// every other candidate's loss with reason.
+func decide(strategy string, candidates []Candidate, winner int, reason func(loser Candidate) string) *Decision {
+	w := candidates[winner]
+	event := w.Event
+	d := &Decision{Event: &event, Rule: w.Rule.Name, Strategy: strategy, Overruled: []OverruledEvent{}}
+	for i, c := range candidates {
+		if i != winner {
+			d.Overruled = append(d.Overruled, OverruledEvent{Event: c.Event, Rule: c.Rule.Name, Reason: reason(c)})
+		}
+	}
+	return d
+}
+
+// rankedWinner returns the index of the candidate ranking highest by score,
+// ties going to the earlier candidate.
+func rankedWinner(candidates []Candidate, score func(Candidate) int) int {
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return score(candidates[order[a]]) > score(candidates[order[b]])
+	})
+	return order[0]
+}
+
+// PriorityWins picks the event of the passing rule with the highest
+// Candidate.Salience, ties going to the rule evaluated first.
+func PriorityWins() ConflictResolver {
+	return ConflictResolverFunc(func(candidates []Candidate) (*Decision, error) {
+		w := rankedWinner(candidates, func(c Candidate) int { return c.Salience })
+		winner := candidates[w]
+		return decide("priorityWins", candidates, w, func(c Candidate) string {
+			if c.Salience == winner.Salience {
+				return fmt.Sprintf("tied on priority %d with %s, which was evaluated first", c.Salience, ruleLabelForDecision(winner))
+			}
+			return fmt.Sprintf("priority %d is lower than %s's %d", c.Salience, ruleLabelForDecision(winner), winner.Salience)
+		}), nil
+	})
+}
+
+// FirstMatch picks the event of the first rule that passed.
+func FirstMatch() ConflictResolver {
+	return ConflictResolverFunc(func(candidates []Candidate) (*Decision, error) {
+		return decide("firstMatch", candidates, 0, func(Candidate) string {
+			return fmt.Sprintf("passed after %s", ruleLabelForDecision(candidates[0]))
+		}), nil
+	})
+}
+
+// MostSpecific picks the event of the passing rule with the most leaf
+// conditions, as counted by CountLeafConditions. Ties go to the higher
+// priority and then to the rule evaluated first.
+func MostSpecific() ConflictResolver {
+	return ConflictResolverFunc(func(candidates []Candidate) (*Decision, error) {
+		leaves := func(c Candidate) int { return CountLeafConditions(&c.Rule.Conditions) }
+		// Candidates are in evaluation order, which is already by
+		// descending priority, so ranking by leaf count alone keeps the
+		// priority tie-break.
+		w := rankedWinner(candidates, leaves)
+		winner := candidates[w]
+		return decide("mostSpecific", candidates, w, func(c Candidate) string {
+			if leaves(c) == leaves(winner) {
+				return fmt.Sprintf("tied on %d leaf conditions with %s, which was evaluated first", leaves(c), ruleLabelForDecision(winner))
+			}
+			return fmt.Sprintf("%d leaf conditions, fewer than %s's %d", leaves(c), ruleLabelForDecision(winner), leaves(winner))
+		}), nil
+	})
+}
+
+// AllMustAgree decides only when every passing rule emitted the same event
+// (type and params). On disagreement the decision has no event and every
+// candidate is listed as overruled.
+func AllMustAgree() ConflictResolver {
+	return ConflictResolverFunc(func(candidates []Candidate) (*Decision, error) {
+		first := candidates[0]
+		for _, c := range candidates[1:] {
+			if eventsEqual(c.Event, first.Event) {
+				continue
+			}
+			d := &Decision{Strategy: "allMustAgree", Overruled: []OverruledEvent{}}
+			for _, c := range candidates {
+				d.Overruled = append(d.Overruled, OverruledEvent{
+					Event:  c.Event,
+					Rule:   c.Rule.Name,
+					Reason: "passing rules emitted conflicting events",
+				})
+			}
+			return d, nil
+		}
+		return decide("allMustAgree", candidates, 0, func(c Candidate) string {
+			return fmt.Sprintf("duplicates the event of %s", ruleLabelForDecision(first))
+		}), nil
+	})
+}
+
+func ruleLabelForDecision(c Candidate) string {
+	if c.Rule.Name == "" {
+		return "an unnamed rule"
+	}
+	return fmt.Sprintf("rule %q", c.Rule.Name)
+}
@@ -0,0 +1,98 @@
+package rulesengine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func conflictEngine(t *testing.T, resolver ConflictResolver) *Engine {
+	t.Helper()
+	engine := NewEngine(WithConflictResolver(resolver))
+	require.NoError(t, engine.AddRule(NewRule(
+		Condition{Fact: "score", Operator: "gte", Value: 50},
+		Event{Type: "approve"},
+		WithName("approve"), WithPriorityForRule(10),
+	)))
+	require.NoError(t, engine.AddRule(NewRule(
+		Condition{All: []Condition{
+			{Fact: "score", Operator: "lt", Value: 80},
+			{Fact: "country", Operator: "eq", Value: "XX"},
+			{Fact: "amount", Operator: "gt", Value: 1000},
+		}},
+		Event{Type: "decline"},
+		WithName("decline"), WithPriorityForRule(5),
+	)))
+	require.NoError(t, engine.AddRule(NewRule(
+		Condition{Fact: "amount", Operator: "gt", Value: 1000},
+		Event{Type: "review"},
+		WithName("review"), WithPriorityForRule(5),
+	)))
+	return engine
+}
+
+var conflictFacts = map[string]interface{}{"score": 60, "country": "XX", "amount": 5000}
+
+func TestConflictResolution_Strategies(t *testing.T) {
+	result, err := conflictEngine(t, PriorityWins()).Run(conflictFacts)
+	require.NoError(t, err)
+	d := result.Decision
+	require.NotNil(t, d)
+	assert.Equal(t, "priorityWins", d.Strategy)
+	assert.Equal(t, "approve", d.Event.Type)
+	assert.Equal(t, "approve", d.Rule)
+	require.Len(t, d.Overruled, 2)
+	assert.Equal(t, "decline", d.Overruled[0].Rule)
+	assert.Equal(t, `priority 5 is lower than rule "approve"'s 10`, d.Overruled[0].Reason)
+	assert.Len(t, result.Events, 3)
+
+	result, err = conflictEngine(t, FirstMatch()).Run(conflictFacts)
+	require.NoError(t, err)
+	assert.Equal(t, "approve", result.Decision.Event.Type)
+	assert.Equal(t, `passed after rule "approve"`, result.Decision.Overruled[1].Reason)
+
+	result, err = conflictEngine(t, MostSpecific()).Run(conflictFacts)
+	require.NoError(t, err)
+	d = result.Decision
+	assert.Equal(t, "decline", d.Event.Type)
+	require.Len(t, d.Overruled, 2)
+	assert.Equal(t, `1 leaf conditions, fewer than rule "decline"'s 3`, d.Overruled[0].Reason)
+
+	result, err = conflictEngine(t, AllMustAgree()).Run(conflictFacts)
+	require.NoError(t, err)
+	d = result.Decision
+	assert.Nil(t, d.Event)
+	assert.Len(t, d.Overruled, 3)
+
+	result, err = conflictEngine(t, AllMustAgree()).Run(map[string]interface{}{"score": 90, "country": "US", "amount": 10})
+	require.NoError(t, err)
+	assert.Equal(t, "approve", result.Decision.Event.Type)
+	assert.Empty(t, result.Decision.Overruled)
+
+	result, err = conflictEngine(t, PriorityWins()).Run(map[string]interface{}{"score": 10, "country": "US", "amount": 10})
+	require.NoError(t, err)
+	assert.Nil(t, result.Decision)
+}
+
+func TestConflictResolution_Custom(t *testing.T) {
+	lastWins := ConflictResolverFunc(func(candidates []Candidate) (*Decision, error) {
+		last := len(candidates) - 1
+		return decide("", candidates, last, func(Candidate) string { return "not last" }), nil
+	})
+	result, err := conflictEngine(t, lastWins).Run(conflictFacts)
+	require.NoError(t, err)
+	assert.Equal(t, "custom", result.Decision.Strategy)
+	assert.Equal(t, "review", result.Decision.Event.Type)
+
+	failing := ConflictResolverFunc(func([]Candidate) (*Decision, error) {
+		return nil, errors.New("no quorum")
+	})
+	_, err = conflictEngine(t, failing).Run(conflictFacts)
+	assert.EqualError(t, err, "no quorum")
+
+	result, err = NewEngine().Run(conflictFacts)
+	require.NoError(t, err)
+	assert.Nil(t, result.Decision)
+}
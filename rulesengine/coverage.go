@@ -0,0 +1,306 @@
+package rulesengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sync"
+)
+
+// CoverageCollector records how often rules and their condition nodes are
+// evaluated, true, false or skipped across any number of runs. Nodes are
+// addressed with the same paths ValidateCondition uses, relative to the
+// rule's root condition. A collector is safe for concurrent use.
+type CoverageCollector struct {
+	mu    sync.Mutex
+	runs  int
+	rules map[string]*ruleCoverage
+	order []string
+}
+
+type ruleCoverage struct {
+	counts CoverageCounts
+	nodes  map[string]*NodeCoverage
+	order  []string
+}
+
+// CoverageCounts holds the outcome counters for a rule or condition node.
+type CoverageCounts struct {
+	Evaluated int `json:"evaluated" bson:"evaluated" xml:"evaluated" yaml:"evaluated"`
+	True      int `json:"true" bson:"true" xml:"true" yaml:"true"`
+	False     int `json:"false" bson:"false" xml:"false" yaml:"false"`
+	Skipped   int `json:"skipped" bson:"skipped" xml:"skipped" yaml:"skipped"`
+}
+
+func (c *CoverageCounts) add(o CoverageCounts) {
+	c.Evaluated += o.Evaluated
+	c.True += o.True
+	c.False += o.False
+	c.Skipped += o.Skipped
+}
+
+func (c *CoverageCounts) record(evaluated, result bool) {
+	switch {
+	case !evaluated:
+		c.Skipped++
+	case result:
+		c.Evaluated++
+		c.True++
+	default:
+		c.Evaluated++
+		c.False++
+	}
+}
+
+// NodeCoverage is the coverage of a single condition node within a rule.
+type NodeCoverage struct {
+	Path        string         `json:"path" bson:"path" xml:"path" yaml:"path"`
+	Description string         `json:"description" bson:"description" xml:"description" yaml:"description"`
+	Counts      CoverageCounts `json:"counts" bson:"counts" xml:"counts" yaml:"counts"`
+}
+
+// RuleCoverage is the coverage of a rule and every node in its condition tree.
+type RuleCoverage struct {
+	Name   string         `json:"name" bson:"name" xml:"name" yaml:"name"`
+	Counts CoverageCounts `json:"counts" bson:"counts" xml:"counts" yaml:"counts"`
+	Nodes  []NodeCoverage `json:"nodes" bson:"nodes" xml:"nodes" yaml:"nodes"`
+}
+
+// CoverageReport is a point-in-time snapshot of a CoverageCollector.
+type CoverageReport struct {
+	Runs       int            `json:"runs" bson:"runs" xml:"runs" yaml:"runs"`
+	Rules      []RuleCoverage `json:"rules" bson:"rules" xml:"rules" yaml:"rules"`
+	RulesFired int            `json:"rulesFired" bson:"rulesFired" xml:"rulesFired" yaml:"rulesFired"`
+	// NodesCovered counts nodes that were evaluated at least once;
+	// NodesBothOutcomes counts nodes that were seen both true and false.
+	TotalNodes        int `json:"totalNodes" bson:"totalNodes" xml:"totalNodes" yaml:"totalNodes"`
+	NodesCovered      int `json:"nodesCovered" bson:"nodesCovered" xml:"nodesCovered" yaml:"nodesCovered"`
+	NodesBothOutcomes int `json:"nodesBothOutcomes" bson:"nodesBothOutcomes" xml:"nodesBothOutcomes" yaml:"nodesBothOutcomes"`
+}
+
+// NewCoverageCollector creates an empty collector.
+func NewCoverageCollector() *CoverageCollector {
+	return &CoverageCollector{rules: make(map[string]*ruleCoverage)}
+}
+
+// WithCoverage records the run into the given collector. Coverage needs the
+// evaluation trace, so the rules are traced even if WithTrace is not set;
+// the trace is only kept on the RuleResults when WithTrace is also given.
+func WithCoverage(collector *CoverageCollector) RunOption {
+	return func(c *runConfig) { c.coverage = collector }
+}
+
+func coverageKey(rule *Rule, index int) string {
+	if rule.Name != "" {
+		return rule.Name
+	}
+	return fmt.Sprintf("rules[%d]", index)
+}
+
+func (cc *CoverageCollector) recordRun() {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.runs++
+}
+
+// recordRule records one evaluation of a rule. A nil trace means the rule was
+// not evaluated during the run.
+func (cc *CoverageCollector) recordRule(key string, rule *Rule, trace *TraceNode) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	rc := cc.ruleFor(key)
+	rc.counts.record(trace != nil, trace != nil && trace.Result)
+	rc.walk(&rule.Conditions, "", trace)
+}
+
+func (cc *CoverageCollector) ruleFor(key string) *ruleCoverage {
+	rc, ok := cc.rules[key]
+	if !ok {
+		rc = &ruleCoverage{nodes: make(map[string]*NodeCoverage)}
+		cc.rules[key] = rc
+		cc.order = append(cc.order, key)
+	}
+	return rc
+}
+
+func (rc *ruleCoverage) nodeFor(path, description string) *NodeCoverage {
+	n, ok := rc.nodes[path]
+	if !ok {
+		n = &NodeCoverage{Path: path, Description: description}
+		rc.nodes[path] = n
+		rc.order = append(rc.order, path)
+	}
+	return n
+}
+
+// walk records a condition node and its children against the trace produced
+// by EvaluateWithTrace. Children missing from the trace were short-circuited
+// and are counted as skipped. Referenced conditions are recorded at the
+// reference node only.
+func (rc *ruleCoverage) walk(c *Condition, path string, trace *TraceNode) {
+	rc.nodeFor(path, describeNode(c)).Counts.record(trace != nil, trace != nil && trace.Result)
+
+	childTrace := func(i int) *TraceNode {
+		if trace == nil || i >= len(trace.Children) {
+			return nil
+		}
+		return trace.Children[i]
+	}
+	switch {
+	case c.ConditionRef != "":
+	case len(c.All) > 0:
+		for i := range c.All {
+			rc.walk(&c.All[i], joinPath(path, fmt.Sprintf("All[%d]", i)), childTrace(i))
+		}
+	case len(c.Any) > 0:
+		for i := range c.Any {
+			rc.walk(&c.Any[i], joinPath(path, fmt.Sprintf("Any[%d]", i)), childTrace(i))
+		}
+	case c.Not != nil:
+		rc.walk(c.Not, joinPath(path, "Not"), childTrace(0))
+	case c.Aggregate != nil && c.Aggregate.Where != nil:
+		// Where is evaluated once per item; an empty collection leaves it
+		// skipped.
+		where := joinPath(path, "Where")
+		if trace == nil || len(trace.Children) == 0 {
+			rc.walk(c.Aggregate.Where, where, nil)
+		}
+		if trace != nil {
+			for _, item := range trace.Children {
+				rc.walk(c.Aggregate.Where, where, item)
+			}
+		}
+	}
+}
+
+func describeNode(c *Condition) string {
+	switch {
+	case c.ConditionRef != "":
+		return "condition " + c.ConditionRef
+	case len(c.All) > 0:
+		return "all"
+	case len(c.Any) > 0:
+		return "any"
+	case c.Not != nil:
+		return "not"
+	case c.Aggregate != nil:
+		return describeAggregate(c.Aggregate)
+	}
+	return describeLeaf(c)
+}
+
+// Merge adds the counts of other into the collector.
+func (cc *CoverageCollector) Merge(other *CoverageCollector) {
+	if other == nil || other == cc {
+		return
+	}
+	report := other.Report()
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.runs += report.Runs
+	for _, r := range report.Rules {
+		rc := cc.ruleFor(r.Name)
+		rc.counts.add(r.Counts)
+		for _, n := range r.Nodes {
+			rc.nodeFor(n.Path, n.Description).Counts.add(n.Counts)
+		}
+	}
+}
+
+// Report returns a snapshot of the collected coverage.
+func (cc *CoverageCollector) Report() *CoverageReport {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	report := &CoverageReport{Runs: cc.runs, Rules: make([]RuleCoverage, 0, len(cc.order))}
+	for _, key := range cc.order {
+		rc := cc.rules[key]
+		r := RuleCoverage{Name: key, Counts: rc.counts, Nodes: make([]NodeCoverage, 0, len(rc.order))}
+		if rc.counts.True > 0 {
+			report.RulesFired++
+		}
+		for _, path := range rc.order {
+			n := *rc.nodes[path]
+			r.Nodes = append(r.Nodes, n)
+			report.TotalNodes++
+			if n.Counts.Evaluated > 0 {
+				report.NodesCovered++
+			}
+			if n.Counts.True > 0 && n.Counts.False > 0 {
+				report.NodesBothOutcomes++
+			}
+		}
+		report.Rules = append(report.Rules, r)
+	}
+	return report
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *CoverageReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteHTML writes the report as a standalone HTML page.
+func (r *CoverageReport) WriteHTML(w io.Writer) error {
+	return coverageTemplate.Execute(w, r)
+}
+
+func percent(part, total int) string {
+	if total == 0 {
+		return "0.0%"
+	}
+	return fmt.Sprintf("%.1f%%", float64(part)*100/float64(total))
+}
+
+func nodeClass(c CoverageCounts) string {
+	switch {
+	case c.Evaluated == 0:
+		return "uncovered"
+	case c.True == 0 || c.False == 0:
+		return "partial"
+	}
+	return "covered"
+}
+
+var coverageTemplate = template.Must(template.New("coverage").Funcs(template.FuncMap{
+	"percent":   percent,
+	"nodeClass": nodeClass,
+	"rootPath": func(path string) string {
+		if path == "" {
+			return "(root)"
+		}
+		return path
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Rule coverage</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+td.num { text-align: right; }
+tr.uncovered { background: #f8d7da; }
+tr.partial { background: #fff3cd; }
+tr.covered { background: #d4edda; }
+</style>
+</head>
+<body>
+<h1>Rule coverage</h1>
+<p>{{.Runs}} runs, {{.RulesFired}} of {{len .Rules}} rules fired ({{percent .RulesFired (len .Rules)}}).
+{{.NodesCovered}} of {{.TotalNodes}} condition nodes evaluated ({{percent .NodesCovered .TotalNodes}}),
+{{.NodesBothOutcomes}} seen both true and false ({{percent .NodesBothOutcomes .TotalNodes}}).</p>
+{{range .Rules}}
+<h2>{{.Name}}</h2>
+<p>evaluated {{.Counts.Evaluated}}, fired {{.Counts.True}}, failed {{.Counts.False}}, skipped {{.Counts.Skipped}}</p>
+<table>
+<tr><th>Path</th><th>Condition</th><th>Evaluated</th><th>True</th><th>False</th><th>Skipped</th></tr>
+{{range .Nodes}}<tr class="{{nodeClass .Counts}}"><td>{{rootPath .Path}}</td><td>{{.Description}}</td><td class="num">{{.Counts.Evaluated}}</td><td class="num">{{.Counts.True}}</td><td class="num">{{.Counts.False}}</td><td class="num">{{.Counts.Skipped}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
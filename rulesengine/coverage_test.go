@@ -0,0 +1,120 @@
+package rulesengine
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCoverageEngine(t *testing.T) *Engine {
+	engine := NewEngine()
+	require.NoError(t, engine.AddRule(NewRule(
+		Condition{All: []Condition{
+			{Fact: "age", Operator: "gte", Value: 18},
+			{Any: []Condition{
+				{Fact: "country", Operator: "eq", Value: "US"},
+				{Fact: "country", Operator: "eq", Value: "CA"},
+			}},
+		}},
+		Event{Type: "eligible"},
+		WithName("eligible"),
+	)))
+	return engine
+}
+
+func nodeCounts(t *testing.T, report *CoverageReport, rule, path string) CoverageCounts {
+	for _, r := range report.Rules {
+		if r.Name != rule {
+			continue
+		}
+		for _, n := range r.Nodes {
+			if n.Path == path {
+				return n.Counts
+			}
+		}
+	}
+	t.Fatalf("node %s of rule %s not found", path, rule)
+	return CoverageCounts{}
+}
+
+func TestCoverage_CountsNodes(t *testing.T) {
+	engine := newCoverageEngine(t)
+	collector := NewCoverageCollector()
+
+	for _, facts := range []map[string]interface{}{
+		{"age": 30, "country": "US"},
+		{"age": 30, "country": "CA"},
+		{"age": 10, "country": "US"},
+	} {
+		result, err := engine.Run(facts, WithCoverage(collector))
+		require.NoError(t, err)
+		assert.Nil(t, result.RuleResults[0].Trace, "trace is only kept with WithTrace")
+	}
+
+	report := collector.Report()
+	assert.Equal(t, 3, report.Runs)
+	require.Len(t, report.Rules, 1)
+	assert.Equal(t, CoverageCounts{Evaluated: 3, True: 2, False: 1}, report.Rules[0].Counts)
+
+	assert.Equal(t, CoverageCounts{Evaluated: 3, True: 2, False: 1}, nodeCounts(t, report, "eligible", ""))
+	assert.Equal(t, CoverageCounts{Evaluated: 3, True: 2, False: 1}, nodeCounts(t, report, "eligible", "All[0]"))
+	assert.Equal(t, CoverageCounts{Evaluated: 2, True: 2, Skipped: 1}, nodeCounts(t, report, "eligible", "All[1]"))
+	assert.Equal(t, CoverageCounts{Evaluated: 2, True: 1, False: 1, Skipped: 1}, nodeCounts(t, report, "eligible", "All[1].Any[0]"))
+	assert.Equal(t, CoverageCounts{Evaluated: 1, True: 1, Skipped: 2}, nodeCounts(t, report, "eligible", "All[1].Any[1]"))
+
+	assert.Equal(t, 1, report.RulesFired)
+	assert.Equal(t, 5, report.TotalNodes)
+	assert.Equal(t, 5, report.NodesCovered)
+	assert.Equal(t, 3, report.NodesBothOutcomes)
+}
+
+func TestCoverage_KeepsTraceWhenRequested(t *testing.T) {
+	engine := newCoverageEngine(t)
+	collector := NewCoverageCollector()
+
+	result, err := engine.Run(map[string]interface{}{"age": 30, "country": "US"}, WithCoverage(collector), WithTrace())
+	require.NoError(t, err)
+	assert.NotNil(t, result.RuleResults[0].Trace)
+}
+
+func TestCoverage_Merge(t *testing.T) {
+	engine := newCoverageEngine(t)
+	a := NewCoverageCollector()
+	b := NewCoverageCollector()
+
+	_, err := engine.Run(map[string]interface{}{"age": 30, "country": "US"}, WithCoverage(a))
+	require.NoError(t, err)
+	_, err = engine.Run(map[string]interface{}{"age": 30, "country": "MX"}, WithCoverage(b))
+	require.NoError(t, err)
+
+	a.Merge(b)
+	report := a.Report()
+	assert.Equal(t, 2, report.Runs)
+	assert.Equal(t, CoverageCounts{Evaluated: 2, True: 1, False: 1}, report.Rules[0].Counts)
+	assert.Equal(t, CoverageCounts{Evaluated: 2, True: 1, False: 1}, nodeCounts(t, report, "eligible", "All[1].Any[0]"))
+	assert.Equal(t, CoverageCounts{Evaluated: 1, False: 1, Skipped: 1}, nodeCounts(t, report, "eligible", "All[1].Any[1]"))
+}
+
+func TestCoverage_Export(t *testing.T) {
+	engine := newCoverageEngine(t)
+	collector := NewCoverageCollector()
+	_, err := engine.Run(map[string]interface{}{"age": 10, "country": "US"}, WithCoverage(collector))
+	require.NoError(t, err)
+	report := collector.Report()
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteJSON(&buf))
+	var decoded CoverageReport
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, report, &decoded)
+
+	buf.Reset()
+	require.NoError(t, report.WriteHTML(&buf))
+	html := buf.String()
+	assert.Contains(t, html, "<h2>eligible</h2>")
+	assert.Contains(t, html, `class="uncovered"`)
+	assert.Contains(t, html, "age gte 18")
+}
@@ -0,0 +1,608 @@
+package rulesengine
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HitPolicy decides which rows of a decision table fire when several match.
+type HitPolicy string
+
+const (
+	// HitPolicyUnique expects at most one row to match any input. A run in
+	// which a second row matches fails; Check reports rows that can overlap.
+	HitPolicyUnique HitPolicy = "unique"
+	// HitPolicyFirst fires only the first matching row in table order.
+	HitPolicyFirst HitPolicy = "first"
+	// HitPolicyPriority fires only the matching row with the highest Priority,
+	// ties going to the earlier row.
+	HitPolicyPriority HitPolicy = "priority"
+	// HitPolicyCollect fires every matching row.
+	HitPolicyCollect HitPolicy = "collect"
+)
+
+// DecisionInput maps an input column to a fact, an optional path and the
+// operator applied to the column's cells.
+type DecisionInput struct {
+	Fact     string                 `json:"fact" bson:"fact" xml:"fact" yaml:"fact"`
+	Path     string                 `json:"path,omitempty" bson:"path,omitempty" xml:"path,omitempty" yaml:"path,omitempty"`
+	Operator string                 `json:"operator" bson:"operator" xml:"operator" yaml:"operator"`
+	Params   map[string]interface{} `json:"params,omitempty" bson:"params,omitempty" xml:"params,omitempty" yaml:"params,omitempty"`
+}
+
+// DecisionRow is one row of a decision table. Inputs and Outputs line up
+// with the table's columns. A nil or "-" input cell matches any value.
+type DecisionRow struct {
+	Inputs   []interface{} `json:"inputs" bson:"inputs" xml:"inputs" yaml:"inputs"`
+	Outputs  []interface{} `json:"outputs" bson:"outputs" xml:"outputs" yaml:"outputs"`
+	Priority int           `json:"priority,omitempty" bson:"priority,omitempty" xml:"priority,omitempty" yaml:"priority,omitempty"`
+}
+
+// DecisionTable is a spreadsheet-style rule source: each row compiles into a
+// rule whose event carries the row's outputs as params.
+type DecisionTable struct {
+	Name string `json:"name" bson:"name" xml:"name" yaml:"name"`
+	// EventType is the type of the emitted events; it defaults to Name.
+	EventType string          `json:"eventType,omitempty" bson:"eventType,omitempty" xml:"eventType,omitempty" yaml:"eventType,omitempty"`
+	HitPolicy HitPolicy       `json:"hitPolicy,omitempty" bson:"hitPolicy,omitempty" xml:"hitPolicy,omitempty" yaml:"hitPolicy,omitempty"`
+	Priority  int             `json:"priority,omitempty" bson:"priority,omitempty" xml:"priority,omitempty" yaml:"priority,omitempty"`
+	Inputs    []DecisionInput `json:"inputs" bson:"inputs" xml:"inputs" yaml:"inputs"`
+	Outputs   []string        `json:"outputs" bson:"outputs" xml:"outputs" yaml:"outputs"`
+	Rows      []DecisionRow   `json:"rows" bson:"rows" xml:"rows" yaml:"rows"`
+}
+
+// LoadDecisionTableFromJSON parses a decision table in its JSON form.
+func LoadDecisionTableFromJSON(data []byte) (*DecisionTable, error) {
+	var table DecisionTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, err
+	}
+	return &table, nil
+}
+
+// LoadDecisionTableFromYAML parses a decision table in its YAML form.
+func LoadDecisionTableFromYAML(data []byte) (*DecisionTable, error) {
+	var table DecisionTable
+	if err := yaml.Unmarshal(data, &table); err != nil {
+		return nil, err
+	}
+	return &table, nil
+}
+
+// LoadDecisionTableFromCSV parses a decision table from CSV. The header row
+// names the columns:
+//
+//	input:<fact>[.<path>]:<operator>   an input column
+//	output:<param>                     an output column
+//	priority                           the row priority
+//
+// Cells are parsed as JSON when possible (numbers, booleans, quoted strings,
+// arrays) and used as plain strings otherwise. For in and notIn columns a
+// cell such as "US|CA" is split into a list. Empty and "-" input cells match
+// any value. The returned table has no name and the unique hit policy.
+func LoadDecisionTableFromCSV(data []byte) (*DecisionTable, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("decision table CSV has no header row")
+	}
+
+	table := &DecisionTable{HitPolicy: HitPolicyUnique}
+	type column struct {
+		kind  string
+		index int
+	}
+	columns := make([]column, len(records[0]))
+	for i, header := range records[0] {
+		header = strings.TrimSpace(header)
+		switch {
+		case strings.HasPrefix(header, "input:"):
+			spec := strings.TrimPrefix(header, "input:")
+			sep := strings.LastIndex(spec, ":")
+			if sep <= 0 || sep == len(spec)-1 {
+				return nil, fmt.Errorf("column %d: input header %q must be input:<fact>:<operator>", i+1, header)
+			}
+			input := DecisionInput{Fact: spec[:sep], Operator: spec[sep+1:]}
+			if dot := strings.Index(input.Fact, "."); dot > 0 {
+				input.Fact, input.Path = input.Fact[:dot], input.Fact[dot:]
+			}
+			columns[i] = column{kind: "input", index: len(table.Inputs)}
+			table.Inputs = append(table.Inputs, input)
+		case strings.HasPrefix(header, "output:"):
+			name := strings.TrimPrefix(header, "output:")
+			if name == "" {
+				return nil, fmt.Errorf("column %d: output header needs a param name", i+1)
+			}
+			columns[i] = column{kind: "output", index: len(table.Outputs)}
+			table.Outputs = append(table.Outputs, name)
+		case header == "priority":
+			columns[i] = column{kind: "priority"}
+		default:
+			return nil, fmt.Errorf("column %d: unrecognized header %q", i+1, header)
+		}
+	}
+
+	for n, record := range records[1:] {
+		row := DecisionRow{
+			Inputs:  make([]interface{}, len(table.Inputs)),
+			Outputs: make([]interface{}, len(table.Outputs)),
+		}
+		for i, cell := range record {
+			cell = strings.TrimSpace(cell)
+			switch col := columns[i]; col.kind {
+			case "input":
+				row.Inputs[col.index] = parseInputCell(cell, table.Inputs[col.index].Operator)
+			case "output":
+				if cell != "" {
+					row.Outputs[col.index] = parseCell(cell)
+				}
+			case "priority":
+				if cell == "" {
+					continue
+				}
+				p, ok := toFloat64(parseCell(cell))
+				if !ok || p != math.Trunc(p) {
+					return nil, fmt.Errorf("row %d: priority %q is not an integer", n+1, cell)
+				}
+				row.Priority = int(p)
+			}
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table, nil
+}
+
+// LoadDecisionTableFile loads a decision table from a .csv, .yaml/.yml or
+// JSON file. The table name defaults to the file name without extension.
+func LoadDecisionTableFile(path string) (*DecisionTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var table *DecisionTable
+	switch {
+	case strings.EqualFold(filepath.Ext(path), ".csv"):
+		table, err = LoadDecisionTableFromCSV(data)
+	case isYAMLFile(path):
+		table, err = LoadDecisionTableFromYAML(data)
+	default:
+		table, err = LoadDecisionTableFromJSON(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if table.Name == "" {
+		table.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return table, nil
+}
+
+func parseCell(cell string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(cell), &v); err == nil {
+		return v
+	}
+	return cell
+}
+
+func parseInputCell(cell, operator string) interface{} {
+	if cell == "" || cell == "-" {
+		return nil
+	}
+	v := parseCell(cell)
+	if op := canonicalOperator(operator); op == "in" || op == "notIn" {
+		if _, ok := v.([]interface{}); !ok {
+			parts := strings.Split(cell, "|")
+			list := make([]interface{}, len(parts))
+			for i, p := range parts {
+				list[i] = parseCell(strings.TrimSpace(p))
+			}
+			return list
+		}
+	}
+	return v
+}
+
+func isWildcard(cell interface{}) bool {
+	if cell == nil {
+		return true
+	}
+	s, ok := cell.(string)
+	return ok && s == "-"
+}
+
+func (t *DecisionTable) hitPolicy() HitPolicy {
+	if t.HitPolicy == "" {
+		return HitPolicyUnique
+	}
+	return t.HitPolicy
+}
+
+func (t *DecisionTable) validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("decision table needs a name")
+	}
+	switch t.hitPolicy() {
+	case HitPolicyUnique, HitPolicyFirst, HitPolicyPriority, HitPolicyCollect:
+	default:
+		return fmt.Errorf("decision table %s: unknown hit policy %q", t.Name, t.HitPolicy)
+	}
+	if len(t.Inputs) == 0 {
+		return fmt.Errorf("decision table %s: no input columns", t.Name)
+	}
+	for i, in := range t.Inputs {
+		if in.Fact == "" || in.Operator == "" {
+			return fmt.Errorf("decision table %s: input column %d needs a fact and an operator", t.Name, i+1)
+		}
+	}
+	for i, row := range t.Rows {
+		if len(row.Inputs) != len(t.Inputs) {
+			return fmt.Errorf("decision table %s: row %d has %d inputs, want %d", t.Name, i+1, len(row.Inputs), len(t.Inputs))
+		}
+		if len(row.Outputs) != len(t.Outputs) {
+			return fmt.Errorf("decision table %s: row %d has %d outputs, want %d", t.Name, i+1, len(row.Outputs), len(t.Outputs))
+		}
+	}
+	return nil
+}
+
+// rowLeaves returns one leaf condition per non-wildcard cell of the row.
+func (t *DecisionTable) rowLeaves(row DecisionRow) []Condition {
+	var leaves []Condition
+	for i, cell := range row.Inputs {
+		if isWildcard(cell) {
+			continue
+		}
+		in := t.Inputs[i]
+		leaves = append(leaves, Condition{Fact: in.Fact, Path: in.Path, Operator: in.Operator, Params: in.Params, Value: cell})
+	}
+	return leaves
+}
+
+// rowCondition builds the condition matched by a single row. A row of
+// wildcards compiles to a condition that always holds.
+func (t *DecisionTable) rowCondition(row DecisionRow) Condition {
+	leaves := t.rowLeaves(row)
+	switch len(leaves) {
+	case 0:
+		in := t.Inputs[0]
+		return Condition{Fact: in.Fact, Path: in.Path, Operator: "notIn", Params: in.Params, Value: []interface{}{}}
+	case 1:
+		return leaves[0]
+	}
+	return Condition{All: leaves}
+}
+
+// orderedRows returns the row indices in evaluation order: table order, or
+// descending Priority for the priority hit policy.
+func (t *DecisionTable) orderedRows() []int {
+	order := make([]int, len(t.Rows))
+	for i := range order {
+		order[i] = i
+	}
+	if t.hitPolicy() == HitPolicyPriority {
+		sort.SliceStable(order, func(a, b int) bool {
+			return t.Rows[order[a]].Priority > t.Rows[order[b]].Priority
+		})
+	}
+	return order
+}
+
+// Group returns the rule group that applies the table's hit policy to the
+// rules compiled by Rules.
+func (t *DecisionTable) Group() RuleGroup {
+	g := RuleGroup{Name: t.Name}
+	switch t.hitPolicy() {
+	case HitPolicyUnique:
+		g.Strategy = GroupUnique
+	case HitPolicyFirst, HitPolicyPriority:
+		g.Strategy = GroupFirstMatch
+	default:
+		g.Strategy = GroupAll
+	}
+	return g
+}
+
+// Rules compiles the table into one rule per row, named "<table>#<row>"
+// with 1-based row numbers, in evaluation order. The rules belong to the
+// group returned by Group, which the engine needs for the hit policy to
+// apply: rows are evaluated in order and, for the first and priority
+// policies, evaluation stops at the first match. To keep that order under
+// dynamic salience, each of the n rows gets a distinct priority, from
+// Priority*n + n for the first row down to Priority*n + 1 for the last.
+func (t *DecisionTable) Rules() ([]*Rule, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	eventType := t.EventType
+	if eventType == "" {
+		eventType = t.Name
+	}
+
+	rules := make([]*Rule, 0, len(t.Rows))
+	rows := len(t.Rows)
+	for n, idx := range t.orderedRows() {
+		row := t.Rows[idx]
+		params := make(map[string]interface{}, len(t.Outputs))
+		for i, name := range t.Outputs {
+			if row.Outputs[i] != nil {
+				params[name] = row.Outputs[i]
+			}
+		}
+		rules = append(rules, NewRule(t.rowCondition(row), Event{Type: eventType, Params: params},
+			WithName(fmt.Sprintf("%s#%d", t.Name, idx+1)),
+			WithPriorityForRule(t.Priority*rows+(rows-n)),
+			WithGroup(t.Name),
+		))
+	}
+	return rules, nil
+}
+
+// AddDecisionTable compiles the table and adds its group and rules to the
+// engine.
+func (e *Engine) AddDecisionTable(t *DecisionTable) error {
+	rules, err := t.Rules()
+	if err != nil {
+		return err
+	}
+	if err := e.AddGroup(t.Group()); err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if err := e.AddRule(rule); err != nil {
+			return fmt.Errorf("%s: %w", rule.Name, err)
+		}
+	}
+	return nil
+}
+
+// DecisionTableIssueKind classifies a problem found by DecisionTable.Check.
+type DecisionTableIssueKind string
+
+const (
+	// DecisionTableOverlap marks two rows of a unique table that can match
+	// the same input.
+	DecisionTableOverlap DecisionTableIssueKind = "overlap"
+	// DecisionTableUnreachable marks a row of a first or priority table that
+	// an earlier row fully covers. Rows holds the row and the covering row.
+	DecisionTableUnreachable DecisionTableIssueKind = "unreachable"
+	// DecisionTableGap marks an input no row matches.
+	DecisionTableGap DecisionTableIssueKind = "gap"
+)
+
+// DecisionTableIssue is a problem found by DecisionTable.Check. Rows are
+// 1-based. Gaps carry an example of unmatched facts.
+type DecisionTableIssue struct {
+	Kind    DecisionTableIssueKind `json:"kind" bson:"kind" xml:"kind" yaml:"kind"`
+	Rows    []int                  `json:"rows,omitempty" bson:"rows,omitempty" xml:"rows,omitempty" yaml:"rows,omitempty"`
+	Message string                 `json:"message" bson:"message" xml:"message" yaml:"message"`
+	Example map[string]interface{} `json:"example,omitempty" bson:"example,omitempty" xml:"example,omitempty" yaml:"example,omitempty"`
+}
+
+// maxGapSamples bounds the number of input combinations Check evaluates when
+// looking for gaps.
+const maxGapSamples = 20000
+
+// maxGapIssues bounds the number of gaps Check reports.
+const maxGapIssues = 10
+
+// Check reports overlapping rows in unique tables, rows of first and
+// priority tables that an earlier row fully covers, and inputs that no row matches. Overlaps and
+// unreachable rows are found with the static analyzer; gaps are found by
+// evaluating the rows against sample inputs built from the cell values, so
+// they are only reported for inputs near the values the table mentions.
+func (t *DecisionTable) Check() ([]DecisionTableIssue, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	var issues []DecisionTableIssue
+
+	switch t.hitPolicy() {
+	case HitPolicyUnique:
+		for i := range t.Rows {
+			for j := i + 1; j < len(t.Rows); j++ {
+				// The analyzer only compares sibling leaves, so both rows'
+				// leaves go into a single All.
+				leaves := append(t.rowLeaves(t.Rows[i]), t.rowLeaves(t.Rows[j])...)
+				both := Condition{All: leaves}
+				if len(leaves) == 0 || (&analyzer{quiet: 1}).analyze(&both, "") != truthNever {
+					issues = append(issues, DecisionTableIssue{
+						Kind:    DecisionTableOverlap,
+						Rows:    []int{i + 1, j + 1},
+						Message: fmt.Sprintf("rows %d and %d can match the same input", i+1, j+1),
+					})
+				}
+			}
+		}
+	case HitPolicyFirst, HitPolicyPriority:
+		order := t.orderedRows()
+		for n, j := range order {
+			for _, i := range order[:n] {
+				if t.rowCovers(t.Rows[i], t.Rows[j]) {
+					issues = append(issues, DecisionTableIssue{
+						Kind:    DecisionTableUnreachable,
+						Rows:    []int{j + 1, i + 1},
+						Message: fmt.Sprintf("row %d is never reached because row %d matches every input it does", j+1, i+1),
+					})
+					break
+				}
+			}
+		}
+	}
+
+	return append(issues, t.findGaps()...), nil
+}
+
+// rowCovers reports whether every input matching row b also matches row a:
+// for each of a's cells, b's cells contradict the cell's negation.
+func (t *DecisionTable) rowCovers(a, b DecisionRow) bool {
+	others := t.rowLeaves(b)
+	for _, leaf := range t.rowLeaves(a) {
+		negated, ok := negateLeaf(leaf)
+		if !ok {
+			return false
+		}
+		both := Condition{All: append(append([]Condition{}, others...), negated)}
+		if (&analyzer{quiet: 1}).analyze(&both, "") != truthNever {
+			return false
+		}
+	}
+	return true
+}
+
+var negatedOperators = map[string]string{
+	"equal":                "notEqual",
+	"notEqual":             "equal",
+	"in":                   "notIn",
+	"notIn":                "in",
+	"lessThan":             "greaterThanInclusive",
+	"lessThanInclusive":    "greaterThan",
+	"greaterThan":          "lessThanInclusive",
+	"greaterThanInclusive": "lessThan",
+}
+
+// negateLeaf returns the leaf with its operator negated, if the analyzer
+// understands the operator.
+func negateLeaf(c Condition) (Condition, bool) {
+	op, ok := negatedOperators[canonicalOperator(c.Operator)]
+	if !ok {
+		return c, false
+	}
+	c.Operator = op
+	return c, true
+}
+
+// findGaps evaluates the rows against every combination of sample values
+// per input and reports combinations that no row matches. Columns reading
+// the same fact and path share one input.
+func (t *DecisionTable) findGaps() []DecisionTableIssue {
+	var keys []DecisionInput
+	columns := make(map[string][]int)
+	for i, in := range t.Inputs {
+		key := in.Fact + in.Path
+		if _, ok := columns[key]; !ok {
+			keys = append(keys, in)
+		}
+		columns[key] = append(columns[key], i)
+	}
+	samples := make([][]interface{}, len(keys))
+	total := 1
+	for i, in := range keys {
+		samples[i] = t.columnSamples(columns[in.Fact+in.Path])
+		total *= len(samples[i])
+		if total > maxGapSamples {
+			return nil
+		}
+	}
+
+	engine := NewEngine(WithAllowUndefinedFacts())
+	conds := make([]Condition, len(t.Rows))
+	for i, row := range t.Rows {
+		conds[i] = t.rowCondition(row)
+	}
+
+	var issues []DecisionTableIssue
+	combo := make([]int, len(keys))
+	for n := 0; n < total && len(issues) < maxGapIssues; n++ {
+		rem := n
+		for i := len(combo) - 1; i >= 0; i-- {
+			combo[i] = rem % len(samples[i])
+			rem /= len(samples[i])
+		}
+		facts := make(map[string]interface{})
+		for i, in := range keys {
+			v := samples[i][combo[i]]
+			if in.Path == "" {
+				facts[in.Fact] = v
+				continue
+			}
+			obj, _ := facts[in.Fact].(map[string]interface{})
+			if obj == nil {
+				obj = make(map[string]interface{})
+				facts[in.Fact] = obj
+			}
+			obj[strings.TrimPrefix(in.Path, ".")] = v
+		}
+
+		matched := false
+		for i := range conds {
+			ok, err := conds[i].Evaluate(NewAlmanac(engine, facts), engine)
+			if err == nil && ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			issues = append(issues, DecisionTableIssue{
+				Kind:    DecisionTableGap,
+				Message: fmt.Sprintf("no row matches %s", canonicalJSON(facts)),
+				Example: facts,
+			})
+		}
+	}
+	return issues
+}
+
+// columnSamples returns representative values for a set of input columns:
+// every value mentioned in their cells, the midpoints between and just
+// beyond the numeric ones, and a value that equals none of the others.
+func (t *DecisionTable) columnSamples(cols []int) []interface{} {
+	var numbers []float64
+	var others []interface{}
+	for _, row := range t.Rows {
+		for _, col := range cols {
+			cell := row.Inputs[col]
+			if isWildcard(cell) {
+				continue
+			}
+			values, ok := listValues(cell)
+			if !ok {
+				values = []interface{}{cell}
+			}
+			for _, v := range values {
+				if f, ok := toFloat64(v); ok {
+					numbers = append(numbers, f)
+				} else if !valueIn(v, others) {
+					others = append(others, v)
+				}
+			}
+		}
+	}
+	if len(numbers) == 0 && len(others) == 0 {
+		return []interface{}{nil}
+	}
+
+	var samples []interface{}
+	if len(numbers) > 0 {
+		sort.Float64s(numbers)
+		samples = append(samples, numbers[0]-1)
+		for i, f := range numbers {
+			if i > 0 && f == numbers[i-1] {
+				continue
+			}
+			samples = append(samples, f)
+			if i+1 < len(numbers) && numbers[i+1] != f {
+				samples = append(samples, (f+numbers[i+1])/2)
+			}
+		}
+		samples = append(samples, numbers[len(numbers)-1]+1)
+	}
+	if len(others) > 0 {
+		samples = append(samples, others...)
+		samples = append(samples, "<other>")
+	}
+	return samples
+}
@@ -0,0 +1,202 @@
+package rulesengine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const discountCSV = `input:age:gte, input:customer.tier:in, output:discount, priority
+65, -, 20, 1
+18, gold|platinum, 15, 5
+18, -, 5, 0
+`
+
+func runTable(t *testing.T, table *DecisionTable, facts map[string]interface{}) []Event {
+	t.Helper()
+	engine := NewEngine()
+	require.NoError(t, engine.AddDecisionTable(table))
+	result, err := engine.Run(facts)
+	require.NoError(t, err)
+	return result.Events
+}
+
+func TestLoadDecisionTableFromCSV(t *testing.T) {
+	table, err := LoadDecisionTableFromCSV([]byte(discountCSV))
+	require.NoError(t, err)
+
+	assert.Equal(t, HitPolicyUnique, table.HitPolicy)
+	assert.Equal(t, []DecisionInput{
+		{Fact: "age", Operator: "gte"},
+		{Fact: "customer", Path: ".tier", Operator: "in"},
+	}, table.Inputs)
+	assert.Equal(t, []string{"discount"}, table.Outputs)
+	require.Len(t, table.Rows, 3)
+	assert.Equal(t, DecisionRow{
+		Inputs:   []interface{}{float64(18), []interface{}{"gold", "platinum"}},
+		Outputs:  []interface{}{float64(15)},
+		Priority: 5,
+	}, table.Rows[1])
+	assert.Nil(t, table.Rows[0].Inputs[1])
+
+	_, err = LoadDecisionTableFromCSV([]byte("input:age\n1\n"))
+	assert.Error(t, err)
+	_, err = LoadDecisionTableFromCSV([]byte("age\n1\n"))
+	assert.Error(t, err)
+}
+
+func TestDecisionTable_HitPolicies(t *testing.T) {
+	table, err := LoadDecisionTableFromCSV([]byte(discountCSV))
+	require.NoError(t, err)
+	table.Name = "discount"
+	facts := map[string]interface{}{
+		"age":      70,
+		"customer": map[string]interface{}{"tier": "gold"},
+	}
+
+	table.HitPolicy = HitPolicyCollect
+	assert.Len(t, runTable(t, table, facts), 3)
+
+	table.HitPolicy = HitPolicyFirst
+	events := runTable(t, table, facts)
+	require.Len(t, events, 1)
+	assert.Equal(t, Event{Type: "discount", Params: map[string]interface{}{"discount": float64(20)}}, events[0])
+
+	table.HitPolicy = HitPolicyPriority
+	events = runTable(t, table, facts)
+	require.Len(t, events, 1)
+	assert.Equal(t, float64(15), events[0].Params["discount"])
+
+	assert.Empty(t, runTable(t, table, map[string]interface{}{
+		"age":      12,
+		"customer": map[string]interface{}{"tier": "gold"},
+	}))
+
+	table.HitPolicy = HitPolicyUnique
+	engine := NewEngine()
+	require.NoError(t, engine.AddDecisionTable(table))
+	_, err = engine.Run(facts)
+	assert.EqualError(t, err, `rule group discount: rules "discount#1" and "discount#2" both matched`)
+	events = runTable(t, table, map[string]interface{}{
+		"age":      30,
+		"customer": map[string]interface{}{"tier": "silver"},
+	})
+	require.Len(t, events, 1)
+	assert.Equal(t, float64(5), events[0].Params["discount"])
+}
+
+func TestDecisionTable_Rules(t *testing.T) {
+	table, err := LoadDecisionTableFromYAML([]byte(`
+name: shipping
+eventType: shipping-rate
+hitPolicy: first
+priority: 3
+inputs:
+  - {fact: country, operator: equal}
+outputs: [rate]
+rows:
+  - {inputs: [US], outputs: [5]}
+  - {inputs: ["-"], outputs: [20]}
+`))
+	require.NoError(t, err)
+
+	rules, err := table.Rules()
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "shipping#1", rules[0].Name)
+	assert.Equal(t, 8, rules[0].Priority)
+	assert.Equal(t, 7, rules[1].Priority)
+	assert.Equal(t, "shipping-rate", rules[1].Event.Type)
+	assert.Equal(t, "shipping", rules[1].Group)
+	assert.Equal(t, Condition{Fact: "country", Operator: "notIn", Value: []interface{}{}}, rules[1].Conditions)
+	assert.Equal(t, RuleGroup{Name: "shipping", Strategy: GroupFirstMatch}, table.Group())
+
+	events := runTable(t, table, map[string]interface{}{"country": "FR"})
+	require.Len(t, events, 1)
+	assert.Equal(t, 20, events[0].Params["rate"])
+
+	table.Rows[1].Outputs = nil
+	_, err = table.Rules()
+	assert.Error(t, err)
+	table.HitPolicy = "random"
+	_, err = table.Rules()
+	assert.Error(t, err)
+}
+
+func TestDecisionTable_FirstMatchUnderDynamicSalience(t *testing.T) {
+	table := &DecisionTable{
+		Name:      "band",
+		HitPolicy: HitPolicyFirst,
+		Inputs:    []DecisionInput{{Fact: "n", Operator: "lessThan"}},
+		Outputs:   []string{"band"},
+	}
+	for i := 1; i <= 12; i++ {
+		table.Rows = append(table.Rows, DecisionRow{Inputs: []interface{}{i * 10}, Outputs: []interface{}{i}})
+	}
+	engine := NewEngine()
+	require.NoError(t, engine.AddDecisionTable(table))
+	require.NoError(t, engine.AddRule(NewRule(Condition{Fact: "n", Operator: "gte", Value: 0}, Event{Type: "ranked"},
+		WithName("ranked"), WithSalience(Salience{Fact: "rank", Default: 0}))))
+
+	// Rows 2 to 12 match; by name "band#10" would sort before "band#2".
+	result, err := engine.Run(map[string]interface{}{"n": 15})
+	require.NoError(t, err)
+	require.NotNil(t, result.Order)
+	require.Len(t, result.Events, 2)
+	assert.Equal(t, Event{Type: "band", Params: map[string]interface{}{"band": 2}}, result.Events[0])
+}
+
+func TestDecisionTable_Check(t *testing.T) {
+	table, err := LoadDecisionTableFromJSON([]byte(`{
+		"name": "grade",
+		"inputs": [{"fact": "score", "operator": "gte"}, {"fact": "score", "operator": "lessThan"}],
+		"outputs": ["grade"],
+		"rows": [
+			{"inputs": [90, null], "outputs": ["A"]},
+			{"inputs": [70, 90], "outputs": ["B"]},
+			{"inputs": [50, 75], "outputs": ["C"]}
+		]
+	}`))
+	require.NoError(t, err)
+
+	issues, err := table.Check()
+	require.NoError(t, err)
+	var overlaps, gaps []DecisionTableIssue
+	for _, issue := range issues {
+		switch issue.Kind {
+		case DecisionTableOverlap:
+			overlaps = append(overlaps, issue)
+		case DecisionTableGap:
+			gaps = append(gaps, issue)
+		}
+	}
+	require.Len(t, overlaps, 1)
+	assert.Equal(t, []int{2, 3}, overlaps[0].Rows)
+	require.NotEmpty(t, gaps)
+	assert.Less(t, gaps[0].Example["score"], float64(50))
+
+	table.HitPolicy = HitPolicyFirst
+	table.Rows = append(table.Rows, DecisionRow{Inputs: []interface{}{95, nil}, Outputs: []interface{}{"A+"}})
+	issues, err = table.Check()
+	require.NoError(t, err)
+	require.NotEmpty(t, issues)
+	assert.Equal(t, DecisionTableUnreachable, issues[0].Kind)
+	assert.Equal(t, []int{4, 1}, issues[0].Rows)
+}
+
+func TestDecisionTable_CheckComplete(t *testing.T) {
+	table := &DecisionTable{
+		Name:      "size",
+		HitPolicy: HitPolicyFirst,
+		Inputs:    []DecisionInput{{Fact: "n", Operator: "lessThan"}},
+		Outputs:   []string{"size"},
+		Rows: []DecisionRow{
+			{Inputs: []interface{}{10}, Outputs: []interface{}{"small"}},
+			{Inputs: []interface{}{nil}, Outputs: []interface{}{"large"}},
+		},
+	}
+	issues, err := table.Check()
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+}
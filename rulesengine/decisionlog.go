@@ -0,0 +1,566 @@
+package rulesengine
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RuleSnapshot is the rules, named conditions and groups an engine ran with.
+// Version identifies the snapshot by its content, so equal rule sets share a
+// version across engines and restarts.
+type RuleSnapshot struct {
+	Version    string               `json:"version" bson:"version" xml:"version" yaml:"version"`
+	Rules      []*Rule              `json:"rules" bson:"rules" xml:"rules" yaml:"rules"`
+	Conditions map[string]Condition `json:"conditions" bson:"conditions" xml:"conditions" yaml:"conditions"`
+	Groups     []RuleGroup          `json:"groups,omitempty" bson:"groups,omitempty" xml:"groups,omitempty" yaml:"groups,omitempty"`
+}
+
+// Snapshot captures copies of the engine's rules, named conditions and
+// groups.
+func (e *Engine) Snapshot() (*RuleSnapshot, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.snapshotLocked()
+}
+
+func (e *Engine) snapshotLocked() (*RuleSnapshot, error) {
+	s := &RuleSnapshot{
+		Rules:      make([]*Rule, len(e.rules)),
+		Conditions: make(map[string]Condition, len(e.conditions)),
+	}
+	for i, r := range e.rules {
+		s.Rules[i] = r.Clone()
+	}
+	for name, c := range e.conditions {
+		s.Conditions[name] = c.Clone()
+	}
+	for _, g := range e.groups {
+		s.Groups = append(s.Groups, *g)
+	}
+	sort.Slice(s.Groups, func(i, j int) bool { return s.Groups[i].Name < s.Groups[j].Name })
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("encoding rule snapshot: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	s.Version = hex.EncodeToString(sum[:8])
+	return s, nil
+}
+
+// DecisionRecord is a logged run: its inputs, the fact values it computed
+// and its outcome, with the version of the rules that produced it.
+//
+// ComputedFacts holds the values in the run's fact cache by fact ID and
+// parameters, as JSON, or "nil" without parameters. Facts added with
+// WithNoCache are not cached and so not logged.
+type DecisionRecord struct {
+	ID            string                            `json:"id" bson:"id" xml:"id" yaml:"id"`
+	Time          time.Time                         `json:"time" bson:"time" xml:"time" yaml:"time"`
+	Version       string                            `json:"version" bson:"version" xml:"version" yaml:"version"`
+	Groups        []string                          `json:"groups,omitempty" bson:"groups,omitempty" xml:"groups,omitempty" yaml:"groups,omitempty"`
+	RuntimeFacts  map[string]interface{}            `json:"runtimeFacts" bson:"runtimeFacts" xml:"runtimeFacts" yaml:"runtimeFacts"`
+	ComputedFacts map[string]map[string]interface{} `json:"computedFacts,omitempty" bson:"computedFacts,omitempty" xml:"computedFacts,omitempty" yaml:"computedFacts,omitempty"`
+	Events        []Event                           `json:"events" bson:"events" xml:"events" yaml:"events"`
+	RuleResults   []*RuleResult                     `json:"ruleResults" bson:"ruleResults" xml:"ruleResults" yaml:"ruleResults"`
+}
+
+// DecisionSink stores decision records and the rule snapshots they refer
+// to. WriteSnapshot is called once per version before the first decision
+// made with it.
+type DecisionSink interface {
+	WriteSnapshot(s *RuleSnapshot) error
+	WriteDecision(d *DecisionRecord) error
+}
+
+// DecisionLog is the contents of a decision log, for replay.
+type DecisionLog struct {
+	Decisions []*DecisionRecord
+	Snapshots map[string]*RuleSnapshot
+}
+
+// Find returns the decision with the given ID.
+func (l *DecisionLog) Find(id string) (*DecisionRecord, bool) {
+	for _, d := range l.Decisions {
+		if d.ID == id {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+// Snapshot returns the rules a decision was made with.
+func (l *DecisionLog) Snapshot(d *DecisionRecord) (*RuleSnapshot, bool) {
+	s, ok := l.Snapshots[d.Version]
+	return s, ok
+}
+
+// DecisionLogger records runs of the engines it is attached to with
+// WithDecisionLog.
+type DecisionLogger struct {
+	sink       DecisionSink
+	sampleRate float64
+	redactions [][]string
+	sample     func() float64
+
+	mu sync.Mutex
+	// Versions by engine, valid while the engine's revision is unchanged.
+	versions map[*Engine]loggedVersion
+	written  map[string]bool
+}
+
+type loggedVersion struct {
+	revision uint64
+	version  string
+}
+
+// DecisionLogOption configures a DecisionLogger.
+type DecisionLogOption func(*DecisionLogger)
+
+// WithSampleRate logs the given fraction of runs, from 0 to 1. The default
+// logs every run.
+func WithSampleRate(rate float64) DecisionLogOption {
+	return func(l *DecisionLogger) {
+		l.sampleRate = rate
+	}
+}
+
+// WithRedaction replaces sensitive values with "[REDACTED]" before they
+// are logged. A path is a fact ID, optionally followed by dotted keys into
+// its value, such as "ssn" or "applicant.card.number"; keys apply to every
+// item of arrays on the way. Runtime facts, computed facts and the fact
+// values in traces are redacted. Replays see the redacted values.
+func WithRedaction(paths ...string) DecisionLogOption {
+	return func(l *DecisionLogger) {
+		for _, p := range paths {
+			l.redactions = append(l.redactions, strings.Split(p, "."))
+		}
+	}
+}
+
+// NewDecisionLogger creates a logger writing to sink.
+func NewDecisionLogger(sink DecisionSink, options ...DecisionLogOption) *DecisionLogger {
+	l := &DecisionLogger{
+		sink:       sink,
+		sampleRate: 1,
+		sample:     rand.Float64,
+		versions:   make(map[*Engine]loggedVersion),
+		written:    make(map[string]bool),
+	}
+	for _, opt := range options {
+		opt(l)
+	}
+	return l
+}
+
+// WithDecisionLog makes Run record its decisions with l. Logged runs are
+// traced so the record explains every rule's outcome; the traces are only
+// returned when the run asks for them. A decision that cannot be logged
+// fails the run.
+func WithDecisionLog(l *DecisionLogger) EngineOption {
+	return func(e *Engine) {
+		e.decisionLog = l
+	}
+}
+
+// sampled decides whether to log a run.
+func (l *DecisionLogger) sampled() bool {
+	return l.sampleRate >= 1 || l.sample() < l.sampleRate
+}
+
+// log records a run of e, whose read lock is held. runtimeFacts are the
+// facts the run started with.
+func (l *DecisionLogger) log(e *Engine, now time.Time, runtimeFacts map[string]interface{}, cfg *runConfig, result *RunResult) error {
+	version, err := l.version(e)
+	if err != nil {
+		return err
+	}
+	d := &DecisionRecord{
+		ID:           newRecordID(),
+		Time:         now,
+		Version:      version,
+		RuntimeFacts: runtimeFacts,
+		Events:       append([]Event{}, result.Events...),
+		RuleResults:  make([]*RuleResult, len(result.RuleResults)),
+	}
+	for name := range cfg.groups {
+		d.Groups = append(d.Groups, name)
+	}
+	sort.Strings(d.Groups)
+	for id, cache := range result.Almanac.factCache {
+		if len(cache) == 0 {
+			continue
+		}
+		if d.ComputedFacts == nil {
+			d.ComputedFacts = make(map[string]map[string]interface{})
+		}
+		d.ComputedFacts[id] = make(map[string]interface{}, len(cache))
+		for key, value := range cache {
+			d.ComputedFacts[id][key] = cloneValue(value)
+		}
+	}
+	for i, rr := range result.RuleResults {
+		copied := *rr
+		d.RuleResults[i] = &copied
+	}
+	l.redact(d)
+	return l.sink.WriteDecision(d)
+}
+
+// version returns the rule version of e, writing its snapshot to the sink
+// the first time the version is seen.
+func (l *DecisionLogger) version(e *Engine) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	revision := e.revision.Load()
+	if v, ok := l.versions[e]; ok && v.revision == revision {
+		return v.version, nil
+	}
+	s, err := e.snapshotLocked()
+	if err != nil {
+		return "", err
+	}
+	if !l.written[s.Version] {
+		if err := l.sink.WriteSnapshot(s); err != nil {
+			return "", err
+		}
+		l.written[s.Version] = true
+	}
+	l.versions[e] = loggedVersion{revision: revision, version: s.Version}
+	return s.Version, nil
+}
+
+const redactedValue = "[REDACTED]"
+
+// redact applies the logger's redactions to d. Values are replaced in
+// copies, so the run's own facts and traces are untouched.
+func (l *DecisionLogger) redact(d *DecisionRecord) {
+	if len(l.redactions) == 0 {
+		return
+	}
+	for _, path := range l.redactions {
+		id, keys := path[0], path[1:]
+		if v, ok := d.RuntimeFacts[id]; ok {
+			d.RuntimeFacts[id] = redactPath(v, keys)
+		}
+		for key, v := range d.ComputedFacts[id] {
+			d.ComputedFacts[id][key] = redactPath(v, keys)
+		}
+		for _, rr := range d.RuleResults {
+			rr.Trace = redactTrace(rr.Trace, id, keys)
+		}
+	}
+}
+
+// redactPath returns a copy of v with the value at keys replaced.
+func redactPath(v interface{}, keys []string) interface{} {
+	if len(keys) == 0 {
+		return redactedValue
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = item
+		}
+		if item, ok := v[keys[0]]; ok {
+			out[keys[0]] = redactPath(item, keys[1:])
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = redactPath(item, keys)
+		}
+		return out
+	}
+	return v
+}
+
+// redactTrace returns a copy of the trace with the values of fact id
+// redacted. A condition reading the fact through its own path has its
+// value redacted whole, since the path may select the sensitive field.
+func redactTrace(node *TraceNode, id string, keys []string) *TraceNode {
+	if node == nil {
+		return nil
+	}
+	if agg := node.Condition.Aggregate; agg != nil && agg.Fact == id {
+		// Reduced values and items all derive from the fact.
+		return redactTraceValues(node)
+	}
+	copied := *node
+	if node.Condition.Fact == id {
+		if node.Condition.Path == "" {
+			copied.FactValue = redactPath(node.FactValue, keys)
+		} else {
+			copied.FactValue = redactedValue
+		}
+	}
+	copied.Children = make([]*TraceNode, len(node.Children))
+	for i, child := range node.Children {
+		copied.Children[i] = redactTrace(child, id, keys)
+	}
+	return &copied
+}
+
+// redactTraceValues returns a copy of the trace with every value redacted.
+func redactTraceValues(node *TraceNode) *TraceNode {
+	copied := *node
+	if node.FactValue != nil {
+		copied.FactValue = redactedValue
+	}
+	if node.Item != nil {
+		copied.Item = redactedValue
+	}
+	copied.Children = make([]*TraceNode, len(node.Children))
+	for i, child := range node.Children {
+		copied.Children[i] = redactTraceValues(child)
+	}
+	return &copied
+}
+
+// MemoryDecisionSink keeps decisions in memory.
+type MemoryDecisionSink struct {
+	mu  sync.Mutex
+	log DecisionLog
+}
+
+func NewMemoryDecisionSink() *MemoryDecisionSink {
+	return &MemoryDecisionSink{log: DecisionLog{Snapshots: make(map[string]*RuleSnapshot)}}
+}
+
+func (s *MemoryDecisionSink) WriteSnapshot(snapshot *RuleSnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.log.Snapshots[snapshot.Version] = snapshot
+	return nil
+}
+
+func (s *MemoryDecisionSink) WriteDecision(d *DecisionRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.log.Decisions = append(s.log.Decisions, d)
+	return nil
+}
+
+// Log returns the decisions and snapshots written so far.
+func (s *MemoryDecisionSink) Log() *DecisionLog {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log := &DecisionLog{
+		Decisions: append([]*DecisionRecord{}, s.log.Decisions...),
+		Snapshots: make(map[string]*RuleSnapshot, len(s.log.Snapshots)),
+	}
+	for v, snapshot := range s.log.Snapshots {
+		log.Snapshots[v] = snapshot
+	}
+	return log
+}
+
+// File names in a FileDecisionSink directory.
+const (
+	decisionsFile = "decisions.jsonl"
+	snapshotsFile = "snapshots.jsonl"
+)
+
+// FileDecisionSink writes decisions as JSON lines to decisions.jsonl in a
+// directory, rotating it to decisions.jsonl.1, .2 and so on when it grows
+// too large and deleting the oldest beyond the limit. Snapshots go to
+// snapshots.jsonl, which is never rotated, so old decisions stay
+// replayable against their rules.
+type FileDecisionSink struct {
+	mu        sync.Mutex
+	dir       string
+	maxSize   int64
+	maxFiles  int
+	file      *os.File
+	size      int64
+	snapshots *os.File
+}
+
+// FileDecisionSinkOption configures a FileDecisionSink.
+type FileDecisionSinkOption func(*FileDecisionSink)
+
+// WithMaxFileSize rotates the decisions file once it reaches n bytes. The
+// default is 100 MiB.
+func WithMaxFileSize(n int64) FileDecisionSinkOption {
+	return func(s *FileDecisionSink) {
+		s.maxSize = n
+	}
+}
+
+// WithMaxFiles keeps at most n rotated decision files. The default is 10.
+func WithMaxFiles(n int) FileDecisionSinkOption {
+	return func(s *FileDecisionSink) {
+		s.maxFiles = n
+	}
+}
+
+// OpenFileDecisionSink opens or creates a decision log in dir.
+func OpenFileDecisionSink(dir string, options ...FileDecisionSinkOption) (*FileDecisionSink, error) {
+	s := &FileDecisionSink{dir: dir, maxSize: 100 << 20, maxFiles: 10}
+	for _, opt := range options {
+		opt(s)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	var err error
+	if s.snapshots, err = os.OpenFile(filepath.Join(dir, snapshotsFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+		return nil, err
+	}
+	if err := s.open(); err != nil {
+		s.snapshots.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileDecisionSink) open() error {
+	f, err := os.OpenFile(filepath.Join(s.dir, decisionsFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.file, s.size = f, info.Size()
+	return nil
+}
+
+// rotate shifts decisions.jsonl.N to N+1, dropping those beyond maxFiles,
+// and starts a new decisions.jsonl.
+func (s *FileDecisionSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	base := filepath.Join(s.dir, decisionsFile)
+	if err := os.Remove(fmt.Sprintf("%s.%d", base, s.maxFiles)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for n := s.maxFiles - 1; n >= 1; n-- {
+		if err := os.Rename(fmt.Sprintf("%s.%d", base, n), fmt.Sprintf("%s.%d", base, n+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if s.maxFiles > 0 {
+		if err := os.Rename(base, base+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(base); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *FileDecisionSink) WriteSnapshot(snapshot *RuleSnapshot) error {
+	line, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.snapshots.Write(append(line, '\n'))
+	return err
+}
+
+func (s *FileDecisionSink) WriteDecision(d *DecisionRecord) error {
+	line, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("rotating decision log: %w", err)
+		}
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+func (s *FileDecisionSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return errors.Join(s.file.Close(), s.snapshots.Close())
+}
+
+// ReadDecisionLog reads the decision log a FileDecisionSink wrote to dir,
+// oldest decisions first.
+func ReadDecisionLog(dir string) (*DecisionLog, error) {
+	log := &DecisionLog{Snapshots: make(map[string]*RuleSnapshot)}
+	err := readJSONLines(filepath.Join(dir, snapshotsFile), func() interface{} { return &RuleSnapshot{} }, func(v interface{}) {
+		s := v.(*RuleSnapshot)
+		log.Snapshots[s.Version] = s
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	base := filepath.Join(dir, decisionsFile)
+	rotated, err := filepath.Glob(base + ".*")
+	if err != nil {
+		return nil, err
+	}
+	suffix := func(path string) int {
+		var n int
+		fmt.Sscanf(strings.TrimPrefix(path, base+"."), "%d", &n)
+		return n
+	}
+	// Higher suffixes are older.
+	sort.Slice(rotated, func(i, j int) bool { return suffix(rotated[i]) > suffix(rotated[j]) })
+	for _, path := range append(rotated, base) {
+		err := readJSONLines(path, func() interface{} { return &DecisionRecord{} }, func(v interface{}) {
+			log.Decisions = append(log.Decisions, v.(*DecisionRecord))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return log, nil
+}
+
+// readJSONLines decodes each line of the file at path into a value from
+// newValue and passes it to fn. A missing file has no lines, and a
+// truncated last line is ignored.
+func readJSONLines(path string, newValue func() interface{}, fn func(interface{})) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		v := newValue()
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		fn(v)
+	}
+}
@@ -0,0 +1,222 @@
+package rulesengine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var decisionTime = time.Date(2024, 5, 6, 10, 0, 0, 0, time.UTC)
+
+// loanEngine logs to sink and approves applicants whose score, a computed
+// fact, exceeds the constant fact "minScore".
+func loanEngine(t *testing.T, sink DecisionSink, options ...DecisionLogOption) *Engine {
+	t.Helper()
+	engine := NewEngine(WithClock(func() time.Time { return decisionTime }), WithDecisionLog(NewDecisionLogger(sink, options...)))
+	require.NoError(t, engine.AddFact("minScore", 600))
+	require.NoError(t, engine.AddFact("score", FactFunc(func(params map[string]interface{}, a *Almanac) (interface{}, error) {
+		income, _ := a.FactValue("applicant", nil, ".income")
+		return toScore(income), nil
+	})))
+	require.NoError(t, engine.AddRule(NewRule(Condition{All: []Condition{
+		{Fact: "score", Operator: "greaterThan", Value: 600},
+		{Fact: "applicant", Operator: "notEqual", Value: nil},
+	}}, Event{Type: "approve"}, WithName("approve"))))
+	return engine
+}
+
+func toScore(income interface{}) int {
+	n, _ := toFloat64(income)
+	return int(n / 100)
+}
+
+func TestDecisionLog_RecordsRun(t *testing.T) {
+	sink := NewMemoryDecisionSink()
+	engine := loanEngine(t, sink)
+
+	applicant := map[string]interface{}{"name": "Ann", "income": 70000}
+	result, err := engine.Run(map[string]interface{}{"applicant": applicant})
+	require.NoError(t, err)
+	require.Len(t, result.Events, 1)
+	assert.Nil(t, result.RuleResults[0].Trace, "traces are only returned when asked for")
+
+	log := sink.Log()
+	require.Len(t, log.Decisions, 1)
+	d := log.Decisions[0]
+	assert.NotEmpty(t, d.ID)
+	assert.Equal(t, decisionTime, d.Time)
+	assert.Equal(t, map[string]interface{}{"applicant": applicant}, d.RuntimeFacts)
+	assert.Equal(t, map[string]map[string]interface{}{"score": {"nil": 700}}, d.ComputedFacts)
+	assert.Equal(t, []Event{{Type: "approve"}}, d.Events)
+	require.Len(t, d.RuleResults, 1)
+	require.NotNil(t, d.RuleResults[0].Trace)
+	assert.Equal(t, 700, d.RuleResults[0].Trace.Children[0].FactValue)
+
+	snapshot, err := engine.Snapshot()
+	require.NoError(t, err)
+	assert.Equal(t, snapshot.Version, d.Version)
+	assert.Equal(t, map[string]*RuleSnapshot{snapshot.Version: snapshot}, log.Snapshots)
+
+	// The snapshot is written once per version.
+	_, err = engine.Run(map[string]interface{}{"applicant": applicant})
+	require.NoError(t, err)
+	assert.Len(t, sink.Log().Snapshots, 1)
+	require.NoError(t, engine.DisableRule("approve"))
+	_, err = engine.Run(map[string]interface{}{"applicant": applicant})
+	require.NoError(t, err)
+	log = sink.Log()
+	require.Len(t, log.Decisions, 3)
+	assert.Len(t, log.Snapshots, 2)
+	assert.NotEqual(t, d.Version, log.Decisions[2].Version)
+	assert.Equal(t, log.Decisions[0].Version, log.Decisions[1].Version)
+}
+
+func TestDecisionLog_GroupToggleChangesVersion(t *testing.T) {
+	sink := NewMemoryDecisionSink()
+	engine := loanEngine(t, sink)
+	require.NoError(t, engine.AddGroup(RuleGroup{Name: "lending"}))
+	facts := map[string]interface{}{"applicant": map[string]interface{}{"income": 70000}}
+
+	_, err := engine.Run(facts)
+	require.NoError(t, err)
+	require.NoError(t, engine.DisableGroup("lending"))
+	_, err = engine.Run(facts)
+	require.NoError(t, err)
+
+	log := sink.Log()
+	require.Len(t, log.Decisions, 2)
+	version := log.Decisions[1].Version
+	assert.NotEqual(t, log.Decisions[0].Version, version)
+	require.Contains(t, log.Snapshots, version)
+	assert.Equal(t, []RuleGroup{{Name: "lending", Disabled: true}}, log.Snapshots[version].Groups)
+}
+
+func TestSnapshot_VersionFollowsContent(t *testing.T) {
+	build := func() *Engine {
+		engine := NewEngine()
+		require.NoError(t, engine.AddRule(NewRule(Condition{Fact: "n", Operator: "gt", Value: 1}, Event{Type: "big"}, WithName("big"))))
+		return engine
+	}
+	a, err := build().Snapshot()
+	require.NoError(t, err)
+	b, err := build().Snapshot()
+	require.NoError(t, err)
+	assert.Equal(t, a.Version, b.Version)
+
+	engine := build()
+	engine.SetCondition("adult", Condition{Fact: "age", Operator: "gte", Value: 18})
+	c, err := engine.Snapshot()
+	require.NoError(t, err)
+	assert.NotEqual(t, a.Version, c.Version)
+}
+
+func TestDecisionLog_Redaction(t *testing.T) {
+	sink := NewMemoryDecisionSink()
+	engine := loanEngine(t, sink, WithRedaction("applicant.ssn", "applicant.cards.number", "score"))
+
+	applicant := map[string]interface{}{
+		"name":   "Ann",
+		"ssn":    "123-45-6789",
+		"income": 70000,
+		"cards":  []interface{}{map[string]interface{}{"number": "4111", "kind": "visa"}},
+	}
+	result, err := engine.Run(map[string]interface{}{"applicant": applicant}, WithTrace())
+	require.NoError(t, err)
+
+	d := sink.Log().Decisions[0]
+	assert.Equal(t, map[string]interface{}{
+		"name":   "Ann",
+		"ssn":    redactedValue,
+		"income": 70000,
+		"cards":  []interface{}{map[string]interface{}{"number": redactedValue, "kind": "visa"}},
+	}, d.RuntimeFacts["applicant"])
+	assert.Equal(t, redactedValue, d.ComputedFacts["score"]["nil"])
+	trace := d.RuleResults[0].Trace
+	assert.Equal(t, redactedValue, trace.Children[0].FactValue)
+	assert.Equal(t, redactedValue, trace.Children[1].FactValue.(map[string]interface{})["ssn"])
+
+	// The caller's facts and traces are untouched.
+	assert.Equal(t, "123-45-6789", applicant["ssn"])
+	assert.Equal(t, 700, result.RuleResults[0].Trace.Children[0].FactValue)
+	assert.Equal(t, "123-45-6789", result.RuleResults[0].Trace.Children[1].FactValue.(map[string]interface{})["ssn"])
+}
+
+func TestDecisionLog_Sampling(t *testing.T) {
+	sink := NewMemoryDecisionSink()
+	logger := NewDecisionLogger(sink, WithSampleRate(0.25))
+	draws := []float64{0.1, 0.5, 0.9, 0.2}
+	logger.sample = func() float64 {
+		v := draws[0]
+		draws = draws[1:]
+		return v
+	}
+	engine := NewEngine(WithDecisionLog(logger))
+	require.NoError(t, engine.AddRule(NewRule(Condition{Fact: "n", Operator: "gt", Value: 0}, Event{Type: "positive"})))
+	for n := 1; n <= 4; n++ {
+		_, err := engine.Run(map[string]interface{}{"n": n})
+		require.NoError(t, err)
+	}
+	log := sink.Log()
+	require.Len(t, log.Decisions, 2)
+	assert.Equal(t, 1, log.Decisions[0].RuntimeFacts["n"])
+	assert.Equal(t, 4, log.Decisions[1].RuntimeFacts["n"])
+
+	none := NewMemoryDecisionSink()
+	engine = NewEngine(WithDecisionLog(NewDecisionLogger(none, WithSampleRate(0))))
+	_, err := engine.Run(nil)
+	require.NoError(t, err)
+	assert.Empty(t, none.Log().Decisions)
+}
+
+func TestFileDecisionSink_Rotation(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "decisions")
+	sink, err := OpenFileDecisionSink(dir, WithMaxFileSize(600), WithMaxFiles(2))
+	require.NoError(t, err)
+	engine := loanEngine(t, sink)
+	for i := 0; i < 20; i++ {
+		_, err := engine.Run(map[string]interface{}{"applicant": map[string]interface{}{"income": 1000 * i}})
+		require.NoError(t, err)
+	}
+	require.NoError(t, sink.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "decisions.jsonl*"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		filepath.Join(dir, "decisions.jsonl"),
+		filepath.Join(dir, "decisions.jsonl.1"),
+		filepath.Join(dir, "decisions.jsonl.2"),
+	}, files)
+	for _, f := range files {
+		info, err := os.Stat(f)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, info.Size(), int64(600))
+	}
+
+	log, err := ReadDecisionLog(dir)
+	require.NoError(t, err)
+	require.NotEmpty(t, log.Decisions)
+	assert.Less(t, len(log.Decisions), 20, "the oldest files were deleted")
+	// Decisions are read oldest first and end with the last run.
+	for i, d := range log.Decisions {
+		want := 1000 * float64(20-len(log.Decisions)+i)
+		assert.Equal(t, want, d.RuntimeFacts["applicant"].(map[string]interface{})["income"], fmt.Sprint(i))
+	}
+	snapshot, ok := log.Snapshot(log.Decisions[0])
+	require.True(t, ok)
+	assert.Equal(t, "approve", snapshot.Rules[0].Name)
+
+	// Reopening appends to the current file.
+	sink, err = OpenFileDecisionSink(dir)
+	require.NoError(t, err)
+	require.NoError(t, sink.WriteDecision(&DecisionRecord{ID: "extra"}))
+	require.NoError(t, sink.Close())
+	log, err = ReadDecisionLog(dir)
+	require.NoError(t, err)
+	_, ok = log.Find("extra")
+	assert.True(t, ok)
+}
@@ -0,0 +1,345 @@
+package rulesengine
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ConditionDiffKind describes how a condition node changed between two trees.
+type ConditionDiffKind string
+
+const (
+	ConditionAdded   ConditionDiffKind = "added"
+	ConditionRemoved ConditionDiffKind = "removed"
+	ConditionChanged ConditionDiffKind = "changed"
+)
+
+// ConditionDiff is a node of a condition tree diff. Path addresses the node
+// in the new tree and OldPath in the old one, using ValidateCondition paths.
+// Children are only set for compound nodes whose own type is unchanged.
+type ConditionDiff struct {
+	Kind     ConditionDiffKind `json:"kind" bson:"kind" xml:"kind" yaml:"kind"`
+	Path     string            `json:"path" bson:"path" xml:"path" yaml:"path"`
+	OldPath  string            `json:"oldPath" bson:"oldPath" xml:"oldPath" yaml:"oldPath"`
+	Old      *Condition        `json:"old,omitempty" bson:"old,omitempty" xml:"old,omitempty" yaml:"old,omitempty"`
+	New      *Condition        `json:"new,omitempty" bson:"new,omitempty" xml:"new,omitempty" yaml:"new,omitempty"`
+	Children []*ConditionDiff  `json:"children,omitempty" bson:"children,omitempty" xml:"children,omitempty" yaml:"children,omitempty"`
+}
+
+// PriorityChange records a changed rule priority.
+type PriorityChange struct {
+	Old int `json:"old" bson:"old" xml:"old" yaml:"old"`
+	New int `json:"new" bson:"new" xml:"new" yaml:"new"`
+}
+
+// EventChange records a changed rule event.
+type EventChange struct {
+	Old Event `json:"old" bson:"old" xml:"old" yaml:"old"`
+	New Event `json:"new" bson:"new" xml:"new" yaml:"new"`
+}
+
+// FieldChange records a change to any other persisted rule field, named by
+// its JSON key, such as group, schedule or actions. Unset values are nil.
+type FieldChange struct {
+	Field string      `json:"field" bson:"field" xml:"field" yaml:"field"`
+	Old   interface{} `json:"old" bson:"old" xml:"old" yaml:"old"`
+	New   interface{} `json:"new" bson:"new" xml:"new" yaml:"new"`
+}
+
+// RuleDiff describes how a rule with the same name differs between rule sets.
+// Nil fields are unchanged.
+type RuleDiff struct {
+	Name       string          `json:"name" bson:"name" xml:"name" yaml:"name"`
+	Priority   *PriorityChange `json:"priority,omitempty" bson:"priority,omitempty" xml:"priority,omitempty" yaml:"priority,omitempty"`
+	Event      *EventChange    `json:"event,omitempty" bson:"event,omitempty" xml:"event,omitempty" yaml:"event,omitempty"`
+	Conditions *ConditionDiff  `json:"conditions,omitempty" bson:"conditions,omitempty" xml:"conditions,omitempty" yaml:"conditions,omitempty"`
+	Fields     []FieldChange   `json:"fields,omitempty" bson:"fields,omitempty" xml:"fields,omitempty" yaml:"fields,omitempty"`
+}
+
+// RuleSetDiff is the semantic difference between two rule sets.
+type RuleSetDiff struct {
+	Added   []*Rule    `json:"added" bson:"added" xml:"added" yaml:"added"`
+	Removed []*Rule    `json:"removed" bson:"removed" xml:"removed" yaml:"removed"`
+	Changed []RuleDiff `json:"changed" bson:"changed" xml:"changed" yaml:"changed"`
+}
+
+// Empty reports whether the two rule sets are equivalent.
+func (d *RuleSetDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffRuleSets matches rules by Name and reports added, removed and changed
+// rules. Conditions are compared semantically: operator aliases are treated
+// as equal and reordering the children of All and Any is not a change.
+func DiffRuleSets(oldRules, newRules []*Rule) *RuleSetDiff {
+	d := &RuleSetDiff{Added: []*Rule{}, Removed: []*Rule{}, Changed: []RuleDiff{}}
+
+	unmatched := make(map[string][]*Rule, len(oldRules))
+	for _, r := range oldRules {
+		unmatched[r.Name] = append(unmatched[r.Name], r)
+	}
+	matched := make(map[*Rule]bool, len(oldRules))
+	for _, r := range newRules {
+		candidates := unmatched[r.Name]
+		if len(candidates) == 0 {
+			d.Added = append(d.Added, r)
+			continue
+		}
+		old := candidates[0]
+		unmatched[r.Name] = candidates[1:]
+		matched[old] = true
+
+		rd := RuleDiff{Name: r.Name}
+		if old.Priority != r.Priority {
+			rd.Priority = &PriorityChange{Old: old.Priority, New: r.Priority}
+		}
+		if !eventsEqual(old.Event, r.Event) {
+			rd.Event = &EventChange{Old: old.Event, New: r.Event}
+		}
+		rd.Conditions = DiffConditions(&old.Conditions, &r.Conditions)
+		rd.Fields = diffRuleFields(old, r)
+		if rd.Priority != nil || rd.Event != nil || rd.Conditions != nil || len(rd.Fields) > 0 {
+			d.Changed = append(d.Changed, rd)
+		}
+	}
+	for _, r := range oldRules {
+		if !matched[r] {
+			d.Removed = append(d.Removed, r)
+		}
+	}
+	return d
+}
+
+// diffRuleFields compares the persisted rule fields other than the name,
+// priority, event and conditions.
+func diffRuleFields(old, r *Rule) []FieldChange {
+	var changes []FieldChange
+	add := func(field string, equal bool, oldValue, newValue interface{}) {
+		if !equal {
+			changes = append(changes, FieldChange{Field: field, Old: oldValue, New: newValue})
+		}
+	}
+	add("group", old.Group == r.Group, optional(old.Group, old.Group != ""), optional(r.Group, r.Group != ""))
+	add("disabled", old.Disabled == r.Disabled, optional(old.Disabled, old.Disabled), optional(r.Disabled, r.Disabled))
+	add("effectiveFrom", timesEqual(old.EffectiveFrom, r.EffectiveFrom),
+		optional(old.EffectiveFrom, old.EffectiveFrom != nil), optional(r.EffectiveFrom, r.EffectiveFrom != nil))
+	add("effectiveUntil", timesEqual(old.EffectiveUntil, r.EffectiveUntil),
+		optional(old.EffectiveUntil, old.EffectiveUntil != nil), optional(r.EffectiveUntil, r.EffectiveUntil != nil))
+	add("schedule", canonicalJSON(old.Schedule) == canonicalJSON(r.Schedule),
+		optional(old.Schedule, old.Schedule != nil), optional(r.Schedule, r.Schedule != nil))
+	add("salience", canonicalJSON(old.Salience) == canonicalJSON(r.Salience),
+		optional(old.Salience, old.Salience != nil), optional(r.Salience, r.Salience != nil))
+	add("actions", len(old.Actions) == 0 && len(r.Actions) == 0 || canonicalJSON(old.Actions) == canonicalJSON(r.Actions),
+		optional(old.Actions, len(old.Actions) > 0), optional(r.Actions, len(r.Actions) > 0))
+	return changes
+}
+
+// optional returns v if it is set, and nil otherwise.
+func optional(v interface{}, set bool) interface{} {
+	if !set {
+		return nil
+	}
+	return v
+}
+
+func timesEqual(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// DiffConditions returns the tree diff between two conditions, or nil if
+// they are semantically identical.
+func DiffConditions(oldCond, newCond *Condition) *ConditionDiff {
+	return diffCondition(oldCond, newCond, "", "")
+}
+
+func diffCondition(oldCond, newCond *Condition, oldPath, newPath string) *ConditionDiff {
+	if conditionKey(oldCond) == conditionKey(newCond) {
+		return nil
+	}
+	d := &ConditionDiff{Kind: ConditionChanged, Path: newPath, OldPath: oldPath, Old: oldCond, New: newCond}
+	switch {
+	case len(oldCond.All) > 0 && len(newCond.All) > 0:
+		d.Children = diffChildren(oldCond.All, newCond.All, oldPath, newPath, "All")
+	case len(oldCond.Any) > 0 && len(newCond.Any) > 0:
+		d.Children = diffChildren(oldCond.Any, newCond.Any, oldPath, newPath, "Any")
+	case oldCond.Not != nil && newCond.Not != nil:
+		if child := diffCondition(oldCond.Not, newCond.Not, joinPath(oldPath, "Not"), joinPath(newPath, "Not")); child != nil {
+			d.Children = []*ConditionDiff{child}
+		}
+	case oldCond.Aggregate != nil && newCond.Aggregate != nil && oldCond.Aggregate.Where != nil && newCond.Aggregate.Where != nil:
+		if child := diffCondition(oldCond.Aggregate.Where, newCond.Aggregate.Where, joinPath(oldPath, "Where"), joinPath(newPath, "Where")); child != nil {
+			d.Children = []*ConditionDiff{child}
+		}
+	}
+	return d
+}
+
+// diffChildren matches the children of two commutative groups. Identical
+// children are paired first regardless of position; the remaining children
+// are paired when they are the same kind of node on the same fact, and are
+// otherwise reported as added or removed.
+func diffChildren(oldChildren, newChildren []Condition, oldPath, newPath, kind string) []*ConditionDiff {
+	childPath := func(path string, i int) string {
+		return joinPath(path, fmt.Sprintf("%s[%d]", kind, i))
+	}
+	oldKeys := make([]string, len(oldChildren))
+	for i := range oldChildren {
+		oldKeys[i] = conditionKey(&oldChildren[i])
+	}
+	oldUsed := make([]bool, len(oldChildren))
+	newUsed := make([]bool, len(newChildren))
+	for j := range newChildren {
+		key := conditionKey(&newChildren[j])
+		for i := range oldChildren {
+			if !oldUsed[i] && oldKeys[i] == key {
+				oldUsed[i], newUsed[j] = true, true
+				break
+			}
+		}
+	}
+
+	var diffs []*ConditionDiff
+	for j := range newChildren {
+		if newUsed[j] {
+			continue
+		}
+		for i := range oldChildren {
+			if oldUsed[i] || nodeShape(&oldChildren[i]) != nodeShape(&newChildren[j]) {
+				continue
+			}
+			oldUsed[i], newUsed[j] = true, true
+			if child := diffCondition(&oldChildren[i], &newChildren[j], childPath(oldPath, i), childPath(newPath, j)); child != nil {
+				diffs = append(diffs, child)
+			}
+			break
+		}
+	}
+	for i := range oldChildren {
+		if !oldUsed[i] {
+			diffs = append(diffs, &ConditionDiff{Kind: ConditionRemoved, OldPath: childPath(oldPath, i), Old: &oldChildren[i]})
+		}
+	}
+	for j := range newChildren {
+		if !newUsed[j] {
+			diffs = append(diffs, &ConditionDiff{Kind: ConditionAdded, Path: childPath(newPath, j), New: &newChildren[j]})
+		}
+	}
+	return diffs
+}
+
+// nodeShape identifies nodes that are worth pairing as "changed" rather
+// than reporting as a removal plus an addition.
+func nodeShape(c *Condition) string {
+	switch {
+	case c.ConditionRef != "":
+		return "ref"
+	case len(c.All) > 0:
+		return "all"
+	case len(c.Any) > 0:
+		return "any"
+	case c.Not != nil:
+		return "not"
+	case c.Aggregate != nil:
+		return "aggregate:" + c.Aggregate.Fact + c.Aggregate.Path
+	}
+	return "leaf:" + c.Fact + c.Path
+}
+
+// WriteReport writes a human-readable description of the diff.
+func (d *RuleSetDiff) WriteReport(w io.Writer) error {
+	var b strings.Builder
+	for _, r := range d.Added {
+		fmt.Fprintf(&b, "+ rule %q (priority %d, event %s)\n", r.Name, r.Priority, canonicalJSON(r.Event))
+	}
+	for _, r := range d.Removed {
+		fmt.Fprintf(&b, "- rule %q\n", r.Name)
+	}
+	for _, rd := range d.Changed {
+		fmt.Fprintf(&b, "~ rule %q\n", rd.Name)
+		if rd.Priority != nil {
+			fmt.Fprintf(&b, "    priority: %d -> %d\n", rd.Priority.Old, rd.Priority.New)
+		}
+		if rd.Event != nil {
+			fmt.Fprintf(&b, "    event: %s -> %s\n", canonicalJSON(rd.Event.Old), canonicalJSON(rd.Event.New))
+		}
+		for _, fc := range rd.Fields {
+			fmt.Fprintf(&b, "    %s: %s -> %s\n", fc.Field, canonicalJSON(fc.Old), canonicalJSON(fc.New))
+		}
+		if rd.Conditions != nil {
+			b.WriteString("    conditions:\n")
+			writeConditionDiff(&b, rd.Conditions)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (d *RuleSetDiff) String() string {
+	var b strings.Builder
+	d.WriteReport(&b)
+	return b.String()
+}
+
+func writeConditionDiff(b *strings.Builder, d *ConditionDiff) {
+	switch {
+	case d.Kind == ConditionAdded:
+		fmt.Fprintf(b, "      + %s: %s\n", displayPath(d.Path), describeTree(d.New))
+	case d.Kind == ConditionRemoved:
+		fmt.Fprintf(b, "      - %s: %s\n", displayPath(d.OldPath), describeTree(d.Old))
+	case len(d.Children) > 0:
+		// An aggregate's own fact, operator or value may change along with
+		// its Where.
+		if d.Old.Aggregate != nil && d.New.Aggregate != nil {
+			if before, after := describeAggregate(d.Old.Aggregate), describeAggregate(d.New.Aggregate); before != after {
+				fmt.Fprintf(b, "      ~ %s: %s -> %s\n", changedPath(d), before, after)
+			}
+		}
+		for _, child := range d.Children {
+			writeConditionDiff(b, child)
+		}
+	default:
+		fmt.Fprintf(b, "      ~ %s: %s -> %s\n", changedPath(d), describeTree(d.Old), describeTree(d.New))
+	}
+}
+
+// changedPath renders the path of a changed node, noting where it was if
+// it moved.
+func changedPath(d *ConditionDiff) string {
+	path := displayPath(d.Path)
+	if d.OldPath != d.Path {
+		path = fmt.Sprintf("%s (was %s)", path, displayPath(d.OldPath))
+	}
+	return path
+}
+
+// describeTree renders a condition tree on a single line.
+func describeTree(c *Condition) string {
+	join := func(op string, children []Condition) string {
+		parts := make([]string, len(children))
+		for i := range children {
+			parts[i] = describeTree(&children[i])
+		}
+		return "(" + strings.Join(parts, " "+op+" ") + ")"
+	}
+	switch {
+	case c.ConditionRef != "":
+		return "condition " + c.ConditionRef
+	case len(c.All) > 0:
+		return join("and", c.All)
+	case len(c.Any) > 0:
+		return join("or", c.Any)
+	case c.Not != nil:
+		return "not " + describeTree(c.Not)
+	case c.Aggregate != nil:
+		if c.Aggregate.Where == nil {
+			return describeAggregate(c.Aggregate)
+		}
+		return describeAggregate(c.Aggregate) + " where " + describeTree(c.Aggregate.Where)
+	}
+	return describeLeaf(c)
+}
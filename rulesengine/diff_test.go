@@ -0,0 +1,145 @@
+package rulesengine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffConditions_IgnoresReordering(t *testing.T) {
+	oldCond := &Condition{All: []Condition{
+		{Fact: "age", Operator: "gte", Value: 18},
+		{Any: []Condition{
+			{Fact: "country", Operator: "eq", Value: "US"},
+			{Fact: "country", Operator: "eq", Value: "CA"},
+		}},
+	}}
+	newCond := &Condition{All: []Condition{
+		{Any: []Condition{
+			{Fact: "country", Operator: "equal", Value: "CA"},
+			{Fact: "country", Operator: "equal", Value: "US"},
+		}},
+		{Fact: "age", Operator: "greaterThanInclusive", Value: 18},
+	}}
+
+	assert.Nil(t, DiffConditions(oldCond, newCond))
+}
+
+func TestDiffConditions_TreeDiff(t *testing.T) {
+	oldCond := &Condition{All: []Condition{
+		{Fact: "age", Operator: "gte", Value: 18},
+		{Fact: "banned", Operator: "eq", Value: false},
+		{Not: &Condition{Fact: "tier", Operator: "eq", Value: "trial"}},
+	}}
+	newCond := &Condition{All: []Condition{
+		{Not: &Condition{Fact: "tier", Operator: "eq", Value: "free"}},
+		{Fact: "country", Operator: "in", Value: []interface{}{"US"}},
+		{Fact: "age", Operator: "gte", Value: 21},
+	}}
+
+	d := DiffConditions(oldCond, newCond)
+	require.NotNil(t, d)
+	assert.Equal(t, ConditionChanged, d.Kind)
+	require.Len(t, d.Children, 4)
+
+	not := d.Children[0]
+	assert.Equal(t, "All[0]", not.Path)
+	assert.Equal(t, "All[2]", not.OldPath)
+	require.Len(t, not.Children, 1)
+	assert.Equal(t, "All[0].Not", not.Children[0].Path)
+	assert.Equal(t, "free", not.Children[0].New.Value)
+
+	age := d.Children[1]
+	assert.Equal(t, ConditionChanged, age.Kind)
+	assert.Equal(t, "All[2]", age.Path)
+	assert.Equal(t, 18, age.Old.Value)
+	assert.Equal(t, 21, age.New.Value)
+
+	assert.Equal(t, ConditionRemoved, d.Children[2].Kind)
+	assert.Equal(t, "All[1]", d.Children[2].OldPath)
+	assert.Equal(t, ConditionAdded, d.Children[3].Kind)
+	assert.Equal(t, "All[1]", d.Children[3].Path)
+}
+
+func TestDiffConditions_TypeChange(t *testing.T) {
+	oldCond := &Condition{All: []Condition{{Fact: "x", Operator: "eq", Value: 1}, {Fact: "y", Operator: "eq", Value: 1}}}
+	newCond := &Condition{Any: []Condition{{Fact: "x", Operator: "eq", Value: 1}, {Fact: "y", Operator: "eq", Value: 1}}}
+
+	d := DiffConditions(oldCond, newCond)
+	require.NotNil(t, d)
+	assert.Equal(t, ConditionChanged, d.Kind)
+	assert.Empty(t, d.Children)
+}
+
+func TestDiffRuleSets(t *testing.T) {
+	oldRules := []*Rule{
+		NewRule(Condition{Fact: "score", Operator: "gte", Value: 700}, Event{Type: "approve"}, WithName("approve"), WithPriorityForRule(10)),
+		NewRule(Condition{Fact: "score", Operator: "lt", Value: 500}, Event{Type: "decline"}, WithName("decline")),
+		NewRule(Condition{Fact: "vip", Operator: "eq", Value: true}, Event{Type: "fast-track"}, WithName("vip")),
+	}
+	newRules := []*Rule{
+		NewRule(Condition{Fact: "vip", Operator: "equal", Value: true}, Event{Type: "fast-track"}, WithName("vip")),
+		NewRule(Condition{Fact: "score", Operator: "gte", Value: 650}, Event{Type: "approve", Params: map[string]interface{}{"limit": 1000}}, WithName("approve"), WithPriorityForRule(20)),
+		NewRule(Condition{Fact: "score", Operator: "lt", Value: 300}, Event{Type: "refer"}, WithName("refer")),
+	}
+
+	d := DiffRuleSets(oldRules, newRules)
+	assert.False(t, d.Empty())
+	require.Len(t, d.Added, 1)
+	assert.Equal(t, "refer", d.Added[0].Name)
+	require.Len(t, d.Removed, 1)
+	assert.Equal(t, "decline", d.Removed[0].Name)
+	require.Len(t, d.Changed, 1)
+
+	rd := d.Changed[0]
+	assert.Equal(t, "approve", rd.Name)
+	assert.Equal(t, &PriorityChange{Old: 10, New: 20}, rd.Priority)
+	require.NotNil(t, rd.Event)
+	assert.Equal(t, 1000, rd.Event.New.Params["limit"])
+	require.NotNil(t, rd.Conditions)
+
+	report := d.String()
+	assert.Contains(t, report, `+ rule "refer"`)
+	assert.Contains(t, report, `- rule "decline"`)
+	assert.Contains(t, report, `~ rule "approve"`)
+	assert.Contains(t, report, "priority: 10 -> 20")
+	assert.Contains(t, report, "~ (root): score gte 700 -> score gte 650")
+
+	assert.True(t, DiffRuleSets(oldRules, oldRules).Empty())
+}
+
+func TestDiffRuleSets_ComparesEveryField(t *testing.T) {
+	cond := Condition{Fact: "n", Operator: "gt", Value: 0}
+	base := func(options ...RuleOption) []*Rule {
+		return []*Rule{NewRule(cond, Event{Type: "e"}, append([]RuleOption{WithName("r")}, options...)...)}
+	}
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := map[string]RuleOption{
+		"group":          WithGroup("promo"),
+		"disabled":       WithDisabled(),
+		"effectiveFrom":  WithEffectiveFrom(from),
+		"effectiveUntil": WithEffectiveUntil(from),
+		"schedule":       WithSchedule(Schedule{Days: "mon"}),
+		"salience":       WithSalience(Salience{Fact: "tier", Default: 1}),
+		"actions":        WithActions(ActionSpec{Type: "setFact", Params: map[string]interface{}{"fact": "x", "value": 1}}),
+	}
+	for field, option := range tests {
+		t.Run(field, func(t *testing.T) {
+			d := DiffRuleSets(base(), base(option))
+			require.Len(t, d.Changed, 1)
+			require.Len(t, d.Changed[0].Fields, 1)
+			fc := d.Changed[0].Fields[0]
+			assert.Equal(t, field, fc.Field)
+			assert.Nil(t, fc.Old)
+			assert.NotNil(t, fc.New)
+			assert.Contains(t, d.String(), "    "+field+": ")
+		})
+	}
+
+	// The same instant in another zone is not a change.
+	local := from.In(time.FixedZone("CET", 3600))
+	assert.True(t, DiffRuleSets(base(WithEffectiveFrom(from)), base(WithEffectiveFrom(local))).Empty())
+	assert.True(t, DiffRuleSets(base(WithActions()), base()).Empty())
+}
@@ -9,6 +9,10 @@ import (
 
 type EngineManager struct {
 	engines sync.Map
+	// Shadows by champion name.
+	shadows sync.Map
+	// Experiments by name.
+	experiments sync.Map
 }
 
 func NewEngineManager() *EngineManager {
@@ -17,8 +21,8 @@ func NewEngineManager() *EngineManager {
 	}
 }
 
-func (em *EngineManager) CreateEngine(name string) *Engine {
-	engine := NewEngine()
+func (em *EngineManager) CreateEngine(name string, options ...EngineOption) *Engine {
+	engine := NewEngine(options...)
 	em.engines.Store(name, engine)
 	return engine
 }
@@ -31,8 +35,16 @@ func (em *EngineManager) GetEngine(name string) *Engine {
 	return nil
 }
 
+// DeleteEngine removes an engine, along with its pairing as a champion or
+// a challenger.
 func (em *EngineManager) DeleteEngine(name string) {
 	em.engines.Delete(name)
+	em.shadows.Range(func(key, value interface{}) bool {
+		if key.(string) == name || value.(*Shadow).Challenger == name {
+			em.shadows.Delete(key)
+		}
+		return true
+	})
 }
 
 func (em *EngineManager) GetEngines() map[string]*Engine {
@@ -43,3 +55,61 @@ func (em *EngineManager) GetEngines() map[string]*Engine {
 	})
 	return engines
 }
+
+// SetChallenger pairs the champion engine with a challenger, replacing any
+// existing challenger and its statistics. From then on Run evaluates the
+// challenger alongside every champion run.
+func (em *EngineManager) SetChallenger(champion, challenger string, options ...ShadowOption) (*Shadow, error) {
+	if champion == challenger {
+		return nil, fmt.Errorf("engine %s cannot challenge itself", champion)
+	}
+	for _, name := range []string{champion, challenger} {
+		if _, ok := em.engines.Load(name); !ok {
+			return nil, fmt.Errorf("engine %s not found", name)
+		}
+	}
+	shadow := newShadow(champion, challenger, options...)
+	em.shadows.Store(champion, shadow)
+	return shadow, nil
+}
+
+// RemoveChallenger ends the champion's pairing. It reports whether the
+// champion had a challenger.
+func (em *EngineManager) RemoveChallenger(champion string) bool {
+	_, ok := em.shadows.LoadAndDelete(champion)
+	return ok
+}
+
+// GetShadow returns the champion's pairing, or nil if it has no
+// challenger.
+func (em *EngineManager) GetShadow(champion string) *Shadow {
+	if shadow, ok := em.shadows.Load(champion); ok {
+		return shadow.(*Shadow)
+	}
+	return nil
+}
+
+// Run runs the named engine. If it is paired with a challenger, the
+// challenger evaluates the same runtime facts in the background; the
+// result is always the champion's.
+func (em *EngineManager) Run(name string, runtimeFacts map[string]interface{}, options ...RunOption) (*RunResult, error) {
+	engine, ok := em.engines.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("engine %s not found", name)
+	}
+	shadow := em.GetShadow(name)
+	if shadow == nil {
+		return engine.(*Engine).Run(runtimeFacts, options...)
+	}
+	facts := cloneParams(runtimeFacts)
+	result, err := engine.(*Engine).Run(runtimeFacts, options...)
+	if err != nil {
+		return result, err
+	}
+	var challenger *Engine
+	if e, ok := em.engines.Load(shadow.Challenger); ok {
+		challenger = e.(*Engine)
+	}
+	shadow.evaluate(challenger, facts, options, result)
+	return result, nil
+}
@@ -2,12 +2,14 @@ package rulesengine
 
 import (
 	"fmt"
+	"net/http"
 	"reflect"
 	"regexp"
 	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type Engine struct {
@@ -17,26 +19,66 @@ type Engine struct {
 	operators                 map[string]OperatorFunc
 	operatorDecorators        map[string]OperatorDecorator
 	conditions                map[string]Condition
+	groups                    map[string]*RuleGroup
 	allowUndefinedFacts       bool
 	allowUndefinedConditions  bool
 	replaceFactsInEventParams bool
 	stopRequested             atomic.Bool
 	pathResolver              PathResolverFunc
+	conflictResolver          ConflictResolver
+	clock                     func() time.Time
+	actions                   map[string]ActionHandler
+	metricSink                MetricSink
+	webhookEndpoint           string
+	httpClient                *http.Client
+	subMu                     sync.RWMutex
+	subscriptions             []*subscription
+	handlers                  sync.WaitGroup
+	outbox                    *Outbox
+	decisionLog               *DecisionLogger
+	// revision counts changes to rules, named conditions and groups.
+	revision atomic.Uint64
 }
 
-func NewEngine() *Engine {
+// EngineOption configures an Engine at construction time.
+type EngineOption func(*Engine)
+
+// WithAllowUndefinedFacts makes undefined facts evaluate to nil instead of
+// failing the run.
+func WithAllowUndefinedFacts() EngineOption {
+	return func(e *Engine) {
+		e.allowUndefinedFacts = true
+	}
+}
+
+// WithAllowUndefinedConditions makes references to undefined named
+// conditions evaluate to false instead of failing the run.
+func WithAllowUndefinedConditions() EngineOption {
+	return func(e *Engine) {
+		e.allowUndefinedConditions = true
+	}
+}
+
+func NewEngine(options ...EngineOption) *Engine {
 	e := &Engine{
 		facts:                     make(map[string]*Fact),
 		rules:                     []*Rule{},
 		operators:                 make(map[string]OperatorFunc),
 		operatorDecorators:        make(map[string]OperatorDecorator),
 		conditions:                make(map[string]Condition),
+		groups:                    make(map[string]*RuleGroup),
 		allowUndefinedFacts:       false,
 		allowUndefinedConditions:  false,
 		replaceFactsInEventParams: false,
 		pathResolver:              DefaultPathResolver,
+		clock:                     time.Now,
+		actions:                   make(map[string]ActionHandler),
 	}
 	e.initOperators()
+	e.initActions()
+	for _, opt := range options {
+		opt(e)
+	}
 	return e
 }
 
@@ -78,6 +120,71 @@ func (e *Engine) RemoveFact(id string) {
 func (e *Engine) AddRule(rule *Rule) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	if err := checkRule(rule); err != nil {
+		return err
+	}
+	e.rules = append(e.rules, rule)
+	e.sortRules()
+	e.revision.Add(1)
+	return nil
+}
+
+// UpdateRule replaces the first rule named name with rule, which may carry a
+// different name. The replacement is checked as in AddRule.
+func (e *Engine) UpdateRule(name string, rule *Rule) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := checkRule(rule); err != nil {
+		return err
+	}
+	for i, r := range e.rules {
+		if r.Name == name {
+			e.rules[i] = rule
+			e.sortRules()
+			e.revision.Add(1)
+			return nil
+		}
+	}
+	return fmt.Errorf("rule not found: %s", name)
+}
+
+// EnableRule clears Disabled on every rule named name.
+func (e *Engine) EnableRule(name string) error {
+	return e.setRuleDisabled(name, false)
+}
+
+// DisableRule sets Disabled on every rule named name. Disabled rules are
+// reported with RuleStatusDisabled and not evaluated.
+func (e *Engine) DisableRule(name string) error {
+	return e.setRuleDisabled(name, true)
+}
+
+func (e *Engine) setRuleDisabled(name string, disabled bool) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	found := false
+	for _, r := range e.rules {
+		if r.Name == name {
+			r.Disabled = disabled
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("rule not found: %s", name)
+	}
+	e.revision.Add(1)
+	return nil
+}
+
+func (e *Engine) sortRules() {
+	sort.SliceStable(e.rules, func(i, j int) bool {
+		return e.rules[i].Priority > e.rules[j].Priority
+	})
+}
+
+// checkRule runs the structural checks applied when a rule is added, and
+// prepares the rule to run.
+func checkRule(rule *Rule) error {
 	errs := ValidateCondition(&rule.Conditions)
 	if len(errs) > 0 {
 		msgs := make([]string, len(errs))
@@ -86,10 +193,31 @@ func (e *Engine) AddRule(rule *Rule) error {
 		}
 		return fmt.Errorf("invalid rule conditions: %s", strings.Join(msgs, "; "))
 	}
-	e.rules = append(e.rules, rule)
-	sort.Slice(e.rules, func(i, j int) bool {
-		return e.rules[i].Priority > e.rules[j].Priority
-	})
+	if errs := validateActivation(rule); len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, ve := range errs {
+			msgs[i] = ve.Error()
+		}
+		return fmt.Errorf("invalid rule activation: %s", strings.Join(msgs, "; "))
+	}
+	if errs := validateSalience(rule); len(errs) > 0 {
+		return fmt.Errorf("invalid rule salience: %s", errs[0].Error())
+	}
+	if errs := validateActions(rule); len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, ve := range errs {
+			msgs[i] = ve.Error()
+		}
+		return fmt.Errorf("invalid rule actions: %s", strings.Join(msgs, "; "))
+	}
+	if rule.Schedule != nil {
+		// Parse the schedule once rather than on every run. It was
+		// validated above. The parse goes into the engine's own copy, as
+		// the caller may share the Schedule or edit it later.
+		schedule := *rule.Schedule
+		schedule.parsed, _ = schedule.parse()
+		rule.Schedule = &schedule
+	}
 	return nil
 }
 
@@ -103,6 +231,7 @@ func (e *Engine) RemoveRule(ruleName string) {
 		}
 	}
 	e.rules = filtered
+	e.revision.Add(1)
 }
 
 func (e *Engine) AddOperator(name string, op OperatorFunc) {
@@ -133,12 +262,14 @@ func (e *Engine) SetCondition(name string, cond Condition) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	e.conditions[name] = cond
+	e.revision.Add(1)
 }
 
 func (e *Engine) RemoveCondition(name string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	delete(e.conditions, name)
+	e.revision.Add(1)
 }
 
 func (e *Engine) Stop() {
@@ -154,7 +285,26 @@ func (e *Engine) Run(runtimeFacts map[string]interface{}, options ...RunOption)
 		opt(cfg)
 	}
 
-	almanac := NewAlmanac(e, runtimeFacts)
+	// Decide before running whether to log, so unlogged runs are not traced.
+	var logged map[string]interface{}
+	if e.decisionLog != nil && !cfg.dryRun && e.decisionLog.sampled() {
+		logged = cloneParams(runtimeFacts)
+		if logged == nil {
+			logged = map[string]interface{}{}
+		}
+	}
+	traced := cfg.trace || cfg.coverage != nil || logged != nil
+
+	// Actions set facts in the almanac, so give it its own map rather than
+	// writing to the caller's, which may be shared by concurrent runs.
+	facts := make(map[string]interface{}, len(runtimeFacts))
+	for id, value := range runtimeFacts {
+		facts[id] = value
+	}
+	almanac := NewAlmanac(e, facts)
+	for id, cache := range cfg.factCache {
+		almanac.factCache[id] = cloneParams(cache)
+	}
 	result := &RunResult{
 		Almanac:            almanac,
 		Events:             []Event{},
@@ -163,14 +313,64 @@ func (e *Engine) Run(runtimeFacts map[string]interface{}, options ...RunOption)
 		FailureRuleResults: []*RuleResult{},
 	}
 
-	for _, rule := range e.rules {
-		if e.stopRequested.Load() {
+	if cfg.coverage != nil {
+		cfg.coverage.recordRun()
+	}
+
+	order, ranked, err := e.evaluationOrder(almanac)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		order = make([]int, len(e.rules))
+		for i := range order {
+			order[i] = i
+		}
+	} else {
+		result.Order = ranked
+	}
+
+	var candidates []Candidate
+	now := e.clock()
+	groups := newGroupRun(e, cfg)
+	stop := false
+	for n, i := range order {
+		rule := e.rules[i]
+		if stop || e.stopRequested.Load() {
+			if cfg.coverage != nil {
+				for _, j := range order[n:] {
+					cfg.coverage.recordRule(coverageKey(e.rules[j], j), e.rules[j], nil)
+				}
+			}
 			break
 		}
+		if !groups.active(rule) {
+			if cfg.coverage != nil {
+				cfg.coverage.recordRule(coverageKey(rule, i), rule, nil)
+			}
+			continue
+		}
+		if rule.Disabled {
+			if cfg.coverage != nil {
+				cfg.coverage.recordRule(coverageKey(rule, i), rule, nil)
+			}
+			result.RuleResults = append(result.RuleResults, &RuleResult{Name: rule.Name, Status: RuleStatusDisabled})
+			continue
+		}
+		active, err := rule.ActiveAt(now)
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			if cfg.coverage != nil {
+				cfg.coverage.recordRule(coverageKey(rule, i), rule, nil)
+			}
+			result.RuleResults = append(result.RuleResults, &RuleResult{Name: rule.Name, Status: RuleStatusInactive})
+			continue
+		}
 		var passed bool
 		var ruleResult *RuleResult
-		var err error
-		if cfg.trace {
+		if traced {
 			passed, ruleResult, err = rule.EvaluateWithTrace(almanac, e)
 		} else {
 			passed, ruleResult, err = rule.Evaluate(almanac, e)
@@ -178,10 +378,28 @@ func (e *Engine) Run(runtimeFacts map[string]interface{}, options ...RunOption)
 		if err != nil {
 			return nil, err
 		}
+		if cfg.coverage != nil {
+			cfg.coverage.recordRule(coverageKey(rule, i), rule, ruleResult.Trace)
+		}
 		result.RuleResults = append(result.RuleResults, ruleResult)
 		if passed {
+			if stop, err = groups.passed(rule); err != nil {
+				return nil, err
+			}
 			result.Events = append(result.Events, rule.Event)
-			if rule.OnSuccess != nil {
+			salience := rule.Priority
+			if result.Order != nil {
+				salience = result.Order[n].Salience
+			}
+			candidates = append(candidates, Candidate{Rule: rule, Event: rule.Event, Salience: salience})
+			// Persist the event before its callback, actions and handlers
+			// act on it.
+			if e.outbox != nil && !cfg.dryRun {
+				if err := e.outbox.Enqueue(OutboxRecord{Rule: rule.Name, Event: rule.Event}); err != nil {
+					return nil, fmt.Errorf("outbox: %w", err)
+				}
+			}
+			if rule.OnSuccess != nil && !cfg.dryRun {
 				if err := rule.OnSuccess(rule.Event, almanac, ruleResult); err != nil {
 					return nil, err
 				}
@@ -189,12 +407,43 @@ func (e *Engine) Run(runtimeFacts map[string]interface{}, options ...RunOption)
 		} else {
 			result.FailureRuleResults = append(result.FailureRuleResults, ruleResult)
 			result.FailureEvents = append(result.FailureEvents, rule.Event)
-			if rule.OnFailure != nil {
+			if rule.OnFailure != nil && !cfg.dryRun {
 				if err := rule.OnFailure(rule.Event, almanac, ruleResult); err != nil {
 					return nil, err
 				}
 			}
 		}
+		if cfg.dryRun {
+			continue
+		}
+		if len(rule.Actions) > 0 {
+			ctx := &ActionContext{Rule: rule, Event: rule.Event, Almanac: almanac, Result: ruleResult}
+			actions, err := e.runActions(ctx, passed)
+			result.Actions = append(result.Actions, actions...)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := e.dispatch(rule.Event, almanac, ruleResult, passed); err != nil {
+			return nil, err
+		}
+	}
+	if e.conflictResolver != nil && len(candidates) > 0 {
+		decision, err := e.conflictResolver.Resolve(candidates)
+		if err != nil {
+			return nil, err
+		}
+		result.Decision = decision
+	}
+	if logged != nil {
+		if err := e.decisionLog.log(e, now, logged, cfg, result); err != nil {
+			return nil, fmt.Errorf("decision log: %w", err)
+		}
+	}
+	if traced && !cfg.trace {
+		for _, rr := range result.RuleResults {
+			rr.Trace = nil
+		}
 	}
 	return result, nil
 }
@@ -334,11 +583,15 @@ func DefaultPathResolver(object interface{}, path string) interface{} {
 }
 
 type RunResult struct {
-	Events             []Event       `json:"events" bson:"events" xml:"events" yaml:"events"`
-	FailureEvents      []Event       `json:"failureEvents" bson:"failureEvents" xml:"failureEvents" yaml:"failureEvents"`
-	Almanac            *Almanac      `json:"-" bson:"-" xml:"-" yaml:"-"`
-	RuleResults        []*RuleResult `json:"ruleResults" bson:"ruleResults" xml:"ruleResults" yaml:"ruleResults"`
-	FailureRuleResults []*RuleResult `json:"failureRuleResults" bson:"failureRuleResults" xml:"failureRuleResults" yaml:"failureRuleResults"`
+	Events             []Event        `json:"events" bson:"events" xml:"events" yaml:"events"`
+	FailureEvents      []Event        `json:"failureEvents" bson:"failureEvents" xml:"failureEvents" yaml:"failureEvents"`
+	Almanac            *Almanac       `json:"-" bson:"-" xml:"-" yaml:"-"`
+	RuleResults        []*RuleResult  `json:"ruleResults" bson:"ruleResults" xml:"ruleResults" yaml:"ruleResults"`
+	FailureRuleResults []*RuleResult  `json:"failureRuleResults" bson:"failureRuleResults" xml:"failureRuleResults" yaml:"failureRuleResults"`
+	Decision           *Decision      `json:"decision,omitempty" bson:"decision,omitempty" xml:"decision,omitempty" yaml:"decision,omitempty"`
+	Order              []RuleOrder    `json:"order,omitempty" bson:"order,omitempty" xml:"order,omitempty" yaml:"order,omitempty"`
+	Actions            []ActionResult `json:"actions,omitempty" bson:"actions,omitempty" xml:"actions,omitempty" yaml:"actions,omitempty"`
+	Assignment         *Assignment    `json:"assignment,omitempty" bson:"assignment,omitempty" xml:"assignment,omitempty" yaml:"assignment,omitempty"`
 }
 
 func (e *Engine) GetRulesAsJSON() []interface{} {
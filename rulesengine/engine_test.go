@@ -1,11 +1,13 @@
 package rulesengine
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -424,3 +426,97 @@ func TestEngine_StopAtomic(t *testing.T) {
 	assert.Len(t, result.Events, 1)
 	assert.Equal(t, "first", result.Events[0].Type)
 }
+
+func TestEngine_Options_AllowUndefined(t *testing.T) {
+	engine := NewEngine(WithAllowUndefinedFacts(), WithAllowUndefinedConditions())
+	require.NoError(t, engine.AddRule(NewRule(
+		Condition{Any: []Condition{
+			{ConditionRef: "missing"},
+			{Fact: "unknown", Operator: "equal", Value: nil},
+		}},
+		Event{Type: "fallback"},
+	)))
+
+	result, err := engine.Run(nil)
+	require.NoError(t, err)
+	require.Len(t, result.Events, 1)
+	assert.Empty(t, engine.Validate())
+}
+
+func TestEngine_EqualPriorityKeepsInsertionOrder(t *testing.T) {
+	engine := NewEngine()
+	engine.AddFact("x", 1)
+	names := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	for _, name := range names {
+		require.NoError(t, engine.AddRule(NewRule(
+			Condition{Fact: "x", Operator: "equal", Value: 1},
+			Event{Type: name},
+			WithName(name),
+		)))
+	}
+
+	result, err := engine.Run(nil)
+	require.NoError(t, err)
+	var got []string
+	for _, ev := range result.Events {
+		got = append(got, ev.Type)
+	}
+	assert.Equal(t, names, got)
+}
+
+func TestEngine_UpdateRule(t *testing.T) {
+	engine := NewEngine()
+	cond := Condition{Fact: "n", Operator: "equal", Value: 1}
+	require.NoError(t, engine.AddRule(NewRule(cond, Event{Type: "a"}, WithName("a"), WithPriorityForRule(1))))
+	require.NoError(t, engine.AddRule(NewRule(cond, Event{Type: "b"}, WithName("b"), WithPriorityForRule(5))))
+
+	// Raising a's priority moves it first.
+	require.NoError(t, engine.UpdateRule("a", NewRule(cond, Event{Type: "a2"}, WithName("a"), WithPriorityForRule(9))))
+	result, err := engine.Run(map[string]interface{}{"n": 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a2", "b"}, eventTypes(result))
+
+	assert.ErrorContains(t, engine.UpdateRule("missing", NewRule(cond, Event{Type: "x"})), "rule not found")
+	assert.ErrorContains(t, engine.UpdateRule("a", NewRule(Condition{Fact: "n"}, Event{Type: "x"})), "invalid rule conditions")
+	rule, _ := engine.GetRule("a")
+	assert.Equal(t, "a2", rule.Event.Type)
+}
+
+func TestEngine_DisableRule(t *testing.T) {
+	engine := NewEngine()
+	cond := Condition{Fact: "n", Operator: "equal", Value: 1}
+	require.NoError(t, engine.AddRule(NewRule(cond, Event{Type: "a"}, WithName("a"))))
+	require.NoError(t, engine.AddRule(NewRule(cond, Event{Type: "b"}, WithName("b"), WithDisabled())))
+
+	result, err := engine.Run(map[string]interface{}{"n": 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, eventTypes(result))
+	require.Len(t, result.RuleResults, 2)
+	assert.Equal(t, RuleStatusDisabled, result.RuleResults[1].Status)
+	assert.Empty(t, result.FailureRuleResults)
+
+	require.NoError(t, engine.EnableRule("b"))
+	require.NoError(t, engine.DisableRule("a"))
+	result, err = engine.Run(map[string]interface{}{"n": 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, eventTypes(result))
+
+	assert.ErrorContains(t, engine.EnableRule("missing"), "rule not found")
+}
+
+func TestEngine_GetRulesAsJSON(t *testing.T) {
+	engine := NewEngine()
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rule := NewRule(Condition{Fact: "n", Operator: "equal", Value: 1.0}, Event{Type: "a"},
+		WithName("a"), WithGroup("g"), WithDisabled(), WithEffectiveFrom(from), WithEffectiveUntil(from.AddDate(0, 1, 0)),
+		WithSchedule(Schedule{Days: "mon-fri"}), WithSalience(Salience{Fact: "score"}),
+		WithActions(ActionSpec{Type: "setFact", Params: map[string]interface{}{"fact": "seen", "value": true}}))
+	require.NoError(t, engine.AddRule(rule))
+
+	// The JSON form matches the rule's own encoding.
+	want, err := json.Marshal(rule)
+	require.NoError(t, err)
+	got, err := json.Marshal(engine.GetRulesAsJSON()[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, string(want), string(got))
+}
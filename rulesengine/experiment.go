@@ -0,0 +1,344 @@
+package rulesengine
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Variant routes a share of an experiment's runs to an engine. A variant
+// receives Weight out of the sum of the experiment's weights.
+type Variant struct {
+	Name   string `json:"name" bson:"name" xml:"name" yaml:"name"`
+	Engine string `json:"engine" bson:"engine" xml:"engine" yaml:"engine"`
+	Weight int    `json:"weight" bson:"weight" xml:"weight" yaml:"weight"`
+}
+
+// Experiment splits runs between engines running variants of a rule set.
+// Key names the runtime fact identifying the unit assigned, such as a user
+// ID; a dotted path reads a field of an object fact. Runs with the same key
+// are assigned the same variant as long as the weights do not change.
+type Experiment struct {
+	Name     string    `json:"name" bson:"name" xml:"name" yaml:"name"`
+	Key      string    `json:"key" bson:"key" xml:"key" yaml:"key"`
+	Variants []Variant `json:"variants" bson:"variants" xml:"variants" yaml:"variants"`
+}
+
+// Assignment records the variant an experiment run was assigned, in the
+// run's RunResult. Sticky is set when the variant was taken from an earlier
+// assignment rather than hashed.
+type Assignment struct {
+	Experiment string `json:"experiment" bson:"experiment" xml:"experiment" yaml:"experiment"`
+	Variant    string `json:"variant" bson:"variant" xml:"variant" yaml:"variant"`
+	Engine     string `json:"engine" bson:"engine" xml:"engine" yaml:"engine"`
+	Key        string `json:"key" bson:"key" xml:"key" yaml:"key"`
+	Sticky     bool   `json:"sticky,omitempty" bson:"sticky,omitempty" xml:"sticky,omitempty" yaml:"sticky,omitempty"`
+}
+
+// Exposure records that a unit was served a variant.
+type Exposure struct {
+	Time       time.Time `json:"time" bson:"time" xml:"time" yaml:"time"`
+	Experiment string    `json:"experiment" bson:"experiment" xml:"experiment" yaml:"experiment"`
+	Variant    string    `json:"variant" bson:"variant" xml:"variant" yaml:"variant"`
+	Key        string    `json:"key" bson:"key" xml:"key" yaml:"key"`
+}
+
+// ExposureSink stores exposures.
+type ExposureSink interface {
+	WriteExposure(e Exposure) error
+}
+
+// AssignmentStore keeps the variant each unit was assigned, by experiment
+// and key.
+type AssignmentStore interface {
+	LoadAssignment(experiment, key string) (variant string, ok bool, err error)
+	StoreAssignment(experiment, key, variant string) error
+}
+
+// ExperimentOption configures an experiment added to an EngineManager.
+type ExperimentOption func(*experimentConfig)
+
+type experimentConfig struct {
+	assignments AssignmentStore
+	exposures   ExposureSink
+	clock       func() time.Time
+}
+
+// WithStickyAssignments keeps each unit on the variant it was first
+// assigned, even after the weights change, until that variant is removed
+// or given no weight. A new assignment is stored once its run, and its
+// exposure if one is logged, succeeds.
+func WithStickyAssignments(store AssignmentStore) ExperimentOption {
+	return func(c *experimentConfig) {
+		c.assignments = store
+	}
+}
+
+// WithExposureLog writes an exposure to sink for every successful run of
+// the experiment. A run fails if its exposure cannot be written.
+func WithExposureLog(sink ExposureSink) ExperimentOption {
+	return func(c *experimentConfig) {
+		c.exposures = sink
+	}
+}
+
+// WithExperimentClock sets the time source for exposures.
+func WithExperimentClock(now func() time.Time) ExperimentOption {
+	return func(c *experimentConfig) {
+		c.clock = now
+	}
+}
+
+type experiment struct {
+	Experiment
+	experimentConfig
+	totalWeight int
+}
+
+// AddExperiment adds an experiment, replacing any of the same name. Every
+// variant must name an engine of the manager.
+func (em *EngineManager) AddExperiment(exp Experiment, options ...ExperimentOption) error {
+	if exp.Name == "" {
+		return fmt.Errorf("experiment name is required")
+	}
+	if exp.Key == "" {
+		return fmt.Errorf("experiment %s: key is required", exp.Name)
+	}
+	if len(exp.Variants) == 0 {
+		return fmt.Errorf("experiment %s: no variants", exp.Name)
+	}
+	x := &experiment{experimentConfig: experimentConfig{clock: time.Now}}
+	names := make(map[string]bool)
+	for _, v := range exp.Variants {
+		switch {
+		case v.Name == "":
+			return fmt.Errorf("experiment %s: variant name is required", exp.Name)
+		case names[v.Name]:
+			return fmt.Errorf("experiment %s: duplicate variant %s", exp.Name, v.Name)
+		case v.Weight < 0:
+			return fmt.Errorf("experiment %s: variant %s has a negative weight", exp.Name, v.Name)
+		}
+		if _, ok := em.engines.Load(v.Engine); !ok {
+			return fmt.Errorf("experiment %s: variant %s: engine %s not found", exp.Name, v.Name, v.Engine)
+		}
+		names[v.Name] = true
+		x.totalWeight += v.Weight
+	}
+	if x.totalWeight == 0 {
+		return fmt.Errorf("experiment %s: variants have no weight", exp.Name)
+	}
+	x.Experiment = exp.clone()
+	for _, opt := range options {
+		opt(&x.experimentConfig)
+	}
+	em.experiments.Store(exp.Name, x)
+	return nil
+}
+
+// GetExperiment returns the named experiment, or nil if there is none.
+func (em *EngineManager) GetExperiment(name string) *Experiment {
+	if x, ok := em.experiments.Load(name); ok {
+		exp := x.(*experiment).clone()
+		return &exp
+	}
+	return nil
+}
+
+// GetExperiments returns the manager's experiments by name.
+func (em *EngineManager) GetExperiments() map[string]Experiment {
+	experiments := make(map[string]Experiment)
+	em.experiments.Range(func(key, value interface{}) bool {
+		experiments[key.(string)] = value.(*experiment).clone()
+		return true
+	})
+	return experiments
+}
+
+// DeleteExperiment removes an experiment. Sticky assignments are kept in
+// their store.
+func (em *EngineManager) DeleteExperiment(name string) {
+	em.experiments.Delete(name)
+}
+
+// RunExperiment assigns the runtime facts to a variant of the named
+// experiment by their key and runs the variant's engine, as Run does. The
+// result records the assignment. A run that fails leaves no exposure and
+// stores no sticky assignment.
+func (em *EngineManager) RunExperiment(name string, runtimeFacts map[string]interface{}, options ...RunOption) (*RunResult, error) {
+	x, ok := em.experiments.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("experiment %s not found", name)
+	}
+	exp := x.(*experiment)
+	assignment, err := exp.assign(runtimeFacts)
+	if err != nil {
+		return nil, err
+	}
+	result, err := em.Run(assignment.Engine, runtimeFacts, options...)
+	if err != nil {
+		return result, err
+	}
+	result.Assignment = assignment
+	if exp.exposures != nil {
+		exposure := Exposure{
+			Time:       exp.clock(),
+			Experiment: assignment.Experiment,
+			Variant:    assignment.Variant,
+			Key:        assignment.Key,
+		}
+		if err := exp.exposures.WriteExposure(exposure); err != nil {
+			return result, fmt.Errorf("exposure log: %w", err)
+		}
+	}
+	if exp.assignments != nil && !assignment.Sticky {
+		if err := exp.assignments.StoreAssignment(exp.Name, assignment.Key, assignment.Variant); err != nil {
+			return result, fmt.Errorf("experiment %s: storing assignment: %w", exp.Name, err)
+		}
+	}
+	return result, nil
+}
+
+func (x *experiment) assign(runtimeFacts map[string]interface{}) (*Assignment, error) {
+	key, err := experimentKey(runtimeFacts, x.Key)
+	if err != nil {
+		return nil, fmt.Errorf("experiment %s: %w", x.Name, err)
+	}
+	if x.assignments != nil {
+		name, ok, err := x.assignments.LoadAssignment(x.Name, key)
+		if err != nil {
+			return nil, fmt.Errorf("experiment %s: loading assignment: %w", x.Name, err)
+		}
+		if ok {
+			for _, v := range x.Variants {
+				if v.Name == name && v.Weight > 0 {
+					return &Assignment{Experiment: x.Name, Variant: v.Name, Engine: v.Engine, Key: key, Sticky: true}, nil
+				}
+			}
+		}
+	}
+	v := x.bucket(key)
+	return &Assignment{Experiment: x.Name, Variant: v.Name, Engine: v.Engine, Key: key}, nil
+}
+
+// bucket hashes key, salted with the experiment name so experiments split
+// units independently, onto the variants' weights.
+func (x *experiment) bucket(key string) Variant {
+	sum := sha256.Sum256([]byte(x.Name + "\x00" + key))
+	n := binary.BigEndian.Uint64(sum[:8]) % uint64(x.totalWeight)
+	for _, v := range x.Variants {
+		if n < uint64(v.Weight) {
+			return v
+		}
+		n -= uint64(v.Weight)
+	}
+	return x.Variants[len(x.Variants)-1]
+}
+
+// experimentKey reads the key at path from the runtime facts as a string.
+func experimentKey(runtimeFacts map[string]interface{}, path string) (string, error) {
+	var value interface{} = runtimeFacts
+	for _, field := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			value = nil
+			break
+		}
+		value = m[field]
+	}
+	switch v := value.(type) {
+	case nil:
+		return "", fmt.Errorf("runtime fact %s is missing", path)
+	case string:
+		return v, nil
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func (e Experiment) clone() Experiment {
+	e.Variants = append([]Variant(nil), e.Variants...)
+	return e
+}
+
+// MemoryAssignmentStore keeps sticky assignments in memory.
+type MemoryAssignmentStore struct {
+	mu          sync.RWMutex
+	assignments map[string]map[string]string
+}
+
+func NewMemoryAssignmentStore() *MemoryAssignmentStore {
+	return &MemoryAssignmentStore{assignments: make(map[string]map[string]string)}
+}
+
+func (s *MemoryAssignmentStore) LoadAssignment(experiment, key string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	variant, ok := s.assignments[experiment][key]
+	return variant, ok, nil
+}
+
+func (s *MemoryAssignmentStore) StoreAssignment(experiment, key, variant string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.assignments[experiment] == nil {
+		s.assignments[experiment] = make(map[string]string)
+	}
+	s.assignments[experiment][key] = variant
+	return nil
+}
+
+// MemoryExposureSink keeps exposures in memory.
+type MemoryExposureSink struct {
+	mu        sync.Mutex
+	exposures []Exposure
+}
+
+func NewMemoryExposureSink() *MemoryExposureSink {
+	return &MemoryExposureSink{}
+}
+
+func (s *MemoryExposureSink) WriteExposure(e Exposure) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exposures = append(s.exposures, e)
+	return nil
+}
+
+// Exposures returns the exposures written so far.
+func (s *MemoryExposureSink) Exposures() []Exposure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Exposure{}, s.exposures...)
+}
+
+// JSONLinesExposureSink writes each exposure as a line of JSON.
+type JSONLinesExposureSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewJSONLinesExposureSink(w io.Writer) *JSONLinesExposureSink {
+	return &JSONLinesExposureSink{w: w}
+}
+
+func (s *JSONLinesExposureSink) WriteExposure(e Exposure) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
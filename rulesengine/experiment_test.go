@@ -0,0 +1,197 @@
+package rulesengine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// thresholdExperiment creates engines approving amounts under 500 and under
+// 1000, split evenly by the experiment "limit" on the user's ID.
+func thresholdExperiment(t *testing.T, options ...ExperimentOption) *EngineManager {
+	t.Helper()
+	em := NewEngineManager()
+	for name, limit := range map[string]int{"low": 500, "high": 1000} {
+		engine := em.CreateEngine(name)
+		require.NoError(t, engine.AddRule(NewRule(Condition{Fact: "amount", Operator: "lessThan", Value: limit}, Event{Type: "approve"}, WithName("approve"))))
+	}
+	require.NoError(t, em.AddExperiment(Experiment{
+		Name: "limit",
+		Key:  "user.id",
+		Variants: []Variant{
+			{Name: "control", Engine: "low", Weight: 50},
+			{Name: "raised", Engine: "high", Weight: 50},
+		},
+	}, options...))
+	return em
+}
+
+func runUser(t *testing.T, em *EngineManager, id interface{}) *RunResult {
+	t.Helper()
+	result, err := em.RunExperiment("limit", map[string]interface{}{
+		"user":   map[string]interface{}{"id": id},
+		"amount": 700,
+	})
+	require.NoError(t, err)
+	return result
+}
+
+func TestExperiment_AssignsByKey(t *testing.T) {
+	em := thresholdExperiment(t)
+
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		result := runUser(t, em, fmt.Sprintf("user-%d", i))
+		a := result.Assignment
+		require.NotNil(t, a)
+		assert.Equal(t, "limit", a.Experiment)
+		assert.Equal(t, fmt.Sprintf("user-%d", i), a.Key)
+		assert.False(t, a.Sticky)
+		counts[a.Variant]++
+		if a.Variant == "raised" {
+			assert.Equal(t, "high", a.Engine)
+			assert.Len(t, result.Events, 1)
+		} else {
+			assert.Equal(t, "low", a.Engine)
+			assert.Empty(t, result.Events)
+		}
+	}
+	assert.InDelta(t, 500, counts["control"], 60)
+	assert.InDelta(t, 500, counts["raised"], 60)
+
+	// The same key always gets the same variant, whatever its type.
+	first := runUser(t, em, 42).Assignment
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, first, runUser(t, em, 42).Assignment)
+	}
+	assert.Equal(t, first, runUser(t, em, float64(42)).Assignment, "JSON numbers hash like integers")
+	assert.Equal(t, "42", first.Key)
+}
+
+func TestExperiment_Weights(t *testing.T) {
+	em := thresholdExperiment(t)
+	require.NoError(t, em.AddExperiment(Experiment{Name: "limit", Key: "user.id", Variants: []Variant{
+		{Name: "control", Engine: "low", Weight: 0},
+		{Name: "raised", Engine: "high", Weight: 1},
+	}}))
+	for i := 0; i < 50; i++ {
+		assert.Equal(t, "raised", runUser(t, em, i).Assignment.Variant)
+	}
+}
+
+func TestExperiment_StickyAssignments(t *testing.T) {
+	store := NewMemoryAssignmentStore()
+	em := thresholdExperiment(t, WithStickyAssignments(store))
+
+	assigned := map[int]string{}
+	for i := 0; i < 20; i++ {
+		assigned[i] = runUser(t, em, i).Assignment.Variant
+	}
+
+	// Shifting the weights keeps units on their variants.
+	require.NoError(t, em.AddExperiment(Experiment{Name: "limit", Key: "user.id", Variants: []Variant{
+		{Name: "control", Engine: "low", Weight: 1},
+		{Name: "raised", Engine: "high", Weight: 99},
+	}}, WithStickyAssignments(store)))
+	for i := 0; i < 20; i++ {
+		a := runUser(t, em, i).Assignment
+		assert.Equal(t, assigned[i], a.Variant)
+		assert.True(t, a.Sticky)
+	}
+
+	// Units on a variant that is turned off are reassigned.
+	require.NoError(t, em.AddExperiment(Experiment{Name: "limit", Key: "user.id", Variants: []Variant{
+		{Name: "control", Engine: "low", Weight: 0},
+		{Name: "raised", Engine: "high", Weight: 1},
+	}}, WithStickyAssignments(store)))
+	for i := 0; i < 20; i++ {
+		a := runUser(t, em, i).Assignment
+		assert.Equal(t, "raised", a.Variant)
+		assert.Equal(t, assigned[i] == "raised", a.Sticky)
+	}
+	variant, ok, err := store.LoadAssignment("limit", "0")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "raised", variant)
+
+	// Failed runs and exposures store no assignment.
+	_, err = em.RunExperiment("limit", map[string]interface{}{"user": map[string]interface{}{"id": "bob"}})
+	assert.EqualError(t, err, "undefined fact: amount")
+	require.NoError(t, em.AddExperiment(Experiment{Name: "limit", Key: "user.id", Variants: []Variant{
+		{Name: "control", Engine: "low", Weight: 1},
+	}}, WithStickyAssignments(store), WithExposureLog(failingExposureSink{})))
+	_, err = em.RunExperiment("limit", map[string]interface{}{"user": map[string]interface{}{"id": "bob"}, "amount": 1})
+	assert.EqualError(t, err, "exposure log: disk full")
+	_, ok, err = store.LoadAssignment("limit", "bob")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+type failingExposureSink struct{}
+
+func (failingExposureSink) WriteExposure(Exposure) error {
+	return errors.New("disk full")
+}
+
+func TestExperiment_ExposureLog(t *testing.T) {
+	now := time.Date(2024, 5, 6, 10, 0, 0, 0, time.UTC)
+	sink := NewMemoryExposureSink()
+	em := thresholdExperiment(t, WithExposureLog(sink), WithExperimentClock(func() time.Time { return now }))
+
+	a := runUser(t, em, "ann").Assignment
+	_, err := em.RunExperiment("limit", map[string]interface{}{"amount": 1})
+	assert.EqualError(t, err, "experiment limit: runtime fact user.id is missing")
+	assert.Equal(t, []Exposure{{Time: now, Experiment: "limit", Variant: a.Variant, Key: "ann"}}, sink.Exposures())
+
+	var buf bytes.Buffer
+	em = thresholdExperiment(t, WithExposureLog(NewJSONLinesExposureSink(&buf)), WithExperimentClock(func() time.Time { return now }))
+	runUser(t, em, "ann")
+	var exposure Exposure
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &exposure))
+	assert.Equal(t, Exposure{Time: now, Experiment: "limit", Variant: a.Variant, Key: "ann"}, exposure)
+}
+
+func TestEngineManager_Experiments(t *testing.T) {
+	em := thresholdExperiment(t)
+	exp := em.GetExperiment("limit")
+	require.NotNil(t, exp)
+	assert.Equal(t, "user.id", exp.Key)
+	exp.Variants[0].Weight = 0
+	assert.Equal(t, 50, em.GetExperiment("limit").Variants[0].Weight, "experiments are returned as copies")
+	assert.Equal(t, []string{"limit"}, keys(em.GetExperiments()))
+
+	for _, tc := range []struct {
+		exp  Experiment
+		want string
+	}{
+		{Experiment{Key: "id"}, "experiment name is required"},
+		{Experiment{Name: "x"}, "experiment x: key is required"},
+		{Experiment{Name: "x", Key: "id"}, "experiment x: no variants"},
+		{Experiment{Name: "x", Key: "id", Variants: []Variant{{Engine: "low", Weight: 1}}}, "experiment x: variant name is required"},
+		{Experiment{Name: "x", Key: "id", Variants: []Variant{{Name: "a", Engine: "low", Weight: 1}, {Name: "a", Engine: "high", Weight: 1}}}, "experiment x: duplicate variant a"},
+		{Experiment{Name: "x", Key: "id", Variants: []Variant{{Name: "a", Engine: "low", Weight: -1}}}, "experiment x: variant a has a negative weight"},
+		{Experiment{Name: "x", Key: "id", Variants: []Variant{{Name: "a", Engine: "missing", Weight: 1}}}, "experiment x: variant a: engine missing not found"},
+		{Experiment{Name: "x", Key: "id", Variants: []Variant{{Name: "a", Engine: "low"}}}, "experiment x: variants have no weight"},
+	} {
+		assert.EqualError(t, em.AddExperiment(tc.exp), tc.want)
+	}
+
+	em.DeleteExperiment("limit")
+	assert.Nil(t, em.GetExperiment("limit"))
+	_, err := em.RunExperiment("limit", nil)
+	assert.EqualError(t, err, "experiment limit not found")
+}
+
+func keys(m map[string]Experiment) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
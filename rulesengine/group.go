@@ -0,0 +1,157 @@
+package rulesengine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// GroupStrategy controls how a rule group behaves once one of its rules
+// passes.
+type GroupStrategy string
+
+const (
+	// GroupAll evaluates every rule in the group. It is the default.
+	GroupAll GroupStrategy = "all"
+	// GroupFirstMatch skips the group's remaining rules after one passes.
+	GroupFirstMatch GroupStrategy = "firstMatch"
+	// GroupStopOnMatch ends the run after one of the group's rules passes.
+	GroupStopOnMatch GroupStrategy = "stopOnMatch"
+	// GroupUnique expects at most one of the group's rules to pass, and
+	// fails the run with an error when a second one does.
+	GroupUnique GroupStrategy = "unique"
+)
+
+// RuleGroup configures the rules whose Group is Name. Rules naming a group
+// the engine does not know run as if it were enabled with GroupAll.
+type RuleGroup struct {
+	Name     string        `json:"name" bson:"name" xml:"name" yaml:"name"`
+	Disabled bool          `json:"disabled,omitempty" bson:"disabled,omitempty" xml:"disabled,omitempty" yaml:"disabled,omitempty"`
+	Strategy GroupStrategy `json:"strategy,omitempty" bson:"strategy,omitempty" xml:"strategy,omitempty" yaml:"strategy,omitempty"`
+}
+
+func validateGroup(g RuleGroup) error {
+	if g.Name == "" {
+		return fmt.Errorf("rule group needs a name")
+	}
+	switch g.Strategy {
+	case "", GroupAll, GroupFirstMatch, GroupStopOnMatch, GroupUnique:
+		return nil
+	}
+	return fmt.Errorf("rule group %s: unknown strategy %q", g.Name, g.Strategy)
+}
+
+// AddGroup adds a rule group, replacing any group with the same name.
+func (e *Engine) AddGroup(g RuleGroup) error {
+	if err := validateGroup(g); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.groups[g.Name] = &g
+	e.revision.Add(1)
+	return nil
+}
+
+func (e *Engine) RemoveGroup(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.groups, name)
+	e.revision.Add(1)
+}
+
+// EnableGroup enables a group so its rules run again.
+func (e *Engine) EnableGroup(name string) error {
+	return e.setGroupDisabled(name, false)
+}
+
+// DisableGroup disables a group; its rules are skipped by Run.
+func (e *Engine) DisableGroup(name string) error {
+	return e.setGroupDisabled(name, true)
+}
+
+func (e *Engine) setGroupDisabled(name string, disabled bool) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	g, ok := e.groups[name]
+	if !ok {
+		return fmt.Errorf("undefined rule group: %s", name)
+	}
+	g.Disabled = disabled
+	e.revision.Add(1)
+	return nil
+}
+
+// Groups returns the engine's rule groups sorted by name.
+func (e *Engine) Groups() []RuleGroup {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.groupList()
+}
+
+func (e *Engine) groupList() []RuleGroup {
+	groups := make([]RuleGroup, 0, len(e.groups))
+	for _, g := range e.groups {
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
+}
+
+// WithGroups restricts a run to rules in the named groups. Pass "" to
+// include rules without a group. Disabled groups are skipped even when
+// selected.
+func WithGroups(names ...string) RunOption {
+	return func(c *runConfig) {
+		if c.groups == nil {
+			c.groups = make(map[string]bool, len(names))
+		}
+		for _, name := range names {
+			c.groups[name] = true
+		}
+	}
+}
+
+// groupRun tracks group activity during a single run.
+type groupRun struct {
+	engine   *Engine
+	selected map[string]bool
+	matched  map[string]*Rule
+}
+
+func newGroupRun(e *Engine, cfg *runConfig) *groupRun {
+	return &groupRun{engine: e, selected: cfg.groups, matched: make(map[string]*Rule)}
+}
+
+// active reports whether the rule should be evaluated.
+func (g *groupRun) active(rule *Rule) bool {
+	if g.selected != nil && !g.selected[rule.Group] {
+		return false
+	}
+	if rule.Group == "" {
+		return true
+	}
+	group, ok := g.engine.groups[rule.Group]
+	if !ok {
+		return true
+	}
+	if group.Disabled {
+		return false
+	}
+	return !(group.Strategy == GroupFirstMatch && g.matched[rule.Group] != nil)
+}
+
+// passed records a passing rule and reports whether the run should stop. It
+// fails when the rule is the second to pass in a unique group.
+func (g *groupRun) passed(rule *Rule) (bool, error) {
+	if rule.Group == "" {
+		return false, nil
+	}
+	group, ok := g.engine.groups[rule.Group]
+	if first := g.matched[rule.Group]; ok && first != nil && group.Strategy == GroupUnique {
+		return false, fmt.Errorf("rule group %s: rules %q and %q both matched", rule.Group, first.Name, rule.Name)
+	}
+	if g.matched[rule.Group] == nil {
+		g.matched[rule.Group] = rule
+	}
+	return ok && group.Strategy == GroupStopOnMatch, nil
+}
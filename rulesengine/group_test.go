@@ -0,0 +1,130 @@
+package rulesengine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func groupedEngine(t *testing.T) *Engine {
+	t.Helper()
+	engine := NewEngine()
+	pass := Condition{Fact: "n", Operator: "gte", Value: 0}
+	add := func(name, group string, priority int) {
+		require.NoError(t, engine.AddRule(NewRule(pass, Event{Type: name},
+			WithName(name), WithGroup(group), WithPriorityForRule(priority))))
+	}
+	add("a1", "a", 10)
+	add("a2", "a", 9)
+	add("b1", "b", 8)
+	add("b2", "b", 7)
+	add("free", "", 6)
+	add("c1", "c", 5)
+	return engine
+}
+
+func eventTypes(result *RunResult) []string {
+	types := make([]string, len(result.Events))
+	for i, ev := range result.Events {
+		types[i] = ev.Type
+	}
+	return types
+}
+
+func TestRun_GroupStrategies(t *testing.T) {
+	engine := groupedEngine(t)
+	facts := map[string]interface{}{"n": 1}
+
+	result, err := engine.Run(facts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a1", "a2", "b1", "b2", "free", "c1"}, eventTypes(result))
+
+	require.NoError(t, engine.AddGroup(RuleGroup{Name: "a", Strategy: GroupFirstMatch}))
+	require.NoError(t, engine.AddGroup(RuleGroup{Name: "b", Strategy: GroupStopOnMatch}))
+	result, err = engine.Run(facts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a1", "b1"}, eventTypes(result))
+	assert.Len(t, result.RuleResults, 2)
+
+	require.NoError(t, engine.DisableGroup("b"))
+	result, err = engine.Run(facts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a1", "free", "c1"}, eventTypes(result))
+
+	require.NoError(t, engine.EnableGroup("b"))
+	require.NoError(t, engine.AddGroup(RuleGroup{Name: "a", Strategy: GroupUnique}))
+	_, err = engine.Run(facts)
+	assert.EqualError(t, err, `rule group a: rules "a1" and "a2" both matched`)
+	require.NoError(t, engine.AddGroup(RuleGroup{Name: "c", Strategy: GroupUnique}))
+	result, err = engine.Run(facts, WithGroups("c"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c1"}, eventTypes(result))
+
+	assert.Error(t, engine.DisableGroup("missing"))
+	assert.Error(t, engine.AddGroup(RuleGroup{Name: "d", Strategy: "sometimes"}))
+	assert.Error(t, engine.AddGroup(RuleGroup{}))
+}
+
+func TestRun_WithGroups(t *testing.T) {
+	engine := groupedEngine(t)
+	facts := map[string]interface{}{"n": 1}
+
+	result, err := engine.Run(facts, WithGroups("c"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c1"}, eventTypes(result))
+
+	result, err = engine.Run(facts, WithGroups("a", ""))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a1", "a2", "free"}, eventTypes(result))
+
+	require.NoError(t, engine.AddGroup(RuleGroup{Name: "c", Disabled: true}))
+	result, err = engine.Run(facts, WithGroups("c"))
+	require.NoError(t, err)
+	assert.Empty(t, result.Events)
+}
+
+func TestRuleSet_RoundTrip(t *testing.T) {
+	rs, err := LoadRuleSetFromYAML([]byte(`
+groups:
+  - name: fraud
+    strategy: stopOnMatch
+  - name: promo
+    disabled: true
+rules:
+  - name: block
+    group: fraud
+    conditions: {fact: score, operator: gt, value: 90}
+    event: {type: block}
+`))
+	require.NoError(t, err)
+	engine := NewEngine()
+	require.NoError(t, engine.AddRuleSet(rs))
+	assert.Equal(t, []RuleGroup{
+		{Name: "fraud", Strategy: GroupStopOnMatch},
+		{Name: "promo", Disabled: true},
+	}, engine.Groups())
+
+	data, err := engine.ExportRuleSetJSON()
+	require.NoError(t, err)
+	loaded, err := LoadRuleSetFromJSON(data)
+	require.NoError(t, err)
+	assert.Equal(t, engine.Groups(), loaded.Groups)
+	require.Len(t, loaded.Rules, 1)
+	assert.Equal(t, "fraud", loaded.Rules[0].Group)
+
+	data, err = engine.ExportRuleSetYAML()
+	require.NoError(t, err)
+	loaded, err = LoadRuleSetFromYAML(data)
+	require.NoError(t, err)
+	assert.Equal(t, engine.Groups(), loaded.Groups)
+
+	bare, err := LoadRuleSetFromJSON([]byte(`[{"name": "r", "conditions": {"fact": "x", "operator": "eq", "value": 1}, "event": {"type": "e"}}]`))
+	require.NoError(t, err)
+	assert.Empty(t, bare.Groups)
+	assert.Len(t, bare.Rules, 1)
+
+	bare, err = LoadRuleSetFromYAML([]byte("- name: r\n  conditions: {fact: x, operator: eq, value: 1}\n  event: {type: e}\n"))
+	require.NoError(t, err)
+	assert.Len(t, bare.Rules, 1)
+}
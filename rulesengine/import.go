@@ -2,7 +2,13 @@ package rulesengine
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
 	"gopkg.in/yaml.v3"
 )
 
@@ -30,6 +36,152 @@ func LoadRulesFromYAML(data []byte) ([]*Rule, error) {
 	return result, nil
 }
 
+// bsonRules wraps a rule list, since a BSON document cannot be a bare array.
+type bsonRules struct {
+	Rules []*Rule `bson:"rules"`
+}
+
+// LoadRulesFromBSON decodes a document of the form {rules: [...]}. Embedded
+// documents in values and params decode as maps rather than ordered documents.
+func LoadRulesFromBSON(data []byte) ([]*Rule, error) {
+	dec, err := bson.NewDecoder(bsonrw.NewBSONDocumentReader(data))
+	if err != nil {
+		return nil, err
+	}
+	dec.DefaultDocumentM()
+	var doc bsonRules
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc.Rules, nil
+}
+
+// LoadRulesFile loads rules from a file, using YAML for .yaml and .yml
+// files, BSON for .bson files and JSON otherwise.
+func LoadRulesFile(path string) ([]*Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isYAMLFile(path) {
+		return LoadRulesFromYAML(data)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".bson") {
+		return LoadRulesFromBSON(data)
+	}
+	return LoadRulesFromJSON(data)
+}
+
+func isYAMLFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// RuleSet is a rule document carrying group configuration alongside the
+// rules.
+type RuleSet struct {
+	Groups []RuleGroup `json:"groups,omitempty" bson:"groups,omitempty" xml:"groups,omitempty" yaml:"groups,omitempty"`
+	Rules  []*Rule     `json:"rules" bson:"rules" xml:"rules" yaml:"rules"`
+}
+
+// LoadRuleSetFromJSON decodes either a rule set object or a bare rule array.
+func LoadRuleSetFromJSON(data []byte) (*RuleSet, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		rules, err := LoadRulesFromJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		return &RuleSet{Rules: rules}, nil
+	}
+	var rs RuleSet
+	if err := json.Unmarshal(data, &rs); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+// LoadRuleSetFromYAML decodes either a rule set mapping or a bare rule
+// sequence.
+func LoadRuleSetFromYAML(data []byte) (*RuleSet, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	if len(node.Content) > 0 && node.Content[0].Kind == yaml.SequenceNode {
+		rules, err := LoadRulesFromYAML(data)
+		if err != nil {
+			return nil, err
+		}
+		return &RuleSet{Rules: rules}, nil
+	}
+	var rs RuleSet
+	if err := yaml.Unmarshal(data, &rs); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+// LoadRuleSetFile loads a rule set from a file, using YAML for .yaml and .yml
+// files, BSON for .bson files and JSON otherwise.
+func LoadRuleSetFile(path string) (*RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isYAMLFile(path) {
+		return LoadRuleSetFromYAML(data)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".bson") {
+		dec, err := bson.NewDecoder(bsonrw.NewBSONDocumentReader(data))
+		if err != nil {
+			return nil, err
+		}
+		dec.DefaultDocumentM()
+		var rs RuleSet
+		if err := dec.Decode(&rs); err != nil {
+			return nil, err
+		}
+		return &rs, nil
+	}
+	return LoadRuleSetFromJSON(data)
+}
+
+// AddRuleSet adds the rule set's groups and then its rules.
+func (e *Engine) AddRuleSet(rs *RuleSet) error {
+	for _, g := range rs.Groups {
+		if err := e.AddGroup(g); err != nil {
+			return err
+		}
+	}
+	for _, rule := range rs.Rules {
+		if err := e.AddRule(rule); err != nil {
+			return fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+	}
+	return nil
+}
+
+// RuleSet returns the engine's groups and rules as a rule set.
+func (e *Engine) RuleSet() *RuleSet {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	rules := make([]*Rule, len(e.rules))
+	copy(rules, e.rules)
+	return &RuleSet{Groups: e.groupList(), Rules: rules}
+}
+
+func (e *Engine) ExportRuleSetJSON() ([]byte, error) {
+	return json.Marshal(e.RuleSet())
+}
+
+func (e *Engine) ExportRuleSetYAML() ([]byte, error) {
+	return yaml.Marshal(e.RuleSet())
+}
+
 func (e *Engine) ExportRulesJSON() ([]byte, error) {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
@@ -41,3 +193,21 @@ func (e *Engine) ExportRulesYAML() ([]byte, error) {
 	defer e.mu.RUnlock()
 	return yaml.Marshal(e.rules)
 }
+
+// MarshalRulesBSON encodes rules in the document form read by
+// LoadRulesFromBSON.
+func MarshalRulesBSON(rules []*Rule) ([]byte, error) {
+	return bson.Marshal(bsonRules{Rules: rules})
+}
+
+// MarshalRuleSetBSON encodes a rule set in the document form read by
+// LoadRuleSetFile.
+func MarshalRuleSetBSON(rs *RuleSet) ([]byte, error) {
+	return bson.Marshal(rs)
+}
+
+func (e *Engine) ExportRulesBSON() ([]byte, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return MarshalRulesBSON(e.rules)
+}
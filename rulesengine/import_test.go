@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 func TestJSON_RoundTrip(t *testing.T) {
@@ -81,3 +82,40 @@ func TestLoadRulesFromJSON_SingleRule(t *testing.T) {
 	assert.Equal(t, "test", rules[0].Name)
 	assert.Equal(t, "eq", rules[0].Conditions.Operator)
 }
+
+func TestBSON_RulesRoundTrip(t *testing.T) {
+	engine := NewEngine()
+	require.NoError(t, engine.AddRule(NewRule(
+		Condition{Fact: "country", Operator: "in", Value: []interface{}{"US", "CA"}, Params: map[string]interface{}{"nested": map[string]interface{}{"k": "v"}}},
+		Event{Type: "na", Params: map[string]interface{}{"region": "north-america"}},
+		WithName("na"),
+		WithPriorityForRule(3),
+	)))
+
+	data, err := engine.ExportRulesBSON()
+	require.NoError(t, err)
+
+	rules, err := LoadRulesFromBSON(data)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, "na", rules[0].Name)
+	assert.Equal(t, 3, rules[0].Priority)
+	assert.Equal(t, "north-america", rules[0].Event.Params["region"])
+	assert.Equal(t, map[string]interface{}{"k": "v"}, map[string]interface{}(rules[0].Conditions.Params["nested"].(bson.M)))
+	assert.True(t, sliceContains(rules[0].Conditions.Value, "CA"))
+}
+
+func TestLoadRulesFile_ByExtension(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := writeFile(t, dir, "rules.json", `[{"name":"j","conditions":{"fact":"x","operator":"eq","value":1},"event":{"type":"j"}}]`)
+	yamlPath := writeFile(t, dir, "rules.yml", "- name: y\n  conditions: {fact: x, operator: eq, value: 1}\n  event: {type: y}\n")
+
+	rules, err := LoadRulesFile(jsonPath)
+	require.NoError(t, err)
+	assert.Equal(t, "j", rules[0].Name)
+
+	rules, err = LoadRulesFile(yamlPath)
+	require.NoError(t, err)
+	assert.Equal(t, "y", rules[0].Name)
+	assert.Equal(t, 1, rules[0].Conditions.Value)
+}
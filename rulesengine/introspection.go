@@ -0,0 +1,230 @@
+package rulesengine
+
+import (
+	"sort"
+	"time"
+)
+
+// FactInfo describes a fact registered with an engine. Value is set for
+// constant facts only.
+type FactInfo struct {
+	ID         string      `json:"id" bson:"id" xml:"id" yaml:"id"`
+	IsConstant bool        `json:"isConstant" bson:"isConstant" xml:"isConstant" yaml:"isConstant"`
+	Cache      bool        `json:"cache" bson:"cache" xml:"cache" yaml:"cache"`
+	Priority   int         `json:"priority" bson:"priority" xml:"priority" yaml:"priority"`
+	Value      interface{} `json:"value,omitempty" bson:"value,omitempty" xml:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// OperatorList names the operators and operator decorators of an engine.
+type OperatorList struct {
+	Operators  []string `json:"operators" bson:"operators" xml:"operators" yaml:"operators"`
+	Decorators []string `json:"decorators" bson:"decorators" xml:"decorators" yaml:"decorators"`
+}
+
+// GetRule returns a copy of the first rule with the given name.
+func (e *Engine) GetRule(name string) (*Rule, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, r := range e.rules {
+		if r.Name == name {
+			return r.Clone(), true
+		}
+	}
+	return nil, false
+}
+
+// ListRules returns copies of the engine's rules in static priority order.
+func (e *Engine) ListRules() []*Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	rules := make([]*Rule, len(e.rules))
+	for i, r := range e.rules {
+		rules[i] = r.Clone()
+	}
+	return rules
+}
+
+// GetFact describes the fact with the given ID.
+func (e *Engine) GetFact(id string) (FactInfo, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	f, ok := e.facts[id]
+	if !ok {
+		return FactInfo{}, false
+	}
+	return factInfo(id, f), true
+}
+
+// ListFacts describes the engine's facts, sorted by ID.
+func (e *Engine) ListFacts() []FactInfo {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	facts := make([]FactInfo, 0, len(e.facts))
+	for id, f := range e.facts {
+		facts = append(facts, factInfo(id, f))
+	}
+	sort.Slice(facts, func(i, j int) bool { return facts[i].ID < facts[j].ID })
+	return facts
+}
+
+func factInfo(id string, f *Fact) FactInfo {
+	info := FactInfo{ID: id, IsConstant: f.IsConstant, Cache: f.Cache, Priority: f.Priority}
+	if f.IsConstant {
+		// Constant facts ignore their arguments.
+		value, _ := f.Fn(nil, nil)
+		info.Value = cloneValue(value)
+	}
+	return info
+}
+
+// GetCondition returns a copy of the named condition.
+func (e *Engine) GetCondition(name string) (Condition, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	c, ok := e.conditions[name]
+	if !ok {
+		return Condition{}, false
+	}
+	return c.Clone(), true
+}
+
+// ListConditions returns copies of the engine's named conditions.
+func (e *Engine) ListConditions() map[string]Condition {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	conditions := make(map[string]Condition, len(e.conditions))
+	for name, c := range e.conditions {
+		conditions[name] = c.Clone()
+	}
+	return conditions
+}
+
+// ListOperators returns the sorted names of the engine's operators and
+// operator decorators.
+func (e *Engine) ListOperators() OperatorList {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	list := OperatorList{
+		Operators:  make([]string, 0, len(e.operators)),
+		Decorators: make([]string, 0, len(e.operatorDecorators)),
+	}
+	for name := range e.operators {
+		list.Operators = append(list.Operators, name)
+	}
+	for name := range e.operatorDecorators {
+		list.Decorators = append(list.Decorators, name)
+	}
+	sort.Strings(list.Operators)
+	sort.Strings(list.Decorators)
+	return list
+}
+
+// Clone returns a deep copy of the rule. Maps and slices of parameters and
+// values are copied; callbacks and salience functions are shared.
+func (r *Rule) Clone() *Rule {
+	c := *r
+	c.Conditions = r.Conditions.Clone()
+	c.Event = r.Event.Clone()
+	c.EffectiveFrom = cloneTime(r.EffectiveFrom)
+	c.EffectiveUntil = cloneTime(r.EffectiveUntil)
+	if r.Schedule != nil {
+		// The copy may be edited, so it parses its own fields.
+		c.Schedule = &Schedule{Days: r.Schedule.Days, Hours: r.Schedule.Hours, Timezone: r.Schedule.Timezone}
+	}
+	if r.Salience != nil {
+		s := *r.Salience
+		s.Params = cloneParams(r.Salience.Params)
+		if r.Salience.Values != nil {
+			s.Values = make(map[string]int, len(r.Salience.Values))
+			for k, v := range r.Salience.Values {
+				s.Values[k] = v
+			}
+		}
+		c.Salience = &s
+	}
+	if r.Actions != nil {
+		c.Actions = make([]ActionSpec, len(r.Actions))
+		for i, a := range r.Actions {
+			a.Params = cloneParams(a.Params)
+			c.Actions[i] = a
+		}
+	}
+	return &c
+}
+
+// Clone returns a deep copy of the event.
+func (ev *Event) Clone() Event {
+	return Event{Type: ev.Type, Params: cloneParams(ev.Params)}
+}
+
+// Clone returns a deep copy of the condition tree.
+func (c *Condition) Clone() Condition {
+	out := *c
+	out.Value = cloneValue(c.Value)
+	out.Params = cloneParams(c.Params)
+	out.All = cloneConditions(c.All)
+	out.Any = cloneConditions(c.Any)
+	if c.Not != nil {
+		not := c.Not.Clone()
+		out.Not = &not
+	}
+	if c.Aggregate != nil {
+		a := *c.Aggregate
+		a.Params = cloneParams(a.Params)
+		a.Value = cloneValue(a.Value)
+		if a.Where != nil {
+			where := a.Where.Clone()
+			a.Where = &where
+		}
+		out.Aggregate = &a
+	}
+	return out
+}
+
+func cloneConditions(cs []Condition) []Condition {
+	if cs == nil {
+		return nil
+	}
+	out := make([]Condition, len(cs))
+	for i := range cs {
+		out[i] = cs[i].Clone()
+	}
+	return out
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+func cloneParams(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = cloneValue(v)
+	}
+	return out
+}
+
+// cloneValue copies the maps and slices of JSON-like values. Other values
+// are returned as is.
+func cloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return cloneParams(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = cloneValue(item)
+		}
+		return out
+	case []string:
+		return append([]string(nil), v...)
+	}
+	return v
+}
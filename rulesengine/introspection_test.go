@@ -0,0 +1,128 @@
+package rulesengine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_GetRuleReturnsCopy(t *testing.T) {
+	engine := NewEngine()
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rule := NewRule(Condition{All: []Condition{
+		{Fact: "age", Operator: "in", Value: []interface{}{18, 19}},
+		{Not: &Condition{Fact: "profile", Path: ".banned", Operator: "equal", Value: true}},
+	}}, Event{Type: "adult", Params: map[string]interface{}{"tags": []interface{}{"a"}}},
+		WithName("adult"), WithPriorityForRule(5), WithEffectiveFrom(from),
+		WithSalience(Salience{Fact: "tier", Values: map[string]int{"gold": 10}}),
+		WithActions(ActionSpec{Type: "setFact", Params: map[string]interface{}{"fact": "x", "value": 1}}))
+	require.NoError(t, engine.AddRule(rule))
+
+	got, ok := engine.GetRule("adult")
+	require.True(t, ok)
+	assert.Equal(t, rule.Conditions, got.Conditions)
+	assert.Equal(t, rule.Event, got.Event)
+	assert.Equal(t, 5, got.Priority)
+
+	// Mutating the copy leaves the engine's rule untouched.
+	got.Conditions.All[0].Value.([]interface{})[0] = 99
+	got.Conditions.All[1].Not.Value = false
+	got.Event.Params["tags"].([]interface{})[0] = "b"
+	*got.EffectiveFrom = from.Add(time.Hour)
+	got.Salience.Values["gold"] = 0
+	got.Actions[0].Params["value"] = 2
+
+	again, ok := engine.GetRule("adult")
+	require.True(t, ok)
+	assert.Equal(t, 18, again.Conditions.All[0].Value.([]interface{})[0])
+	assert.Equal(t, true, again.Conditions.All[1].Not.Value)
+	assert.Equal(t, "a", again.Event.Params["tags"].([]interface{})[0])
+	assert.Equal(t, from, *again.EffectiveFrom)
+	assert.Equal(t, 10, again.Salience.Values["gold"])
+	assert.Equal(t, 1, again.Actions[0].Params["value"])
+
+	_, ok = engine.GetRule("missing")
+	assert.False(t, ok)
+}
+
+func TestEngine_ListRules(t *testing.T) {
+	engine := NewEngine()
+	assert.Empty(t, engine.ListRules())
+	cond := Condition{Fact: "n", Operator: "equal", Value: 1}
+	require.NoError(t, engine.AddRule(NewRule(cond, Event{Type: "low"}, WithName("low"), WithPriorityForRule(1))))
+	require.NoError(t, engine.AddRule(NewRule(cond, Event{Type: "high"}, WithName("high"), WithPriorityForRule(9))))
+
+	rules := engine.ListRules()
+	require.Len(t, rules, 2)
+	assert.Equal(t, "high", rules[0].Name)
+	assert.Equal(t, "low", rules[1].Name)
+
+	rules[0].Name = "renamed"
+	_, ok := engine.GetRule("high")
+	assert.True(t, ok)
+}
+
+func TestEngine_ListFacts(t *testing.T) {
+	engine := NewEngine()
+	require.NoError(t, engine.AddFact("limits", map[string]interface{}{"max": 10}, WithPriorityForFact(3)))
+	require.NoError(t, engine.AddFact("score", FactFunc(func(map[string]interface{}, *Almanac) (interface{}, error) {
+		return 1, nil
+	}), WithNoCache()))
+
+	facts := engine.ListFacts()
+	assert.Equal(t, []FactInfo{
+		{ID: "limits", IsConstant: true, Cache: true, Priority: 3, Value: map[string]interface{}{"max": 10}},
+		{ID: "score", IsConstant: false, Cache: false, Priority: 1},
+	}, facts)
+
+	facts[0].Value.(map[string]interface{})["max"] = 0
+	assert.Equal(t, 10, engine.ListFacts()[0].Value.(map[string]interface{})["max"])
+}
+
+func TestEngine_GetFact(t *testing.T) {
+	engine := NewEngine()
+	require.NoError(t, engine.AddFact("limit", 100))
+
+	fact, ok := engine.GetFact("limit")
+	assert.True(t, ok)
+	assert.Equal(t, FactInfo{ID: "limit", IsConstant: true, Cache: true, Priority: 1, Value: 100}, fact)
+	_, ok = engine.GetFact("missing")
+	assert.False(t, ok)
+}
+
+func TestEngine_ListConditions(t *testing.T) {
+	engine := NewEngine()
+	engine.SetCondition("adult", Condition{Fact: "age", Operator: "gte", Value: 18})
+
+	conditions := engine.ListConditions()
+	assert.Equal(t, map[string]Condition{"adult": {Fact: "age", Operator: "gte", Value: 18}}, conditions)
+
+	delete(conditions, "adult")
+	assert.Len(t, engine.ListConditions(), 1)
+}
+
+func TestEngine_ListOperators(t *testing.T) {
+	engine := NewEngine()
+	engine.AddOperator("startsWith", func(a, b interface{}) bool { return false })
+	engine.AddOperatorDecorator("not", func(a, b interface{}, next OperatorFunc) bool { return !next(a, b) })
+
+	list := engine.ListOperators()
+	assert.Contains(t, list.Operators, "equal")
+	assert.Contains(t, list.Operators, "startsWith")
+	assert.IsNonDecreasing(t, list.Operators)
+	assert.Equal(t, []string{"not"}, list.Decorators)
+}
+
+func TestCondition_CloneAggregate(t *testing.T) {
+	c := Condition{Aggregate: &Aggregate{
+		Fact: "xs", Reduce: ReduceAny,
+		Where: &Condition{Fact: "item", Operator: "in", Value: []interface{}{"a"}},
+	}}
+	clone := c.Clone()
+	clone.Aggregate.Where.Value.([]interface{})[0] = "b"
+	clone.Aggregate.Reduce = ReduceAll
+	assert.Equal(t, "a", c.Aggregate.Where.Value.([]interface{})[0])
+	assert.Equal(t, ReduceAny, c.Aggregate.Reduce)
+}
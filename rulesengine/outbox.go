@@ -0,0 +1,527 @@
+package rulesengine
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// OutboxRecord is an event waiting in an outbox for delivery.
+type OutboxRecord struct {
+	ID          string    `json:"id" bson:"id" xml:"id" yaml:"id"`
+	Rule        string    `json:"rule" bson:"rule" xml:"rule" yaml:"rule"`
+	Event       Event     `json:"event" bson:"event" xml:"event" yaml:"event"`
+	CreatedAt   time.Time `json:"createdAt" bson:"createdAt" xml:"createdAt" yaml:"createdAt"`
+	Attempts    int       `json:"attempts" bson:"attempts" xml:"attempts" yaml:"attempts"`
+	NextAttempt time.Time `json:"nextAttempt" bson:"nextAttempt" xml:"nextAttempt" yaml:"nextAttempt"`
+	LastError   string    `json:"lastError,omitempty" bson:"lastError,omitempty" xml:"lastError,omitempty" yaml:"lastError,omitempty"`
+}
+
+// OutboxStore persists outbox records. Pending returns records in the order
+// they were appended.
+type OutboxStore interface {
+	Append(records ...OutboxRecord) error
+	Pending() ([]OutboxRecord, error)
+	// Update replaces a pending record after a failed attempt.
+	Update(record OutboxRecord) error
+	// Remove deletes a delivered record.
+	Remove(id string) error
+	// DeadLetter moves a pending record to the dead letters.
+	DeadLetter(record OutboxRecord) error
+	DeadLetters() ([]OutboxRecord, error)
+}
+
+// MemoryOutboxStore is an OutboxStore that does not survive the process.
+type MemoryOutboxStore struct {
+	mu      sync.Mutex
+	pending []OutboxRecord
+	dead    []OutboxRecord
+}
+
+func NewMemoryOutboxStore() *MemoryOutboxStore {
+	return &MemoryOutboxStore{}
+}
+
+func (s *MemoryOutboxStore) Append(records ...OutboxRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, records...)
+	return nil
+}
+
+func (s *MemoryOutboxStore) Pending() ([]OutboxRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]OutboxRecord{}, s.pending...), nil
+}
+
+func (s *MemoryOutboxStore) Update(record OutboxRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return replaceRecord(s.pending, record)
+}
+
+func (s *MemoryOutboxStore) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	s.pending, err = removeRecord(s.pending, id)
+	return err
+}
+
+func (s *MemoryOutboxStore) DeadLetter(record OutboxRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	if s.pending, err = removeRecord(s.pending, record.ID); err != nil {
+		return err
+	}
+	s.dead = append(s.dead, record)
+	return nil
+}
+
+func (s *MemoryOutboxStore) DeadLetters() ([]OutboxRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]OutboxRecord{}, s.dead...), nil
+}
+
+func (s *MemoryOutboxStore) has(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.pending {
+		if r.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func replaceRecord(records []OutboxRecord, record OutboxRecord) error {
+	for i := range records {
+		if records[i].ID == record.ID {
+			records[i] = record
+			return nil
+		}
+	}
+	return fmt.Errorf("outbox record %s not found", record.ID)
+}
+
+func removeRecord(records []OutboxRecord, id string) ([]OutboxRecord, error) {
+	for i := range records {
+		if records[i].ID == id {
+			return append(records[:i], records[i+1:]...), nil
+		}
+	}
+	return records, fmt.Errorf("outbox record %s not found", id)
+}
+
+// FileOutboxStore is an OutboxStore backed by an append-only journal of
+// JSON lines. Every change is synced to disk before it returns, and the
+// state is rebuilt from the journal when the store is opened.
+type FileOutboxStore struct {
+	mem  MemoryOutboxStore
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// journalEntry is one line of a FileOutboxStore journal.
+type journalEntry struct {
+	Op     string        `json:"op"`
+	Record *OutboxRecord `json:"record,omitempty"`
+	ID     string        `json:"id,omitempty"`
+}
+
+const (
+	journalAppend = "append"
+	journalUpdate = "update"
+	journalRemove = "remove"
+	journalDead   = "dead"
+)
+
+// OpenFileOutboxStore opens or creates the journal at path. A truncated last
+// line, left by a crash mid-write, is ignored.
+func OpenFileOutboxStore(path string) (*FileOutboxStore, error) {
+	s := &FileOutboxStore{path: path}
+	size, err := s.replay()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	// Drop any interrupted write so new entries start on a fresh line.
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	s.file = f
+	return s, nil
+}
+
+// replay rebuilds the state from the journal and returns the length of its
+// complete lines.
+func (s *FileOutboxStore) replay() (int64, error) {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var size int64
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return size, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return 0, fmt.Errorf("%s:%d: %w", s.path, line, err)
+		}
+		if err := s.apply(entry); err != nil {
+			return 0, fmt.Errorf("%s:%d: %w", s.path, line, err)
+		}
+		size += int64(len(data))
+	}
+}
+
+func (s *FileOutboxStore) apply(entry journalEntry) error {
+	switch entry.Op {
+	case journalAppend:
+		return s.mem.Append(*entry.Record)
+	case journalUpdate:
+		return s.mem.Update(*entry.Record)
+	case journalRemove:
+		return s.mem.Remove(entry.ID)
+	case journalDead:
+		return s.mem.DeadLetter(*entry.Record)
+	}
+	return fmt.Errorf("unknown journal op %q", entry.Op)
+}
+
+// write journals the entries and then applies them in memory.
+func (s *FileOutboxStore) write(entries ...journalEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Check first so the journal never holds an entry that cannot replay.
+	for _, entry := range entries {
+		id := entry.ID
+		if entry.Record != nil {
+			id = entry.Record.ID
+		}
+		if entry.Op != journalAppend && !s.mem.has(id) {
+			return fmt.Errorf("outbox record %s not found", id)
+		}
+	}
+	var buf []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	if _, err := s.file.Write(buf); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := s.apply(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileOutboxStore) Append(records ...OutboxRecord) error {
+	entries := make([]journalEntry, len(records))
+	for i := range records {
+		entries[i] = journalEntry{Op: journalAppend, Record: &records[i]}
+	}
+	return s.write(entries...)
+}
+
+func (s *FileOutboxStore) Pending() ([]OutboxRecord, error) {
+	return s.mem.Pending()
+}
+
+func (s *FileOutboxStore) Update(record OutboxRecord) error {
+	return s.write(journalEntry{Op: journalUpdate, Record: &record})
+}
+
+func (s *FileOutboxStore) Remove(id string) error {
+	return s.write(journalEntry{Op: journalRemove, ID: id})
+}
+
+func (s *FileOutboxStore) DeadLetter(record OutboxRecord) error {
+	return s.write(journalEntry{Op: journalDead, Record: &record})
+}
+
+func (s *FileOutboxStore) DeadLetters() ([]OutboxRecord, error) {
+	return s.mem.DeadLetters()
+}
+
+// Compact rewrites the journal so it holds only the current pending records
+// and dead letters.
+func (s *FileOutboxStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending, _ := s.mem.Pending()
+	dead, _ := s.mem.DeadLetters()
+
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	// Any failure before the rename leaves the journal untouched.
+	abort := func(err error) error {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("compacting outbox journal: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for i := range dead {
+		// Dead letters are journaled as an append followed by a dead entry.
+		if err := enc.Encode(journalEntry{Op: journalAppend, Record: &dead[i]}); err != nil {
+			return abort(err)
+		}
+		if err := enc.Encode(journalEntry{Op: journalDead, Record: &dead[i]}); err != nil {
+			return abort(err)
+		}
+	}
+	for i := range pending {
+		if err := enc.Encode(journalEntry{Op: journalAppend, Record: &pending[i]}); err != nil {
+			return abort(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return abort(err)
+	}
+	if err := f.Sync(); err != nil {
+		return abort(err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("compacting outbox journal: %w", err)
+	}
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.file, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	return err
+}
+
+func (s *FileOutboxStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// EventSink delivers outbox records to a destination.
+type EventSink interface {
+	Deliver(ctx context.Context, record OutboxRecord) error
+}
+
+// EventSinkFunc adapts a function to EventSink.
+type EventSinkFunc func(ctx context.Context, record OutboxRecord) error
+
+func (f EventSinkFunc) Deliver(ctx context.Context, record OutboxRecord) error {
+	return f(ctx, record)
+}
+
+// Outbox persists events before they are acted on and delivers them to a
+// sink, retrying failures with backoff and dead-lettering records that run
+// out of attempts.
+type Outbox struct {
+	mu          sync.Mutex
+	store       OutboxStore
+	sink        EventSink
+	maxAttempts int
+	backoff     func(attempts int) time.Duration
+	now         func() time.Time
+}
+
+// OutboxOption configures an Outbox.
+type OutboxOption func(*Outbox)
+
+// WithMaxAttempts sets how many deliveries are attempted before a record is
+// dead-lettered. It defaults to 5.
+func WithMaxAttempts(n int) OutboxOption {
+	return func(o *Outbox) {
+		o.maxAttempts = n
+	}
+}
+
+// WithBackoff sets the delay before the next attempt after the given number
+// of failed attempts. It defaults to exponential backoff from one second,
+// capped at five minutes.
+func WithBackoff(backoff func(attempts int) time.Duration) OutboxOption {
+	return func(o *Outbox) {
+		o.backoff = backoff
+	}
+}
+
+// WithOutboxClock sets the time source used for scheduling retries.
+func WithOutboxClock(now func() time.Time) OutboxOption {
+	return func(o *Outbox) {
+		o.now = now
+	}
+}
+
+func NewOutbox(store OutboxStore, sink EventSink, options ...OutboxOption) *Outbox {
+	o := &Outbox{
+		store:       store,
+		sink:        sink,
+		maxAttempts: 5,
+		backoff:     defaultBackoff,
+		now:         time.Now,
+	}
+	for _, opt := range options {
+		opt(o)
+	}
+	return o
+}
+
+func defaultBackoff(attempts int) time.Duration {
+	d := time.Second << (attempts - 1)
+	if attempts > 9 || d > 5*time.Minute {
+		return 5 * time.Minute
+	}
+	return d
+}
+
+// WithOutbox makes Run persist the event of each passing rule to the outbox
+// as soon as the rule passes, before its OnSuccess callback, actions and
+// subscription handlers run. Delivery happens separately through Flush or
+// Start.
+func WithOutbox(o *Outbox) EngineOption {
+	return func(e *Engine) {
+		e.outbox = o
+	}
+}
+
+func newRecordID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Enqueue persists records, assigning IDs and timestamps to records that
+// lack them.
+func (o *Outbox) Enqueue(records ...OutboxRecord) error {
+	now := o.now()
+	for i := range records {
+		if records[i].ID == "" {
+			records[i].ID = newRecordID()
+		}
+		if records[i].CreatedAt.IsZero() {
+			records[i].CreatedAt = now
+		}
+	}
+	return o.store.Append(records...)
+}
+
+// FlushResult summarizes a Flush.
+type FlushResult struct {
+	Delivered    int `json:"delivered" bson:"delivered" xml:"delivered" yaml:"delivered"`
+	Retried      int `json:"retried" bson:"retried" xml:"retried" yaml:"retried"`
+	DeadLettered int `json:"deadLettered" bson:"deadLettered" xml:"deadLettered" yaml:"deadLettered"`
+}
+
+// Flush attempts delivery of every pending record that is due, in order.
+// A failed record does not block the ones after it.
+func (o *Outbox) Flush(ctx context.Context) (FlushResult, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var res FlushResult
+	pending, err := o.store.Pending()
+	if err != nil {
+		return res, err
+	}
+	for _, record := range pending {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		if record.NextAttempt.After(o.now()) {
+			continue
+		}
+		deliverErr := o.sink.Deliver(ctx, record)
+		if deliverErr == nil {
+			if err := o.store.Remove(record.ID); err != nil {
+				return res, err
+			}
+			res.Delivered++
+			continue
+		}
+		record.Attempts++
+		record.LastError = deliverErr.Error()
+		if record.Attempts >= o.maxAttempts {
+			if err := o.store.DeadLetter(record); err != nil {
+				return res, err
+			}
+			res.DeadLettered++
+			continue
+		}
+		record.NextAttempt = o.now().Add(o.backoff(record.Attempts))
+		if err := o.store.Update(record); err != nil {
+			return res, err
+		}
+		res.Retried++
+	}
+	return res, nil
+}
+
+// Start flushes the outbox every interval until ctx is done. Flush errors
+// are passed to onError if it is not nil.
+func (o *Outbox) Start(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := o.Flush(ctx); err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Pending returns the records awaiting delivery.
+func (o *Outbox) Pending() ([]OutboxRecord, error) {
+	return o.store.Pending()
+}
+
+// DeadLetters returns the records that ran out of attempts.
+func (o *Outbox) DeadLetters() ([]OutboxRecord, error) {
+	return o.store.DeadLetters()
+}
@@ -0,0 +1,238 @@
+package rulesengine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakySink fails the first failures deliveries of each record.
+type flakySink struct {
+	failures  int
+	attempts  map[string]int
+	delivered []OutboxRecord
+}
+
+func (s *flakySink) Deliver(ctx context.Context, record OutboxRecord) error {
+	if s.attempts == nil {
+		s.attempts = map[string]int{}
+	}
+	s.attempts[record.ID]++
+	if s.attempts[record.ID] <= s.failures {
+		return errors.New("unavailable")
+	}
+	s.delivered = append(s.delivered, record)
+	return nil
+}
+
+func TestEngine_RunEnqueuesToOutbox(t *testing.T) {
+	store := NewMemoryOutboxStore()
+	sink := &flakySink{}
+	outbox := NewOutbox(store, sink)
+	engine := NewEngine(WithOutbox(outbox))
+	require.NoError(t, engine.AddRule(NewRule(Condition{Fact: "n", Operator: "gt", Value: 0}, Event{Type: "positive"}, WithName("positive"))))
+	require.NoError(t, engine.AddRule(NewRule(Condition{Fact: "n", Operator: "lt", Value: 0}, Event{Type: "negative"}, WithName("negative"))))
+
+	_, err := engine.Run(map[string]interface{}{"n": 1})
+	require.NoError(t, err)
+	pending, err := outbox.Pending()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "positive", pending[0].Rule)
+	assert.NotEmpty(t, pending[0].ID)
+	assert.False(t, pending[0].CreatedAt.IsZero())
+
+	res, err := outbox.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, FlushResult{Delivered: 1}, res)
+	require.Len(t, sink.delivered, 1)
+	assert.Equal(t, Event{Type: "positive"}, sink.delivered[0].Event)
+	pending, _ = outbox.Pending()
+	assert.Empty(t, pending)
+}
+
+func TestEngine_RunEnqueuesBeforeActing(t *testing.T) {
+	outbox := NewOutbox(NewMemoryOutboxStore(), &flakySink{})
+	engine := NewEngine(WithOutbox(outbox))
+	var seen []int
+	record := func() {
+		pending, err := outbox.Pending()
+		require.NoError(t, err)
+		seen = append(seen, len(pending))
+	}
+	require.NoError(t, engine.AddRule(NewRule(Condition{Fact: "n", Operator: "gt", Value: 0}, Event{Type: "positive"}, WithName("positive"),
+		WithOnSuccess(func(Event, *Almanac, *RuleResult) error {
+			record()
+			return nil
+		}),
+		WithActions(ActionSpec{Type: "checkOutbox"}))))
+	engine.RegisterAction("checkOutbox", func(ctx *ActionContext, params map[string]interface{}) (interface{}, error) {
+		record()
+		return nil, nil
+	})
+	engine.On("positive", func(Event, *Almanac, *RuleResult) error {
+		record()
+		return nil
+	})
+
+	_, err := engine.Run(map[string]interface{}{"n": 1})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 1, 1}, seen)
+}
+
+func TestOutbox_RetriesAndDeadLetters(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sink := &flakySink{failures: 1}
+	outbox := NewOutbox(NewMemoryOutboxStore(), sink,
+		WithMaxAttempts(2),
+		WithBackoff(func(int) time.Duration { return time.Minute }),
+		WithOutboxClock(func() time.Time { return now }))
+	require.NoError(t, outbox.Enqueue(OutboxRecord{ID: "a", Event: Event{Type: "a"}}))
+
+	res, err := outbox.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, FlushResult{Retried: 1}, res)
+	pending, _ := outbox.Pending()
+	require.Len(t, pending, 1)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, "unavailable", pending[0].LastError)
+	assert.Equal(t, now.Add(time.Minute), pending[0].NextAttempt)
+
+	// Not due yet.
+	res, err = outbox.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, FlushResult{}, res)
+
+	now = now.Add(time.Minute)
+	res, err = outbox.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, FlushResult{Delivered: 1}, res)
+
+	sink.failures = 5
+	require.NoError(t, outbox.Enqueue(OutboxRecord{ID: "b", Event: Event{Type: "b"}}))
+	outbox.Flush(context.Background())
+	now = now.Add(time.Minute)
+	res, err = outbox.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, FlushResult{DeadLettered: 1}, res)
+	dead, err := outbox.DeadLetters()
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, "b", dead[0].ID)
+	assert.Equal(t, 2, dead[0].Attempts)
+}
+
+func TestFileOutboxStore_SurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	store, err := OpenFileOutboxStore(path)
+	require.NoError(t, err)
+	require.NoError(t, store.Append(
+		OutboxRecord{ID: "a", Event: Event{Type: "a"}},
+		OutboxRecord{ID: "b", Event: Event{Type: "b"}},
+		OutboxRecord{ID: "c", Event: Event{Type: "c"}},
+	))
+	require.NoError(t, store.Remove("a"))
+	require.NoError(t, store.Update(OutboxRecord{ID: "b", Event: Event{Type: "b"}, Attempts: 1}))
+	require.NoError(t, store.DeadLetter(OutboxRecord{ID: "c", Event: Event{Type: "c"}, Attempts: 3}))
+	assert.Error(t, store.Remove("missing"))
+	require.NoError(t, store.Close())
+
+	// Simulate a crash in the middle of a write.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	f.WriteString(`{"op":"append","record":{"id":"d"`)
+	f.Close()
+
+	store, err = OpenFileOutboxStore(path)
+	require.NoError(t, err)
+	pending, _ := store.Pending()
+	require.Len(t, pending, 1)
+	assert.Equal(t, "b", pending[0].ID)
+	assert.Equal(t, 1, pending[0].Attempts)
+	dead, _ := store.DeadLetters()
+	require.Len(t, dead, 1)
+	assert.Equal(t, "c", dead[0].ID)
+
+	require.NoError(t, store.Append(OutboxRecord{ID: "e", Event: Event{Type: "e"}}))
+	require.NoError(t, store.Compact())
+	require.NoError(t, store.Append(OutboxRecord{ID: "f", Event: Event{Type: "f"}}))
+	require.NoError(t, store.Close())
+
+	store, err = OpenFileOutboxStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	pending, _ = store.Pending()
+	ids := []string{}
+	for _, r := range pending {
+		ids = append(ids, r.ID)
+	}
+	assert.Equal(t, []string{"b", "e", "f"}, ids)
+	dead, _ = store.DeadLetters()
+	assert.Len(t, dead, 1)
+}
+
+func TestOutbox_Start(t *testing.T) {
+	ch := make(chan OutboxRecord, 1)
+	outbox := NewOutbox(NewMemoryOutboxStore(), NewChannelSink(ch))
+	require.NoError(t, outbox.Enqueue(OutboxRecord{Event: Event{Type: "tick"}}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		outbox.Start(ctx, 10*time.Millisecond, nil)
+		close(done)
+	}()
+	select {
+	case record := <-ch:
+		assert.Equal(t, "tick", record.Event.Type)
+	case <-time.After(time.Second):
+		t.Fatal("record was not delivered")
+	}
+	cancel()
+	<-done
+}
+
+// failingJSON marshals once and then fails, so a record can be journaled
+// and still break a later compaction.
+type failingJSON struct{ calls *int }
+
+func (f failingJSON) MarshalJSON() ([]byte, error) {
+	*f.calls++
+	if *f.calls > 1 {
+		return nil, errors.New("cannot encode")
+	}
+	return []byte(`"ok"`), nil
+}
+
+func TestFileOutboxStore_CompactFailureKeepsJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	store, err := OpenFileOutboxStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	calls := 0
+	require.NoError(t, store.Append(
+		OutboxRecord{ID: "a", Event: Event{Type: "a"}},
+		OutboxRecord{ID: "b", Event: Event{Type: "b", Params: map[string]interface{}{"v": failingJSON{&calls}}}},
+	))
+	before, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, store.Compact(), "cannot encode")
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+	_, err = os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, store.Remove("b"))
+	require.NoError(t, store.Compact())
+	pending, _ := store.Pending()
+	require.Len(t, pending, 1)
+	assert.Equal(t, "a", pending[0].ID)
+}
@@ -0,0 +1,214 @@
+package rulesengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ReplayResult compares a logged decision with a replay of it.
+type ReplayResult struct {
+	Decision *DecisionRecord `json:"decision" bson:"decision" xml:"decision" yaml:"decision"`
+	// Version is the version of the rules replayed against.
+	Version string       `json:"version" bson:"version" xml:"version" yaml:"version"`
+	Result  *RunResult   `json:"result" bson:"result" xml:"result" yaml:"result"`
+	Diff    DecisionDiff `json:"diff" bson:"diff" xml:"diff" yaml:"diff"`
+}
+
+// OutcomeChange records a rule whose outcome differs between a decision and
+// its replay. Old or New is empty if the rule was not reported on that
+// side.
+type OutcomeChange struct {
+	Rule string `json:"rule" bson:"rule" xml:"rule" yaml:"rule"`
+	Old  string `json:"old" bson:"old" xml:"old" yaml:"old"`
+	New  string `json:"new" bson:"new" xml:"new" yaml:"new"`
+}
+
+// DecisionDiff is the difference between a decision and its replay.
+type DecisionDiff struct {
+	AddedEvents   []Event         `json:"addedEvents" bson:"addedEvents" xml:"addedEvents" yaml:"addedEvents"`
+	RemovedEvents []Event         `json:"removedEvents" bson:"removedEvents" xml:"removedEvents" yaml:"removedEvents"`
+	Changed       []OutcomeChange `json:"changed" bson:"changed" xml:"changed" yaml:"changed"`
+}
+
+// Empty reports whether the replay reached the same decision.
+func (d *DecisionDiff) Empty() bool {
+	return len(d.AddedEvents) == 0 && len(d.RemovedEvents) == 0 && len(d.Changed) == 0
+}
+
+// WriteReport writes a human-readable summary of the diff.
+func (d *DecisionDiff) WriteReport(w io.Writer) error {
+	if d.Empty() {
+		_, err := fmt.Fprintln(w, "same decision")
+		return err
+	}
+	for _, ev := range d.RemovedEvents {
+		if _, err := fmt.Fprintf(w, "- event %s\n", eventKey(ev)); err != nil {
+			return err
+		}
+	}
+	for _, ev := range d.AddedEvents {
+		if _, err := fmt.Fprintf(w, "+ event %s\n", eventKey(ev)); err != nil {
+			return err
+		}
+	}
+	for _, c := range d.Changed {
+		if _, err := fmt.Fprintf(w, "~ rule %s: %s -> %s\n", c.Rule, outcomeOrNone(c.Old), outcomeOrNone(c.New)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func outcomeOrNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+// Replay re-runs a logged decision and diffs the outcome. With a nil
+// snapshot it runs against the engine's current rules, otherwise against
+// the snapshot's rules, named conditions and groups, such as those the
+// decision was made with; facts and operators are always the engine's.
+//
+// The replay sees the decision's runtime facts and the fact values it
+// computed, so facts are not recomputed unless the rules need new ones, and
+// it runs at the decision's time, so rule schedules match. Callbacks,
+// actions, subscriptions, the outbox and the decision log are skipped.
+func (e *Engine) Replay(d *DecisionRecord, snapshot *RuleSnapshot) (*ReplayResult, error) {
+	replay, version, err := e.replayEngine(d, snapshot)
+	if err != nil {
+		return nil, err
+	}
+	options := []RunOption{WithTrace(), func(c *runConfig) {
+		c.dryRun = true
+		c.factCache = d.ComputedFacts
+	}}
+	if len(d.Groups) > 0 {
+		options = append(options, WithGroups(d.Groups...))
+	}
+	result, err := replay.Run(cloneParams(d.RuntimeFacts), options...)
+	if err != nil {
+		return nil, fmt.Errorf("replaying decision %s: %w", d.ID, err)
+	}
+	return &ReplayResult{
+		Decision: d,
+		Version:  version,
+		Result:   result,
+		Diff:     diffResults(d.Events, d.RuleResults, result),
+	}, nil
+}
+
+// replayEngine returns an engine sharing e's facts and operators, with the
+// rules of snapshot or of e and a clock stopped at the decision's time.
+func (e *Engine) replayEngine(d *DecisionRecord, snapshot *RuleSnapshot) (*Engine, string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if snapshot == nil {
+		var err error
+		if snapshot, err = e.snapshotLocked(); err != nil {
+			return nil, "", err
+		}
+	}
+	replay := NewEngine(WithClock(func() time.Time { return d.Time }))
+	replay.allowUndefinedFacts = e.allowUndefinedFacts
+	replay.allowUndefinedConditions = e.allowUndefinedConditions
+	replay.replaceFactsInEventParams = e.replaceFactsInEventParams
+	replay.pathResolver = e.pathResolver
+	replay.conflictResolver = e.conflictResolver
+	for id, f := range e.facts {
+		replay.facts[id] = f
+	}
+	for name, op := range e.operators {
+		replay.operators[name] = op
+	}
+	for name, dec := range e.operatorDecorators {
+		replay.operatorDecorators[name] = dec
+	}
+	for _, r := range snapshot.Rules {
+		replay.rules = append(replay.rules, r.Clone())
+	}
+	replay.sortRules()
+	for name, c := range snapshot.Conditions {
+		replay.conditions[name] = c.Clone()
+	}
+	for _, g := range snapshot.Groups {
+		g := g
+		replay.groups[g.Name] = &g
+	}
+	return replay, snapshot.Version, nil
+}
+
+// ruleOutcome is a rule result's status, or passed or failed for results
+// from before statuses were reported.
+func ruleOutcome(rr *RuleResult) string {
+	if rr.Status != "" {
+		return rr.Status
+	}
+	if rr.Success {
+		return RuleStatusPassed
+	}
+	return RuleStatusFailed
+}
+
+// eventKey identifies an event by its JSON form, so events compare equal
+// after a round trip through the log.
+func eventKey(ev Event) string {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return ev.Type
+	}
+	return string(data)
+}
+
+// diffResults compares the events and rule results of an earlier run with
+// result.
+func diffResults(events []Event, ruleResults []*RuleResult, result *RunResult) DecisionDiff {
+	diff := DecisionDiff{AddedEvents: []Event{}, RemovedEvents: []Event{}, Changed: []OutcomeChange{}}
+
+	remaining := make(map[string]int)
+	for _, ev := range result.Events {
+		remaining[eventKey(ev)]++
+	}
+	for _, ev := range events {
+		key := eventKey(ev)
+		if remaining[key] > 0 {
+			remaining[key]--
+			continue
+		}
+		diff.RemovedEvents = append(diff.RemovedEvents, ev)
+	}
+	for _, ev := range result.Events {
+		key := eventKey(ev)
+		if remaining[key] > 0 {
+			remaining[key]--
+			diff.AddedEvents = append(diff.AddedEvents, ev)
+		}
+	}
+
+	old := make(map[string]string)
+	var names []string
+	for _, rr := range ruleResults {
+		if _, ok := old[rr.Name]; !ok {
+			names = append(names, rr.Name)
+		}
+		old[rr.Name] = ruleOutcome(rr)
+	}
+	replayed := make(map[string]string)
+	for _, rr := range result.RuleResults {
+		if _, ok := old[rr.Name]; !ok {
+			if _, ok := replayed[rr.Name]; !ok {
+				names = append(names, rr.Name)
+			}
+		}
+		replayed[rr.Name] = ruleOutcome(rr)
+	}
+	for _, name := range names {
+		if old[name] != replayed[name] {
+			diff.Changed = append(diff.Changed, OutcomeChange{Rule: name, Old: old[name], New: replayed[name]})
+		}
+	}
+	return diff
+}
@@ -0,0 +1,143 @@
+package rulesengine
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplay_CurrentAndHistoricalRules(t *testing.T) {
+	sink := NewMemoryDecisionSink()
+	engine := loanEngine(t, sink)
+	_, err := engine.Run(map[string]interface{}{"applicant": map[string]interface{}{"income": 65000}})
+	require.NoError(t, err)
+	log := sink.Log()
+	d := log.Decisions[0]
+
+	// Tighten the rule after the decision was made.
+	require.NoError(t, engine.UpdateRule("approve", NewRule(Condition{Fact: "score", Operator: "greaterThan", Value: 680}, Event{Type: "approve"}, WithName("approve"))))
+	require.NoError(t, engine.AddRule(NewRule(Condition{Fact: "score", Operator: "lessThan", Value: 680}, Event{Type: "review"}, WithName("review"))))
+
+	replayed, err := engine.Replay(d, nil)
+	require.NoError(t, err)
+	current, err := engine.Snapshot()
+	require.NoError(t, err)
+	assert.Equal(t, current.Version, replayed.Version)
+	assert.Equal(t, []Event{{Type: "approve"}}, replayed.Diff.RemovedEvents)
+	assert.Equal(t, []Event{{Type: "review"}}, replayed.Diff.AddedEvents)
+	assert.Equal(t, []OutcomeChange{
+		{Rule: "approve", Old: RuleStatusPassed, New: RuleStatusFailed},
+		{Rule: "review", Old: "", New: RuleStatusPassed},
+	}, replayed.Diff.Changed)
+
+	var report bytes.Buffer
+	require.NoError(t, replayed.Diff.WriteReport(&report))
+	assert.Equal(t, `- event {"type":"approve"}
++ event {"type":"review"}
+~ rule approve: passed -> failed
+~ rule review: (none) -> passed
+`, report.String())
+
+	snapshot, ok := log.Snapshot(d)
+	require.True(t, ok)
+	replayed, err = engine.Replay(d, snapshot)
+	require.NoError(t, err)
+	assert.Equal(t, d.Version, replayed.Version)
+	assert.True(t, replayed.Diff.Empty())
+	report.Reset()
+	require.NoError(t, replayed.Diff.WriteReport(&report))
+	assert.Equal(t, "same decision\n", report.String())
+
+	// Replays are not themselves logged.
+	assert.Len(t, sink.Log().Decisions, 1)
+}
+
+func TestReplay_UsesLoggedFactValues(t *testing.T) {
+	sink := NewMemoryDecisionSink()
+	rate := 0.01
+	engine := NewEngine(WithDecisionLog(NewDecisionLogger(sink)))
+	calls := 0
+	require.NoError(t, engine.AddFact("rate", FactFunc(func(params map[string]interface{}, a *Almanac) (interface{}, error) {
+		calls++
+		return rate, nil
+	})))
+	require.NoError(t, engine.AddRule(NewRule(Condition{Fact: "rate", Operator: "lessThan", Value: 0.05}, Event{Type: "cheap"}, WithName("cheap"))))
+	_, err := engine.Run(nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+
+	// The rate has since changed, but the replay sees the logged value.
+	rate = 0.09
+	replayed, err := engine.Replay(sink.Log().Decisions[0], nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.True(t, replayed.Diff.Empty())
+}
+
+func TestReplay_HasNoSideEffects(t *testing.T) {
+	sink := NewMemoryDecisionSink()
+	var handled, succeeded int
+	engine := NewEngine(WithDecisionLog(NewDecisionLogger(sink)))
+	engine.On("big", func(Event, *Almanac, *RuleResult) error {
+		handled++
+		return nil
+	})
+	require.NoError(t, engine.AddRule(NewRule(Condition{Fact: "n", Operator: "greaterThan", Value: 10}, Event{Type: "big"}, WithName("big"),
+		WithOnSuccess(func(Event, *Almanac, *RuleResult) error {
+			succeeded++
+			return nil
+		}))))
+	_, err := engine.Run(map[string]interface{}{"n": 11})
+	require.NoError(t, err)
+	require.Equal(t, 1, handled)
+	require.Equal(t, 1, succeeded)
+
+	replayed, err := engine.Replay(sink.Log().Decisions[0], nil)
+	require.NoError(t, err)
+	assert.Equal(t, []Event{{Type: "big"}}, replayed.Result.Events)
+	assert.Equal(t, 1, handled)
+	assert.Equal(t, 1, succeeded)
+}
+
+func TestReplay_RunsAtDecisionTime(t *testing.T) {
+	sink := NewMemoryDecisionSink()
+	now := decisionTime // a Monday
+	engine := NewEngine(WithClock(func() time.Time { return now }), WithDecisionLog(NewDecisionLogger(sink)))
+	require.NoError(t, engine.AddRule(NewRule(Condition{Fact: "n", Operator: "greaterThan", Value: 0}, Event{Type: "weekday"},
+		WithName("weekday"), WithSchedule(Schedule{Days: "mon-fri"}))))
+	_, err := engine.Run(map[string]interface{}{"n": 1})
+	require.NoError(t, err)
+
+	now = decisionTime.AddDate(0, 0, 5) // Saturday
+	replayed, err := engine.Replay(sink.Log().Decisions[0], nil)
+	require.NoError(t, err)
+	assert.True(t, replayed.Diff.Empty())
+	assert.Equal(t, RuleStatusPassed, replayed.Result.RuleResults[0].Status)
+}
+
+func TestReplay_FromFileSink(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := OpenFileDecisionSink(dir)
+	require.NoError(t, err)
+	engine := loanEngine(t, sink)
+	_, err = engine.Run(map[string]interface{}{"applicant": map[string]interface{}{"income": 65000, "name": "Bo"}})
+	require.NoError(t, err)
+	require.NoError(t, sink.Close())
+
+	log, err := ReadDecisionLog(dir)
+	require.NoError(t, err)
+	require.Len(t, log.Decisions, 1)
+	d := log.Decisions[0]
+	snapshot, ok := log.Snapshot(d)
+	require.True(t, ok)
+
+	replayed, err := engine.Replay(d, snapshot)
+	require.NoError(t, err)
+	assert.True(t, replayed.Diff.Empty(), "%+v", replayed.Diff)
+	replayed, err = engine.Replay(d, nil)
+	require.NoError(t, err)
+	assert.True(t, replayed.Diff.Empty(), "%+v", replayed.Diff)
+}
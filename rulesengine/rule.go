@@ -4,22 +4,32 @@ package rulesengine
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
 // Rule represents a single rule with its conditions, event, priority, and callbacks.
 type Rule struct {
-	Conditions Condition                                                         `json:"conditions" bson:"conditions" xml:"conditions" yaml:"conditions"`
-	Event      Event                                                             `json:"event" bson:"event" xml:"event" yaml:"event"`
-	Priority   int                                                               `json:"priority" bson:"priority" xml:"priority" yaml:"priority"`
-	Name       string                                                            `json:"name" bson:"name" xml:"name" yaml:"name"`
-	OnSuccess  func(event Event, almanac *Almanac, ruleResult *RuleResult) error  `json:"-" bson:"-" xml:"-" yaml:"-"`
-	OnFailure  func(event Event, almanac *Almanac, ruleResult *RuleResult) error  `json:"-" bson:"-" xml:"-" yaml:"-"`
+	Conditions     Condition                                                         `json:"conditions" bson:"conditions" xml:"conditions" yaml:"conditions"`
+	Event          Event                                                             `json:"event" bson:"event" xml:"event" yaml:"event"`
+	Priority       int                                                               `json:"priority" bson:"priority" xml:"priority" yaml:"priority"`
+	Name           string                                                            `json:"name" bson:"name" xml:"name" yaml:"name"`
+	Group          string                                                            `json:"group,omitempty" bson:"group,omitempty" xml:"group,omitempty" yaml:"group,omitempty"`
+	Disabled       bool                                                              `json:"disabled,omitempty" bson:"disabled,omitempty" xml:"disabled,omitempty" yaml:"disabled,omitempty"`
+	EffectiveFrom  *time.Time                                                        `json:"effectiveFrom,omitempty" bson:"effectiveFrom,omitempty" xml:"effectiveFrom,omitempty" yaml:"effectiveFrom,omitempty"`
+	EffectiveUntil *time.Time                                                        `json:"effectiveUntil,omitempty" bson:"effectiveUntil,omitempty" xml:"effectiveUntil,omitempty" yaml:"effectiveUntil,omitempty"`
+	Schedule       *Schedule                                                         `json:"schedule,omitempty" bson:"schedule,omitempty" xml:"schedule,omitempty" yaml:"schedule,omitempty"`
+	Salience       *Salience                                                         `json:"salience,omitempty" bson:"salience,omitempty" xml:"salience,omitempty" yaml:"salience,omitempty"`
+	SalienceFunc   SalienceFunc                                                      `json:"-" bson:"-" xml:"-" yaml:"-"`
+	Actions        []ActionSpec                                                      `json:"actions,omitempty" bson:"actions,omitempty" xml:"actions,omitempty" yaml:"actions,omitempty"`
+	OnSuccess      func(event Event, almanac *Almanac, ruleResult *RuleResult) error `json:"-" bson:"-" xml:"-" yaml:"-"`
+	OnFailure      func(event Event, almanac *Almanac, ruleResult *RuleResult) error `json:"-" bson:"-" xml:"-" yaml:"-"`
 }
 
 // RuleResult holds metadata about a rule evaluation.
 type RuleResult struct {
 	Name    string     `json:"name" bson:"name" xml:"name" yaml:"name"`
 	Success bool       `json:"success" bson:"success" xml:"success" yaml:"success"`
+	Status  string     `json:"status,omitempty" bson:"status,omitempty" xml:"status,omitempty" yaml:"status,omitempty"`
 	Trace   *TraceNode `json:"trace,omitempty" bson:"trace,omitempty" xml:"trace,omitempty" yaml:"trace,omitempty"`
 }
 
@@ -53,6 +63,20 @@ func WithName(name string) RuleOption {
 	}
 }
 
+// WithGroup puts the rule in a rule group.
+func WithGroup(group string) RuleOption {
+	return func(r *Rule) {
+		r.Group = group
+	}
+}
+
+// WithDisabled adds the rule disabled; see Engine.EnableRule.
+func WithDisabled() RuleOption {
+	return func(r *Rule) {
+		r.Disabled = true
+	}
+}
+
 // WithOnSuccess registers a callback for when the rule succeeds.
 func WithOnSuccess(callback func(event Event, almanac *Almanac, rr *RuleResult) error) RuleOption {
 	return func(r *Rule) {
@@ -76,10 +100,18 @@ func (r *Rule) Evaluate(almanac *Almanac, engine *Engine) (bool, *RuleResult, er
 	ruleResult := &RuleResult{
 		Name:    r.Name,
 		Success: result,
+		Status:  ruleStatus(result),
 	}
 	return result, ruleResult, nil
 }
 
+func ruleStatus(passed bool) string {
+	if passed {
+		return RuleStatusPassed
+	}
+	return RuleStatusFailed
+}
+
 // Event represents an event triggered by a rule.
 type Event struct {
 	Type   string                 `json:"type" bson:"type" xml:"type" yaml:"type"`
@@ -97,6 +129,7 @@ type Condition struct {
 	Params       map[string]interface{} `json:"params,omitempty" bson:"params,omitempty" xml:"params,omitempty" yaml:"params,omitempty"`
 	Path         string                 `json:"path,omitempty" bson:"path,omitempty" xml:"path,omitempty" yaml:"path,omitempty"`
 	ConditionRef string                 `json:"condition,omitempty" bson:"condition,omitempty" xml:"condition,omitempty" yaml:"condition,omitempty"`
+	Aggregate    *Aggregate             `json:"aggregate,omitempty" bson:"aggregate,omitempty" xml:"aggregate,omitempty" yaml:"aggregate,omitempty"`
 }
 
 // Evaluate evaluates the condition recursively.
@@ -144,6 +177,10 @@ func (c *Condition) Evaluate(almanac *Almanac, engine *Engine) (bool, error) {
 		}
 		return !res, nil
 	}
+	if c.Aggregate != nil {
+		res, _, _, err := c.Aggregate.evaluate(almanac, engine, false)
+		return res, err
+	}
 	// Basic condition: evaluate fact using operator.
 	if c.Fact != "" && c.Operator != "" {
 		factValue, err := almanac.FactValue(c.Fact, c.Params, c.Path)
@@ -194,5 +231,26 @@ func (rule *Rule) ToJSON() map[string]interface{} {
 		"priority":   rule.Priority,
 		"name":       rule.Name,
 	}
+	if rule.Group != "" {
+		json["group"] = rule.Group
+	}
+	if rule.Disabled {
+		json["disabled"] = true
+	}
+	if rule.EffectiveFrom != nil {
+		json["effectiveFrom"] = rule.EffectiveFrom
+	}
+	if rule.EffectiveUntil != nil {
+		json["effectiveUntil"] = rule.EffectiveUntil
+	}
+	if rule.Schedule != nil {
+		json["schedule"] = rule.Schedule
+	}
+	if rule.Salience != nil {
+		json["salience"] = rule.Salience
+	}
+	if len(rule.Actions) > 0 {
+		json["actions"] = rule.Actions
+	}
 	return json
 }
@@ -0,0 +1,145 @@
+package rulesengine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Salience computes a rule's evaluation rank from a fact at the start of each
+// run. When Values is empty the fact value itself is used if it is numeric;
+// otherwise the value, formatted with fmt.Sprint, is looked up in Values.
+// Default applies when the fact is missing, not numeric or not listed.
+type Salience struct {
+	Fact    string                 `json:"fact" bson:"fact" xml:"fact" yaml:"fact"`
+	Path    string                 `json:"path,omitempty" bson:"path,omitempty" xml:"path,omitempty" yaml:"path,omitempty"`
+	Params  map[string]interface{} `json:"params,omitempty" bson:"params,omitempty" xml:"params,omitempty" yaml:"params,omitempty"`
+	Values  map[string]int         `json:"values,omitempty" bson:"values,omitempty" xml:"values,omitempty" yaml:"values,omitempty"`
+	Default int                    `json:"default,omitempty" bson:"default,omitempty" xml:"default,omitempty" yaml:"default,omitempty"`
+}
+
+// SalienceFunc computes a rule's evaluation rank from the run's almanac.
+type SalienceFunc func(almanac *Almanac) (int, error)
+
+// RuleOrder is a rule's position in a run ordered by dynamic salience.
+type RuleOrder struct {
+	Name     string `json:"name" bson:"name" xml:"name" yaml:"name"`
+	Salience int    `json:"salience" bson:"salience" xml:"salience" yaml:"salience"`
+}
+
+// WithSalience ranks the rule by a fact instead of its static Priority.
+func WithSalience(s Salience) RuleOption {
+	return func(r *Rule) {
+		r.Salience = &s
+	}
+}
+
+// WithSalienceFunc ranks the rule by a function of the almanac instead of
+// its static Priority. It takes precedence over Salience and is not
+// serialized.
+func WithSalienceFunc(fn SalienceFunc) RuleOption {
+	return func(r *Rule) {
+		r.SalienceFunc = fn
+	}
+}
+
+func (r *Rule) hasDynamicSalience() bool {
+	return r.SalienceFunc != nil || r.Salience != nil
+}
+
+func (r *Rule) salience(almanac *Almanac) (int, error) {
+	if r.SalienceFunc != nil {
+		return r.SalienceFunc(almanac)
+	}
+	if r.Salience == nil {
+		return r.Priority, nil
+	}
+	s := r.Salience
+	// A fact that is neither a runtime fact nor defined on the engine is
+	// missing, not an error.
+	if _, ok := almanac.runtimeFacts[s.Fact]; !ok {
+		if _, ok := almanac.engine.facts[s.Fact]; !ok {
+			return s.Default, nil
+		}
+	}
+	value, err := almanac.FactValue(s.Fact, s.Params, s.Path)
+	if err != nil {
+		return 0, err
+	}
+	if value == nil {
+		return s.Default, nil
+	}
+	if len(s.Values) == 0 {
+		if f, ok := toFloat64(value); ok {
+			return int(f), nil
+		}
+		return s.Default, nil
+	}
+	if n, ok := s.Values[fmt.Sprint(value)]; ok {
+		return n, nil
+	}
+	return s.Default, nil
+}
+
+func validateSalience(r *Rule) []ValidationError {
+	if r.Salience != nil && r.Salience.Fact == "" {
+		return []ValidationError{{Path: "salience", Message: "salience requires a fact"}}
+	}
+	return nil
+}
+
+// evaluationOrder returns the indices of e.rules in the order to evaluate
+// them. When no rule has dynamic salience it returns nil, and rules run in
+// their static Priority order. Otherwise every rule is ranked by its
+// salience, or its Priority if it has none. Rules with static priority keep
+// their order in e.rules among themselves; ties involving a rule with
+// dynamic salience are broken by name.
+func (e *Engine) evaluationOrder(almanac *Almanac) ([]int, []RuleOrder, error) {
+	dynamic := false
+	for _, rule := range e.rules {
+		if rule.hasDynamicSalience() {
+			dynamic = true
+			break
+		}
+	}
+	if !dynamic {
+		return nil, nil, nil
+	}
+
+	// e.rules is sorted by Priority, so the static rules are already in
+	// order. The dynamic ones are sorted and merged into them.
+	scores := make([]int, len(e.rules))
+	var static, ranked []int
+	for i, rule := range e.rules {
+		score, err := rule.salience(almanac)
+		if err != nil {
+			return nil, nil, fmt.Errorf("salience of rule %q: %w", rule.Name, err)
+		}
+		scores[i] = score
+		if rule.hasDynamicSalience() {
+			ranked = append(ranked, i)
+		} else {
+			static = append(static, i)
+		}
+	}
+	before := func(a, b int) bool {
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		return e.rules[a].Name < e.rules[b].Name
+	}
+	sort.SliceStable(ranked, func(a, b int) bool { return before(ranked[a], ranked[b]) })
+	order := make([]int, 0, len(e.rules))
+	for len(static) > 0 && len(ranked) > 0 {
+		if before(ranked[0], static[0]) {
+			order, ranked = append(order, ranked[0]), ranked[1:]
+		} else {
+			order, static = append(order, static[0]), static[1:]
+		}
+	}
+	order = append(append(order, static...), ranked...)
+	ruleOrder := make([]RuleOrder, len(order))
+	for n, i := range order {
+		ruleOrder[n] = RuleOrder{Name: e.rules[i].Name, Salience: scores[i]}
+	}
+	return order, ruleOrder, nil
+}
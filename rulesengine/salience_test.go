@@ -0,0 +1,136 @@
+package rulesengine
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func salienceEngine(t *testing.T, options ...EngineOption) *Engine {
+	t.Helper()
+	engine := NewEngine(options...)
+	always := Condition{Fact: "region", Operator: "notIn", Value: []interface{}{}}
+	require.NoError(t, engine.AddRule(NewRule(always, Event{Type: "eu"}, WithName("eu"),
+		WithSalience(Salience{Fact: "region", Values: map[string]int{"EU": 100}, Default: 1}))))
+	require.NoError(t, engine.AddRule(NewRule(always, Event{Type: "us"}, WithName("us"),
+		WithSalience(Salience{Fact: "region", Values: map[string]int{"US": 100}, Default: 1}))))
+	require.NoError(t, engine.AddRule(NewRule(always, Event{Type: "tier"}, WithName("tier"),
+		WithSalience(Salience{Fact: "customer", Path: ".tier"}))))
+	require.NoError(t, engine.AddRule(NewRule(always, Event{Type: "static"}, WithName("static"),
+		WithPriorityForRule(50))))
+	return engine
+}
+
+func TestRun_DynamicSalience(t *testing.T) {
+	engine := salienceEngine(t)
+
+	result, err := engine.Run(map[string]interface{}{"region": "US", "customer": map[string]interface{}{"tier": 70}})
+	require.NoError(t, err)
+	assert.Equal(t, []RuleOrder{
+		{Name: "us", Salience: 100},
+		{Name: "tier", Salience: 70},
+		{Name: "static", Salience: 50},
+		{Name: "eu", Salience: 1},
+	}, result.Order)
+	assert.Equal(t, []string{"us", "tier", "static", "eu"}, eventTypes(result))
+
+	result, err = engine.Run(map[string]interface{}{"region": "FR", "customer": map[string]interface{}{}})
+	require.NoError(t, err)
+	// Ties with dynamic rules are broken by name.
+	assert.Equal(t, []string{"static", "eu", "us", "tier"}, eventTypes(result))
+}
+
+func TestRun_SalienceMissingFactUsesDefault(t *testing.T) {
+	engine := NewEngine()
+	cond := Condition{Fact: "amount", Operator: "gte", Value: 0}
+	require.NoError(t, engine.AddRule(NewRule(cond, Event{Type: "a"}, WithName("a"),
+		WithSalience(Salience{Fact: "tier", Values: map[string]int{"gold": 100}, Default: 5}))))
+	require.NoError(t, engine.AddRule(NewRule(cond, Event{Type: "b"}, WithName("b"), WithPriorityForRule(10))))
+
+	result, err := engine.Run(map[string]interface{}{"amount": 1})
+	require.NoError(t, err)
+	assert.Equal(t, []RuleOrder{{Name: "b", Salience: 10}, {Name: "a", Salience: 5}}, result.Order)
+
+	result, err = engine.Run(map[string]interface{}{"amount": 1, "tier": "gold"})
+	require.NoError(t, err)
+	assert.Equal(t, []RuleOrder{{Name: "a", Salience: 100}, {Name: "b", Salience: 10}}, result.Order)
+}
+
+func TestRun_DynamicSalienceKeepsStaticOrder(t *testing.T) {
+	engine := NewEngine()
+	always := Condition{Fact: "n", Operator: "gte", Value: 0}
+	for _, name := range []string{"zeta", "alpha", "mid"} {
+		require.NoError(t, engine.AddRule(NewRule(always, Event{Type: name}, WithName(name), WithPriorityForRule(5))))
+	}
+	require.NoError(t, engine.AddRule(NewRule(always, Event{Type: "beta"}, WithName("beta"),
+		WithSalience(Salience{Fact: "n"}))))
+
+	// Static rules tied on priority keep the order they were added in; the
+	// dynamic rule is placed among them by name.
+	result, err := engine.Run(map[string]interface{}{"n": 5})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"beta", "zeta", "alpha", "mid"}, eventTypes(result))
+
+	result, err = engine.Run(map[string]interface{}{"n": 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"zeta", "alpha", "mid", "beta"}, eventTypes(result))
+}
+
+func TestRun_SalienceFunc(t *testing.T) {
+	engine := NewEngine(WithConflictResolver(PriorityWins()))
+	cond := Condition{Fact: "n", Operator: "gte", Value: 0}
+	require.NoError(t, engine.AddRule(NewRule(cond, Event{Type: "low"}, WithName("low"), WithPriorityForRule(10))))
+	require.NoError(t, engine.AddRule(NewRule(cond, Event{Type: "boost"}, WithName("boost"),
+		WithSalienceFunc(func(a *Almanac) (int, error) {
+			n, err := a.FactValue("n", nil, "")
+			if err != nil {
+				return 0, err
+			}
+			f, _ := toFloat64(n)
+			return int(f) * 10, nil
+		}))))
+
+	result, err := engine.Run(map[string]interface{}{"n": 5})
+	require.NoError(t, err)
+	assert.Equal(t, "boost", result.Order[0].Name)
+	assert.Equal(t, "boost", result.Decision.Rule)
+
+	result, err = engine.Run(map[string]interface{}{"n": 0})
+	require.NoError(t, err)
+	assert.Equal(t, "low", result.Decision.Rule)
+
+	failing := NewEngine()
+	require.NoError(t, failing.AddRule(NewRule(cond, Event{Type: "x"}, WithName("x"),
+		WithSalienceFunc(func(*Almanac) (int, error) { return 0, errors.New("boom") }))))
+	_, err = failing.Run(map[string]interface{}{"n": 1})
+	assert.EqualError(t, err, `salience of rule "x": boom`)
+}
+
+func TestRun_StaticPriorityHasNoOrder(t *testing.T) {
+	engine := NewEngine()
+	require.NoError(t, engine.AddRule(NewRule(Condition{Fact: "n", Operator: "eq", Value: 1}, Event{Type: "a"})))
+	result, err := engine.Run(map[string]interface{}{"n": 1})
+	require.NoError(t, err)
+	assert.Nil(t, result.Order)
+}
+
+func TestSalience_Serialization(t *testing.T) {
+	rules, err := LoadRulesFromJSON([]byte(`[{
+		"name": "r",
+		"conditions": {"fact": "x", "operator": "eq", "value": 1},
+		"event": {"type": "e"},
+		"salience": {"fact": "region", "values": {"EU": 5}, "default": 2}
+	}]`))
+	require.NoError(t, err)
+	require.NotNil(t, rules[0].Salience)
+	assert.Equal(t, Salience{Fact: "region", Values: map[string]int{"EU": 5}, Default: 2}, *rules[0].Salience)
+	data, err := json.Marshal(rules[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"salience":{"fact":"region","values":{"EU":5},"default":2}`)
+
+	err = NewEngine().AddRule(NewRule(Condition{Fact: "x", Operator: "eq", Value: 1}, Event{Type: "e"}, WithSalience(Salience{})))
+	assert.ErrorContains(t, err, "salience requires a fact")
+}
@@ -0,0 +1,186 @@
+package rulesengine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule statuses reported in RuleResult.Status.
+const (
+	RuleStatusPassed   = "passed"
+	RuleStatusFailed   = "failed"
+	RuleStatusInactive = "inactive"
+	RuleStatusDisabled = "disabled"
+)
+
+// Schedule restricts a rule to recurring days and hours, in the style of
+// cron fields. Days is a comma-separated list of day names (sun..sat) or
+// numbers (0..6, Sunday first) and ranges such as "mon-fri" or "fri-mon".
+// Hours lists hours of the day (0..23) and inclusive ranges such as "9-17"
+// or "22-6". Empty fields and "*" match everything. Times are converted to
+// Timezone, an IANA zone name defaulting to UTC, before matching.
+type Schedule struct {
+	Days     string `json:"days,omitempty" bson:"days,omitempty" xml:"days,omitempty" yaml:"days,omitempty"`
+	Hours    string `json:"hours,omitempty" bson:"hours,omitempty" xml:"hours,omitempty" yaml:"hours,omitempty"`
+	Timezone string `json:"timezone,omitempty" bson:"timezone,omitempty" xml:"timezone,omitempty" yaml:"timezone,omitempty"`
+
+	// parsed caches the fields once the rule is added to an engine.
+	parsed *parsedSchedule
+}
+
+// parsedSchedule is a Schedule with its fields parsed.
+type parsedSchedule struct {
+	days     []bool
+	hours    []bool
+	location *time.Location
+}
+
+var dayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// parseField expands a cron-like field into the set of values it matches.
+// Ranges whose end is before their start wrap around past max.
+func parseField(field string, max int, names map[string]int) ([]bool, error) {
+	set := make([]bool, max+1)
+	field = strings.TrimSpace(field)
+	if field == "" || field == "*" {
+		for i := range set {
+			set[i] = true
+		}
+		return set, nil
+	}
+	value := func(s string) (int, error) {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if n, ok := names[s]; ok {
+			return n, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > max {
+			return 0, fmt.Errorf("invalid value %q", s)
+		}
+		return n, nil
+	}
+	for _, part := range strings.Split(field, ",") {
+		lo, hi, isRange := strings.Cut(part, "-")
+		from, err := value(lo)
+		if err != nil {
+			return nil, err
+		}
+		to := from
+		if isRange {
+			if to, err = value(hi); err != nil {
+				return nil, err
+			}
+		}
+		for n := from; ; n = (n + 1) % (max + 1) {
+			set[n] = true
+			if n == to {
+				break
+			}
+		}
+	}
+	return set, nil
+}
+
+func (s *Schedule) parse() (*parsedSchedule, error) {
+	days, err := parseField(s.Days, 6, dayNames)
+	if err != nil {
+		return nil, fmt.Errorf("days: %w", err)
+	}
+	hours, err := parseField(s.Hours, 23, nil)
+	if err != nil {
+		return nil, fmt.Errorf("hours: %w", err)
+	}
+	loc := time.UTC
+	if s.Timezone != "" {
+		if loc, err = time.LoadLocation(s.Timezone); err != nil {
+			return nil, fmt.Errorf("timezone: %w", err)
+		}
+	}
+	return &parsedSchedule{days: days, hours: hours, location: loc}, nil
+}
+
+// Validate reports a malformed days, hours or timezone field.
+func (s *Schedule) Validate() error {
+	_, err := s.parse()
+	return err
+}
+
+// Active reports whether t falls within the schedule. Schedules of rules
+// added to an engine are parsed once when added; others are parsed on every
+// call.
+func (s *Schedule) Active(t time.Time) (bool, error) {
+	p := s.parsed
+	if p == nil {
+		var err error
+		if p, err = s.parse(); err != nil {
+			return false, err
+		}
+	}
+	t = t.In(p.location)
+	return p.days[int(t.Weekday())] && p.hours[t.Hour()], nil
+}
+
+// ActiveAt reports whether the rule applies at t: on or after EffectiveFrom,
+// before EffectiveUntil and within its Schedule.
+func (r *Rule) ActiveAt(t time.Time) (bool, error) {
+	if r.EffectiveFrom != nil && t.Before(*r.EffectiveFrom) {
+		return false, nil
+	}
+	if r.EffectiveUntil != nil && !t.Before(*r.EffectiveUntil) {
+		return false, nil
+	}
+	if r.Schedule == nil {
+		return true, nil
+	}
+	return r.Schedule.Active(t)
+}
+
+// validateActivation checks the rule's effective dates and schedule.
+func validateActivation(r *Rule) []ValidationError {
+	var errs []ValidationError
+	if r.EffectiveFrom != nil && r.EffectiveUntil != nil && !r.EffectiveFrom.Before(*r.EffectiveUntil) {
+		errs = append(errs, ValidationError{
+			Path:    "effectiveUntil",
+			Message: "effectiveUntil must be after effectiveFrom",
+		})
+	}
+	if r.Schedule != nil {
+		if err := r.Schedule.Validate(); err != nil {
+			errs = append(errs, ValidationError{Path: "schedule", Message: err.Error()})
+		}
+	}
+	return errs
+}
+
+// WithEffectiveFrom makes the rule inactive before t.
+func WithEffectiveFrom(t time.Time) RuleOption {
+	return func(r *Rule) {
+		r.EffectiveFrom = &t
+	}
+}
+
+// WithEffectiveUntil makes the rule inactive from t on.
+func WithEffectiveUntil(t time.Time) RuleOption {
+	return func(r *Rule) {
+		r.EffectiveUntil = &t
+	}
+}
+
+// WithSchedule restricts the rule to a recurring schedule.
+func WithSchedule(s Schedule) RuleOption {
+	return func(r *Rule) {
+		r.Schedule = &s
+	}
+}
+
+// WithClock sets the time source used to decide which rules are active.
+// It defaults to time.Now.
+func WithClock(now func() time.Time) EngineOption {
+	return func(e *Engine) {
+		e.clock = now
+	}
+}
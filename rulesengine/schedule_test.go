@@ -0,0 +1,146 @@
+package rulesengine
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSchedule_Active(t *testing.T) {
+	// 2024-03-04 is a Monday.
+	monday10 := time.Date(2024, 3, 4, 10, 30, 0, 0, time.UTC)
+	saturday23 := time.Date(2024, 3, 9, 23, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		at       time.Time
+		want     bool
+	}{
+		{"empty matches everything", Schedule{}, saturday23, true},
+		{"weekday range", Schedule{Days: "mon-fri"}, monday10, true},
+		{"weekday range excludes weekend", Schedule{Days: "mon-fri"}, saturday23, false},
+		{"wrapping day range", Schedule{Days: "fri-mon"}, saturday23, true},
+		{"numeric days", Schedule{Days: "0,6"}, saturday23, true},
+		{"hour range", Schedule{Hours: "9-17"}, monday10, true},
+		{"hour range end is inclusive", Schedule{Hours: "9-17"}, monday10.Add(7 * time.Hour), true},
+		{"wrapping hour range", Schedule{Hours: "22-6"}, monday10, false},
+		{"timezone shifts hour", Schedule{Hours: "5", Timezone: "America/New_York"}, monday10, true},
+		{"timezone shifts day", Schedule{Days: "sat", Timezone: "Asia/Tokyo"}, saturday23, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.schedule.Active(tt.at)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	assert.Error(t, (&Schedule{Days: "funday"}).Validate())
+	assert.Error(t, (&Schedule{Hours: "9-24"}).Validate())
+	assert.Error(t, (&Schedule{Timezone: "Mars/Olympus"}).Validate())
+}
+
+func TestRun_InactiveRules(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	engine := NewEngine(WithClock(func() time.Time { return now }))
+	cond := Condition{Fact: "x", Operator: "eq", Value: 1}
+	require.NoError(t, engine.AddRule(NewRule(cond, Event{Type: "future"},
+		WithName("future"), WithEffectiveFrom(now.Add(time.Hour)))))
+	require.NoError(t, engine.AddRule(NewRule(cond, Event{Type: "expired"},
+		WithName("expired"), WithEffectiveUntil(now))))
+	require.NoError(t, engine.AddRule(NewRule(cond, Event{Type: "window"},
+		WithName("window"), WithEffectiveFrom(now.Add(-time.Hour)), WithEffectiveUntil(now.Add(time.Hour)))))
+	require.NoError(t, engine.AddRule(NewRule(cond, Event{Type: "weekdays"},
+		WithName("weekdays"), WithSchedule(Schedule{Days: "mon-fri"}))))
+
+	result, err := engine.Run(map[string]interface{}{"x": 2})
+	require.NoError(t, err)
+	statuses := map[string]string{}
+	for _, rr := range result.RuleResults {
+		statuses[rr.Name] = rr.Status
+	}
+	assert.Equal(t, map[string]string{
+		"future":   RuleStatusInactive,
+		"expired":  RuleStatusInactive,
+		"window":   RuleStatusFailed,
+		"weekdays": RuleStatusInactive,
+	}, statuses)
+	require.Len(t, result.FailureRuleResults, 1)
+	assert.Equal(t, "window", result.FailureRuleResults[0].Name)
+
+	now = now.Add(2 * 24 * time.Hour)
+	result, err = engine.Run(map[string]interface{}{"x": 1})
+	require.NoError(t, err)
+	assert.Equal(t, []Event{{Type: "future"}, {Type: "weekdays"}}, result.Events)
+
+	err = engine.AddRule(NewRule(cond, Event{Type: "bad"}, WithSchedule(Schedule{Hours: "25"})))
+	assert.ErrorContains(t, err, "invalid rule activation")
+	err = engine.AddRule(NewRule(cond, Event{Type: "bad"}, WithEffectiveFrom(now), WithEffectiveUntil(now)))
+	assert.ErrorContains(t, err, "effectiveUntil must be after effectiveFrom")
+}
+
+func TestAddRule_ParsesScheduleOnce(t *testing.T) {
+	monday10 := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	engine := NewEngine(WithClock(func() time.Time { return monday10 }))
+	rule := NewRule(Condition{Fact: "x", Operator: "eq", Value: 1}, Event{Type: "e"},
+		WithName("office"), WithSchedule(Schedule{Days: "mon-fri", Hours: "9-17", Timezone: "Europe/Paris"}))
+	require.NoError(t, engine.AddRule(rule))
+	require.NotNil(t, rule.Schedule.parsed)
+	assert.Equal(t, "Europe/Paris", rule.Schedule.parsed.location.String())
+
+	result, err := engine.Run(map[string]interface{}{"x": 1})
+	require.NoError(t, err)
+	assert.Equal(t, []Event{{Type: "e"}}, result.Events)
+
+	// Copies drop the parsed fields, so edits to them take effect.
+	copied, ok := engine.GetRule("office")
+	require.True(t, ok)
+	assert.Nil(t, copied.Schedule.parsed)
+	copied.Schedule.Days = "sat,sun"
+	active, err := copied.ActiveAt(monday10)
+	require.NoError(t, err)
+	assert.False(t, active)
+
+	// A schedule shared by the caller is left untouched.
+	shared := &Schedule{Days: "mon-fri"}
+	other := NewRule(Condition{Fact: "x", Operator: "eq", Value: 1}, Event{Type: "e"}, WithName("other"))
+	other.Schedule = shared
+	require.NoError(t, engine.AddRule(other))
+	assert.Nil(t, shared.parsed)
+	assert.NotSame(t, shared, other.Schedule)
+}
+
+func TestRule_ActivationSerialization(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	rule := NewRule(Condition{Fact: "x", Operator: "eq", Value: 1.0}, Event{Type: "e"},
+		WithName("promo"), WithEffectiveFrom(from), WithEffectiveUntil(until),
+		WithSchedule(Schedule{Days: "sat,sun", Hours: "9-17", Timezone: "Europe/Paris"}))
+
+	data, err := json.Marshal(rule)
+	require.NoError(t, err)
+	var fromJSON Rule
+	require.NoError(t, json.Unmarshal(data, &fromJSON))
+	assert.True(t, from.Equal(*fromJSON.EffectiveFrom))
+	assert.Equal(t, rule.Schedule, fromJSON.Schedule)
+
+	data, err = yaml.Marshal(rule)
+	require.NoError(t, err)
+	var fromYAML Rule
+	require.NoError(t, yaml.Unmarshal(data, &fromYAML))
+	assert.True(t, until.Equal(*fromYAML.EffectiveUntil))
+	assert.Equal(t, rule.Schedule, fromYAML.Schedule)
+
+	data, err = MarshalRulesBSON([]*Rule{rule})
+	require.NoError(t, err)
+	rules, err := LoadRulesFromBSON(data)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.True(t, from.Equal(*rules[0].EffectiveFrom))
+	assert.Equal(t, rule.Schedule, rules[0].Schedule)
+}
@@ -0,0 +1,461 @@
+package rulesengine
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// WindowKind selects how a window moves over time.
+type WindowKind string
+
+const (
+	// WindowSliding covers the Size before the current time.
+	WindowSliding WindowKind = "sliding"
+	// WindowTumbling covers the current interval of Size, aligned to
+	// multiples of Size since the zero time, and resets when it ends.
+	WindowTumbling WindowKind = "tumbling"
+)
+
+// Window aggregate functions.
+const (
+	WindowCount    = "count"
+	WindowSum      = "sum"
+	WindowDistinct = "distinct"
+)
+
+// Window is an aggregate kept per session and exposed to rules as the fact
+// Name. Every run whose runtime facts contain Fact, with a non-nil value at
+// Path, adds one observation. count is the number of observations in the
+// window, sum adds their numeric values and distinct counts their distinct
+// values.
+type Window struct {
+	Name string        `json:"name" bson:"name" xml:"name" yaml:"name"`
+	Kind WindowKind    `json:"kind" bson:"kind" xml:"kind" yaml:"kind"`
+	Size time.Duration `json:"size" bson:"size" xml:"size" yaml:"size"`
+	Func string        `json:"func" bson:"func" xml:"func" yaml:"func"`
+	Fact string        `json:"fact" bson:"fact" xml:"fact" yaml:"fact"`
+	Path string        `json:"path,omitempty" bson:"path,omitempty" xml:"path,omitempty" yaml:"path,omitempty"`
+}
+
+func (w *Window) validate() error {
+	if w.Name == "" {
+		return fmt.Errorf("window requires a name")
+	}
+	if w.Fact == "" {
+		return fmt.Errorf("window %s requires a fact", w.Name)
+	}
+	if w.Fact == w.Name {
+		return fmt.Errorf("window %s cannot feed from itself", w.Name)
+	}
+	if w.Size <= 0 {
+		return fmt.Errorf("window %s requires a positive size", w.Name)
+	}
+	switch w.Kind {
+	case WindowSliding, WindowTumbling:
+	default:
+		return fmt.Errorf("window %s has unknown kind %q", w.Name, w.Kind)
+	}
+	switch w.Func {
+	case WindowCount, WindowSum, WindowDistinct:
+	default:
+		return fmt.Errorf("window %s has unknown func %q", w.Name, w.Func)
+	}
+	return nil
+}
+
+// start returns the earliest observation time still inside the window.
+func (w *Window) start(now time.Time) time.Time {
+	if w.Kind == WindowTumbling {
+		return now.Truncate(w.Size)
+	}
+	return now.Add(-w.Size).Add(time.Nanosecond)
+}
+
+// Observation is one value fed into a window.
+type Observation struct {
+	At    time.Time   `json:"at" bson:"at" xml:"at" yaml:"at"`
+	Value interface{} `json:"value" bson:"value" xml:"value" yaml:"value"`
+}
+
+type session struct {
+	id       string
+	mu       sync.Mutex
+	lastSeen time.Time
+	windows  map[string][]Observation
+	elem     *list.Element
+}
+
+// SessionOption configures a SessionStore.
+type SessionOption func(*SessionStore)
+
+// WithSessionTTL expires sessions that have not run for ttl. Zero, the
+// default, keeps sessions until they are evicted or removed.
+func WithSessionTTL(ttl time.Duration) SessionOption {
+	return func(s *SessionStore) {
+		s.ttl = ttl
+	}
+}
+
+// WithMaxSessions bounds the number of sessions kept; the least recently
+// used session is evicted first. Zero means unbounded.
+func WithMaxSessions(n int) SessionOption {
+	return func(s *SessionStore) {
+		s.maxSessions = n
+	}
+}
+
+// WithMaxObservations bounds the observations kept per window of a session;
+// the oldest are dropped first, so aggregates then cover only the most
+// recent n. Zero means unbounded.
+func WithMaxObservations(n int) SessionOption {
+	return func(s *SessionStore) {
+		s.maxObservations = n
+	}
+}
+
+// WithSessionClock sets the time source for observations and expiry. It
+// defaults to the engine's clock.
+func WithSessionClock(now func() time.Time) SessionOption {
+	return func(s *SessionStore) {
+		s.clock = now
+	}
+}
+
+// SessionStore keeps windowed aggregates per entity across runs of an
+// engine. It is safe for concurrent use; runs for the same entity update
+// its windows one at a time.
+type SessionStore struct {
+	engine          *Engine
+	windows         []Window
+	ttl             time.Duration
+	maxSessions     int
+	maxObservations int
+	clock           func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	lru      *list.List // front is most recently used
+}
+
+// NewSessionStore creates a store that feeds windows from the runtime facts
+// of runs of engine.
+func NewSessionStore(engine *Engine, windows []Window, options ...SessionOption) (*SessionStore, error) {
+	seen := make(map[string]bool, len(windows))
+	for i := range windows {
+		if err := windows[i].validate(); err != nil {
+			return nil, err
+		}
+		if seen[windows[i].Name] {
+			return nil, fmt.Errorf("duplicate window %s", windows[i].Name)
+		}
+		seen[windows[i].Name] = true
+	}
+	s := &SessionStore{
+		engine:   engine,
+		windows:  append([]Window(nil), windows...),
+		clock:    engine.clock,
+		sessions: make(map[string]*session),
+		lru:      list.New(),
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Run feeds runtimeFacts into the windows of the entity's session and runs
+// the engine with the window values added as facts. The current run counts
+// towards its own windows. Window facts replace runtime facts of the same
+// name.
+func (s *SessionStore) Run(entityID string, runtimeFacts map[string]interface{}, options ...RunOption) (*RunResult, error) {
+	now := s.clock()
+	sess := s.session(entityID, now, true)
+
+	facts := make(map[string]interface{}, len(runtimeFacts)+len(s.windows))
+	for k, v := range runtimeFacts {
+		facts[k] = v
+	}
+	sess.mu.Lock()
+	for i := range s.windows {
+		w := &s.windows[i]
+		if value, ok := runtimeFacts[w.Fact]; ok {
+			if w.Path != "" {
+				value = s.engine.pathResolver(value, w.Path)
+			}
+			if value != nil {
+				s.observe(sess, w, Observation{At: now, Value: value})
+			}
+		}
+	}
+	values, err := s.values(sess, now)
+	sess.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	for name, v := range values {
+		facts[name] = v
+	}
+	return s.engine.Run(facts, options...)
+}
+
+// Facts returns the current window values of an entity without adding an
+// observation. An unknown entity has empty windows.
+func (s *SessionStore) Facts(entityID string) (map[string]interface{}, error) {
+	now := s.clock()
+	sess := s.session(entityID, now, false)
+	if sess == nil {
+		sess = &session{windows: map[string][]Observation{}}
+	}
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return s.values(sess, now)
+}
+
+// Remove deletes an entity's session.
+func (s *SessionStore) Remove(entityID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess, ok := s.sessions[entityID]; ok {
+		s.drop(sess)
+	}
+}
+
+// Len returns the number of sessions held.
+func (s *SessionStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// Expire removes the sessions idle for longer than the TTL and returns how
+// many were removed. Expired sessions are also dropped when next accessed.
+func (s *SessionStore) Expire() int {
+	if s.ttl <= 0 {
+		return 0
+	}
+	now := s.clock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	// The list is ordered by use, so expired sessions are at the back.
+	for e := s.lru.Back(); e != nil; e = s.lru.Back() {
+		sess := e.Value.(*session)
+		if !s.expired(sess, now) {
+			break
+		}
+		s.drop(sess)
+		removed++
+	}
+	return removed
+}
+
+func (s *SessionStore) expired(sess *session, now time.Time) bool {
+	return s.ttl > 0 && now.Sub(sess.lastSeen) > s.ttl
+}
+
+// session returns the entity's session, marking it used at now. Expired
+// sessions are replaced. With create unset it returns nil for a missing
+// session.
+func (s *SessionStore) session(entityID string, now time.Time, create bool) *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[entityID]
+	if ok && s.expired(sess, now) {
+		s.drop(sess)
+		ok = false
+	}
+	if !ok {
+		if !create {
+			return nil
+		}
+		sess = &session{id: entityID, windows: make(map[string][]Observation)}
+		s.insert(sess)
+	}
+	if create {
+		sess.lastSeen = now
+		s.lru.MoveToFront(sess.elem)
+	}
+	return sess
+}
+
+func (s *SessionStore) insert(sess *session) {
+	s.sessions[sess.id] = sess
+	sess.elem = s.lru.PushFront(sess)
+	for s.maxSessions > 0 && len(s.sessions) > s.maxSessions {
+		s.drop(s.lru.Back().Value.(*session))
+	}
+}
+
+func (s *SessionStore) drop(sess *session) {
+	delete(s.sessions, sess.id)
+	s.lru.Remove(sess.elem)
+}
+
+// observe appends an observation and drops those outside the window or over
+// the bound. Callers hold sess.mu.
+func (s *SessionStore) observe(sess *session, w *Window, o Observation) {
+	obs := append(prune(sess.windows[w.Name], w.start(o.At)), o)
+	if s.maxObservations > 0 && len(obs) > s.maxObservations {
+		obs = append([]Observation(nil), obs[len(obs)-s.maxObservations:]...)
+	}
+	sess.windows[w.Name] = obs
+}
+
+// prune drops observations before start. Observations are kept in time
+// order.
+func prune(obs []Observation, start time.Time) []Observation {
+	i := 0
+	for i < len(obs) && obs[i].At.Before(start) {
+		i++
+	}
+	return obs[i:]
+}
+
+// values computes every window of the session at now. Callers hold sess.mu.
+func (s *SessionStore) values(sess *session, now time.Time) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(s.windows))
+	for i := range s.windows {
+		w := &s.windows[i]
+		obs := prune(sess.windows[w.Name], w.start(now))
+		sess.windows[w.Name] = obs
+		switch w.Func {
+		case WindowCount:
+			values[w.Name] = float64(len(obs))
+		case WindowSum:
+			sum := 0.0
+			for _, o := range obs {
+				f, ok := toFloat64(o.Value)
+				if !ok {
+					return nil, fmt.Errorf("window %s: %v is not a number", w.Name, o.Value)
+				}
+				sum += f
+			}
+			values[w.Name] = sum
+		case WindowDistinct:
+			distinct := make(map[string]bool, len(obs))
+			for _, o := range obs {
+				distinct[canonicalJSON(o.Value)] = true
+			}
+			values[w.Name] = float64(len(distinct))
+		}
+	}
+	return values, nil
+}
+
+// SessionSnapshot is the serialized state of a SessionStore.
+type SessionSnapshot struct {
+	Sessions []SessionState `json:"sessions" bson:"sessions" xml:"sessions" yaml:"sessions"`
+}
+
+// SessionState is the serialized state of one session.
+type SessionState struct {
+	ID       string                   `json:"id" bson:"id" xml:"id" yaml:"id"`
+	LastSeen time.Time                `json:"lastSeen" bson:"lastSeen" xml:"lastSeen" yaml:"lastSeen"`
+	Windows  map[string][]Observation `json:"windows" bson:"windows" xml:"windows" yaml:"windows"`
+}
+
+// Snapshot returns a copy of every session, least recently used first.
+func (s *SessionStore) Snapshot() SessionSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := SessionSnapshot{Sessions: make([]SessionState, 0, len(s.sessions))}
+	for e := s.lru.Back(); e != nil; e = e.Prev() {
+		sess := e.Value.(*session)
+		sess.mu.Lock()
+		state := SessionState{ID: sess.id, LastSeen: sess.lastSeen, Windows: make(map[string][]Observation, len(sess.windows))}
+		for name, obs := range sess.windows {
+			if len(obs) > 0 {
+				state.Windows[name] = append([]Observation(nil), obs...)
+			}
+		}
+		sess.mu.Unlock()
+		snap.Sessions = append(snap.Sessions, state)
+	}
+	return snap
+}
+
+// Restore replaces the store's sessions with those of a snapshot. Windows
+// the store does not define are ignored, and the TTL and bounds apply as
+// sessions are loaded.
+func (s *SessionStore) Restore(snap SessionSnapshot) {
+	defined := make(map[string]bool, len(s.windows))
+	for _, w := range s.windows {
+		defined[w.Name] = true
+	}
+	now := s.clock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[string]*session, len(snap.Sessions))
+	s.lru.Init()
+	for _, state := range snap.Sessions {
+		sess := &session{id: state.ID, lastSeen: state.LastSeen, windows: make(map[string][]Observation)}
+		if s.expired(sess, now) {
+			continue
+		}
+		for name, obs := range state.Windows {
+			if !defined[name] {
+				continue
+			}
+			if s.maxObservations > 0 && len(obs) > s.maxObservations {
+				obs = obs[len(obs)-s.maxObservations:]
+			}
+			sess.windows[name] = append([]Observation(nil), obs...)
+		}
+		if old, ok := s.sessions[sess.id]; ok {
+			s.drop(old)
+		}
+		s.insert(sess)
+	}
+}
+
+// WriteSnapshot writes the sessions as JSON.
+func (s *SessionStore) WriteSnapshot(w io.Writer) error {
+	return json.NewEncoder(w).Encode(s.Snapshot())
+}
+
+// ReadSnapshot restores the sessions from JSON written by WriteSnapshot.
+func (s *SessionStore) ReadSnapshot(r io.Reader) error {
+	var snap SessionSnapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("reading session snapshot: %w", err)
+	}
+	s.Restore(snap)
+	return nil
+}
+
+// SaveFile writes a snapshot to path, replacing it atomically.
+func (s *SessionStore) SaveFile(path string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := s.WriteSnapshot(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadFile restores a snapshot written by SaveFile.
+func (s *SessionStore) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.ReadSnapshot(f)
+}
@@ -0,0 +1,198 @@
+package rulesengine
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func loginWindows() []Window {
+	return []Window{
+		{Name: "logins10m", Kind: WindowSliding, Size: 10 * time.Minute, Func: WindowCount, Fact: "login"},
+		{Name: "spendHour", Kind: WindowTumbling, Size: time.Hour, Func: WindowSum, Fact: "purchase", Path: ".amount"},
+		{Name: "ips10m", Kind: WindowSliding, Size: 10 * time.Minute, Func: WindowDistinct, Fact: "login", Path: ".ip"},
+	}
+}
+
+func sessionStore(t *testing.T, options ...SessionOption) (*SessionStore, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	engine := NewEngine(WithClock(clock.Now))
+	require.NoError(t, engine.AddRule(NewRule(
+		Condition{Fact: "logins10m", Operator: "greaterThan", Value: 3},
+		Event{Type: "too-many-logins"}, WithName("logins"))))
+	store, err := NewSessionStore(engine, loginWindows(), options...)
+	require.NoError(t, err)
+	return store, clock
+}
+
+func login(ip string) map[string]interface{} {
+	return map[string]interface{}{"login": map[string]interface{}{"ip": ip}}
+}
+
+func TestSessionStore_SlidingWindow(t *testing.T) {
+	store, clock := sessionStore(t)
+
+	for i := 0; i < 3; i++ {
+		result, err := store.Run("alice", login("10.0.0.1"))
+		require.NoError(t, err)
+		assert.Empty(t, result.Events)
+		clock.Advance(time.Minute)
+	}
+	result, err := store.Run("alice", login("10.0.0.2"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"too-many-logins"}, eventTypes(result))
+
+	// Other entities have their own windows.
+	result, err = store.Run("bob", login("10.0.0.9"))
+	require.NoError(t, err)
+	assert.Empty(t, result.Events)
+
+	facts, err := store.Facts("alice")
+	require.NoError(t, err)
+	assert.Equal(t, 4.0, facts["logins10m"])
+	assert.Equal(t, 2.0, facts["ips10m"])
+
+	// The first login leaves the window exactly ten minutes after it happened.
+	clock.Advance(7 * time.Minute)
+	facts, err = store.Facts("alice")
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, facts["logins10m"])
+}
+
+func TestSessionStore_TumblingWindow(t *testing.T) {
+	store, clock := sessionStore(t)
+	purchase := func(amount float64) map[string]interface{} {
+		return map[string]interface{}{"purchase": map[string]interface{}{"amount": amount}}
+	}
+
+	clock.Advance(40 * time.Minute)
+	_, err := store.Run("alice", purchase(20))
+	require.NoError(t, err)
+	clock.Advance(15 * time.Minute)
+	result, err := store.Run("alice", purchase(5))
+	require.NoError(t, err)
+	assert.Equal(t, 25.0, result.Almanac.GetRuntimeFacts()["spendHour"])
+
+	// A new hour starts a new window even though the last purchase was
+	// only ten minutes ago.
+	clock.Advance(10 * time.Minute)
+	facts, err := store.Facts("alice")
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, facts["spendHour"])
+	// Runs without the feeding fact add nothing.
+	assert.Equal(t, 0.0, facts["logins10m"])
+}
+
+func TestSessionStore_Expiry(t *testing.T) {
+	store, clock := sessionStore(t, WithSessionTTL(30*time.Minute))
+
+	_, err := store.Run("alice", login("a"))
+	require.NoError(t, err)
+	clock.Advance(20 * time.Minute)
+	_, err = store.Run("bob", login("b"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, store.Len())
+
+	clock.Advance(15 * time.Minute)
+	assert.Equal(t, 1, store.Expire())
+	assert.Equal(t, 1, store.Len())
+
+	clock.Advance(time.Hour)
+	// Accessing an expired session starts it afresh.
+	_, err = store.Run("bob", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, store.Len())
+}
+
+func TestSessionStore_MemoryBounds(t *testing.T) {
+	store, clock := sessionStore(t, WithMaxSessions(2), WithMaxObservations(2))
+
+	for _, id := range []string{"a", "b", "a", "c"} {
+		_, err := store.Run(id, login(id))
+		require.NoError(t, err)
+		clock.Advance(time.Second)
+	}
+	// b was least recently used when c arrived.
+	assert.Equal(t, 2, store.Len())
+	snap := store.Snapshot()
+	require.Len(t, snap.Sessions, 2)
+	assert.Equal(t, "a", snap.Sessions[0].ID)
+	assert.Equal(t, "c", snap.Sessions[1].ID)
+
+	for i := 0; i < 5; i++ {
+		_, err := store.Run("a", login("x"))
+		require.NoError(t, err)
+	}
+	facts, err := store.Facts("a")
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, facts["logins10m"])
+}
+
+func TestSessionStore_SnapshotRestore(t *testing.T) {
+	store, clock := sessionStore(t)
+	for _, ip := range []string{"1", "2", "2"} {
+		_, err := store.Run("alice", login(ip))
+		require.NoError(t, err)
+		clock.Advance(time.Minute)
+	}
+
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	require.NoError(t, store.SaveFile(path))
+
+	restored, err := NewSessionStore(store.engine, loginWindows(), WithSessionClock(clock.Now))
+	require.NoError(t, err)
+	require.NoError(t, restored.LoadFile(path))
+	facts, err := restored.Facts("alice")
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, facts["logins10m"])
+	assert.Equal(t, 2.0, facts["ips10m"])
+
+	result, err := restored.Run("alice", login("3"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"too-many-logins"}, eventTypes(result))
+
+	// Windows the store does not define are dropped.
+	var buf bytes.Buffer
+	require.NoError(t, store.WriteSnapshot(&buf))
+	narrow, err := NewSessionStore(store.engine, loginWindows()[:1])
+	require.NoError(t, err)
+	require.NoError(t, narrow.ReadSnapshot(&buf))
+	snap := narrow.Snapshot()
+	require.Len(t, snap.Sessions, 1)
+	assert.Len(t, snap.Sessions[0].Windows, 1)
+
+	assert.Error(t, narrow.ReadSnapshot(bytes.NewBufferString("{")))
+}
+
+func TestNewSessionStore_Validation(t *testing.T) {
+	engine := NewEngine()
+	tests := []struct {
+		window Window
+		err    string
+	}{
+		{Window{Kind: WindowSliding, Size: time.Minute, Func: WindowCount, Fact: "f"}, "requires a name"},
+		{Window{Name: "w", Kind: WindowSliding, Size: time.Minute, Func: WindowCount}, "requires a fact"},
+		{Window{Name: "w", Kind: WindowSliding, Func: WindowCount, Fact: "f"}, "positive size"},
+		{Window{Name: "w", Kind: "hopping", Size: time.Minute, Func: WindowCount, Fact: "f"}, "unknown kind"},
+		{Window{Name: "w", Kind: WindowSliding, Size: time.Minute, Func: "avg", Fact: "f"}, "unknown func"},
+		{Window{Name: "f", Kind: WindowSliding, Size: time.Minute, Func: WindowCount, Fact: "f"}, "itself"},
+	}
+	for _, tt := range tests {
+		_, err := NewSessionStore(engine, []Window{tt.window})
+		assert.ErrorContains(t, err, tt.err)
+	}
+
+	w := loginWindows()[0]
+	_, err := NewSessionStore(engine, []Window{w, w})
+	assert.ErrorContains(t, err, "duplicate window")
+}
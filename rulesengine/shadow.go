@@ -0,0 +1,195 @@
+package rulesengine
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ShadowStats summarises a challenger's evaluations. Runs counts the
+// champion runs the challenger evaluated and equals Matches plus
+// Divergences plus Errors; Skipped counts those it missed because too many
+// evaluations were pending.
+type ShadowStats struct {
+	Champion    string    `json:"champion" bson:"champion" xml:"champion" yaml:"champion"`
+	Challenger  string    `json:"challenger" bson:"challenger" xml:"challenger" yaml:"challenger"`
+	Since       time.Time `json:"since" bson:"since" xml:"since" yaml:"since"`
+	Runs        int       `json:"runs" bson:"runs" xml:"runs" yaml:"runs"`
+	Matches     int       `json:"matches" bson:"matches" xml:"matches" yaml:"matches"`
+	Divergences int       `json:"divergences" bson:"divergences" xml:"divergences" yaml:"divergences"`
+	Errors      int       `json:"errors" bson:"errors" xml:"errors" yaml:"errors"`
+	Skipped     int       `json:"skipped" bson:"skipped" xml:"skipped" yaml:"skipped"`
+	// RuleDivergences counts, by rule, the runs in which its outcome
+	// differed.
+	RuleDivergences map[string]int `json:"ruleDivergences" bson:"ruleDivergences" xml:"ruleDivergences" yaml:"ruleDivergences"`
+	LastError       string         `json:"lastError,omitempty" bson:"lastError,omitempty" xml:"lastError,omitempty" yaml:"lastError,omitempty"`
+}
+
+// Divergence is a run in which the challenger's decision differed from the
+// champion's. In Diff the champion's decision is the old one: AddedEvents
+// were fired only by the challenger and RemovedEvents only by the champion.
+type Divergence struct {
+	Time             time.Time              `json:"time" bson:"time" xml:"time" yaml:"time"`
+	Facts            map[string]interface{} `json:"facts" bson:"facts" xml:"facts" yaml:"facts"`
+	ChampionEvents   []Event                `json:"championEvents" bson:"championEvents" xml:"championEvents" yaml:"championEvents"`
+	ChallengerEvents []Event                `json:"challengerEvents" bson:"challengerEvents" xml:"challengerEvents" yaml:"challengerEvents"`
+	Diff             DecisionDiff           `json:"diff" bson:"diff" xml:"diff" yaml:"diff"`
+}
+
+// ShadowOption configures a Shadow.
+type ShadowOption func(*Shadow)
+
+// WithMaxPending bounds the challenger evaluations running at once; runs
+// beyond it are skipped rather than queued, so a slow challenger never
+// holds back the champion. The default is 16.
+func WithMaxPending(n int) ShadowOption {
+	return func(s *Shadow) {
+		s.maxPending = n
+	}
+}
+
+// WithMaxDivergences bounds the divergences kept; the oldest are dropped
+// first. The default is 100.
+func WithMaxDivergences(n int) ShadowOption {
+	return func(s *Shadow) {
+		s.maxDivergences = n
+	}
+}
+
+// Shadow pairs a champion engine with a challenger that silently evaluates
+// the same runtime facts. The challenger runs in the background as a dry
+// run, so its callbacks, actions, subscriptions, outbox and decision log
+// are skipped, and Shadow records where its decision differs.
+type Shadow struct {
+	Champion   string
+	Challenger string
+
+	maxPending     int
+	maxDivergences int
+
+	mu          sync.Mutex
+	stats       ShadowStats
+	divergences []Divergence
+	pending     int
+	wg          sync.WaitGroup
+}
+
+func newShadow(champion, challenger string, options ...ShadowOption) *Shadow {
+	s := &Shadow{
+		Champion:       champion,
+		Challenger:     challenger,
+		maxPending:     16,
+		maxDivergences: 100,
+		stats: ShadowStats{
+			Champion:        champion,
+			Challenger:      challenger,
+			Since:           time.Now(),
+			RuleDivergences: make(map[string]int),
+		},
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+	return s
+}
+
+// Stats returns the statistics gathered since the engines were paired.
+func (s *Shadow) Stats() ShadowStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	stats.RuleDivergences = make(map[string]int, len(s.stats.RuleDivergences))
+	for rule, n := range s.stats.RuleDivergences {
+		stats.RuleDivergences[rule] = n
+	}
+	return stats
+}
+
+// Divergences returns the most recent divergences, oldest first.
+func (s *Shadow) Divergences() []Divergence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Divergence{}, s.divergences...)
+}
+
+// Wait blocks until the pending challenger evaluations have finished.
+func (s *Shadow) Wait() {
+	s.wg.Wait()
+}
+
+// evaluate runs challenger, if there is room, on the facts of a champion
+// run that produced result, and records the comparison.
+func (s *Shadow) evaluate(challenger *Engine, facts map[string]interface{}, options []RunOption, result *RunResult) {
+	s.mu.Lock()
+	if s.pending >= s.maxPending {
+		s.stats.Skipped++
+		s.mu.Unlock()
+		return
+	}
+	s.pending++
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	// Copy what is compared, as the caller owns result.
+	events := cloneEvents(result.Events)
+	ruleResults := make([]*RuleResult, len(result.RuleResults))
+	for i, rr := range result.RuleResults {
+		ruleResults[i] = &RuleResult{Name: rr.Name, Status: rr.Status, Success: rr.Success}
+	}
+	options = append(options[:len(options):len(options)], func(c *runConfig) {
+		c.dryRun = true
+	})
+
+	go func() {
+		defer s.wg.Done()
+		var (
+			challenged *RunResult
+			err        error
+		)
+		if challenger == nil {
+			err = fmt.Errorf("engine %s not found", s.Challenger)
+		} else {
+			challenged, err = challenger.Run(cloneParams(facts), options...)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.pending--
+		s.stats.Runs++
+		if err != nil {
+			s.stats.Errors++
+			s.stats.LastError = err.Error()
+			return
+		}
+		diff := diffResults(events, ruleResults, challenged)
+		if diff.Empty() {
+			s.stats.Matches++
+			return
+		}
+		s.stats.Divergences++
+		for _, c := range diff.Changed {
+			s.stats.RuleDivergences[c.Rule]++
+		}
+		s.divergences = append(s.divergences, Divergence{
+			Time:             time.Now(),
+			Facts:            facts,
+			ChampionEvents:   events,
+			ChallengerEvents: challenged.Events,
+			Diff:             diff,
+		})
+		if n := len(s.divergences) - s.maxDivergences; n > 0 {
+			s.divergences = append([]Divergence{}, s.divergences[n:]...)
+		}
+	}()
+}
+
+func cloneEvents(events []Event) []Event {
+	if events == nil {
+		return nil
+	}
+	out := make([]Event, len(events))
+	for i, ev := range events {
+		out[i] = Event{Type: ev.Type, Params: cloneParams(ev.Params)}
+	}
+	return out
+}
@@ -0,0 +1,133 @@
+package rulesengine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pairedEngines creates a champion approving amounts under 1000 and a
+// challenger approving amounts under 500.
+func pairedEngines(t *testing.T, options ...ShadowOption) (*EngineManager, *Shadow, *int) {
+	t.Helper()
+	em := NewEngineManager()
+	champion := em.CreateEngine("champion")
+	require.NoError(t, champion.AddRule(NewRule(Condition{Fact: "amount", Operator: "lessThan", Value: 1000}, Event{Type: "approve"}, WithName("approve"))))
+
+	challengerCalls := 0
+	challenger := em.CreateEngine("challenger")
+	require.NoError(t, challenger.AddRule(NewRule(Condition{Fact: "amount", Operator: "lessThan", Value: 500}, Event{Type: "approve"}, WithName("approve"),
+		WithOnSuccess(func(Event, *Almanac, *RuleResult) error {
+			challengerCalls++
+			return nil
+		}))))
+
+	shadow, err := em.SetChallenger("champion", "challenger", options...)
+	require.NoError(t, err)
+	return em, shadow, &challengerCalls
+}
+
+func TestShadow_RecordsDivergences(t *testing.T) {
+	em, shadow, challengerCalls := pairedEngines(t)
+
+	for _, amount := range []int{100, 700, 2000, 800} {
+		result, err := em.Run("champion", map[string]interface{}{"amount": amount})
+		require.NoError(t, err)
+		if amount < 1000 {
+			assert.Equal(t, []Event{{Type: "approve"}}, result.Events, "the champion's result is returned")
+		} else {
+			assert.Empty(t, result.Events)
+		}
+	}
+	shadow.Wait()
+
+	stats := shadow.Stats()
+	assert.Equal(t, "champion", stats.Champion)
+	assert.Equal(t, "challenger", stats.Challenger)
+	assert.False(t, stats.Since.IsZero())
+	assert.Equal(t, 4, stats.Runs)
+	assert.Equal(t, 2, stats.Matches)
+	assert.Equal(t, 2, stats.Divergences)
+	assert.Zero(t, stats.Errors)
+	assert.Equal(t, map[string]int{"approve": 2}, stats.RuleDivergences)
+
+	divergences := shadow.Divergences()
+	require.Len(t, divergences, 2)
+	amounts := []interface{}{divergences[0].Facts["amount"], divergences[1].Facts["amount"]}
+	assert.ElementsMatch(t, []interface{}{700, 800}, amounts)
+	d := divergences[0]
+	assert.Equal(t, []Event{{Type: "approve"}}, d.ChampionEvents)
+	assert.Empty(t, d.ChallengerEvents)
+	assert.Equal(t, []Event{{Type: "approve"}}, d.Diff.RemovedEvents)
+	assert.Equal(t, []OutcomeChange{{Rule: "approve", Old: RuleStatusPassed, New: RuleStatusFailed}}, d.Diff.Changed)
+
+	assert.Zero(t, *challengerCalls, "the challenger runs without side effects")
+}
+
+func TestShadow_Limits(t *testing.T) {
+	em, shadow, _ := pairedEngines(t, WithMaxDivergences(1))
+	for _, amount := range []int{600, 700} {
+		_, err := em.Run("champion", map[string]interface{}{"amount": amount})
+		require.NoError(t, err)
+		shadow.Wait()
+	}
+	divergences := shadow.Divergences()
+	require.Len(t, divergences, 1)
+	assert.Equal(t, 700, divergences[0].Facts["amount"])
+	assert.Equal(t, 2, shadow.Stats().Divergences)
+
+	em, shadow, _ = pairedEngines(t, WithMaxPending(0))
+	_, err := em.Run("champion", map[string]interface{}{"amount": 600})
+	require.NoError(t, err)
+	shadow.Wait()
+	stats := shadow.Stats()
+	assert.Zero(t, stats.Runs)
+	assert.Equal(t, 1, stats.Skipped)
+}
+
+func TestShadow_ChallengerErrors(t *testing.T) {
+	em, shadow, _ := pairedEngines(t)
+	challenger := em.GetEngine("challenger")
+	require.NoError(t, challenger.AddFact("limit", FactFunc(func(map[string]interface{}, *Almanac) (interface{}, error) {
+		return nil, errors.New("limits unavailable")
+	})))
+	require.NoError(t, challenger.AddRule(NewRule(Condition{Fact: "limit", Operator: "greaterThan", Value: 0}, Event{Type: "limited"}, WithName("limited"))))
+
+	result, err := em.Run("champion", map[string]interface{}{"amount": 100})
+	require.NoError(t, err, "challenger errors never fail the champion's run")
+	assert.Len(t, result.Events, 1)
+	shadow.Wait()
+	stats := shadow.Stats()
+	assert.Equal(t, 1, stats.Runs)
+	assert.Equal(t, 1, stats.Errors)
+	assert.Contains(t, stats.LastError, "limits unavailable")
+}
+
+func TestEngineManager_Challengers(t *testing.T) {
+	em, shadow, _ := pairedEngines(t)
+	assert.Same(t, shadow, em.GetShadow("champion"))
+	assert.Nil(t, em.GetShadow("challenger"))
+
+	_, err := em.SetChallenger("champion", "champion")
+	assert.EqualError(t, err, "engine champion cannot challenge itself")
+	_, err = em.SetChallenger("champion", "missing")
+	assert.EqualError(t, err, "engine missing not found")
+	_, err = em.Run("missing", nil)
+	assert.EqualError(t, err, "engine missing not found")
+
+	// Without a challenger, Run runs the engine alone.
+	result, err := em.Run("challenger", map[string]interface{}{"amount": 100})
+	require.NoError(t, err)
+	assert.Len(t, result.Events, 1)
+
+	assert.True(t, em.RemoveChallenger("champion"))
+	assert.False(t, em.RemoveChallenger("champion"))
+	assert.Nil(t, em.GetShadow("champion"))
+
+	_, err = em.SetChallenger("champion", "challenger")
+	require.NoError(t, err)
+	em.DeleteEngine("challenger")
+	assert.Nil(t, em.GetShadow("champion"), "deleting the challenger ends the pairing")
+}
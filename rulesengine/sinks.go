@@ -0,0 +1,95 @@
+package rulesengine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// JSONLinesSink writes each record as a line of JSON, for example to stdout.
+type JSONLinesSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{w: w}
+}
+
+func (s *JSONLinesSink) Deliver(ctx context.Context, record OutboxRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+// WebhookSink posts each record as JSON to a URL. Responses other than 2xx
+// are delivery failures.
+type WebhookSink struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+}
+
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *WebhookSink) Deliver(ctx context.Context, record OutboxRecord) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Lets receivers drop duplicates caused by retries.
+	req.Header.Set("Idempotency-Key", record.ID)
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// ChannelSink sends each record on a channel, blocking until it is received
+// or the delivery context is done.
+type ChannelSink struct {
+	ch chan<- OutboxRecord
+}
+
+func NewChannelSink(ch chan<- OutboxRecord) *ChannelSink {
+	return &ChannelSink{ch: ch}
+}
+
+func (s *ChannelSink) Deliver(ctx context.Context, record OutboxRecord) error {
+	select {
+	case s.ch <- record:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
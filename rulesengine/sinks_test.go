@@ -0,0 +1,69 @@
+package rulesengine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONLinesSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONLinesSink(&buf)
+	require.NoError(t, sink.Deliver(context.Background(), OutboxRecord{ID: "1", Event: Event{Type: "a"}}))
+	require.NoError(t, sink.Deliver(context.Background(), OutboxRecord{ID: "2", Event: Event{Type: "b"}}))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	var record OutboxRecord
+	require.NoError(t, json.Unmarshal(lines[1], &record))
+	assert.Equal(t, "2", record.ID)
+}
+
+func TestWebhookSink(t *testing.T) {
+	var received []OutboxRecord
+	var keys []string
+	fail := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var record OutboxRecord
+		json.NewDecoder(r.Body).Decode(&record)
+		received = append(received, record)
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	outbox := NewOutbox(NewMemoryOutboxStore(), NewWebhookSink(server.URL), WithBackoff(func(int) time.Duration { return 0 }))
+	require.NoError(t, outbox.Enqueue(OutboxRecord{ID: "r1", Rule: "big", Event: Event{Type: "flag"}}))
+
+	res, err := outbox.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Retried)
+	pending, _ := outbox.Pending()
+	assert.Equal(t, "webhook returned 503 Service Unavailable", pending[0].LastError)
+
+	fail = false
+	res, err = outbox.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Delivered)
+	require.Len(t, received, 1)
+	assert.Equal(t, "big", received[0].Rule)
+	assert.Equal(t, []string{"r1"}, keys)
+}
+
+func TestChannelSink_ContextDone(t *testing.T) {
+	sink := NewChannelSink(make(chan OutboxRecord))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, sink.Deliver(ctx, OutboxRecord{}), context.Canceled)
+}
@@ -0,0 +1,176 @@
+package rulesengine
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// EventHandler receives the event of a rule evaluated during a run, with the
+// run's almanac and the rule's result.
+type EventHandler func(event Event, almanac *Almanac, ruleResult *RuleResult) error
+
+// HandlerErrorPolicy decides what a handler error does to the run.
+type HandlerErrorPolicy string
+
+const (
+	// HandlerErrorFail fails the run with the handler's error. It is the
+	// default for synchronous handlers; asynchronous handlers log instead.
+	HandlerErrorFail HandlerErrorPolicy = "fail"
+	// HandlerErrorLog logs the error and continues.
+	HandlerErrorLog HandlerErrorPolicy = "log"
+	// HandlerErrorIgnore drops the error.
+	HandlerErrorIgnore HandlerErrorPolicy = "ignore"
+)
+
+// SubscriptionOption configures a subscription.
+type SubscriptionOption func(*subscription)
+
+// Async runs the handler in its own goroutine with a snapshot of the
+// almanac, so it neither delays nor fails the run. Use WaitForHandlers to
+// wait for outstanding handlers.
+func Async() SubscriptionOption {
+	return func(s *subscription) {
+		s.async = true
+	}
+}
+
+// WithErrorPolicy sets what happens when the handler returns an error or
+// panics.
+func WithErrorPolicy(policy HandlerErrorPolicy) SubscriptionOption {
+	return func(s *subscription) {
+		s.policy = policy
+	}
+}
+
+type subscriptionKind int
+
+const (
+	subscribeType subscriptionKind = iota
+	subscribeAny
+	subscribeSuccess
+	subscribeFailure
+)
+
+type subscription struct {
+	kind      subscriptionKind
+	eventType string
+	handler   EventHandler
+	async     bool
+	policy    HandlerErrorPolicy
+}
+
+func (s *subscription) matches(event Event, passed bool) bool {
+	switch s.kind {
+	case subscribeType:
+		return passed && event.Type == s.eventType
+	case subscribeSuccess:
+		return passed
+	case subscribeFailure:
+		return !passed
+	}
+	return true
+}
+
+// On subscribes to events of the given type emitted by passing rules. The
+// returned function removes the subscription.
+func (e *Engine) On(eventType string, handler EventHandler, options ...SubscriptionOption) func() {
+	return e.subscribe(&subscription{kind: subscribeType, eventType: eventType, handler: handler}, options)
+}
+
+// OnAny subscribes to every evaluated rule, passing or failing; the
+// RuleResult tells which.
+func (e *Engine) OnAny(handler EventHandler, options ...SubscriptionOption) func() {
+	return e.subscribe(&subscription{kind: subscribeAny, handler: handler}, options)
+}
+
+// OnSuccess subscribes to the events of every passing rule.
+func (e *Engine) OnSuccess(handler EventHandler, options ...SubscriptionOption) func() {
+	return e.subscribe(&subscription{kind: subscribeSuccess, handler: handler}, options)
+}
+
+// OnFailure subscribes to the events of every failing rule.
+func (e *Engine) OnFailure(handler EventHandler, options ...SubscriptionOption) func() {
+	return e.subscribe(&subscription{kind: subscribeFailure, handler: handler}, options)
+}
+
+// Subscriptions use their own lock, so handlers may subscribe and
+// unsubscribe while Run holds the engine lock.
+func (e *Engine) subscribe(s *subscription, options []SubscriptionOption) func() {
+	for _, opt := range options {
+		opt(s)
+	}
+	e.subMu.Lock()
+	e.subscriptions = append(e.subscriptions, s)
+	e.subMu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			e.subMu.Lock()
+			defer e.subMu.Unlock()
+			for i, other := range e.subscriptions {
+				if other == s {
+					e.subscriptions = append(e.subscriptions[:i:i], e.subscriptions[i+1:]...)
+					return
+				}
+			}
+		})
+	}
+}
+
+// WaitForHandlers blocks until every asynchronous handler started so far has
+// returned.
+func (e *Engine) WaitForHandlers() {
+	e.handlers.Wait()
+}
+
+// dispatch calls the subscriptions matching a rule outcome in subscription
+// order. It returns the first error of a synchronous handler whose policy is
+// HandlerErrorFail.
+func (e *Engine) dispatch(event Event, almanac *Almanac, rr *RuleResult, passed bool) error {
+	e.subMu.RLock()
+	subs := make([]*subscription, 0, len(e.subscriptions))
+	for _, s := range e.subscriptions {
+		if s.matches(event, passed) {
+			subs = append(subs, s)
+		}
+	}
+	e.subMu.RUnlock()
+
+	for _, s := range subs {
+		if s.async {
+			snapshot, result := almanac.snapshot(), *rr
+			e.handlers.Add(1)
+			go func(s *subscription) {
+				defer e.handlers.Done()
+				if err := callHandler(s.handler, event, snapshot, &result); err != nil && s.policy != HandlerErrorIgnore {
+					log.Error().Err(err).Str("event", event.Type).Str("rule", rr.Name).Msg("async event handler failed")
+				}
+			}(s)
+			continue
+		}
+		err := callHandler(s.handler, event, almanac, rr)
+		if err == nil {
+			continue
+		}
+		switch s.policy {
+		case HandlerErrorIgnore:
+		case HandlerErrorLog:
+			log.Error().Err(err).Str("event", event.Type).Str("rule", rr.Name).Msg("event handler failed")
+		default:
+			return fmt.Errorf("event handler for %s: %w", event.Type, err)
+		}
+	}
+	return nil
+}
+
+func callHandler(h EventHandler, event Event, almanac *Almanac, rr *RuleResult) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	return h(event, almanac, rr)
+}
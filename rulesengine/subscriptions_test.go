@@ -0,0 +1,101 @@
+package rulesengine
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func subscriptionEngine(t *testing.T) *Engine {
+	t.Helper()
+	engine := NewEngine()
+	require.NoError(t, engine.AddRule(NewRule(Condition{Fact: "n", Operator: "gt", Value: 0}, Event{Type: "positive"}, WithName("positive"))))
+	require.NoError(t, engine.AddRule(NewRule(Condition{Fact: "n", Operator: "lt", Value: 0}, Event{Type: "negative"}, WithName("negative"))))
+	return engine
+}
+
+func TestEngine_Subscriptions(t *testing.T) {
+	engine := subscriptionEngine(t)
+	var calls []string
+	record := func(prefix string) EventHandler {
+		return func(event Event, almanac *Almanac, rr *RuleResult) error {
+			calls = append(calls, prefix+":"+event.Type+":"+rr.Status)
+			return nil
+		}
+	}
+	engine.On("positive", record("on"))
+	engine.On("negative", record("neg"))
+	engine.OnSuccess(record("success"))
+	engine.OnFailure(record("failure"))
+	unsubscribe := engine.OnAny(record("any"))
+
+	_, err := engine.Run(map[string]interface{}{"n": 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"on:positive:passed", "success:positive:passed", "any:positive:passed",
+		"failure:negative:failed", "any:negative:failed",
+	}, calls)
+
+	unsubscribe()
+	unsubscribe()
+	calls = nil
+	_, err = engine.Run(map[string]interface{}{"n": -1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"failure:positive:failed", "neg:negative:passed", "success:negative:passed"}, calls)
+}
+
+func TestEngine_SubscriptionErrorPolicies(t *testing.T) {
+	engine := subscriptionEngine(t)
+	failing := func(Event, *Almanac, *RuleResult) error { return errors.New("boom") }
+
+	unsubscribe := engine.OnSuccess(failing)
+	_, err := engine.Run(map[string]interface{}{"n": 1})
+	assert.EqualError(t, err, "event handler for positive: boom")
+	unsubscribe()
+
+	unsubscribe = engine.OnSuccess(func(Event, *Almanac, *RuleResult) error { panic("bad") })
+	_, err = engine.Run(map[string]interface{}{"n": 1})
+	assert.ErrorContains(t, err, "handler panicked: bad")
+	unsubscribe()
+
+	engine.OnSuccess(failing, WithErrorPolicy(HandlerErrorLog))
+	engine.OnSuccess(failing, WithErrorPolicy(HandlerErrorIgnore))
+	_, err = engine.Run(map[string]interface{}{"n": 1})
+	assert.NoError(t, err)
+}
+
+func TestEngine_AsyncSubscriptions(t *testing.T) {
+	engine := subscriptionEngine(t)
+	var mu sync.Mutex
+	var seen []interface{}
+	engine.On("positive", func(event Event, almanac *Almanac, rr *RuleResult) error {
+		v, err := almanac.FactValue("n", nil, "")
+		mu.Lock()
+		seen = append(seen, v)
+		mu.Unlock()
+		return err
+	}, Async())
+	engine.OnSuccess(func(Event, *Almanac, *RuleResult) error { return errors.New("ignored") }, Async())
+
+	for i := 1; i <= 3; i++ {
+		_, err := engine.Run(map[string]interface{}{"n": i})
+		require.NoError(t, err)
+	}
+	engine.WaitForHandlers()
+	assert.ElementsMatch(t, []interface{}{1, 2, 3}, seen)
+}
+
+func TestEngine_SubscribeDuringRun(t *testing.T) {
+	engine := subscriptionEngine(t)
+	var late int
+	engine.OnAny(func(Event, *Almanac, *RuleResult) error {
+		engine.On("negative", func(Event, *Almanac, *RuleResult) error { late++; return nil })
+		return nil
+	})
+	_, err := engine.Run(map[string]interface{}{"n": -1})
+	require.NoError(t, err)
+	assert.Equal(t, 1, late)
+}
@@ -0,0 +1,389 @@
+package rulesengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestSuite is a declarative set of test cases for a rules file. Suites are
+// written in YAML or JSON so rule authors can test rules without Go code.
+type TestSuite struct {
+	Name string `json:"name,omitempty" bson:"name,omitempty" xml:"name,omitempty" yaml:"name,omitempty"`
+	// Rules is the rules file the suite targets, relative to the suite file.
+	Rules string     `json:"rules,omitempty" bson:"rules,omitempty" xml:"rules,omitempty" yaml:"rules,omitempty"`
+	Tests []TestCase `json:"tests" bson:"tests" xml:"tests" yaml:"tests"`
+}
+
+// TestCase runs the engine once with Facts as runtime facts and checks the
+// outcome. A nil Events list is not checked; an empty one expects no events.
+// Passing and Failing list rule names that must have that outcome.
+type TestCase struct {
+	Name    string                 `json:"name" bson:"name" xml:"name" yaml:"name"`
+	Facts   map[string]interface{} `json:"facts,omitempty" bson:"facts,omitempty" xml:"facts,omitempty" yaml:"facts,omitempty"`
+	Events  []Event                `json:"events,omitempty" bson:"events,omitempty" xml:"events,omitempty" yaml:"events,omitempty"`
+	Passing []string               `json:"passing,omitempty" bson:"passing,omitempty" xml:"passing,omitempty" yaml:"passing,omitempty"`
+	Failing []string               `json:"failing,omitempty" bson:"failing,omitempty" xml:"failing,omitempty" yaml:"failing,omitempty"`
+	Trace   []TraceAssertion       `json:"trace,omitempty" bson:"trace,omitempty" xml:"trace,omitempty" yaml:"trace,omitempty"`
+}
+
+// TraceAssertion checks a single condition node of a rule's trace. Path uses
+// the ValidateCondition addressing relative to the rule's root condition.
+// Skipped asserts that the node was short-circuited; otherwise Result, when
+// set, is the expected outcome of the node.
+type TraceAssertion struct {
+	Rule    string `json:"rule" bson:"rule" xml:"rule" yaml:"rule"`
+	Path    string `json:"path,omitempty" bson:"path,omitempty" xml:"path,omitempty" yaml:"path,omitempty"`
+	Result  *bool  `json:"result,omitempty" bson:"result,omitempty" xml:"result,omitempty" yaml:"result,omitempty"`
+	Skipped bool   `json:"skipped,omitempty" bson:"skipped,omitempty" xml:"skipped,omitempty" yaml:"skipped,omitempty"`
+}
+
+// TestCaseResult is the outcome of a single test case.
+type TestCaseResult struct {
+	Name     string   `json:"name" bson:"name" xml:"name" yaml:"name"`
+	Passed   bool     `json:"passed" bson:"passed" xml:"passed" yaml:"passed"`
+	Failures []string `json:"failures,omitempty" bson:"failures,omitempty" xml:"failures,omitempty" yaml:"failures,omitempty"`
+}
+
+// TestSuiteResult is the outcome of a whole suite.
+type TestSuiteResult struct {
+	Name   string           `json:"name" bson:"name" xml:"name" yaml:"name"`
+	Passed int              `json:"passed" bson:"passed" xml:"passed" yaml:"passed"`
+	Failed int              `json:"failed" bson:"failed" xml:"failed" yaml:"failed"`
+	Cases  []TestCaseResult `json:"cases" bson:"cases" xml:"cases" yaml:"cases"`
+}
+
+// OK reports whether every test case passed.
+func (r *TestSuiteResult) OK() bool {
+	return r.Failed == 0
+}
+
+// WriteReport writes a human-readable summary of the suite, including a diff
+// for every failing case.
+func (r *TestSuiteResult) WriteReport(w io.Writer) error {
+	for _, c := range r.Cases {
+		status := "PASS"
+		if !c.Passed {
+			status = "FAIL"
+		}
+		if _, err := fmt.Fprintf(w, "--- %s: %s\n", status, c.Name); err != nil {
+			return err
+		}
+		for _, f := range c.Failures {
+			if _, err := fmt.Fprintf(w, "    %s\n", strings.ReplaceAll(f, "\n", "\n    ")); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s: %d passed, %d failed\n", r.Name, r.Passed, r.Failed)
+	return err
+}
+
+// LoadTestSuiteFromJSON parses a test suite. Numeric fact values are decoded
+// as float64, matching rules loaded with LoadRulesFromJSON.
+func LoadTestSuiteFromJSON(data []byte) (*TestSuite, error) {
+	var suite TestSuite
+	if err := json.Unmarshal(data, &suite); err != nil {
+		return nil, err
+	}
+	return &suite, nil
+}
+
+// LoadTestSuiteFromYAML parses a test suite. Facts are normalized to the
+// types JSON decoding produces so YAML suites behave like JSON ones.
+func LoadTestSuiteFromYAML(data []byte) (*TestSuite, error) {
+	var suite TestSuite
+	if err := yaml.Unmarshal(data, &suite); err != nil {
+		return nil, err
+	}
+	for i := range suite.Tests {
+		facts, err := normalizeJSON(suite.Tests[i].Facts)
+		if err != nil {
+			return nil, fmt.Errorf("test %q: %w", suite.Tests[i].Name, err)
+		}
+		suite.Tests[i].Facts, _ = facts.(map[string]interface{})
+		for j := range suite.Tests[i].Events {
+			params, err := normalizeJSON(suite.Tests[i].Events[j].Params)
+			if err != nil {
+				return nil, fmt.Errorf("test %q: %w", suite.Tests[i].Name, err)
+			}
+			suite.Tests[i].Events[j].Params, _ = params.(map[string]interface{})
+		}
+	}
+	return &suite, nil
+}
+
+// LoadTestSuiteFile loads a test suite from a JSON or YAML file, chosen by
+// extension. The suite name defaults to the file name.
+func LoadTestSuiteFile(path string) (*TestSuite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var suite *TestSuite
+	if isYAMLFile(path) {
+		suite, err = LoadTestSuiteFromYAML(data)
+	} else {
+		suite, err = LoadTestSuiteFromJSON(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if suite.Name == "" {
+		suite.Name = filepath.Base(path)
+	}
+	return suite, nil
+}
+
+// RunTestFiles loads the rules file into a fresh engine and runs every test
+// file against it. When rulesPath is empty each suite's own Rules field is
+// used instead.
+func RunTestFiles(rulesPath string, testPaths ...string) ([]*TestSuiteResult, error) {
+	results := make([]*TestSuiteResult, 0, len(testPaths))
+	for _, testPath := range testPaths {
+		suite, err := LoadTestSuiteFile(testPath)
+		if err != nil {
+			return nil, err
+		}
+		path := rulesPath
+		if path == "" {
+			if suite.Rules == "" {
+				return nil, fmt.Errorf("%s: no rules file given", testPath)
+			}
+			path = filepath.Join(filepath.Dir(testPath), suite.Rules)
+		}
+		engine, err := loadTestEngine(path)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, RunTestSuite(engine, suite))
+	}
+	return results, nil
+}
+
+// loadTestEngine builds an engine from a rules file. YAML rules are passed
+// through JSON so their values have the same types as the suite's facts.
+func loadTestEngine(path string) (*Engine, error) {
+	rules, err := LoadRulesFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isYAMLFile(path) {
+		data, err := json.Marshal(rules)
+		if err != nil {
+			return nil, err
+		}
+		if rules, err = LoadRulesFromJSON(data); err != nil {
+			return nil, err
+		}
+	}
+	engine := NewEngine()
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			return nil, fmt.Errorf("%s: rule %q: %w", path, rule.Name, err)
+		}
+	}
+	return engine, nil
+}
+
+// RunTestSuite runs every case of the suite against the engine.
+func RunTestSuite(engine *Engine, suite *TestSuite) *TestSuiteResult {
+	result := &TestSuiteResult{Name: suite.Name, Cases: make([]TestCaseResult, 0, len(suite.Tests))}
+	for i, tc := range suite.Tests {
+		name := tc.Name
+		if name == "" {
+			name = fmt.Sprintf("tests[%d]", i)
+		}
+		cr := TestCaseResult{Name: name, Failures: runTestCase(engine, &tc)}
+		cr.Passed = len(cr.Failures) == 0
+		if cr.Passed {
+			result.Passed++
+		} else {
+			result.Failed++
+		}
+		result.Cases = append(result.Cases, cr)
+	}
+	return result
+}
+
+func runTestCase(engine *Engine, tc *TestCase) []string {
+	facts := make(map[string]interface{}, len(tc.Facts))
+	for k, v := range tc.Facts {
+		facts[k] = v
+	}
+	var opts []RunOption
+	if len(tc.Trace) > 0 {
+		opts = append(opts, WithTrace())
+	}
+	run, err := engine.Run(facts, opts...)
+	if err != nil {
+		return []string{fmt.Sprintf("run failed: %v", err)}
+	}
+
+	var failures []string
+	if tc.Events != nil {
+		if diff := diffEvents(tc.Events, run.Events); diff != "" {
+			failures = append(failures, "events differ (- expected, + actual):\n"+diff)
+		}
+	}
+
+	outcomes := make(map[string]*RuleResult, len(run.RuleResults))
+	for _, rr := range run.RuleResults {
+		// Inactive and disabled rules were not evaluated, so they neither
+		// pass nor fail.
+		if rr.Status != RuleStatusInactive && rr.Status != RuleStatusDisabled {
+			outcomes[rr.Name] = rr
+		}
+	}
+	for _, name := range tc.Passing {
+		rr, ok := outcomes[name]
+		switch {
+		case !ok:
+			failures = append(failures, fmt.Sprintf("rule %q: expected to pass but was not evaluated", name))
+		case !rr.Success:
+			failures = append(failures, fmt.Sprintf("rule %q: expected to pass but failed", name))
+		}
+	}
+	for _, name := range tc.Failing {
+		rr, ok := outcomes[name]
+		switch {
+		case !ok:
+			failures = append(failures, fmt.Sprintf("rule %q: expected to fail but was not evaluated", name))
+		case rr.Success:
+			failures = append(failures, fmt.Sprintf("rule %q: expected to fail but passed", name))
+		}
+	}
+
+	for _, ta := range tc.Trace {
+		rr, ok := outcomes[ta.Rule]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("trace %s %s: rule was not evaluated", ta.Rule, displayPath(ta.Path)))
+			continue
+		}
+		node, err := traceNodeAt(rr.Trace, ta.Path)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("trace %s %s: %v", ta.Rule, displayPath(ta.Path), err))
+			continue
+		}
+		switch {
+		case ta.Skipped && node != nil:
+			failures = append(failures, fmt.Sprintf("trace %s %s: expected skipped but evaluated to %t", ta.Rule, displayPath(ta.Path), node.Result))
+		case !ta.Skipped && node == nil:
+			failures = append(failures, fmt.Sprintf("trace %s %s: expected evaluated but was skipped", ta.Rule, displayPath(ta.Path)))
+		case !ta.Skipped && ta.Result != nil && node.Result != *ta.Result:
+			failures = append(failures, fmt.Sprintf("trace %s %s: expected %t, got %t", ta.Rule, displayPath(ta.Path), *ta.Result, node.Result))
+		}
+	}
+	return failures
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
+
+// traceNodeAt follows a ValidateCondition-style path through a trace. It
+// returns nil without an error when the addressed node was short-circuited.
+func traceNodeAt(trace *TraceNode, path string) (*TraceNode, error) {
+	if trace == nil {
+		return nil, fmt.Errorf("no trace recorded")
+	}
+	node := trace
+	if path == "" {
+		return node, nil
+	}
+	for _, segment := range strings.Split(path, ".") {
+		var kind string
+		index := 0
+		if segment == "Where" {
+			return nil, fmt.Errorf("no single condition at %s: aggregate items are traced separately", path)
+		}
+		if segment == "Not" {
+			kind = "Not"
+		} else {
+			open := strings.Index(segment, "[")
+			if open < 0 || !strings.HasSuffix(segment, "]") {
+				return nil, fmt.Errorf("invalid path segment %q", segment)
+			}
+			kind = segment[:open]
+			n, err := strconv.Atoi(segment[open+1 : len(segment)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid path segment %q", segment)
+			}
+			index = n
+		}
+		c := node.Condition
+		switch {
+		case kind == "All" && index < len(c.All):
+		case kind == "Any" && index < len(c.Any):
+		case kind == "Not" && c.Not != nil:
+		default:
+			return nil, fmt.Errorf("no condition at %s", path)
+		}
+		if index >= len(node.Children) {
+			return nil, nil
+		}
+		node = node.Children[index]
+	}
+	return node, nil
+}
+
+// diffEvents compares two event lists as multisets and returns one line per
+// missing ("-") or unexpected ("+") event, or "" if they match.
+func diffEvents(expected, actual []Event) string {
+	remaining := make([]string, len(actual))
+	for i, ev := range actual {
+		remaining[i] = canonicalJSON(ev)
+	}
+	var missing []string
+	for _, ev := range expected {
+		key := canonicalJSON(ev)
+		found := false
+		for i, r := range remaining {
+			if r == key {
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(remaining)
+	var lines []string
+	for _, m := range missing {
+		lines = append(lines, "- "+m)
+	}
+	for _, r := range remaining {
+		lines = append(lines, "+ "+r)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// normalizeJSON round-trips a value through JSON so that numbers become
+// float64 and nested maps become map[string]interface{}.
+func normalizeJSON(v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,147 @@
+package rulesengine
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const suiteRulesYAML = `
+- name: adult
+  priority: 10
+  conditions:
+    all:
+      - fact: age
+        operator: gte
+        value: 18
+      - fact: country
+        operator: in
+        value: [US, CA]
+  event:
+    type: eligible
+    params:
+      tier: 1
+- name: senior
+  conditions:
+    fact: age
+    operator: gte
+    value: 65
+  event:
+    type: discount
+`
+
+const suiteYAML = `
+name: eligibility
+rules: rules.yaml
+tests:
+  - name: adult in US
+    facts: {age: 30, country: US}
+    events:
+      - type: eligible
+        params: {tier: 1}
+    passing: [adult]
+    failing: [senior]
+    trace:
+      - rule: adult
+        path: All[1]
+        result: true
+  - name: minor
+    facts: {age: 12, country: US}
+    events: []
+    failing: [adult]
+    trace:
+      - rule: adult
+        path: All[1]
+        skipped: true
+`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestRunTestFiles_Passing(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "rules.yaml", suiteRulesYAML)
+	suitePath := writeFile(t, dir, "eligibility_test.yaml", suiteYAML)
+
+	results, err := RunTestFiles("", suitePath)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	var buf bytes.Buffer
+	require.NoError(t, results[0].WriteReport(&buf))
+	assert.True(t, results[0].OK(), buf.String())
+	assert.Equal(t, 2, results[0].Passed)
+	assert.Equal(t, "eligibility", results[0].Name)
+}
+
+func TestRunTestSuite_ReportsFailures(t *testing.T) {
+	engine := NewEngine()
+	require.NoError(t, engine.AddRule(NewRule(
+		Condition{All: []Condition{
+			{Fact: "age", Operator: "gte", Value: float64(18)},
+			{Fact: "vip", Operator: "eq", Value: true},
+		}},
+		Event{Type: "approve"},
+		WithName("approve"),
+	)))
+
+	suite, err := LoadTestSuiteFromJSON([]byte(`{
+		"name": "failing",
+		"tests": [{
+			"name": "wrong expectations",
+			"facts": {"age": 30, "vip": false},
+			"events": [{"type": "approve"}],
+			"passing": ["approve"],
+			"trace": [{"rule": "approve", "path": "All[1]", "result": true}]
+		}, {
+			"name": "run error",
+			"facts": {"age": 30}
+		}]
+	}`))
+	require.NoError(t, err)
+
+	result := RunTestSuite(engine, suite)
+	assert.False(t, result.OK())
+	assert.Equal(t, 2, result.Failed)
+	require.Len(t, result.Cases, 2)
+
+	failures := result.Cases[0].Failures
+	require.Len(t, failures, 3)
+	assert.Contains(t, failures[0], `- {"type":"approve"}`)
+	assert.Contains(t, failures[1], `rule "approve": expected to pass but failed`)
+	assert.Contains(t, failures[2], "All[1]: expected true, got false")
+
+	require.Len(t, result.Cases[1].Failures, 1)
+	assert.Contains(t, result.Cases[1].Failures[0], "undefined fact: vip")
+
+	var buf bytes.Buffer
+	require.NoError(t, result.WriteReport(&buf))
+	assert.Contains(t, buf.String(), "--- FAIL: wrong expectations")
+	assert.Contains(t, buf.String(), "failing: 0 passed, 2 failed")
+}
+
+func TestDiffEvents(t *testing.T) {
+	expected := []Event{{Type: "a"}, {Type: "b", Params: map[string]interface{}{"n": 1}}}
+	actual := []Event{{Type: "b", Params: map[string]interface{}{"n": float64(1)}}, {Type: "c"}}
+
+	assert.Equal(t, "- {\"type\":\"a\"}\n+ {\"type\":\"c\"}", diffEvents(expected, actual))
+	assert.Empty(t, diffEvents(actual, actual))
+}
+
+func TestTraceNodeAt_InvalidPath(t *testing.T) {
+	trace := &TraceNode{Condition: Condition{All: []Condition{{Fact: "x", Operator: "eq"}}}, Children: []*TraceNode{{}}}
+
+	_, err := traceNodeAt(trace, "Any[0]")
+	assert.Error(t, err)
+	_, err = traceNodeAt(trace, "All[x]")
+	assert.Error(t, err)
+	node, err := traceNodeAt(trace, "All[0]")
+	require.NoError(t, err)
+	assert.NotNil(t, node)
+}
@@ -2,10 +2,14 @@ package rulesengine
 
 import "fmt"
 
+// TraceNode records the outcome of one condition. For an aggregate
+// condition FactValue holds the reduced value and Children hold one node per
+// item of the collection, with Item set.
 type TraceNode struct {
 	Condition Condition    `json:"condition" bson:"condition" xml:"condition" yaml:"condition"`
 	Result    bool         `json:"result" bson:"result" xml:"result" yaml:"result"`
 	FactValue interface{}  `json:"factValue,omitempty" bson:"factValue,omitempty" xml:"factValue,omitempty" yaml:"factValue,omitempty"`
+	Item      interface{}  `json:"item,omitempty" bson:"item,omitempty" xml:"item,omitempty" yaml:"item,omitempty"`
 	Children  []*TraceNode `json:"children,omitempty" bson:"children,omitempty" xml:"children,omitempty" yaml:"children,omitempty"`
 }
 
@@ -76,6 +80,17 @@ func (c *Condition) EvaluateWithTrace(almanac *Almanac, engine *Engine) (bool, *
 		return !result, trace, nil
 	}
 
+	if c.Aggregate != nil {
+		result, reduced, items, err := c.Aggregate.evaluate(almanac, engine, true)
+		if err != nil {
+			return false, nil, err
+		}
+		trace.Result = result
+		trace.FactValue = reduced
+		trace.Children = items
+		return result, trace, nil
+	}
+
 	if c.Fact != "" && c.Operator != "" {
 		factValue, err := almanac.FactValue(c.Fact, c.Params, c.Path)
 		if err != nil {
@@ -97,7 +112,14 @@ func (c *Condition) EvaluateWithTrace(almanac *Almanac, engine *Engine) (bool, *
 type RunOption func(*runConfig)
 
 type runConfig struct {
-	trace bool
+	trace    bool
+	coverage *CoverageCollector
+	groups   map[string]bool
+	// dryRun skips callbacks, actions, subscriptions and the outbox, for
+	// replays.
+	dryRun bool
+	// factCache seeds the almanac's fact cache, for replays.
+	factCache map[string]map[string]interface{}
 }
 
 func WithTrace() RunOption {
@@ -112,6 +134,7 @@ func (r *Rule) EvaluateWithTrace(almanac *Almanac, engine *Engine) (bool, *RuleR
 	ruleResult := &RuleResult{
 		Name:    r.Name,
 		Success: result,
+		Status:  ruleStatus(result),
 		Trace:   trace,
 	}
 	return result, ruleResult, nil
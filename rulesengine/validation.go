@@ -9,22 +9,38 @@ func (e *Engine) Validate() []ValidationError {
 	var errs []ValidationError
 	for i, rule := range e.rules {
 		prefix := fmt.Sprintf("rules[%d]", i)
-
-		structErrs := ValidateCondition(&rule.Conditions)
-		for _, se := range structErrs {
+		for _, ve := range e.validateRule(rule) {
 			path := prefix
-			if se.Path != "" {
-				path = prefix + "." + se.Path
+			if ve.Path != "" {
+				path = prefix + "." + ve.Path
 			}
-			errs = append(errs, ValidationError{Path: path, Message: se.Message})
+			errs = append(errs, ValidationError{Path: path, Message: ve.Message})
 		}
-
-		errs = append(errs, e.validateConditionState(&rule.Conditions, prefix)...)
 	}
 	return errs
 }
 
-func (e *Engine) validateConditionState(c *Condition, path string) []ValidationError {
+// ValidateRule checks a rule against the engine without adding it. Paths are
+// relative to the rule's root condition.
+func (e *Engine) ValidateRule(rule *Rule) []ValidationError {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.validateRule(rule)
+}
+
+func (e *Engine) validateRule(rule *Rule) []ValidationError {
+	errs := ValidateCondition(&rule.Conditions)
+	errs = append(errs, e.validateConditionState(&rule.Conditions, "", nil)...)
+	errs = append(errs, validateActivation(rule)...)
+	errs = append(errs, validateSalience(rule)...)
+	errs = append(errs, validateActions(rule)...)
+	return append(errs, e.validateActionTypes(rule)...)
+}
+
+// validateConditionState checks facts, operators and references against the
+// engine. bound holds the item facts of enclosing aggregates, which exist
+// only while the aggregate runs.
+func (e *Engine) validateConditionState(c *Condition, path string, bound map[string]bool) []ValidationError {
 	var errs []ValidationError
 
 	if c.ConditionRef != "" {
@@ -39,50 +55,75 @@ func (e *Engine) validateConditionState(c *Condition, path string) []ValidationE
 		return errs
 	}
 
-	if c.Fact != "" && c.Operator != "" {
-		if !e.allowUndefinedFacts {
-			if _, ok := e.facts[c.Fact]; !ok {
-				errs = append(errs, ValidationError{
-					Path:    path,
-					Message: fmt.Sprintf("undefined fact: %s", c.Fact),
-				})
-			}
-		}
-		parts := splitOperator(c.Operator)
-		baseName := parts[len(parts)-1]
-		if _, ok := e.operators[baseName]; !ok {
-			errs = append(errs, ValidationError{
-				Path:    path,
-				Message: fmt.Sprintf("undefined operator: %s", baseName),
-			})
+	if c.Aggregate != nil {
+		a := c.Aggregate
+		errs = append(errs, e.validateFactState(a.Fact, path, bound)...)
+		if a.Operator != "" {
+			errs = append(errs, e.validateOperatorState(a.Operator, path)...)
 		}
-		for i := 0; i < len(parts)-1; i++ {
-			if _, ok := e.operatorDecorators[parts[i]]; !ok {
-				errs = append(errs, ValidationError{
-					Path:    path,
-					Message: fmt.Sprintf("undefined operator decorator: %s", parts[i]),
-				})
+		if a.Where != nil {
+			scope := make(map[string]bool, len(bound)+1)
+			for name := range bound {
+				scope[name] = true
 			}
+			scope[a.itemFact()] = true
+			errs = append(errs, e.validateConditionState(a.Where, joinPath(path, "Where"), scope)...)
 		}
 		return errs
 	}
 
+	if c.Fact != "" && c.Operator != "" {
+		errs = append(errs, e.validateFactState(c.Fact, path, bound)...)
+		return append(errs, e.validateOperatorState(c.Operator, path)...)
+	}
+
 	for i, child := range c.All {
-		errs = append(errs, e.validateConditionState(&child, fmt.Sprintf("%s.All[%d]", path, i))...)
+		errs = append(errs, e.validateConditionState(&child, joinPath(path, fmt.Sprintf("All[%d]", i)), bound)...)
 	}
 	for i, child := range c.Any {
-		errs = append(errs, e.validateConditionState(&child, fmt.Sprintf("%s.Any[%d]", path, i))...)
+		errs = append(errs, e.validateConditionState(&child, joinPath(path, fmt.Sprintf("Any[%d]", i)), bound)...)
 	}
 	if c.Not != nil {
-		errs = append(errs, e.validateConditionState(c.Not, path+".Not")...)
+		errs = append(errs, e.validateConditionState(c.Not, joinPath(path, "Not"), bound)...)
+	}
+
+	return errs
+}
+
+func (e *Engine) validateFactState(fact, path string, bound map[string]bool) []ValidationError {
+	if e.allowUndefinedFacts || bound[fact] {
+		return nil
 	}
+	if _, ok := e.facts[fact]; ok {
+		return nil
+	}
+	return []ValidationError{{Path: path, Message: fmt.Sprintf("undefined fact: %s", fact)}}
+}
 
+func (e *Engine) validateOperatorState(operator, path string) []ValidationError {
+	var errs []ValidationError
+	parts := splitOperator(operator)
+	baseName := parts[len(parts)-1]
+	if _, ok := e.operators[baseName]; !ok {
+		errs = append(errs, ValidationError{
+			Path:    path,
+			Message: fmt.Sprintf("undefined operator: %s", baseName),
+		})
+	}
+	for i := 0; i < len(parts)-1; i++ {
+		if _, ok := e.operatorDecorators[parts[i]]; !ok {
+			errs = append(errs, ValidationError{
+				Path:    path,
+				Message: fmt.Sprintf("undefined operator decorator: %s", parts[i]),
+			})
+		}
+	}
 	return errs
 }
 
 type ValidationError struct {
-	Path    string
-	Message string
+	Path    string `json:"path" bson:"path" xml:"path" yaml:"path"`
+	Message string `json:"message" bson:"message" xml:"message" yaml:"message"`
 }
 
 func (e ValidationError) Error() string {
@@ -107,6 +148,7 @@ func validateCondition(c *Condition, path string) []ValidationError {
 	hasNot := c.Not != nil
 	hasLeaf := c.Fact != "" || c.Operator != ""
 	hasRef := c.ConditionRef != ""
+	hasAggregate := c.Aggregate != nil
 
 	typeCount := 0
 	if hasAll {
@@ -124,6 +166,9 @@ func validateCondition(c *Condition, path string) []ValidationError {
 	if hasRef {
 		typeCount++
 	}
+	if hasAggregate {
+		typeCount++
+	}
 
 	if typeCount == 0 {
 		errs = append(errs, ValidationError{Path: path, Message: "condition is empty"})
@@ -177,5 +222,9 @@ func validateCondition(c *Condition, path string) []ValidationError {
 		errs = append(errs, validateCondition(c.Not, childPath)...)
 	}
 
+	if hasAggregate {
+		errs = append(errs, validateAggregate(c.Aggregate, path)...)
+	}
+
 	return errs
 }
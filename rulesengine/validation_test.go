@@ -252,3 +252,23 @@ func TestAddRule_ValidCondition(t *testing.T) {
 	require.NoError(t, err)
 	assert.Len(t, engine.rules, 1)
 }
+
+func TestEngine_ValidateRule(t *testing.T) {
+	engine := NewEngine()
+	engine.AddFact("age", 30)
+
+	errs := engine.ValidateRule(NewRule(
+		Condition{All: []Condition{
+			{Fact: "age", Operator: "gte", Value: 18},
+			{Not: &Condition{Fact: "missing", Operator: "bogus"}},
+		}},
+		Event{Type: "x"},
+	))
+	require.Len(t, errs, 2)
+	assert.Equal(t, "All[1].Not", errs[0].Path)
+	assert.Contains(t, errs[0].Message, "undefined fact: missing")
+	assert.Equal(t, "All[1].Not", errs[1].Path)
+	assert.Contains(t, errs[1].Message, "undefined operator: bogus")
+
+	assert.Empty(t, engine.ValidateRule(NewRule(Condition{Fact: "age", Operator: "gte", Value: 18}, Event{Type: "x"})))
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Rohan-Muslekar/GavelEngine/api"
+	"github.com/Rohan-Muslekar/GavelEngine/audit"
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+	"github.com/gin-gonic/gin"
+)
+
+// WithAudit records every change made through the API in log, with the
+// authenticated caller and the request ID. A change that cannot be
+// recorded fails with a 500 error.
+func WithAudit(log *audit.Log) Option {
+	return func(s *Server) {
+		s.audit = log
+	}
+}
+
+// RequestIDHeader carries the ID that audit entries record for a request.
+// The server assigns one when the client sends none.
+const RequestIDHeader = "X-Request-ID"
+
+func requestID(c *gin.Context) string {
+	if id := c.GetHeader(RequestIDHeader); id != "" {
+		return id
+	}
+	if id := c.Writer.Header().Get(RequestIDHeader); id != "" {
+		return id
+	}
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	id := hex.EncodeToString(b)
+	c.Header(RequestIDHeader, id)
+	return id
+}
+
+// engineState captures the engine for CreateEngine and DeleteEngine
+// entries.
+func (s *Server) engineState(name string, engine *rulesengine.Engine) *audit.EngineState {
+	state := audit.NewEngineState(name, engine)
+	state.Production = s.IsProduction(name)
+	return state
+}
+
+// record writes an audit entry for a change to the engine in the path,
+// with before and after as a nil interface when there is no such state.
+// It writes a 500 error and returns false if the entry cannot be written.
+func (s *Server) record(c *gin.Context, action, target string, before, after interface{}) bool {
+	if s.audit == nil {
+		return true
+	}
+	entry := audit.Entry{
+		RequestID: requestID(c),
+		Action:    action,
+		Engine:    c.Param("name"),
+		Target:    target,
+	}
+	if entry.Engine == "" {
+		// CreateEngine names the engine in the body.
+		if state, ok := after.(*audit.EngineState); ok {
+			entry.Engine = state.Name
+		}
+	}
+	if p, ok := PrincipalFrom(c); ok {
+		entry.Actor = p.Subject
+	}
+	var err error
+	if entry.Before, err = audit.Marshal(before); err == nil {
+		entry.After, err = audit.Marshal(after)
+	}
+	if err == nil {
+		_, err = s.audit.Record(entry)
+	}
+	if err != nil {
+		fail(c, http.StatusInternalServerError, "recording audit entry: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// Query the audit log
+func (s *Server) queryAudit(c *gin.Context) {
+	if s.audit == nil {
+		fail(c, http.StatusNotFound, "Audit log not enabled")
+		return
+	}
+	q := audit.Query{
+		Engine: c.Query("engine"),
+		Actor:  c.Query("actor"),
+		Action: c.Query("action"),
+		Target: c.Query("target"),
+	}
+	for param, t := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if v := c.Query(param); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				fail(c, http.StatusBadRequest, param+" must be an RFC 3339 time")
+				return
+			}
+			*t = parsed
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			fail(c, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		q.Limit = n
+	}
+	entries, err := s.audit.Query(q)
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, api.AuditLog{Entries: entries})
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Rohan-Muslekar/GavelEngine/api"
+	"github.com/Rohan-Muslekar/GavelEngine/audit"
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func queryAudit(t *testing.T, router http.Handler, query string) []audit.Entry {
+	t.Helper()
+	rec := send(t, router, "view-key", http.MethodGet, "/api/audit"+query, "", http.StatusOK)
+	var out api.AuditLog
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &out))
+	return out.Entries
+}
+
+func TestAudit_RecordsChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	trail, err := audit.Open(path)
+	require.NoError(t, err)
+	defer trail.Close()
+	router := authServer(t, WithAudit(trail))
+
+	entries := queryAudit(t, router, "?engine=prod")
+	require.Len(t, entries, 3)
+	assert.Equal(t, []string{audit.ActionCreateEngine, audit.ActionAddFact, audit.ActionAddRule},
+		[]string{entries[0].Action, entries[1].Action, entries[2].Action})
+	assert.Equal(t, "root", entries[0].Actor)
+	assert.Nil(t, entries[0].Before)
+	assert.JSONEq(t, `{"name":"prod","production":true,"facts":[],"rules":[],"conditions":{}}`, string(entries[0].After))
+	assert.Equal(t, "adult", entries[2].Target)
+
+	// The request ID is taken from the request, or assigned and returned.
+	req := httptest.NewRequest(http.MethodPost, "/api/engines/dev/rules/adult/disable", nil)
+	req.Header.Set("X-API-Key", "author-key")
+	req.Header.Set(RequestIDHeader, "req-42")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	rec := send(t, router, "author-key", http.MethodPut, "/api/engines/dev/conditions/adult", `{"fact":"age","operator":"greaterThanInclusive","value":18}`, http.StatusCreated)
+	assigned := rec.Header().Get(RequestIDHeader)
+	assert.NotEmpty(t, assigned)
+
+	entries = queryAudit(t, router, "?actor=ann")
+	require.Len(t, entries, 2)
+	disable := entries[0]
+	assert.Equal(t, audit.ActionDisableRule, disable.Action)
+	assert.Equal(t, "req-42", disable.RequestID)
+	var before, after rulesengine.Rule
+	require.NoError(t, json.Unmarshal(disable.Before, &before))
+	require.NoError(t, json.Unmarshal(disable.After, &after))
+	assert.False(t, before.Disabled)
+	assert.True(t, after.Disabled)
+	assert.Equal(t, audit.ActionSetCondition, entries[1].Action)
+	assert.Equal(t, assigned, entries[1].RequestID)
+
+	// Deleting an engine keeps its rules in the log.
+	send(t, router, "admin-key", http.MethodDelete, "/api/engines/dev", "", http.StatusOK)
+	entries = queryAudit(t, router, "?action=DeleteEngine&limit=1")
+	require.Len(t, entries, 1)
+	var state audit.EngineState
+	require.NoError(t, json.Unmarshal(entries[0].Before, &state))
+	require.Len(t, state.Rules, 1)
+	assert.Equal(t, "adult", state.Rules[0].Name)
+	assert.Contains(t, state.Conditions, "adult")
+	assert.Nil(t, entries[0].After)
+
+	// Failed changes are not recorded.
+	send(t, router, "author-key", http.MethodPost, "/api/engines/prod/rules/adult/enable", "", http.StatusForbidden)
+	send(t, router, "admin-key", http.MethodDelete, "/api/engines/prod/rules/missing", "", http.StatusOK)
+	all := queryAudit(t, router, "")
+	assert.Len(t, all, 9)
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	verified, err := audit.Verify(f)
+	require.NoError(t, err)
+	assert.Equal(t, all, verified)
+
+	send(t, router, "view-key", http.MethodGet, "/api/audit?since=yesterday", "", http.StatusBadRequest)
+}
+
+func TestAudit_NotEnabled(t *testing.T) {
+	router, _ := testServer(t)
+	out := do(t, router, http.MethodGet, "/api/audit", "", http.StatusNotFound)
+	assert.Equal(t, "Audit log not enabled", out["error"])
+}
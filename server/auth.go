@@ -0,0 +1,300 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Rohan-Muslekar/GavelEngine/api"
+	"github.com/gin-gonic/gin"
+)
+
+// Role is the access level of an authenticated caller. Each role includes
+// the permissions of the roles before it, except RoleRunner, which may only
+// run engines:
+//
+//   - RoleRunner runs engines (run, trace and batch).
+//   - RoleViewer reads engines, facts, rules, conditions and operators.
+//   - RoleAuthor also runs engines and changes facts, rules and named
+//     conditions of engines that are not in production.
+//   - RolePublisher also changes rules of production engines.
+//   - RoleAdmin also creates and deletes engines.
+type Role string
+
+const (
+	RoleRunner    Role = "runner"
+	RoleViewer    Role = "viewer"
+	RoleAuthor    Role = "author"
+	RolePublisher Role = "publisher"
+	RoleAdmin     Role = "admin"
+)
+
+// Permission is what an operation requires of the caller's role.
+type Permission int
+
+const (
+	PermRead Permission = iota
+	PermRun
+	// PermWrite changes an engine; production engines require a publisher.
+	PermWrite
+	PermAdmin
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleRunner:    {PermRun},
+	RoleViewer:    {PermRead},
+	RoleAuthor:    {PermRead, PermRun, PermWrite},
+	RolePublisher: {PermRead, PermRun, PermWrite},
+	RoleAdmin:     {PermRead, PermRun, PermWrite, PermAdmin},
+}
+
+// Valid reports whether r is a known role.
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether r grants p on an engine, which may be in production.
+func (r Role) Can(p Permission, production bool) bool {
+	if p == PermWrite && production && r != RolePublisher && r != RoleAdmin {
+		return false
+	}
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Principal is an authenticated caller.
+type Principal struct {
+	Subject string `json:"sub"`
+	Role    Role   `json:"role"`
+}
+
+// ErrNoCredentials is returned by an Authenticator when a request carries
+// no credentials it understands, so the next authenticator is tried.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Authenticator identifies the caller of a request. It returns
+// ErrNoCredentials if the request has none of the credentials it handles,
+// and another error if it has invalid ones.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithAuth requires every request except for the OpenAPI document to be
+// authenticated by one of authenticators, tried in order, and authorizes it
+// by the caller's role. Without it the server is open.
+func WithAuth(authenticators ...Authenticator) Option {
+	return func(s *Server) {
+		s.authenticators = append(s.authenticators, authenticators...)
+	}
+}
+
+// WithProductionEngines marks engines as production, so only publishers and
+// admins may change their rules, facts and named conditions.
+func WithProductionEngines(names ...string) Option {
+	return func(s *Server) {
+		for _, name := range names {
+			s.production[name] = true
+		}
+	}
+}
+
+const principalKey = "gavel.principal"
+
+// PrincipalFrom returns the caller authenticated for the request, if any.
+func PrincipalFrom(c *gin.Context) (*Principal, bool) {
+	v, ok := c.Get(principalKey)
+	if !ok {
+		return nil, false
+	}
+	return v.(*Principal), true
+}
+
+// Authenticate identifies the caller of r with the first of authenticators
+// that finds credentials it handles. It returns ErrNoCredentials if none
+// does.
+func Authenticate(r *http.Request, authenticators ...Authenticator) (*Principal, error) {
+	for _, a := range authenticators {
+		p, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		if err == nil && !p.Role.Valid() {
+			err = fmt.Errorf("unknown role %q", p.Role)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+	return nil, ErrNoCredentials
+}
+
+// authenticate is the middleware identifying the caller. It passes every
+// request when no authenticators are configured.
+func (s *Server) authenticate(c *gin.Context) {
+	if len(s.authenticators) == 0 {
+		return
+	}
+	p, err := Authenticate(c.Request, s.authenticators...)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer realm="gavel"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, api.Error{Error: "unauthorized: " + err.Error()})
+		return
+	}
+	c.Set(principalKey, p)
+}
+
+// allow returns middleware admitting callers whose role grants p on the
+// engine named in the path.
+func (s *Server) allow(p Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(s.authenticators) == 0 {
+			return
+		}
+		principal, ok := PrincipalFrom(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, api.Error{Error: "unauthorized: no credentials"})
+			return
+		}
+		production := s.IsProduction(c.Param("name"))
+		if !principal.Role.Can(p, production) {
+			msg := fmt.Sprintf("forbidden: role %s may not %s %s", principal.Role, c.Request.Method, c.FullPath())
+			if p == PermWrite && production {
+				msg += " on production engine " + c.Param("name")
+			}
+			c.AbortWithStatusJSON(http.StatusForbidden, api.Error{Error: msg})
+		}
+	}
+}
+
+// IsProduction reports whether the engine is marked as production.
+func (s *Server) IsProduction(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.production[name]
+}
+
+// APIKeys authenticates requests by the key in their X-API-Key header.
+type APIKeys map[string]Principal
+
+func (k APIKeys) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	// Compare every key so the time taken does not reveal a prefix match.
+	var found *Principal
+	for candidate, p := range k {
+		if hmac.Equal([]byte(candidate), []byte(key)) {
+			p := p
+			found = &p
+		}
+	}
+	if found == nil {
+		return nil, errors.New("invalid API key")
+	}
+	return found, nil
+}
+
+// LoadAPIKeys reads API keys from a JSON object mapping each key to its
+// principal, such as {"k3y": {"sub": "ci", "role": "runner"}}.
+func LoadAPIKeys(data []byte) (APIKeys, error) {
+	var keys APIKeys
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("decoding API keys: %w", err)
+	}
+	for key, p := range keys {
+		if !p.Role.Valid() {
+			return nil, fmt.Errorf("API key for %s: unknown role %q", p.Subject, p.Role)
+		}
+		if key == "" {
+			return nil, fmt.Errorf("API key for %s is empty", p.Subject)
+		}
+	}
+	return keys, nil
+}
+
+// HMACTokens issues and verifies compact bearer tokens signed with a shared
+// secret. A token is the base64url JSON claims and their base64url
+// HMAC-SHA256, joined by a dot.
+type HMACTokens struct {
+	secret []byte
+	clock  func() time.Time
+}
+
+type tokenClaims struct {
+	Principal
+	ExpiresAt int64 `json:"exp"`
+}
+
+// NewHMACTokens creates tokens signed with secret.
+func NewHMACTokens(secret []byte) *HMACTokens {
+	return &HMACTokens{secret: secret, clock: time.Now}
+}
+
+// Issue returns a token for p that expires after ttl.
+func (h *HMACTokens) Issue(p Principal, ttl time.Duration) (string, error) {
+	if !p.Role.Valid() {
+		return "", fmt.Errorf("unknown role %q", p.Role)
+	}
+	claims, err := json.Marshal(tokenClaims{Principal: p, ExpiresAt: h.clock().Add(ttl).Unix()})
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(claims)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(h.sign(payload)), nil
+}
+
+func (h *HMACTokens) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// Authenticate verifies the bearer token of r. Tokens with three parts are
+// left to a JWT verifier.
+func (h *HMACTokens) Authenticate(r *http.Request) (*Principal, error) {
+	token, ok := bearerToken(r)
+	if !ok || strings.Count(token, ".") != 1 {
+		return nil, ErrNoCredentials
+	}
+	payload, sig, _ := strings.Cut(token, ".")
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, h.sign(payload)) {
+		return nil, errors.New("invalid token signature")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, errors.New("malformed token")
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, errors.New("malformed token")
+	}
+	if h.clock().Unix() >= claims.ExpiresAt {
+		return nil, errors.New("token expired")
+	}
+	return &claims.Principal, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testKeys = APIKeys{
+	"run-key":     {Subject: "ci", Role: RoleRunner},
+	"view-key":    {Subject: "dash", Role: RoleViewer},
+	"author-key":  {Subject: "ann", Role: RoleAuthor},
+	"publish-key": {Subject: "pat", Role: RolePublisher},
+	"admin-key":   {Subject: "root", Role: RoleAdmin},
+}
+
+// authServer returns a handler requiring testKeys, with the engine "dev"
+// and the production engine "prod", each knowing the fact "age" and the
+// adult rule.
+func authServer(t *testing.T, options ...Option) http.Handler {
+	t.Helper()
+	options = append([]Option{WithAuth(testKeys)}, options...)
+	router := New(rulesengine.NewEngineManager(), options...).Handler()
+	for _, engine := range []string{`{"name":"dev"}`, `{"name":"prod","production":true}`} {
+		send(t, router, "admin-key", http.MethodPost, "/api/engines", engine, http.StatusCreated)
+	}
+	for _, engine := range []string{"dev", "prod"} {
+		send(t, router, "admin-key", http.MethodPost, "/api/engines/"+engine+"/facts", `{"id":"age","type":"function"}`, http.StatusCreated)
+		send(t, router, "admin-key", http.MethodPost, "/api/engines/"+engine+"/rules", adultRule, http.StatusCreated)
+	}
+	return router
+}
+
+// send makes a request with the API key, if any, and checks its status.
+func send(t *testing.T, router http.Handler, key, method, path, body string, wantStatus int) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, wantStatus, rec.Code, "%s %s as %q: %s", method, path, key, rec.Body.String())
+	return rec
+}
+
+func TestAuth_Roles(t *testing.T) {
+	router := authServer(t)
+	tests := []struct {
+		key                                   string
+		read, run, writeDev, writeProd, admin int
+	}{
+		{"run-key", 403, 200, 403, 403, 403},
+		{"view-key", 200, 403, 403, 403, 403},
+		{"author-key", 200, 200, 200, 403, 403},
+		{"publish-key", 200, 200, 200, 200, 403},
+		{"admin-key", 200, 200, 200, 200, 201},
+	}
+	for _, tt := range tests {
+		send(t, router, tt.key, http.MethodGet, "/api/engines/prod/rules", "", tt.read)
+		send(t, router, tt.key, http.MethodPost, "/api/engines/prod/run", `{"age":20}`, tt.run)
+		send(t, router, tt.key, http.MethodPost, "/api/engines/dev/rules/adult/disable", "", tt.writeDev)
+		send(t, router, tt.key, http.MethodPost, "/api/engines/prod/rules/adult/disable", "", tt.writeProd)
+		send(t, router, tt.key, http.MethodPost, "/api/engines", `{"name":"tmp"}`, tt.admin)
+	}
+
+	rec := send(t, router, "author-key", http.MethodPut, "/api/engines/prod/conditions/adult", `{"fact":"age","operator":"equal","value":1}`, http.StatusForbidden)
+	assert.Contains(t, rec.Body.String(), "production engine prod")
+
+	out := do(t, router, http.MethodGet, "/api/openapi.json", "", http.StatusOK)
+	assert.Equal(t, "3.0.3", out["openapi"])
+}
+
+func TestAuth_Unauthorized(t *testing.T) {
+	router := authServer(t)
+	rec := send(t, router, "", http.MethodGet, "/api/engines", "", http.StatusUnauthorized)
+	assert.Equal(t, `Bearer realm="gavel"`, rec.Header().Get("WWW-Authenticate"))
+	assert.Contains(t, rec.Body.String(), "no credentials")
+
+	rec = send(t, router, "wrong", http.MethodGet, "/api/engines", "", http.StatusUnauthorized)
+	assert.Contains(t, rec.Body.String(), "invalid API key")
+
+	// Unknown principals' roles are rejected.
+	router = New(rulesengine.NewEngineManager(), WithAuth(APIKeys{"k": {Subject: "x", Role: "owner"}})).Handler()
+	rec = send(t, router, "k", http.MethodGet, "/api/engines", "", http.StatusUnauthorized)
+	assert.Contains(t, rec.Body.String(), "unknown role")
+}
+
+func TestAuth_ProductionEnginesOption(t *testing.T) {
+	router := authServer(t, WithProductionEngines("dev"))
+	// Creating dev without the production flag clears the mark set by the
+	// option.
+	send(t, router, "author-key", http.MethodPost, "/api/engines/dev/rules/adult/disable", "", http.StatusOK)
+
+	manager := rulesengine.NewEngineManager()
+	manager.CreateEngine("live")
+	router = New(manager, WithAuth(testKeys), WithProductionEngines("live")).Handler()
+	send(t, router, "author-key", http.MethodPost, "/api/engines/live/facts", `{"id":"x","type":"function"}`, http.StatusForbidden)
+	send(t, router, "publish-key", http.MethodPost, "/api/engines/live/facts", `{"id":"x","type":"function"}`, http.StatusCreated)
+	rec := send(t, router, "view-key", http.MethodGet, "/api/engines/live", "", http.StatusOK)
+	assert.Contains(t, rec.Body.String(), `"production":true`)
+}
+
+func TestHMACTokens(t *testing.T) {
+	tokens := NewHMACTokens([]byte("s3cret"))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tokens.clock = func() time.Time { return now }
+	router := New(rulesengine.NewEngineManager(), WithAuth(testKeys, tokens)).Handler()
+
+	token, err := tokens.Issue(Principal{Subject: "ann", Role: RoleViewer}, time.Hour)
+	require.NoError(t, err)
+	get := func(token string, want int) string {
+		req := httptest.NewRequest(http.MethodGet, "/api/engines", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, want, rec.Code, rec.Body.String())
+		return rec.Body.String()
+	}
+	get(token, http.StatusOK)
+
+	// A token signed with another secret, or with edited claims, fails.
+	forged, err := NewHMACTokens([]byte("other")).Issue(Principal{Subject: "ann", Role: RoleAdmin}, time.Hour)
+	require.NoError(t, err)
+	assert.Contains(t, get(forged, http.StatusUnauthorized), "invalid token signature")
+	admin, err := tokens.Issue(Principal{Subject: "ann", Role: RoleAdmin}, time.Hour)
+	require.NoError(t, err)
+	tampered := admin[:len(admin)/2] + token[len(token)/2:]
+	get(tampered, http.StatusUnauthorized)
+
+	now = now.Add(2 * time.Hour)
+	assert.Contains(t, get(token, http.StatusUnauthorized), "token expired")
+
+	_, err = tokens.Issue(Principal{Subject: "x", Role: "owner"}, time.Hour)
+	assert.Error(t, err)
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	keys, err := LoadAPIKeys([]byte(`{"k1":{"sub":"ci","role":"runner"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, APIKeys{"k1": {Subject: "ci", Role: RoleRunner}}, keys)
+
+	_, err = LoadAPIKeys([]byte(`{"k1":{"sub":"ci","role":"root"}}`))
+	assert.ErrorContains(t, err, "unknown role")
+	_, err = LoadAPIKeys([]byte(`{"":{"sub":"ci","role":"admin"}}`))
+	assert.ErrorContains(t, err, "empty")
+	_, err = LoadAPIKeys([]byte(`[`))
+	assert.Error(t, err)
+}
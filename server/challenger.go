@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/Rohan-Muslekar/GavelEngine/api"
+	"github.com/Rohan-Muslekar/GavelEngine/audit"
+	"github.com/gin-gonic/gin"
+)
+
+// Get the statistics and recent divergences of an engine's challenger
+func (s *Server) getChallenger(c *gin.Context) {
+	if _, ok := s.engine(c); !ok {
+		return
+	}
+	shadow := s.manager.GetShadow(c.Param("name"))
+	if shadow == nil {
+		fail(c, http.StatusNotFound, "Challenger not found")
+		return
+	}
+	c.JSON(http.StatusOK, api.ChallengerReport{Stats: shadow.Stats(), Divergences: shadow.Divergences()})
+}
+
+// Pair an engine with a challenger, resetting its statistics
+func (s *Server) setChallenger(c *gin.Context) {
+	if _, ok := s.engine(c); !ok {
+		return
+	}
+	var req api.SetChallengerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	name := c.Param("name")
+	if _, ok := s.manager.GetEngines()[req.Challenger]; !ok {
+		fail(c, http.StatusNotFound, "Challenger engine not found")
+		return
+	}
+	var before interface{}
+	if shadow := s.manager.GetShadow(name); shadow != nil {
+		before = shadow.Challenger
+	}
+	if _, err := s.manager.SetChallenger(name, req.Challenger); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.record(c, audit.ActionSetChallenger, req.Challenger, before, req.Challenger) {
+		return
+	}
+	c.JSON(http.StatusOK, api.Status{Name: req.Challenger, Status: "paired"})
+}
+
+// Stop evaluating an engine's challenger
+func (s *Server) removeChallenger(c *gin.Context) {
+	if _, ok := s.engine(c); !ok {
+		return
+	}
+	name := c.Param("name")
+	shadow := s.manager.GetShadow(name)
+	if shadow == nil || !s.manager.RemoveChallenger(name) {
+		fail(c, http.StatusNotFound, "Challenger not found")
+		return
+	}
+	if !s.record(c, audit.ActionRemoveChallenger, shadow.Challenger, shadow.Challenger, nil) {
+		return
+	}
+	c.JSON(http.StatusOK, api.Status{Status: "deleted"})
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/Rohan-Muslekar/GavelEngine/api"
+	"github.com/Rohan-Muslekar/GavelEngine/audit"
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChallenger(t *testing.T) {
+	trail, err := audit.Open(filepath.Join(t.TempDir(), "audit.log"))
+	require.NoError(t, err)
+	defer trail.Close()
+	manager := rulesengine.NewEngineManager()
+	router := New(manager, WithAudit(trail)).Handler()
+	for _, name := range []string{"e", "next"} {
+		do(t, router, http.MethodPost, "/api/engines", `{"name":"`+name+`"}`, http.StatusCreated)
+		do(t, router, http.MethodPost, "/api/engines/"+name+"/facts", `{"id":"age","type":"function"}`, http.StatusCreated)
+	}
+	do(t, router, http.MethodPost, "/api/engines/e/rules", adultRule, http.StatusCreated)
+	do(t, router, http.MethodPost, "/api/engines/next/rules",
+		`{"name":"adult","conditions":{"fact":"age","operator":"greaterThanInclusive","value":21},"event":{"type":"adult"}}`, http.StatusCreated)
+
+	do(t, router, http.MethodGet, "/api/engines/e/challenger", "", http.StatusNotFound)
+	do(t, router, http.MethodPut, "/api/engines/e/challenger", `{"challenger":"missing"}`, http.StatusNotFound)
+	do(t, router, http.MethodPut, "/api/engines/e/challenger", `{"challenger":"e"}`, http.StatusBadRequest)
+	do(t, router, http.MethodPut, "/api/engines/e/challenger", `{}`, http.StatusBadRequest)
+	out := do(t, router, http.MethodPut, "/api/engines/e/challenger", `{"challenger":"next"}`, http.StatusOK)
+	assert.Equal(t, "paired", out["status"])
+
+	for _, age := range []string{"30", "19", "10"} {
+		out = do(t, router, http.MethodPost, "/api/engines/e/run", `{"age":`+age+`}`, http.StatusOK)
+		if age != "10" {
+			assert.Len(t, out["events"], 1, "the champion's result is returned")
+		}
+	}
+	manager.GetShadow("e").Wait()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/engines/e/challenger", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var report api.ChallengerReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, "next", report.Stats.Challenger)
+	assert.Equal(t, 3, report.Stats.Runs)
+	assert.Equal(t, 2, report.Stats.Matches)
+	assert.Equal(t, 1, report.Stats.Divergences)
+	assert.Equal(t, map[string]int{"adult": 1}, report.Stats.RuleDivergences)
+	require.Len(t, report.Divergences, 1)
+	assert.Equal(t, float64(19), report.Divergences[0].Facts["age"])
+	assert.Equal(t, []rulesengine.OutcomeChange{{Rule: "adult", Old: "passed", New: "failed"}}, report.Divergences[0].Diff.Changed)
+
+	do(t, router, http.MethodDelete, "/api/engines/e/challenger", "", http.StatusOK)
+	do(t, router, http.MethodDelete, "/api/engines/e/challenger", "", http.StatusNotFound)
+	assert.Nil(t, manager.GetShadow("e"))
+
+	entries, err := trail.Query(audit.Query{Engine: "e", Target: "next"})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, audit.ActionSetChallenger, entries[0].Action)
+	assert.Nil(t, entries[0].Before)
+	assert.JSONEq(t, `"next"`, string(entries[0].After))
+	assert.Equal(t, audit.ActionRemoveChallenger, entries[1].Action)
+	assert.JSONEq(t, `"next"`, string(entries[1].Before))
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/Rohan-Muslekar/GavelEngine/api"
+	"github.com/Rohan-Muslekar/GavelEngine/audit"
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+	"github.com/gin-gonic/gin"
+)
+
+// WithExposureLog writes an exposure to sink for every run of an
+// experiment. A run whose exposure cannot be written fails with a 500
+// error.
+func WithExposureLog(sink rulesengine.ExposureSink) Option {
+	return func(s *Server) {
+		s.exposures = sink
+	}
+}
+
+// experiment returns the API form of the named experiment, or nil.
+func (s *Server) experiment(name string) *api.Experiment {
+	exp := s.manager.GetExperiment(name)
+	if exp == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &api.Experiment{Name: exp.Name, Key: exp.Key, Variants: exp.Variants, Sticky: s.sticky[name]}
+}
+
+// List experiments
+func (s *Server) listExperiments(c *gin.Context) {
+	experiments := []api.Experiment{}
+	for name := range s.manager.GetExperiments() {
+		if exp := s.experiment(name); exp != nil {
+			experiments = append(experiments, *exp)
+		}
+	}
+	sort.Slice(experiments, func(i, j int) bool { return experiments[i].Name < experiments[j].Name })
+	c.JSON(http.StatusOK, api.ExperimentList{Experiments: experiments})
+}
+
+// Get an experiment
+func (s *Server) getExperiment(c *gin.Context) {
+	exp := s.experiment(c.Param("experiment"))
+	if exp == nil {
+		fail(c, http.StatusNotFound, "Experiment not found")
+		return
+	}
+	c.JSON(http.StatusOK, exp)
+}
+
+// Create or replace an experiment
+func (s *Server) putExperiment(c *gin.Context) {
+	var req api.Experiment
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Name = c.Param("experiment")
+
+	var opts []rulesengine.ExperimentOption
+	if req.Sticky {
+		opts = append(opts, rulesengine.WithStickyAssignments(s.assignments))
+	}
+	if s.exposures != nil {
+		opts = append(opts, rulesengine.WithExposureLog(s.exposures))
+	}
+	var before interface{}
+	existing := s.experiment(req.Name)
+	if existing != nil {
+		before = existing
+	}
+	exp := rulesengine.Experiment{Name: req.Name, Key: req.Key, Variants: req.Variants}
+	if err := s.manager.AddExperiment(exp, opts...); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	if req.Sticky {
+		s.sticky[req.Name] = true
+	} else {
+		delete(s.sticky, req.Name)
+	}
+	s.mu.Unlock()
+
+	after := s.experiment(req.Name)
+	if !s.record(c, audit.ActionSetExperiment, req.Name, before, after) {
+		return
+	}
+	status := http.StatusCreated
+	if existing != nil {
+		status = http.StatusOK
+	}
+	c.JSON(status, after)
+}
+
+// Remove an experiment
+func (s *Server) removeExperiment(c *gin.Context) {
+	name := c.Param("experiment")
+	before := s.experiment(name)
+	if before == nil {
+		fail(c, http.StatusNotFound, "Experiment not found")
+		return
+	}
+	s.manager.DeleteExperiment(name)
+	s.mu.Lock()
+	delete(s.sticky, name)
+	s.mu.Unlock()
+
+	if !s.record(c, audit.ActionRemoveExperiment, name, before, nil) {
+		return
+	}
+	c.JSON(http.StatusOK, api.Status{Status: "deleted"})
+}
+
+// Run the variant of an experiment assigned to the runtime facts
+func (s *Server) runExperiment(c *gin.Context) {
+	name := c.Param("experiment")
+	if s.manager.GetExperiment(name) == nil {
+		fail(c, http.StatusNotFound, "Experiment not found")
+		return
+	}
+	var runtimeFacts map[string]interface{}
+	if err := c.ShouldBindJSON(&runtimeFacts); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	result, err := s.manager.RunExperiment(name, runtimeFacts)
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, api.RunResponse{Events: result.Events, RuleResults: result.RuleResults, Decision: result.Decision, Order: result.Order, Actions: result.Actions, Assignment: result.Assignment})
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/Rohan-Muslekar/GavelEngine/audit"
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const limitExperiment = `{
+	"key": "userId",
+	"sticky": true,
+	"variants": [
+		{"name": "control", "engine": "e", "weight": 1},
+		{"name": "relaxed", "engine": "relaxed", "weight": 1}
+	]
+}`
+
+func TestExperiments(t *testing.T) {
+	trail, err := audit.Open(filepath.Join(t.TempDir(), "audit.log"))
+	require.NoError(t, err)
+	defer trail.Close()
+	var exposures bytes.Buffer
+	manager := rulesengine.NewEngineManager()
+	router := New(manager, WithAudit(trail), WithExposureLog(rulesengine.NewJSONLinesExposureSink(&exposures))).Handler()
+	for _, name := range []string{"e", "relaxed"} {
+		do(t, router, http.MethodPost, "/api/engines", `{"name":"`+name+`"}`, http.StatusCreated)
+		do(t, router, http.MethodPost, "/api/engines/"+name+"/facts", `{"id":"age","type":"function"}`, http.StatusCreated)
+	}
+	do(t, router, http.MethodPost, "/api/engines/e/rules", adultRule, http.StatusCreated)
+	do(t, router, http.MethodPost, "/api/engines/relaxed/rules",
+		`{"name":"adult","conditions":{"fact":"age","operator":"greaterThanInclusive","value":16},"event":{"type":"adult"}}`, http.StatusCreated)
+
+	do(t, router, http.MethodPost, "/api/experiments/limit/run", `{"userId":"u1","age":17}`, http.StatusNotFound)
+	out := do(t, router, http.MethodPut, "/api/experiments/limit",
+		`{"key":"userId","variants":[{"name":"a","engine":"missing","weight":1}]}`, http.StatusBadRequest)
+	assert.Equal(t, "experiment limit: variant a: engine missing not found", out["error"])
+	do(t, router, http.MethodPut, "/api/experiments/limit", `{"key":"userId"}`, http.StatusBadRequest)
+	out = do(t, router, http.MethodPut, "/api/experiments/limit", limitExperiment, http.StatusCreated)
+	assert.Equal(t, "limit", out["name"])
+	assert.Equal(t, true, out["sticky"])
+	do(t, router, http.MethodPut, "/api/experiments/limit", limitExperiment, http.StatusOK)
+
+	out = do(t, router, http.MethodGet, "/api/experiments", "", http.StatusOK)
+	require.Len(t, out["experiments"], 1)
+	assert.Equal(t, "userId", out["experiments"].([]interface{})[0].(map[string]interface{})["key"])
+
+	variants := map[string]bool{}
+	for _, user := range []string{"u1", "u2", "u3", "u4", "u5", "u6", "u7", "u8"} {
+		out = do(t, router, http.MethodPost, "/api/experiments/limit/run", `{"userId":"`+user+`","age":17}`, http.StatusOK)
+		assignment := out["assignment"].(map[string]interface{})
+		assert.Equal(t, user, assignment["key"])
+		variant := assignment["variant"].(string)
+		variants[variant] = true
+		if variant == "relaxed" {
+			assert.Len(t, out["events"], 1)
+		} else {
+			assert.Empty(t, out["events"])
+		}
+	}
+	assert.Len(t, variants, 2, "users are split between the variants")
+	out = do(t, router, http.MethodPost, "/api/experiments/limit/run", `{"age":17}`, http.StatusInternalServerError)
+	assert.Equal(t, "experiment limit: runtime fact userId is missing", out["error"])
+
+	// Every run is logged as an exposure.
+	dec := json.NewDecoder(&exposures)
+	var logged []rulesengine.Exposure
+	for dec.More() {
+		var e rulesengine.Exposure
+		require.NoError(t, dec.Decode(&e))
+		logged = append(logged, e)
+	}
+	require.Len(t, logged, 8)
+	assert.Equal(t, "u1", logged[0].Key)
+
+	// Sticky assignments survive a change of weights.
+	first := do(t, router, http.MethodPost, "/api/experiments/limit/run", `{"userId":"u1","age":17}`, http.StatusOK)["assignment"].(map[string]interface{})
+	do(t, router, http.MethodPut, "/api/experiments/limit",
+		`{"key":"userId","sticky":true,"variants":[{"name":"control","engine":"e","weight":1},{"name":"relaxed","engine":"relaxed","weight":1000}]}`, http.StatusOK)
+	again := do(t, router, http.MethodPost, "/api/experiments/limit/run", `{"userId":"u1","age":17}`, http.StatusOK)["assignment"].(map[string]interface{})
+	assert.Equal(t, first["variant"], again["variant"])
+	assert.Equal(t, true, again["sticky"])
+
+	do(t, router, http.MethodDelete, "/api/experiments/limit", "", http.StatusOK)
+	do(t, router, http.MethodDelete, "/api/experiments/limit", "", http.StatusNotFound)
+	do(t, router, http.MethodGet, "/api/experiments/limit", "", http.StatusNotFound)
+
+	entries, err := trail.Query(audit.Query{Target: "limit"})
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+	assert.Equal(t, []string{audit.ActionSetExperiment, audit.ActionSetExperiment, audit.ActionSetExperiment, audit.ActionRemoveExperiment},
+		[]string{entries[0].Action, entries[1].Action, entries[2].Action, entries[3].Action})
+	assert.Empty(t, entries[0].Engine)
+	assert.Nil(t, entries[0].Before)
+	assert.NotNil(t, entries[1].Before)
+}
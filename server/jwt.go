@@ -0,0 +1,266 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// JWTVerifier authenticates requests by a JSON Web Token in their
+// Authorization bearer header. The token's "sub" and "role" claims name the
+// principal; "exp" and "nbf" are checked when present.
+//
+// The key decides the accepted algorithm, so a token cannot pick a weaker
+// one: an RSA public key accepts RS256, an ECDSA P-256 key ES256, an Ed25519
+// key EdDSA, and a key file that is not PEM is an HS256 secret.
+type JWTVerifier struct {
+	alg      string
+	key      interface{}
+	issuer   string
+	audience string
+	leeway   time.Duration
+	clock    func() time.Time
+}
+
+// JWTOption configures a JWTVerifier.
+type JWTOption func(*JWTVerifier)
+
+// WithJWTIssuer requires tokens to have the "iss" claim issuer.
+func WithJWTIssuer(issuer string) JWTOption {
+	return func(v *JWTVerifier) {
+		v.issuer = issuer
+	}
+}
+
+// WithJWTAudience requires tokens to list audience in their "aud" claim.
+func WithJWTAudience(audience string) JWTOption {
+	return func(v *JWTVerifier) {
+		v.audience = audience
+	}
+}
+
+// WithJWTLeeway allows for clock skew when checking "exp" and "nbf".
+func WithJWTLeeway(d time.Duration) JWTOption {
+	return func(v *JWTVerifier) {
+		v.leeway = d
+	}
+}
+
+// WithJWTClock sets the clock used to check "exp" and "nbf".
+func WithJWTClock(clock func() time.Time) JWTOption {
+	return func(v *JWTVerifier) {
+		v.clock = clock
+	}
+}
+
+// NewJWTVerifier creates a verifier for the key in keyFile: a PEM public
+// key or certificate, or an HS256 secret.
+func NewJWTVerifier(keyFile string, options ...JWTOption) (*JWTVerifier, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading JWT key: %w", err)
+	}
+	return NewJWTVerifierFromKey(data, options...)
+}
+
+// NewJWTVerifierFromKey is NewJWTVerifier for key file contents.
+func NewJWTVerifierFromKey(data []byte, options ...JWTOption) (*JWTVerifier, error) {
+	v := &JWTVerifier{clock: time.Now}
+	if block, _ := pem.Decode(data); block != nil {
+		pub, err := parsePublicKey(block)
+		if err != nil {
+			return nil, err
+		}
+		if err := v.setKey(pub); err != nil {
+			return nil, err
+		}
+	} else {
+		secret := []byte(strings.TrimSpace(string(data)))
+		if len(secret) < 32 {
+			return nil, fmt.Errorf("HS256 secret must be at least 32 bytes, got %d", len(secret))
+		}
+		v.alg, v.key = "HS256", secret
+	}
+	for _, opt := range options {
+		opt(v)
+	}
+	return v, nil
+}
+
+func parsePublicKey(block *pem.Block) (interface{}, error) {
+	switch block.Type {
+	case "PUBLIC KEY":
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing JWT public key: %w", err)
+		}
+		return pub, nil
+	case "RSA PUBLIC KEY":
+		pub, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing JWT public key: %w", err)
+		}
+		return pub, nil
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing JWT certificate: %w", err)
+		}
+		return cert.PublicKey, nil
+	}
+	return nil, fmt.Errorf("unsupported JWT key PEM block %q", block.Type)
+}
+
+func (v *JWTVerifier) setKey(pub interface{}) error {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		v.alg = "RS256"
+	case *ecdsa.PublicKey:
+		if key.Curve.Params().Name != "P-256" {
+			return fmt.Errorf("unsupported ECDSA curve %s", key.Curve.Params().Name)
+		}
+		v.alg = "ES256"
+	case ed25519.PublicKey:
+		v.alg = "EdDSA"
+	default:
+		return fmt.Errorf("unsupported JWT key type %T", pub)
+	}
+	v.key = pub
+	return nil
+}
+
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Role      Role            `json:"role"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *float64        `json:"exp"`
+	NotBefore *float64        `json:"nbf"`
+}
+
+// Authenticate verifies the bearer JWT of r. Tokens without three parts are
+// left to other authenticators.
+func (v *JWTVerifier) Authenticate(r *http.Request) (*Principal, error) {
+	token, ok := bearerToken(r)
+	if !ok || strings.Count(token, ".") != 2 {
+		return nil, ErrNoCredentials
+	}
+	claims, err := v.verify(token)
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{Subject: claims.Subject, Role: claims.Role}, nil
+}
+
+// verify checks the signature and claims of token.
+func (v *JWTVerifier) verify(token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed JWT")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed JWT header: %w", err)
+	}
+	if header.Alg != v.alg {
+		return nil, fmt.Errorf("JWT algorithm %q not accepted, want %s", header.Alg, v.alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed JWT signature")
+	}
+	if err := v.verifySignature(parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed JWT claims: %w", err)
+	}
+	now := v.clock()
+	if claims.ExpiresAt != nil && !now.Before(unixTime(*claims.ExpiresAt).Add(v.leeway)) {
+		return nil, errors.New("JWT expired")
+	}
+	if claims.NotBefore != nil && now.Add(v.leeway).Before(unixTime(*claims.NotBefore)) {
+		return nil, errors.New("JWT not valid yet")
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return nil, fmt.Errorf("JWT issuer %q not accepted", claims.Issuer)
+	}
+	if v.audience != "" && !hasAudience(claims.Audience, v.audience) {
+		return nil, errors.New("JWT audience not accepted")
+	}
+	return &claims, nil
+}
+
+func (v *JWTVerifier) verifySignature(signed string, sig []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+	valid := false
+	switch key := v.key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		valid = hmac.Equal(sig, mac.Sum(nil))
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	case *ecdsa.PublicKey:
+		// JWS encodes ES256 signatures as the fixed-size r and s.
+		if len(sig) == 64 {
+			r := new(big.Int).SetBytes(sig[:32])
+			s := new(big.Int).SetBytes(sig[32:])
+			valid = ecdsa.Verify(key, digest[:], r, s)
+		}
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, []byte(signed), sig)
+	}
+	if !valid {
+		return errors.New("invalid JWT signature")
+	}
+	return nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func unixTime(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
+
+// hasAudience reports whether the "aud" claim, a string or an array of
+// strings, contains audience.
+func hasAudience(raw json.RawMessage, audience string) bool {
+	var one string
+	if json.Unmarshal(raw, &one) == nil {
+		return one == audience
+	}
+	var many []string
+	if json.Unmarshal(raw, &many) == nil {
+		for _, a := range many {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var jwtNow = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// signJWT signs claims with key, which is an HS256 secret or a private key.
+func signJWT(t *testing.T, alg string, key interface{}, claims map[string]interface{}) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		require.NoError(t, err)
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(signed))
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func publicKeyPEM(t *testing.T, pub interface{}) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func bearerRequest(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api/engines", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func claims(role Role) map[string]interface{} {
+	return map[string]interface{}{"sub": "ann", "role": role, "exp": jwtNow.Add(time.Hour).Unix()}
+}
+
+func TestJWTVerifier_Algorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	tests := []struct {
+		alg     string
+		keyFile []byte
+		signer  interface{}
+	}{
+		{"RS256", publicKeyPEM(t, &rsaKey.PublicKey), rsaKey},
+		{"ES256", publicKeyPEM(t, &ecKey.PublicKey), ecKey},
+		{"EdDSA", publicKeyPEM(t, edPub), edKey},
+		{"HS256", append(secret, '\n'), secret},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "key")
+			require.NoError(t, os.WriteFile(path, tt.keyFile, 0o600))
+			v, err := NewJWTVerifier(path, WithJWTClock(func() time.Time { return jwtNow }))
+			require.NoError(t, err)
+
+			p, err := v.Authenticate(bearerRequest(signJWT(t, tt.alg, tt.signer, claims(RolePublisher))))
+			require.NoError(t, err)
+			assert.Equal(t, &Principal{Subject: "ann", Role: RolePublisher}, p)
+
+			// The key fixes the algorithm.
+			_, err = v.Authenticate(bearerRequest(signJWT(t, "none", secret, claims(RoleAdmin))))
+			assert.ErrorContains(t, err, "not accepted")
+			if tt.alg != "HS256" {
+				// A public key must not be usable as an HMAC secret.
+				_, err = v.Authenticate(bearerRequest(signJWT(t, "HS256", tt.keyFile, claims(RoleAdmin))))
+				assert.ErrorContains(t, err, "not accepted")
+			}
+		})
+	}
+}
+
+func TestJWTVerifier_Claims(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	v, err := NewJWTVerifierFromKey(secret,
+		WithJWTClock(func() time.Time { return jwtNow }),
+		WithJWTIssuer("https://id.example.com"),
+		WithJWTAudience("gavel"),
+		WithJWTLeeway(time.Minute))
+	require.NoError(t, err)
+
+	base := func() map[string]interface{} {
+		c := claims(RoleViewer)
+		c["iss"] = "https://id.example.com"
+		c["aud"] = []string{"other", "gavel"}
+		return c
+	}
+	authenticate := func(mutate func(map[string]interface{})) error {
+		c := base()
+		mutate(c)
+		_, err := v.Authenticate(bearerRequest(signJWT(t, "HS256", secret, c)))
+		return err
+	}
+
+	assert.NoError(t, authenticate(func(map[string]interface{}) {}))
+	assert.NoError(t, authenticate(func(c map[string]interface{}) { c["aud"] = "gavel" }))
+	// Within the leeway.
+	assert.NoError(t, authenticate(func(c map[string]interface{}) { c["exp"] = jwtNow.Add(-30 * time.Second).Unix() }))
+	assert.ErrorContains(t, authenticate(func(c map[string]interface{}) { c["exp"] = jwtNow.Add(-time.Hour).Unix() }), "expired")
+	assert.ErrorContains(t, authenticate(func(c map[string]interface{}) { c["nbf"] = jwtNow.Add(time.Hour).Unix() }), "not valid yet")
+	assert.ErrorContains(t, authenticate(func(c map[string]interface{}) { c["iss"] = "evil" }), "issuer")
+	assert.ErrorContains(t, authenticate(func(c map[string]interface{}) { c["aud"] = "other" }), "audience")
+
+	token := signJWT(t, "HS256", secret, base())
+	_, err = v.Authenticate(bearerRequest(token[:len(token)-2] + "AA"))
+	assert.ErrorContains(t, err, "invalid JWT signature")
+
+	// Tokens that are not JWTs are left to other authenticators.
+	_, err = v.Authenticate(bearerRequest("a.b"))
+	assert.ErrorIs(t, err, ErrNoCredentials)
+	_, err = v.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.ErrorIs(t, err, ErrNoCredentials)
+}
+
+func TestNewJWTVerifier_Keys(t *testing.T) {
+	_, err := NewJWTVerifierFromKey([]byte("short"))
+	assert.ErrorContains(t, err, "at least 32 bytes")
+	_, err = NewJWTVerifier(filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorContains(t, err, "reading JWT key")
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, err = NewJWTVerifierFromKey(publicKeyPEM(t, &p384.PublicKey))
+	assert.ErrorContains(t, err, "unsupported ECDSA curve")
+	_, err = NewJWTVerifierFromKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{1}}))
+	assert.ErrorContains(t, err, "unsupported JWT key PEM block")
+}
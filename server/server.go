@@ -0,0 +1,730 @@
+// Package server implements the HTTP API of the rules engine. The routes and
+// bodies are described by the OpenAPI document in package api.
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/Rohan-Muslekar/GavelEngine/api"
+	"github.com/Rohan-Muslekar/GavelEngine/audit"
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+	"github.com/gin-gonic/gin"
+)
+
+// Server serves the engines of an EngineManager.
+type Server struct {
+	manager *rulesengine.EngineManager
+
+	// Function facts registered over HTTP, by engine and fact ID, since
+	// they cannot be sent as JSON.
+	mu            sync.RWMutex
+	functionFacts map[string]map[string]rulesengine.FactFunc
+	// Engines whose rules only publishers may change.
+	production map[string]bool
+
+	authenticators []Authenticator
+	audit          *audit.Log
+
+	// Experiments with sticky assignments, kept in assignments.
+	sticky      map[string]bool
+	assignments *rulesengine.MemoryAssignmentStore
+	exposures   rulesengine.ExposureSink
+}
+
+// New creates a server for the engines of manager.
+func New(manager *rulesengine.EngineManager, options ...Option) *Server {
+	s := &Server{
+		manager:       manager,
+		functionFacts: make(map[string]map[string]rulesengine.FactFunc),
+		production:    make(map[string]bool),
+		sticky:        make(map[string]bool),
+		assignments:   rulesengine.NewMemoryAssignmentStore(),
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+	return s
+}
+
+// Handler returns the API mounted under /api.
+func (s *Server) Handler() http.Handler {
+	router := gin.New()
+	router.Use(gin.Recovery())
+	s.Register(router.Group("/api"))
+	return router
+}
+
+// Register adds the API routes to group. With WithAuth, every route but
+// the OpenAPI document requires a caller whose role permits it.
+func (s *Server) Register(group *gin.RouterGroup) {
+	group.GET("/openapi.json", s.openAPI)
+
+	group = group.Group("", s.authenticate)
+	read, run, write, admin := s.allow(PermRead), s.allow(PermRun), s.allow(PermWrite), s.allow(PermAdmin)
+
+	// Engine management
+	group.GET("/engines", read, s.listEngines)
+	group.POST("/engines", admin, s.createEngine)
+	group.GET("/engines/:name", read, s.getEngine)
+	group.DELETE("/engines/:name", admin, s.deleteEngine)
+
+	// Fact management
+	group.GET("/engines/:name/facts", read, s.listFacts)
+	group.POST("/engines/:name/facts", write, s.addFact)
+	group.DELETE("/engines/:name/facts/:id", write, s.removeFact)
+
+	// Rule management
+	group.GET("/engines/:name/rules", read, s.listRules)
+	group.POST("/engines/:name/rules", write, s.addRule)
+	group.GET("/engines/:name/rules/:ruleName", read, s.getRule)
+	group.PUT("/engines/:name/rules/:ruleName", write, s.replaceRule)
+	group.PATCH("/engines/:name/rules/:ruleName", write, s.patchRule)
+	group.DELETE("/engines/:name/rules/:ruleName", write, s.removeRule)
+	group.POST("/engines/:name/rules/:ruleName/enable", write, s.enableRule)
+	group.POST("/engines/:name/rules/:ruleName/disable", write, s.disableRule)
+
+	// Named conditions
+	group.GET("/engines/:name/conditions", read, s.listConditions)
+	group.GET("/engines/:name/conditions/:condName", read, s.getCondition)
+	group.PUT("/engines/:name/conditions/:condName", write, s.putCondition)
+	group.DELETE("/engines/:name/conditions/:condName", write, s.removeCondition)
+
+	// Operators
+	group.GET("/engines/:name/operators", read, s.listOperators)
+
+	// Engine execution
+	group.POST("/engines/:name/run", run, s.runEngine)
+	group.POST("/engines/:name/trace", run, s.traceEngine)
+	group.POST("/engines/:name/batch", run, s.batchEngine)
+
+	// Champion-challenger evaluation
+	group.GET("/engines/:name/challenger", read, s.getChallenger)
+	group.PUT("/engines/:name/challenger", write, s.setChallenger)
+	group.DELETE("/engines/:name/challenger", write, s.removeChallenger)
+
+	// Experiments
+	group.GET("/experiments", read, s.listExperiments)
+	group.GET("/experiments/:experiment", read, s.getExperiment)
+	group.PUT("/experiments/:experiment", admin, s.putExperiment)
+	group.DELETE("/experiments/:experiment", admin, s.removeExperiment)
+	group.POST("/experiments/:experiment/run", run, s.runExperiment)
+
+	// Predefined facts
+	group.GET("/predefined-facts", read, s.getPredefinedFacts)
+
+	// Audit log
+	group.GET("/audit", read, s.queryAudit)
+}
+
+func fail(c *gin.Context, status int, message string) {
+	c.JSON(status, api.Error{Error: message})
+}
+
+// engine returns the engine named in the path, or writes a 404.
+func (s *Server) engine(c *gin.Context) (*rulesengine.Engine, bool) {
+	engine := s.manager.GetEngine(c.Param("name"))
+	if engine == nil {
+		fail(c, http.StatusNotFound, "Engine not found")
+		return nil, false
+	}
+	return engine, true
+}
+
+func (s *Server) openAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", api.OpenAPI)
+}
+
+// List all engines
+func (s *Server) listEngines(c *gin.Context) {
+	engines := s.manager.GetEngines()
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	c.JSON(http.StatusOK, api.EngineList{Engines: names})
+}
+
+// Create a new engine
+func (s *Server) createEngine(c *gin.Context) {
+	var req api.CreateEngineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var opts []rulesengine.EngineOption
+	if req.AllowUndefinedFacts {
+		opts = append(opts, rulesengine.WithAllowUndefinedFacts())
+	}
+	if req.AllowUndefinedConditions {
+		opts = append(opts, rulesengine.WithAllowUndefinedConditions())
+	}
+	var before interface{}
+	if existing, ok := s.manager.GetEngines()[req.Name]; ok {
+		before = s.engineState(req.Name, existing)
+	}
+	engine := s.manager.CreateEngine(req.Name, opts...)
+
+	s.mu.Lock()
+	s.functionFacts[req.Name] = make(map[string]rulesengine.FactFunc)
+	if req.Production {
+		s.production[req.Name] = true
+	} else {
+		delete(s.production, req.Name)
+	}
+	s.mu.Unlock()
+
+	if !s.record(c, audit.ActionCreateEngine, "", before, s.engineState(req.Name, engine)) {
+		return
+	}
+	c.JSON(http.StatusCreated, api.Status{Name: req.Name, Status: "created"})
+}
+
+// Get engine details
+func (s *Server) getEngine(c *gin.Context) {
+	if _, ok := s.engine(c); !ok {
+		return
+	}
+	name := c.Param("name")
+	c.JSON(http.StatusOK, api.EngineInfo{Name: name, Production: s.IsProduction(name)})
+}
+
+// Delete an engine
+func (s *Server) deleteEngine(c *gin.Context) {
+	name := c.Param("name")
+	engine, existed := s.manager.GetEngines()[name]
+	var before interface{}
+	if existed {
+		before = s.engineState(name, engine)
+	}
+	s.manager.DeleteEngine(name)
+
+	s.mu.Lock()
+	delete(s.functionFacts, name)
+	delete(s.production, name)
+	s.mu.Unlock()
+
+	if existed && !s.record(c, audit.ActionDeleteEngine, "", before, nil) {
+		return
+	}
+	c.JSON(http.StatusOK, api.Status{Status: "deleted"})
+}
+
+// List facts for an engine
+func (s *Server) listFacts(c *gin.Context) {
+	engine, ok := s.engine(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, api.FactList{Facts: engine.ListFacts()})
+}
+
+// Add a fact to an engine
+func (s *Server) addFact(c *gin.Context) {
+	engine, ok := s.engine(c)
+	if !ok {
+		return
+	}
+	var req api.AddFactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var before interface{}
+	if existing, ok := engine.GetFact(req.ID); ok {
+		before = existing
+	}
+	var factOpts []rulesengine.FactOption
+	if !req.Cache {
+		factOpts = append(factOpts, rulesengine.WithNoCache())
+	}
+
+	switch req.Type {
+	case api.FactTypeConstant:
+		if err := engine.AddFact(req.ID, req.Value, factOpts...); err != nil {
+			fail(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+	case api.FactTypeFunction:
+		// A function fact returns the runtime fact of the same ID, so rules
+		// can refer to facts supplied with each run.
+		id := req.ID
+		factFunc := rulesengine.FactFunc(func(params map[string]interface{}, almanac *rulesengine.Almanac) (interface{}, error) {
+			return almanac.GetRuntimeFacts()[id], nil
+		})
+		if err := engine.AddFact(req.ID, factFunc, factOpts...); err != nil {
+			fail(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		name := c.Param("name")
+		s.mu.Lock()
+		if _, ok := s.functionFacts[name]; !ok {
+			s.functionFacts[name] = make(map[string]rulesengine.FactFunc)
+		}
+		s.functionFacts[name][req.ID] = factFunc
+		s.mu.Unlock()
+	default:
+		fail(c, http.StatusBadRequest, "Invalid fact type. Must be 'constant' or 'function'")
+		return
+	}
+
+	after, _ := engine.GetFact(req.ID)
+	if !s.record(c, audit.ActionAddFact, req.ID, before, after) {
+		return
+	}
+	c.JSON(http.StatusCreated, api.Status{ID: req.ID, Status: "created"})
+}
+
+// Remove a fact from an engine
+func (s *Server) removeFact(c *gin.Context) {
+	engine, ok := s.engine(c)
+	if !ok {
+		return
+	}
+	id := c.Param("id")
+	before, existed := engine.GetFact(id)
+	engine.RemoveFact(id)
+
+	s.mu.Lock()
+	if engineFacts, ok := s.functionFacts[c.Param("name")]; ok {
+		delete(engineFacts, id)
+	}
+	s.mu.Unlock()
+
+	if existed && !s.record(c, audit.ActionRemoveFact, id, before, nil) {
+		return
+	}
+	c.JSON(http.StatusOK, api.Status{Status: "deleted"})
+}
+
+// List rules for an engine
+func (s *Server) listRules(c *gin.Context) {
+	engine, ok := s.engine(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, api.RuleList{Rules: engine.ListRules()})
+}
+
+// Add a rule to an engine
+func (s *Server) addRule(c *gin.Context) {
+	engine, ok := s.engine(c)
+	if !ok {
+		return
+	}
+	var rule api.AddRuleRequest
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if rule.Name == "" {
+		fail(c, http.StatusBadRequest, "rule name is required")
+		return
+	}
+	if errs := engine.ValidateRule(&rule); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, api.Error{Error: "invalid rule", ValidationErrors: errs})
+		return
+	}
+	if err := engine.AddRule(&rule); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.record(c, audit.ActionAddRule, rule.Name, nil, &rule) {
+		return
+	}
+	c.JSON(http.StatusCreated, api.Status{Name: rule.Name, Status: "created"})
+}
+
+// Get rule details
+func (s *Server) getRule(c *gin.Context) {
+	engine, ok := s.engine(c)
+	if !ok {
+		return
+	}
+	rule, ok := engine.GetRule(c.Param("ruleName"))
+	if !ok {
+		fail(c, http.StatusNotFound, "Rule not found")
+		return
+	}
+	c.JSON(http.StatusOK, api.RuleResponse{Rule: rule})
+}
+
+// Replace a rule. The body is a complete rule; its name defaults to the one
+// in the path.
+func (s *Server) replaceRule(c *gin.Context) {
+	engine, ok := s.engine(c)
+	if !ok {
+		return
+	}
+	ruleName := c.Param("ruleName")
+	existing, ok := engine.GetRule(ruleName)
+	if !ok {
+		fail(c, http.StatusNotFound, "Rule not found")
+		return
+	}
+
+	var rule rulesengine.Rule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if rule.Name == "" {
+		rule.Name = ruleName
+	}
+	s.updateRule(c, engine, existing, &rule)
+}
+
+// Patch a rule with a JSON merge patch (RFC 7386) applied to its JSON form.
+func (s *Server) patchRule(c *gin.Context) {
+	engine, ok := s.engine(c)
+	if !ok {
+		return
+	}
+	existing, ok := engine.GetRule(c.Param("ruleName"))
+	if !ok {
+		fail(c, http.StatusNotFound, "Rule not found")
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	var patch interface{}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	current, err := json.Marshal(existing)
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var doc interface{}
+	if err := json.Unmarshal(current, &doc); err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	patched, err := json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var rule rulesengine.Rule
+	if err := json.Unmarshal(patched, &rule); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.updateRule(c, engine, existing, &rule)
+}
+
+// updateRule validates rule against the engine and replaces existing with
+// it. Callbacks cannot be sent as JSON, so those of the existing rule are
+// kept.
+func (s *Server) updateRule(c *gin.Context, engine *rulesengine.Engine, existing, rule *rulesengine.Rule) {
+	rule.OnSuccess = existing.OnSuccess
+	rule.OnFailure = existing.OnFailure
+	rule.SalienceFunc = existing.SalienceFunc
+	if errs := engine.ValidateRule(rule); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, api.Error{Error: "invalid rule", ValidationErrors: errs})
+		return
+	}
+	if err := engine.UpdateRule(existing.Name, rule); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.record(c, audit.ActionUpdateRule, existing.Name, existing, rule) {
+		return
+	}
+	c.JSON(http.StatusOK, api.RuleResponse{Rule: rule})
+}
+
+// mergePatch applies a JSON merge patch to a decoded JSON document: objects
+// are merged recursively, null removes a member and any other value
+// replaces the target.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// Enable a rule
+func (s *Server) enableRule(c *gin.Context) {
+	s.setRuleDisabled(c, false)
+}
+
+// Disable a rule
+func (s *Server) disableRule(c *gin.Context) {
+	s.setRuleDisabled(c, true)
+}
+
+func (s *Server) setRuleDisabled(c *gin.Context, disabled bool) {
+	engine, ok := s.engine(c)
+	if !ok {
+		return
+	}
+	ruleName := c.Param("ruleName")
+	before, _ := engine.GetRule(ruleName)
+	action := audit.ActionEnableRule
+	if disabled {
+		action = audit.ActionDisableRule
+	}
+	var err error
+	if disabled {
+		err = engine.DisableRule(ruleName)
+	} else {
+		err = engine.EnableRule(ruleName)
+	}
+	if err != nil {
+		fail(c, http.StatusNotFound, "Rule not found")
+		return
+	}
+	after, _ := engine.GetRule(ruleName)
+	if !s.record(c, action, ruleName, before, after) {
+		return
+	}
+	c.JSON(http.StatusOK, api.RuleState{Name: ruleName, Disabled: disabled})
+}
+
+// Remove a rule from an engine
+func (s *Server) removeRule(c *gin.Context) {
+	engine, ok := s.engine(c)
+	if !ok {
+		return
+	}
+	ruleName := c.Param("ruleName")
+	before, existed := engine.GetRule(ruleName)
+	engine.RemoveRule(ruleName)
+	if existed && !s.record(c, audit.ActionRemoveRule, ruleName, before, nil) {
+		return
+	}
+	c.JSON(http.StatusOK, api.Status{Status: "deleted"})
+}
+
+// List named conditions for an engine
+func (s *Server) listConditions(c *gin.Context) {
+	engine, ok := s.engine(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, api.ConditionList{Conditions: engine.ListConditions()})
+}
+
+// Get a named condition
+func (s *Server) getCondition(c *gin.Context) {
+	engine, ok := s.engine(c)
+	if !ok {
+		return
+	}
+	condName := c.Param("condName")
+	cond, ok := engine.GetCondition(condName)
+	if !ok {
+		fail(c, http.StatusNotFound, "Condition not found")
+		return
+	}
+	c.JSON(http.StatusOK, api.NamedCondition{Name: condName, Condition: cond})
+}
+
+// Create or replace a named condition
+func (s *Server) putCondition(c *gin.Context) {
+	engine, ok := s.engine(c)
+	if !ok {
+		return
+	}
+	var cond rulesengine.Condition
+	if err := c.ShouldBindJSON(&cond); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Validate as the root condition of a rule, so paths are relative to
+	// the condition.
+	if errs := engine.ValidateRule(rulesengine.NewRule(cond, rulesengine.Event{})); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, api.Error{Error: "invalid condition", ValidationErrors: errs})
+		return
+	}
+
+	condName := c.Param("condName")
+	status := http.StatusOK
+	var before interface{}
+	if existing, ok := engine.GetCondition(condName); ok {
+		before = existing
+	} else {
+		status = http.StatusCreated
+	}
+	engine.SetCondition(condName, cond)
+	if !s.record(c, audit.ActionSetCondition, condName, before, cond) {
+		return
+	}
+	c.JSON(status, api.NamedCondition{Name: condName, Condition: cond})
+}
+
+// Remove a named condition
+func (s *Server) removeCondition(c *gin.Context) {
+	engine, ok := s.engine(c)
+	if !ok {
+		return
+	}
+	condName := c.Param("condName")
+	before, ok := engine.GetCondition(condName)
+	if !ok {
+		fail(c, http.StatusNotFound, "Condition not found")
+		return
+	}
+	engine.RemoveCondition(condName)
+	if !s.record(c, audit.ActionRemoveCondition, condName, before, nil) {
+		return
+	}
+	c.JSON(http.StatusOK, api.Status{Status: "deleted"})
+}
+
+// List operators and operator decorators for an engine
+func (s *Server) listOperators(c *gin.Context) {
+	engine, ok := s.engine(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, engine.ListOperators())
+}
+
+// Run an engine with runtime facts
+func (s *Server) runEngine(c *gin.Context) {
+	s.run(c, false)
+}
+
+// Run an engine with runtime facts and return condition traces
+func (s *Server) traceEngine(c *gin.Context) {
+	s.run(c, true)
+}
+
+func (s *Server) run(c *gin.Context, trace bool) {
+	if _, ok := s.engine(c); !ok {
+		return
+	}
+	var runtimeFacts map[string]interface{}
+	if err := c.ShouldBindJSON(&runtimeFacts); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var opts []rulesengine.RunOption
+	if trace {
+		opts = append(opts, rulesengine.WithTrace())
+	}
+	// The manager also evaluates the engine's challenger, if any.
+	result, err := s.manager.Run(c.Param("name"), runtimeFacts, opts...)
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, api.RunResponse{Events: result.Events, RuleResults: result.RuleResults, Decision: result.Decision, Order: result.Order, Actions: result.Actions})
+}
+
+// maxBatchLine bounds the size of one input line of a batch.
+const maxBatchLine = 10 << 20
+
+// Run an engine over an NDJSON stream of runtime facts, streaming one
+// result line per input and a final summary line
+func (s *Server) batchEngine(c *gin.Context) {
+	engine, ok := s.engine(c)
+	if !ok {
+		return
+	}
+	var opts []rulesengine.BatchOption
+	if v := c.Query("concurrency"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			fail(c, http.StatusBadRequest, "concurrency must be a positive integer")
+			return
+		}
+		opts = append(opts, rulesengine.WithConcurrency(n))
+	}
+	if c.Query("trace") == "true" {
+		opts = append(opts, rulesengine.WithBatchRunOptions(rulesengine.WithTrace()))
+	}
+
+	// Results are written while the request is still being read.
+	_ = http.NewResponseController(c.Writer).EnableFullDuplex()
+	c.Header("Content-Type", api.ContentTypeNDJSON)
+	c.Status(http.StatusOK)
+
+	var readErr error
+	scanner := bufio.NewScanner(c.Request.Body)
+	scanner.Buffer(make([]byte, 64<<10), maxBatchLine)
+	inputs := func(yield func(map[string]interface{}) bool) {
+		for line := 1; scanner.Scan(); line++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			var facts map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &facts); err != nil {
+				readErr = fmt.Errorf("line %d: %v", line, err)
+				return
+			}
+			if !yield(facts) {
+				return
+			}
+		}
+		readErr = scanner.Err()
+	}
+
+	enc := json.NewEncoder(c.Writer)
+	stats, err := engine.RunBatch(c.Request.Context(), inputs, func(item rulesengine.BatchItem) error {
+		line := api.BatchResult{Index: item.Index}
+		if item.Err != nil {
+			line.Error = item.Err.Error()
+		} else {
+			line.Events = item.Result.Events
+			line.RuleResults = item.Result.RuleResults
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}, opts...)
+	if err == nil {
+		err = readErr
+	}
+
+	summary := api.BatchSummary{Stats: stats}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	_ = enc.Encode(summary)
+	c.Writer.Flush()
+}
+
+// Get predefined facts
+func (s *Server) getPredefinedFacts(c *gin.Context) {
+	// Suggested facts for the web UI; they are added as function facts and
+	// supplied in runtime facts.
+	const usage = "Add as function and provide in runtime facts"
+	c.JSON(http.StatusOK, api.PredefinedFactList{Facts: []api.PredefinedFact{
+		{ID: "age", Description: "User's age in years", Usage: usage},
+		{ID: "score", Description: "Numeric score value", Usage: usage},
+		{ID: "userName", Description: "User's name", Usage: usage},
+	}})
+}
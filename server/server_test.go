@@ -0,0 +1,335 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Rohan-Muslekar/GavelEngine/rulesengine"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testServer returns a handler serving one engine named "e" that knows the
+// fact "age", and the manager holding it.
+func testServer(t *testing.T) (http.Handler, *rulesengine.EngineManager) {
+	t.Helper()
+	manager := rulesengine.NewEngineManager()
+	router := New(manager).Handler()
+	do(t, router, http.MethodPost, "/api/engines", `{"name":"e"}`, http.StatusCreated)
+	do(t, router, http.MethodPost, "/api/engines/e/facts", `{"id":"age","type":"function"}`, http.StatusCreated)
+	return router, manager
+}
+
+func do(t *testing.T, router http.Handler, method, path, body string, wantStatus int) map[string]interface{} {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, wantStatus, rec.Code, rec.Body.String())
+	var out map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &out))
+	return out
+}
+
+const adultRule = `{
+	"name": "adult",
+	"priority": 5,
+	"conditions": {"all": [{"fact": "age", "operator": "greaterThanInclusive", "value": 18}]},
+	"event": {"type": "adult"}
+}`
+
+func TestAddRule_RejectsInvalidRule(t *testing.T) {
+	router, _ := testServer(t)
+	out := do(t, router, http.MethodPost, "/api/engines/e/rules",
+		`{"name":"bad","conditions":{"fact":"age"},"event":{"type":"x"}}`, http.StatusBadRequest)
+	assert.Equal(t, "invalid rule", out["error"])
+	assert.Contains(t, out["validationErrors"], map[string]interface{}{"path": "", "message": "leaf condition missing Operator"})
+
+	out = do(t, router, http.MethodPost, "/api/engines/e/rules",
+		`{"conditions":{"fact":"age","operator":"equal","value":1},"event":{"type":"x"}}`, http.StatusBadRequest)
+	assert.Equal(t, "rule name is required", out["error"])
+}
+
+func TestAddRule_KeepsAllFields(t *testing.T) {
+	router, manager := testServer(t)
+	do(t, router, http.MethodPost, "/api/engines/e/rules", `{
+		"name": "adult",
+		"conditions": {"fact": "age", "operator": "greaterThanInclusive", "value": 18},
+		"event": {"type": "adult"},
+		"group": "checks",
+		"disabled": true,
+		"effectiveFrom": "2024-01-01T00:00:00Z",
+		"schedule": {"days": "mon-fri"},
+		"salience": {"fact": "age", "default": 1},
+		"actions": [{"type": "setFact", "params": {"fact": "adult", "value": true}}]
+	}`, http.StatusCreated)
+
+	rule, ok := manager.GetEngine("e").GetRule("adult")
+	require.True(t, ok)
+	assert.Equal(t, "checks", rule.Group)
+	assert.True(t, rule.Disabled)
+	require.NotNil(t, rule.EffectiveFrom)
+	assert.Equal(t, 2024, rule.EffectiveFrom.Year())
+	require.NotNil(t, rule.Schedule)
+	assert.Equal(t, "mon-fri", rule.Schedule.Days)
+	require.NotNil(t, rule.Salience)
+	assert.Equal(t, "age", rule.Salience.Fact)
+	require.Len(t, rule.Actions, 1)
+	assert.Equal(t, "setFact", rule.Actions[0].Type)
+}
+
+func TestRules_Update(t *testing.T) {
+	router, manager := testServer(t)
+	do(t, router, http.MethodPost, "/api/engines/e/rules", adultRule, http.StatusCreated)
+
+	out := do(t, router, http.MethodGet, "/api/engines/e/rules/adult", "", http.StatusOK)
+	assert.Equal(t, "adult", out["rule"].(map[string]interface{})["event"].(map[string]interface{})["type"])
+
+	// PUT replaces the whole rule.
+	do(t, router, http.MethodPut, "/api/engines/e/rules/adult", `{
+		"conditions": {"fact": "age", "operator": "greaterThanInclusive", "value": 21},
+		"event": {"type": "adult-us"}
+	}`, http.StatusOK)
+	rule, ok := manager.GetEngine("e").GetRule("adult")
+	require.True(t, ok)
+	assert.Equal(t, "adult-us", rule.Event.Type)
+	assert.Equal(t, 0, rule.Priority)
+
+	// PATCH merges into the current rule.
+	do(t, router, http.MethodPatch, "/api/engines/e/rules/adult",
+		`{"priority": 7, "event": {"params": {"region": "US"}}}`, http.StatusOK)
+	rule, _ = manager.GetEngine("e").GetRule("adult")
+	assert.Equal(t, 7, rule.Priority)
+	assert.Equal(t, rulesengine.Event{Type: "adult-us", Params: map[string]interface{}{"region": "US"}}, rule.Event)
+	assert.Equal(t, 21.0, rule.Conditions.Value)
+
+	do(t, router, http.MethodPut, "/api/engines/e/rules/missing", adultRule, http.StatusNotFound)
+	do(t, router, http.MethodPatch, "/api/engines/e/rules/missing", `{}`, http.StatusNotFound)
+}
+
+func TestRules_UpdateValidationErrors(t *testing.T) {
+	router, manager := testServer(t)
+	do(t, router, http.MethodPost, "/api/engines/e/rules", adultRule, http.StatusCreated)
+
+	out := do(t, router, http.MethodPatch, "/api/engines/e/rules/adult", `{
+		"conditions": {"all": null, "any": [
+			{"fact": "age", "operator": "greaterThan", "value": 1},
+			{"fact": "height", "operator": "nearly", "value": 2}
+		]}
+	}`, http.StatusBadRequest)
+	assert.Equal(t, "invalid rule", out["error"])
+	assert.ElementsMatch(t, []interface{}{
+		map[string]interface{}{"path": "Any[1]", "message": "undefined fact: height"},
+		map[string]interface{}{"path": "Any[1]", "message": "undefined operator: nearly"},
+	}, out["validationErrors"])
+
+	// The rule is unchanged.
+	rule, _ := manager.GetEngine("e").GetRule("adult")
+	assert.Len(t, rule.Conditions.All, 1)
+}
+
+func TestRules_EnableDisable(t *testing.T) {
+	router, _ := testServer(t)
+	do(t, router, http.MethodPost, "/api/engines/e/rules", adultRule, http.StatusCreated)
+
+	out := do(t, router, http.MethodPost, "/api/engines/e/rules/adult/disable", "", http.StatusOK)
+	assert.Equal(t, true, out["disabled"])
+	out = do(t, router, http.MethodPost, "/api/engines/e/run", `{"age": 30}`, http.StatusOK)
+	assert.Empty(t, out["events"])
+	assert.Equal(t, "disabled", out["ruleResults"].([]interface{})[0].(map[string]interface{})["status"])
+
+	do(t, router, http.MethodPost, "/api/engines/e/rules/adult/enable", "", http.StatusOK)
+	out = do(t, router, http.MethodPost, "/api/engines/e/run", `{"age": 30}`, http.StatusOK)
+	assert.Len(t, out["events"], 1)
+
+	do(t, router, http.MethodPost, "/api/engines/e/rules/missing/enable", "", http.StatusNotFound)
+}
+
+func TestRun_ReturnsDecision(t *testing.T) {
+	router, manager := testServer(t)
+	manager.CreateEngine("d", rulesengine.WithConflictResolver(rulesengine.PriorityWins()))
+	do(t, router, http.MethodPost, "/api/engines/d/facts", `{"id":"age","type":"function"}`, http.StatusCreated)
+	do(t, router, http.MethodPost, "/api/engines/d/rules", adultRule, http.StatusCreated)
+	do(t, router, http.MethodPost, "/api/engines/d/rules",
+		`{"name": "any", "priority": 1, "conditions": {"fact": "age", "operator": "greaterThanInclusive", "value": 0}, "event": {"type": "any"}}`, http.StatusCreated)
+
+	out := do(t, router, http.MethodPost, "/api/engines/d/run", `{"age": 30}`, http.StatusOK)
+	decision := out["decision"].(map[string]interface{})
+	assert.Equal(t, "adult", decision["rule"])
+	assert.Equal(t, "adult", decision["event"].(map[string]interface{})["type"])
+	assert.Equal(t, "any", decision["overruled"].([]interface{})[0].(map[string]interface{})["rule"])
+
+	// Engines without a conflict resolver leave it out.
+	do(t, router, http.MethodPost, "/api/engines/e/rules", adultRule, http.StatusCreated)
+	out = do(t, router, http.MethodPost, "/api/engines/e/run", `{"age": 30}`, http.StatusOK)
+	assert.NotContains(t, out, "decision")
+}
+
+func TestRun_ReturnsOrder(t *testing.T) {
+	router, _ := testServer(t)
+	do(t, router, http.MethodPost, "/api/engines/e/rules", adultRule, http.StatusCreated)
+	out := do(t, router, http.MethodPost, "/api/engines/e/run", `{"age": 30}`, http.StatusOK)
+	assert.NotContains(t, out, "order")
+
+	do(t, router, http.MethodPost, "/api/engines/e/rules",
+		`{"name": "byAge", "salience": {"fact": "age"}, "conditions": {"fact": "age", "operator": "greaterThanInclusive", "value": 0}, "event": {"type": "any"}}`, http.StatusCreated)
+	out = do(t, router, http.MethodPost, "/api/engines/e/run", `{"age": 30}`, http.StatusOK)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "byAge", "salience": 30.0},
+		map[string]interface{}{"name": "adult", "salience": 5.0},
+	}, out["order"])
+}
+
+func TestRun_ReturnsActions(t *testing.T) {
+	router, _ := testServer(t)
+	do(t, router, http.MethodPost, "/api/engines/e/rules", `{
+		"name": "adult",
+		"conditions": {"fact": "age", "operator": "greaterThanInclusive", "value": 18},
+		"event": {"type": "adult"},
+		"actions": [{"type": "setFact", "params": {"fact": "adult", "value": true}}]
+	}`, http.StatusCreated)
+
+	out := do(t, router, http.MethodPost, "/api/engines/e/run", `{"age": 30}`, http.StatusOK)
+	require.Len(t, out["actions"], 1)
+	action := out["actions"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "adult", action["rule"])
+	assert.Equal(t, "setFact", action["type"])
+	assert.Equal(t, rulesengine.ActionStatusOK, action["status"])
+}
+
+func TestConditions_CRUD(t *testing.T) {
+	router, _ := testServer(t)
+
+	do(t, router, http.MethodGet, "/api/engines/e/conditions/adult", "", http.StatusNotFound)
+	do(t, router, http.MethodPut, "/api/engines/e/conditions/adult",
+		`{"fact": "age", "operator": "greaterThanInclusive", "value": 18}`, http.StatusCreated)
+	do(t, router, http.MethodPut, "/api/engines/e/conditions/adult",
+		`{"fact": "age", "operator": "greaterThanInclusive", "value": 21}`, http.StatusOK)
+
+	out := do(t, router, http.MethodGet, "/api/engines/e/conditions/adult", "", http.StatusOK)
+	assert.Equal(t, 21.0, out["condition"].(map[string]interface{})["value"])
+	out = do(t, router, http.MethodGet, "/api/engines/e/conditions", "", http.StatusOK)
+	assert.Len(t, out["conditions"], 1)
+
+	// Rules can refer to it.
+	do(t, router, http.MethodPost, "/api/engines/e/rules",
+		`{"name": "r", "conditions": {"condition": "adult"}, "event": {"type": "ok"}}`, http.StatusCreated)
+	out = do(t, router, http.MethodPost, "/api/engines/e/run", `{"age": 25}`, http.StatusOK)
+	assert.Len(t, out["events"], 1)
+
+	out = do(t, router, http.MethodPut, "/api/engines/e/conditions/broken", `{"all": []}`, http.StatusBadRequest)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"path": "", "message": "All must have at least one child"},
+	}, out["validationErrors"])
+
+	do(t, router, http.MethodDelete, "/api/engines/e/conditions/adult", "", http.StatusOK)
+	do(t, router, http.MethodDelete, "/api/engines/e/conditions/adult", "", http.StatusNotFound)
+}
+
+func TestIntrospection(t *testing.T) {
+	router, _ := testServer(t)
+	do(t, router, http.MethodPost, "/api/engines/e/rules", adultRule, http.StatusCreated)
+
+	out := do(t, router, http.MethodGet, "/api/engines/e/facts", "", http.StatusOK)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"id": "age", "isConstant": false, "cache": false, "priority": 1.0},
+	}, out["facts"])
+	do(t, router, http.MethodGet, "/api/engines/e/rules/missing", "", http.StatusNotFound)
+	out = do(t, router, http.MethodGet, "/api/engines/e/operators", "", http.StatusOK)
+	assert.Contains(t, out["operators"], "equal")
+}
+
+func TestTrace(t *testing.T) {
+	router, _ := testServer(t)
+	do(t, router, http.MethodPost, "/api/engines/e/rules", adultRule, http.StatusCreated)
+
+	out := do(t, router, http.MethodPost, "/api/engines/e/run", `{"age":20}`, http.StatusOK)
+	results := out["ruleResults"].([]interface{})
+	assert.NotContains(t, results[0], "trace")
+
+	out = do(t, router, http.MethodPost, "/api/engines/e/trace", `{"age":12}`, http.StatusOK)
+	assert.Empty(t, out["events"])
+	trace := out["ruleResults"].([]interface{})[0].(map[string]interface{})["trace"].(map[string]interface{})
+	assert.Equal(t, false, trace["result"])
+	leaf := trace["children"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, 12.0, leaf["factValue"])
+}
+
+// The OpenAPI document must describe exactly the routes the server
+// registers.
+func TestOpenAPI_CoversRoutes(t *testing.T) {
+	router := gin.New()
+	New(rulesengine.NewEngineManager()).Register(router.Group("/api"))
+
+	spec := do(t, router, http.MethodGet, "/api/openapi.json", "", http.StatusOK)
+	assert.Equal(t, "3.0.3", spec["openapi"])
+
+	documented := map[string]bool{}
+	for path, item := range spec["paths"].(map[string]interface{}) {
+		for method := range item.(map[string]interface{}) {
+			documented[strings.ToUpper(method)+" /api"+path] = true
+		}
+	}
+	param := regexp.MustCompile(`:(\w+)`)
+	registered := map[string]bool{}
+	for _, route := range router.Routes() {
+		registered[route.Method+" "+param.ReplaceAllString(route.Path, "{$1}")] = true
+	}
+	assert.Equal(t, registered, documented)
+}
+
+func TestBatch(t *testing.T) {
+	router, _ := testServer(t)
+	do(t, router, http.MethodPost, "/api/engines/e/rules", adultRule, http.StatusCreated)
+
+	batch := func(query, body string) []map[string]interface{} {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/engines/e/batch"+query, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+		var lines []map[string]interface{}
+		dec := json.NewDecoder(rec.Body)
+		for dec.More() {
+			var line map[string]interface{}
+			require.NoError(t, dec.Decode(&line))
+			lines = append(lines, line)
+		}
+		return lines
+	}
+
+	lines := batch("?concurrency=1", "{\"age\":20}\n\n{\"age\":10}\n{\"age\":30}\n")
+	require.Len(t, lines, 4)
+	// With one worker, results arrive in input order.
+	assert.Equal(t, 0.0, lines[0]["index"])
+	assert.Len(t, lines[0]["events"], 1)
+	assert.NotContains(t, lines[1], "events")
+	assert.Equal(t, 2.0, lines[2]["index"])
+	assert.Equal(t, map[string]interface{}{
+		"stats": map[string]interface{}{
+			"items": 3.0, "errors": 0.0, "events": 2.0,
+			"fired": map[string]interface{}{"adult": 2.0},
+		},
+	}, lines[3])
+
+	lines = batch("?trace=true", "{\"age\":20}\nnot json\n{\"age\":30}\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0]["ruleResults"].([]interface{})[0], "trace")
+	assert.Contains(t, lines[1]["error"], "line 2")
+	assert.Equal(t, 1.0, lines[1]["stats"].(map[string]interface{})["items"])
+
+	do(t, router, http.MethodPost, "/api/engines/e/batch?concurrency=0", "", http.StatusBadRequest)
+	do(t, router, http.MethodPost, "/api/engines/missing/batch", "", http.StatusNotFound)
+}